@@ -0,0 +1,112 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+/*
+AdaptiveSketch grows with the traffic instead of being sized for the largest
+number of flows up front. Additions go to the current epoch, a sketch which
+is retired once its fill rate crosses a threshold, the next epoch being a
+sketch twice as large. Epochs count disjoint parts of the traffic, so the
+estimate of a flow is the sum of its estimates in all epochs. An
+AdaptiveSketch is safe for concurrent use.
+*/
+type AdaptiveSketch struct {
+	mu      sync.Mutex
+	epochs  []*Sketch
+	maxFill float64
+	opts    []Option
+}
+
+/*
+NewAdaptive returns an AdaptiveSketch whose first epoch is a sketch created
+by New with l, m, w and opts, and which starts a new epoch once the fill rate
+of the current one, in percent as returned by GetFillRate, reaches maxFill.
+opts are applied to every epoch, so they must not include a BitmapBackend.
+*/
+func NewAdaptive(l, m, w uint, maxFill float64, opts ...Option) (*AdaptiveSketch, error) {
+	if maxFill <= 0 || maxFill >= 100 {
+		return nil, fmt.Errorf("Expected 0 < maxFill < 100, got %v", maxFill)
+	}
+	first, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &AdaptiveSketch{epochs: []*Sketch{first}, maxFill: maxFill, opts: opts}, nil
+}
+
+func (a *AdaptiveSketch) current() *Sketch {
+	return a.epochs[len(a.epochs)-1]
+}
+
+// grow starts a new epoch if the current one is full.
+func (a *AdaptiveSketch) grow() {
+	cur := a.current()
+	if cur.getP()*100 < a.maxFill {
+		return
+	}
+	next, err := newSibling(cur, 2*cur.l, a.opts)
+	if err != nil {
+		// l can't be doubled anymore, keep filling the current epoch.
+		return
+	}
+	a.epochs = append(a.epochs, next)
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (a *AdaptiveSketch) Increment(flow []byte) {
+	a.Add(flow, 1)
+}
+
+/*
+Add increments the count of the flow by weight, see Sketch.Add.
+*/
+func (a *AdaptiveSketch) Add(flow []byte, weight uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.current().Add(flow, weight)
+	a.grow()
+}
+
+/*
+GetEstimate returns the estimated count of a given flow, summed over all
+epochs. The signed estimates of the epochs are summed before clamping the
+sum at 0, so that the noise of the epochs a flow is absent from cancels out
+instead of adding up.
+*/
+func (a *AdaptiveSketch) GetEstimate(flow []byte) float64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	est := 0.0
+	for _, epoch := range a.epochs {
+		est += epoch.signedEstimate(flow)
+	}
+	return math.Max(est, 0)
+}
+
+/*
+N returns the number of additions over all epochs.
+*/
+func (a *AdaptiveSketch) N() uint64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := uint64(0)
+	for _, epoch := range a.epochs {
+		n += epoch.N()
+	}
+	return n
+}
+
+/*
+Epochs returns the number of epochs started so far.
+*/
+func (a *AdaptiveSketch) Epochs() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return len(a.epochs)
+}
@@ -0,0 +1,60 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestAdaptiveSketch(t *testing.T) {
+	a, err := NewAdaptive(1<<16, 64, 32, 30)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAdaptive(1<<16, 64, 32, 100); err == nil {
+		t.Error("Expected error for maxFill == 100, got nil")
+	}
+
+	for i := 0; i < 200000; i++ {
+		a.Increment([]byte(fmt.Sprint(i % 20000)))
+		if i%10 == 0 {
+			a.Increment([]byte("heavy"))
+		}
+	}
+	if a.Epochs() < 2 {
+		t.Fatal("Expected the sketch to grow, got", a.Epochs(), "epoch")
+	}
+	for i, epoch := range a.epochs {
+		if epoch.l != 1<<16<<i {
+			t.Errorf("Expected epoch %d to have l = %d, got %d", i, 1<<16<<i, epoch.l)
+		}
+	}
+	if a.N() != 220000 {
+		t.Error("Expected n = 220000, got", a.N())
+	}
+	if est := a.GetEstimate([]byte("heavy")); math.Abs(est-20000)/20000 > 0.2 {
+		t.Errorf("Expected an estimate close to 20000, got %f", est)
+	}
+}
+
+func TestAdaptiveSketchSmallFlows(t *testing.T) {
+	a, _ := NewAdaptive(1<<12, 64, 32, 30, WithSeed(3))
+	for i := 0; i < 200000; i++ {
+		a.Increment([]byte(fmt.Sprint(i % 5000)))
+	}
+	if a.Epochs() < 4 {
+		t.Fatal("Expected the sketch to grow, got", a.Epochs(), "epochs")
+	}
+
+	seen, absent := 0.0, 0.0
+	for i := 0; i < 500; i++ {
+		seen += a.GetEstimate([]byte(fmt.Sprint(i)))
+		absent += a.GetEstimate([]byte(fmt.Sprint("absent-", i)))
+	}
+	if mean := seen / 500; math.Abs(mean-40)/40 > 0.25 {
+		t.Errorf("Expected flows of 40 to average close to 40, got %f", mean)
+	}
+	if mean := absent / 500; mean > 20 {
+		t.Errorf("Expected absent flows to average below 20, got %f", mean)
+	}
+}
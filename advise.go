@@ -0,0 +1,52 @@
+package pmc
+
+import "math"
+
+// advisedFill is the fill rate Advise sizes sketches for, low enough that
+// the noise of the other flows stays small next to the error of the
+// estimator.
+const advisedFill = 0.3
+
+/*
+Advise returns the parameters of a sketch for expectedFlows flows sharing
+expectedTotalPackets additions, whose estimates have a relative standard
+error of about targetError, along the dimensioning guidance of the PMC
+paper:
+
+  - m follows from the 0.78/sqrt(m) standard error of the estimator, as for
+    NewWithAccuracy;
+  - w is large enough for the leading runs of the rows to count all the
+    additions as a single flow, as the phi correction of the estimator does,
+    with a few columns to spare;
+  - l keeps the fill rate at 30% once every flow received its share of the
+    additions, counting the distinct bits each flow sets in its virtual
+    matrix.
+
+targetError is clamped to [0.01, 0.5], and expectedFlows and
+expectedTotalPackets are taken to be at least 1.
+*/
+func Advise(expectedFlows uint, expectedTotalPackets uint64, targetError float64) (l, m, w uint) {
+	targetError = math.Max(0.01, math.Min(0.5, targetError))
+	flows := math.Max(1, float64(expectedFlows))
+	packets := math.Max(1, float64(expectedTotalPackets))
+
+	m = uint(math.Ceil(math.Pow(0.78/targetError, 2)))
+	w = uint(math.Ceil(math.Log2(packets))) + 4
+	if w > 64 {
+		w = 64
+	}
+
+	// Expected number of distinct bits set by flows of the mean size: column
+	// j of a row is hit by an addition with probability 2^-(j+1)/m.
+	size := packets / flows
+	bits := 0.0
+	for j := uint(0); j < w; j++ {
+		bits -= float64(m) * math.Expm1(-size*math.Ldexp(1, -int(j+1))/float64(m))
+	}
+	total := flows * bits
+	l = uint(math.Ceil(-total/math.Log1p(-advisedFill)/64)) * 64
+	if l == 0 {
+		l = 64
+	}
+	return l, m, w
+}
@@ -0,0 +1,43 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestAdvise(t *testing.T) {
+	l, m, w := Advise(10000, 10000000, 0.05)
+	if m != 244 {
+		t.Error("Expected m == 244 for 5% error, got", m)
+	}
+	if w < 24 || w > 32 {
+		t.Error("Expected w to count 10^7 additions, got", w)
+	}
+	if l%64 != 0 {
+		t.Error("Expected l to be a multiple of 64, got", l)
+	}
+
+	l, m, w = Advise(1000, 1000000, 0.1)
+	s, err := New(l, m, w, WithDeterministic(DefaultSeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		s.Add([]byte(fmt.Sprint(i)), 1000)
+	}
+	if fill := s.GetFillRate(); fill < 25 || fill > 35 {
+		t.Error("Expected a fill rate of about 30%, got", fill)
+	}
+	sum := 0.0
+	for i := 0; i < 1000; i++ {
+		sum += math.Abs(s.GetEstimate([]byte(fmt.Sprint(i)))-1000) / 1000
+	}
+	if mean := sum / 1000; mean > 0.2 {
+		t.Error("Expected a mean relative error below 20%, got", mean)
+	}
+
+	if l, m, w := Advise(0, 0, 0); l == 0 || m == 0 || w == 0 {
+		t.Errorf("Expected valid parameters for degenerate inputs, got %d, %d, %d", l, m, w)
+	}
+}
@@ -0,0 +1,97 @@
+package pmc
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/lazybeaver/xorshift"
+)
+
+/*
+Alarm calls a function when the estimated count of a flow crosses a
+threshold. Estimates are costly compared to additions, so each addition is
+only followed by an estimate of its flow with a probability of 1/every per
+unit of weight. Sampling at random rather than every so many additions
+checks every flow about as often as it's added to, however the additions of
+the flows interleave, so a flow is caught about every additions after
+crossing. The samples are drawn from a generator seeded from that of the
+sketch, so that they are reproducible with WithSeed. The function is called once per crossing: a flow fires again only
+after its estimate has been seen below the threshold, e.g. after a Reset of
+the sketch.
+
+To receive alerts on a channel, pass a function sending to it.
+*/
+type Alarm struct {
+	sketch    *Sketch
+	threshold float64
+	every     uint64
+	fn        func(flow []byte, estimate float64)
+	fired     map[string]struct{}
+	rnd       xorshift.XorShift
+}
+
+/*
+NewAlarm returns an Alarm calling fn for the flows of sketch whose estimate
+reaches threshold, estimated on one out of every additions; an every of 1
+estimates flows on all additions.
+*/
+func NewAlarm(sketch *Sketch, threshold float64, every uint64,
+	fn func(flow []byte, estimate float64)) (*Alarm, error) {
+	if threshold <= 0 {
+		return nil, errors.New("Expected threshold > 0")
+	}
+	if every == 0 {
+		return nil, errors.New("Expected every > 0, got 0")
+	}
+	if fn == nil {
+		return nil, errors.New("Expected a non nil function")
+	}
+	sketch.mu.Lock()
+	rnd := sketch.deriveRand()
+	sketch.mu.Unlock()
+	return &Alarm{sketch: sketch, threshold: threshold, every: every, fn: fn,
+		fired: make(map[string]struct{}), rnd: rnd}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (a *Alarm) Increment(flow []byte) {
+	a.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (a *Alarm) Add(flow []byte, weight uint64) {
+	a.sketch.Add(flow, weight)
+	// The high word of r * every is uniform in [0, every) up to a bias of
+	// every/2^64.
+	if hi, _ := bits.Mul64(a.rnd.Next(), a.every); hi < weight {
+		a.Check(flow)
+	}
+}
+
+/*
+Check evaluates the estimate of the flow against the threshold right away,
+and returns it.
+*/
+func (a *Alarm) Check(flow []byte) float64 {
+	est := a.sketch.GetEstimate(flow)
+	_, fired := a.fired[string(flow)]
+	switch {
+	case est >= a.threshold && !fired:
+		a.fired[string(flow)] = struct{}{}
+		a.fn(flow, est)
+	case est < a.threshold && fired:
+		delete(a.fired, string(flow))
+	}
+	return est
+}
+
+/*
+Sketch returns the underlying sketch.
+*/
+func (a *Alarm) Sketch() *Sketch {
+	return a.sketch
+}
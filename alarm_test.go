@@ -0,0 +1,75 @@
+package pmc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestAlarm(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	alerts := make(chan string, 10)
+	a, err := NewAlarm(s, 10000, 100, func(flow []byte, est float64) {
+		alerts <- string(flow)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAlarm(s, 10000, 0, func([]byte, float64) {}); err == nil {
+		t.Error("Expected error for every == 0, got nil")
+	}
+
+	for i := 0; i < 1000; i++ {
+		a.Increment([]byte("mouse"))
+	}
+	for i := 0; i < 20000; i++ {
+		a.Increment([]byte("elephant"))
+	}
+	if len(alerts) != 1 {
+		t.Fatal("Expected a single alert, got", len(alerts))
+	}
+	if flow := <-alerts; flow != "elephant" {
+		t.Error("Expected an alert for the elephant flow, got", flow)
+	}
+
+	s.Reset()
+	if est := a.Check([]byte("elephant")); est != 0 {
+		t.Error("Expected estimate 0 after Reset, got", est)
+	}
+	a.Add([]byte("elephant"), 20000)
+	a.Check([]byte("elephant"))
+	if len(alerts) != 1 {
+		t.Error("Expected the elephant flow to fire again after a Reset")
+	}
+}
+
+func TestAlarmInterleaved(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	var fired []string
+	a, _ := NewAlarm(s, 5000, 2, func(flow []byte, est float64) {
+		fired = append(fired, string(flow))
+	})
+	// A counter of all additions would only ever check the mice, one of
+	// which follows each addition of the elephant flow.
+	for i := 0; i < 10000; i++ {
+		a.Increment([]byte("elephant"))
+		a.Increment([]byte(fmt.Sprintf("mouse-%d", i)))
+	}
+	if len(fired) != 1 || fired[0] != "elephant" {
+		t.Error("Expected the elephant flow to fire, got", fired)
+	}
+}
+
+func TestAlarmSeeded(t *testing.T) {
+	checks := func() int {
+		n := 0
+		s, _ := New(1<<16, 64, 32, WithSeed(5), WithHooks(Hooks{OnEstimate: func([]byte, float64) { n++ }}))
+		a, _ := NewAlarm(s, 1e9, 50, func([]byte, float64) {})
+		for i := 0; i < 5000; i++ {
+			a.Increment([]byte(fmt.Sprint(i % 100)))
+		}
+		return n
+	}
+	if a, b := checks(), checks(); a != b || a == 0 {
+		t.Errorf("Expected seeded alarms to check the same additions, got %d and %d checks", a, b)
+	}
+}
@@ -0,0 +1,172 @@
+package pmc
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+/*
+BitmapBackend stores the bits of a sketch in place of the default dense
+bitmap of l bits, e.g. a compressed bitmap for very large and sparse
+sketches. Indices are in [0, l). A BitmapBackend needs not be safe for
+concurrent use, sketches serialize their accesses to it.
+*/
+type BitmapBackend interface {
+	// Test returns whether bit i is set.
+	Test(i uint) bool
+	// Set sets bit i and returns whether it was clear before.
+	Set(i uint) bool
+	// Clear clears bit i and returns whether it was set before.
+	Clear(i uint) bool
+	// Count returns the number of set bits.
+	Count() uint
+	// ForEach calls fn with the set bits in increasing order, until fn
+	// returns false.
+	ForEach(fn func(i uint) bool)
+	// Clone returns a deep copy of the backend.
+	Clone() BitmapBackend
+	// Reset clears all bits.
+	Reset()
+}
+
+/*
+SharedBackend is a BitmapBackend shared by several sketches, e.g. stateless
+ingest processes feeding one logical sketch, which also keeps their combined
+number of additions. Sketches with a SharedBackend read their number of
+additions and of set bits from it, and ignore the results of Set and Clear.
+*/
+type SharedBackend interface {
+	BitmapBackend
+	// AddN adds delta to the number of additions.
+	AddN(delta uint64)
+	// N returns the number of additions.
+	N() uint64
+}
+
+// addN accounts for delta additions.
+func (sketch *Sketch) addN(delta uint64) {
+	atomic.AddUint64(&sketch.n, delta)
+	if sketch.remote != nil {
+		sketch.remote.AddN(delta)
+	}
+}
+
+// test returns whether the bit at pos is set.
+func (sketch *Sketch) test(pos uint) bool {
+	if sketch.backend != nil {
+		return sketch.backend.Test(pos)
+	}
+	return sketch.bitmap.test(pos)
+}
+
+// forEachChunk calls fn with the words of the bitmap, in chunks of at most
+// chunkWords words. The chunks are only valid until fn returns.
+func (sketch *Sketch) forEachChunk(fn func(words []uint64) error) error {
+	if sketch.backend == nil {
+		words := sketch.bitmap
+		for len(words) > 0 {
+			k := len(words)
+			if k > chunkWords {
+				k = chunkWords
+			}
+			if err := fn(words[:k]); err != nil {
+				return err
+			}
+			words = words[k:]
+		}
+		return nil
+	}
+
+	// Sparse backends are expanded a chunk at a time.
+	nwords := int((sketch.l + 63) / 64)
+	chunk := make([]uint64, chunkWords)
+	base := 0
+	emit := func() error {
+		k := nwords - base
+		if k > chunkWords {
+			k = chunkWords
+		}
+		err := fn(chunk[:k])
+		for i := range chunk {
+			chunk[i] = 0
+		}
+		base += chunkWords
+		return err
+	}
+	var err error
+	sketch.backend.ForEach(func(i uint) bool {
+		word := int(i / 64)
+		for word >= base+chunkWords {
+			if err = emit(); err != nil {
+				return false
+			}
+		}
+		chunk[word-base] |= 1 << (i % 64)
+		return true
+	})
+	for err == nil && base < nwords {
+		err = emit()
+	}
+	return err
+}
+
+// setBitmap replaces the bits of the sketch with those of bitmap.
+func (sketch *Sketch) setBitmap(bitmap bitArray) {
+	sketch.shared = false
+	if sketch.mapping != nil {
+		copy(sketch.bitmap, bitmap)
+		sketch.ones = uint64(bitmap.count())
+		return
+	}
+	if sketch.backend == nil {
+		sketch.bitmap = bitmap
+		sketch.ones = uint64(bitmap.count())
+		return
+	}
+	sketch.backend.Reset()
+	for k, word := range bitmap {
+		for rest := word; rest != 0; rest &= rest - 1 {
+			sketch.backend.Set(uint(k)*64 + uint(bits.TrailingZeros64(rest)))
+		}
+	}
+	sketch.ones = uint64(sketch.backend.Count())
+}
+
+// union sets the bits set in other, whatever their backends. The number of
+// set bits of dense bitmaps is left to be recounted by the caller.
+func (sketch *Sketch) union(other *Sketch) {
+	if sketch.backend == nil && other.backend == nil {
+		sketch.own()
+		sketch.bitmap.union(other.bitmap)
+		return
+	}
+	other.forEachSet(func(pos uint) bool {
+		sketch.setBit(pos)
+		return true
+	})
+}
+
+// recount recomputes the number of set bits.
+func (sketch *Sketch) recount() {
+	if sketch.backend != nil {
+		sketch.ones = uint64(sketch.backend.Count())
+		return
+	}
+	sketch.ones = uint64(sketch.bitmap.count())
+}
+
+// forEachSet calls fn with the set bits in increasing order, until fn
+// returns false.
+func (sketch *Sketch) forEachSet(fn func(pos uint) bool) {
+	if sketch.backend != nil {
+		sketch.backend.ForEach(fn)
+		return
+	}
+	for k, word := range sketch.bitmap {
+		for rest := word; rest != 0; rest &= rest - 1 {
+			if !fn(uint(k)*64 + uint(bits.TrailingZeros64(rest))) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package pmc
+
+import (
+	"sort"
+	"testing"
+)
+
+// mapBackend is a BitmapBackend storing the set bits in a map.
+type mapBackend map[uint]struct{}
+
+func (b mapBackend) Test(i uint) bool { _, ok := b[i]; return ok }
+
+func (b mapBackend) Set(i uint) bool {
+	if b.Test(i) {
+		return false
+	}
+	b[i] = struct{}{}
+	return true
+}
+
+func (b mapBackend) Clear(i uint) bool {
+	if !b.Test(i) {
+		return false
+	}
+	delete(b, i)
+	return true
+}
+
+func (b mapBackend) Count() uint { return uint(len(b)) }
+
+func (b mapBackend) ForEach(fn func(i uint) bool) {
+	keys := make([]uint, 0, len(b))
+	for i := range b {
+		keys = append(keys, i)
+	}
+	sort.Slice(keys, func(x, y int) bool { return keys[x] < keys[y] })
+	for _, i := range keys {
+		if !fn(i) {
+			return
+		}
+	}
+}
+
+func (b mapBackend) Clone() BitmapBackend {
+	c := mapBackend{}
+	for i := range b {
+		c[i] = struct{}{}
+	}
+	return c
+}
+
+func (b mapBackend) Reset() {
+	for i := range b {
+		delete(b, i)
+	}
+}
+
+func TestBitmapBackend(t *testing.T) {
+	// More than a chunk of words, whose last one is partial.
+	l := uint(64*chunkWords*2 + 100)
+	dense, _ := New(l, 64, 32, WithSeed(DefaultSeed))
+	sparse, err := New(l, 64, 32, WithSeed(DefaultSeed), WithBitmapBackend(mapBackend{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sparse.bitmap != nil {
+		t.Error("Expected no dense bitmap to be allocated")
+	}
+	for _, s := range []*Sketch{dense, sparse} {
+		s.Add([]byte("flow"), 3000)
+		s.setBit(l - 1)
+	}
+	if !dense.bitmap.equal(bitArray(sparse.Bits())) || dense.ones != sparse.ones {
+		t.Fatal("Expected the same bits whatever the backend")
+	}
+	if dense.GetEstimate([]byte("flow")) != sparse.GetEstimate([]byte("flow")) {
+		t.Error("Expected the same estimate whatever the backend")
+	}
+
+	var words []uint64
+	sparse.forEachChunk(func(chunk []uint64) error {
+		words = append(words, chunk...)
+		return nil
+	})
+	if !dense.bitmap.equal(words) {
+		t.Error("Expected the chunks of the backend to hold its bits")
+	}
+
+	if _, err := New(l, 64, 32, WithBitmapBackend(mapBackend{}), WithLockFree()); err == nil {
+		t.Error("Expected error for a lock-free sketch with a backend, got nil")
+	}
+	if _, err := New(l, 64, 32, WithBitmapBackend(nil)); err == nil {
+		t.Error("Expected error for a nil backend, got nil")
+	}
+}
@@ -0,0 +1,133 @@
+package pmc
+
+import (
+	"math"
+	"runtime"
+	"sync"
+)
+
+/*
+IncrementBatch increments the count of each of the flows by 1
+*/
+func (sketch *Sketch) IncrementBatch(flows [][]byte) {
+	sketch.mu.Lock()
+	for _, flow := range flows {
+		sketch.increment(flow)
+	}
+	sketch.mu.Unlock()
+	if sketch.hooks != nil {
+		for _, flow := range flows {
+			sketch.incremented(flow, 1)
+		}
+	}
+}
+
+/*
+IncrementN increments the count of the flow by n. Small values of n are
+applied as n calls to Increment. Larger values, where looping would cost more
+than visiting every position of the flow's virtual matrix, are simulated in
+aggregate: each position is set with the probability that at least one of n
+additions would have set it.
+*/
+func (sketch *Sketch) IncrementN(flow []byte, n uint64) {
+	sketch.mu.Lock()
+	sketch.incrementN(flow, n)
+	sketch.mu.Unlock()
+	if sketch.hooks != nil {
+		sketch.incremented(flow, n)
+	}
+}
+
+func (sketch *Sketch) incrementN(flow []byte, n uint64) {
+	if n <= uint64(sketch.m)*uint64(sketch.w) {
+		for k := uint64(0); k < n; k++ {
+			sketch.increment(flow)
+		}
+		return
+	}
+
+	flow, buf := sketch.key(flow)
+	defer releaseKey(buf)
+	sketch.addN(n)
+	if sketch.hll != nil {
+		sketch.hll.add(sketch.flowHash(flow))
+	}
+	for j := uint(0); j < sketch.w; j++ {
+		// Probability of a single addition setting a given bit of column j.
+		pj := sketch.columnProb(j) / float64(sketch.m) * sketch.keepProb(j)
+		if pj <= 0 {
+			continue
+		}
+		q := -math.Expm1(float64(n) * math.Log1p(-pj))
+		for i := uint(0); i < sketch.m; i++ {
+			if sketch.float() < q {
+				sketch.setBit(sketch.getPos(flow, i, j))
+			}
+		}
+	}
+}
+
+/*
+Add accounts weight units, e.g. the bytes of a packet, to the flow. It
+performs the virtual additions of IncrementN, so that the estimate of the
+flow is its total volume rather than its number of packets, as described for
+traffic volume accounting in the PMC paper.
+*/
+func (sketch *Sketch) Add(flow []byte, weight uint64) {
+	sketch.IncrementN(flow, weight)
+}
+
+/*
+GetEstimates returns the estimated counts of the given flows. The fill rate
+and the phi correction are computed once for all flows, and the flows are
+estimated in parallel, except on a BitmapBackend, which needs not be safe for
+concurrent use.
+*/
+func (sketch *Sketch) GetEstimates(flows [][]byte) []float64 {
+	sketch.mu.Lock()
+	estimates := sketch.getEstimates(flows)
+	sketch.mu.Unlock()
+	if sketch.hooks != nil {
+		for i, flow := range flows {
+			sketch.estimated(flow, estimates[i])
+		}
+	}
+	return estimates
+}
+
+func (sketch *Sketch) getEstimates(flows [][]byte) []float64 {
+	p := sketch.getP()
+	var (
+		once sync.Once
+		phi  float64
+	)
+	getPhi := func() float64 {
+		once.Do(func() { phi = sketch.phi(float64(sketch.N()), p) })
+		return phi
+	}
+
+	estimates := make([]float64, len(flows))
+	workers := runtime.GOMAXPROCS(0)
+	if sketch.backend != nil {
+		workers = 1
+	}
+	chunk := (len(flows) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for start := 0; start < len(flows); start += chunk {
+		end := start + chunk
+		if end > len(flows) {
+			end = len(flows)
+		}
+		wg.Add(1)
+		go func(start, end int) {
+			defer wg.Done()
+			for i := start; i < end; i++ {
+				key, buf := sketch.key(flows[i])
+				estimates[i], _ = sketch.estimate(sketch.flowPositions(key), p, getPhi)
+				releaseKey(buf)
+			}
+		}(start, end)
+	}
+	wg.Wait()
+	return estimates
+}
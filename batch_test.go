@@ -0,0 +1,111 @@
+package pmc
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestIncrementBatch(t *testing.T) {
+	a, _ := New(1<<16, 16, 16, WithSeed(7))
+	b, _ := New(1<<16, 16, 16, WithSeed(7))
+	flows := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+
+	a.IncrementBatch(flows)
+	for _, flow := range flows {
+		b.Increment(flow)
+	}
+	if a.n != 3 || !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected IncrementBatch to match calling Increment per flow")
+	}
+}
+
+func TestIncrementN(t *testing.T) {
+	a, _ := New(8000000, 256, 64)
+	b, _ := New(8000000, 256, 64)
+	for i := 0; i < 100000; i++ {
+		a.Increment([]byte("flow"))
+	}
+	b.IncrementN([]byte("flow"), 100000)
+
+	if b.n != 100000 {
+		t.Error("Expected n == 100000, got", b.n)
+	}
+	ea, eb := a.GetEstimate([]byte("flow")), b.GetEstimate([]byte("flow"))
+	if fErr := math.Abs(100 * (1 - eb/ea)); fErr > 10 {
+		t.Errorf("Expected IncrementN estimate %f within 10%% of %f, got %f%%", eb, ea, fErr)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	s, _ := New(8000000, 256, 64)
+	for i := 0; i < 1000; i++ {
+		s.Add([]byte("flow"), 1500)
+	}
+	s.Add([]byte("flow"), 0)
+
+	if s.n != 1500000 {
+		t.Error("Expected n == 1500000, got", s.n)
+	}
+	est := s.GetEstimate([]byte("flow"))
+	if fErr := math.Abs(100 * (1 - est/1500000)); fErr > 15 {
+		t.Errorf("Expected error <= 15%%, got %f", fErr)
+	}
+}
+
+func TestGetEstimates(t *testing.T) {
+	s, _ := New(1<<20, 64, 32)
+	flows := make([][]byte, 100)
+	for i := range flows {
+		flows[i] = []byte("flow-" + strconv.Itoa(i))
+		s.IncrementN(flows[i], uint64(100*(i+1)))
+	}
+
+	estimates := s.GetEstimates(flows)
+	for i, flow := range flows {
+		if e := s.GetEstimate(flow); estimates[i] != e {
+			t.Errorf("Expected estimate %f for flow %d, got %f", e, i, estimates[i])
+		}
+	}
+	if len(s.GetEstimates(nil)) != 0 {
+		t.Error("Expected no estimates for no flows")
+	}
+}
+
+// probedBackend is a mapBackend counting its tests, which makes Test a write
+// that concurrent callers race on.
+type probedBackend struct {
+	mapBackend
+	tests int
+}
+
+func (b *probedBackend) Test(i uint) bool {
+	b.tests++
+	return b.mapBackend.Test(i)
+}
+
+func TestGetEstimatesBackend(t *testing.T) {
+	// The race detector only sees concurrent calls with several Ps.
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(4))
+	b := &probedBackend{mapBackend: mapBackend{}}
+	s, _ := New(1<<20, 64, 32, WithBitmapBackend(b))
+	flows := make([][]byte, 100)
+	for i := range flows {
+		flows[i] = []byte("flow-" + strconv.Itoa(i))
+		s.IncrementN(flows[i], uint64(100*(i+1)))
+	}
+
+	b.tests = 0
+	estimates := s.GetEstimates(flows)
+	tests := b.tests
+	b.tests = 0
+	for i, flow := range flows {
+		if e := s.GetEstimate(flow); estimates[i] != e {
+			t.Errorf("Expected estimate %f for flow %d, got %f", e, i, estimates[i])
+		}
+	}
+	if tests != b.tests {
+		t.Errorf("Expected %d serialized tests of the backend, got %d", b.tests, tests)
+	}
+}
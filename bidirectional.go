@@ -0,0 +1,57 @@
+package pmc
+
+import (
+	"bytes"
+	"errors"
+	"sync"
+
+	"github.com/seiflotfy/pmc/flowkey"
+)
+
+/*
+WithBidirectional counts conversations rather than directions: a flow is
+accounted and estimated as the lowest, in byte order, of its key and of the
+key of its opposite direction, which reverse appends to dst, so that both
+directions add to and estimate the same count. reverse must give back the
+key when applied twice, as flowkey.ReverseFiveTuple does, whose lowest keys
+are those of flowkey.FiveTuple.Canonical. Flows counted by Increment64 are
+hashed by the caller, and counted as they are.
+*/
+func WithBidirectional(reverse func(dst, flow []byte) []byte) Option {
+	return func(sketch *Sketch) error {
+		if reverse == nil {
+			return errors.New("Expected a reverse function, got nil")
+		}
+		sketch.reverse = reverse
+		return nil
+	}
+}
+
+// keyBuf holds a reversed key. Buffers passed to reverse escape to the heap,
+// so they are pooled rather than taken from the stack, which keeps additions
+// and estimates of bidirectional sketches from allocating.
+type keyBuf [flowkey.MaxLen]byte
+
+var keyBufs = sync.Pool{New: func() any { return new(keyBuf) }}
+
+// key returns the key flow is accounted as, see WithBidirectional, and the
+// buffer holding it unless it is flow, to be passed to releaseKey once the
+// key is no longer used.
+func (sketch *Sketch) key(flow []byte) ([]byte, *keyBuf) {
+	if sketch.reverse == nil {
+		return flow, nil
+	}
+	buf := keyBufs.Get().(*keyBuf)
+	if rev := sketch.reverse(buf[:0], flow); bytes.Compare(rev, flow) < 0 {
+		return rev, buf
+	}
+	keyBufs.Put(buf)
+	return flow, nil
+}
+
+// releaseKey returns the buffer of a key returned by key to the pool.
+func releaseKey(buf *keyBuf) {
+	if buf != nil {
+		keyBufs.Put(buf)
+	}
+}
@@ -0,0 +1,50 @@
+package pmc
+
+import (
+	"testing"
+)
+
+// reverseTuple reverses keys made of two 2-byte endpoints.
+func reverseTuple(dst, flow []byte) []byte {
+	if len(flow) != 4 {
+		return append(dst, flow...)
+	}
+	return append(dst, flow[2], flow[3], flow[0], flow[1])
+}
+
+func TestWithBidirectional(t *testing.T) {
+	s, err := New(1<<16, 64, 32, WithBidirectional(reverseTuple))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ab, ba := []byte{1, 1, 2, 2}, []byte{2, 2, 1, 1}
+	for k := 0; k < 500; k++ {
+		s.Increment(ab)
+		s.Increment(ba)
+	}
+	s.Add(ba, 1000)
+	for _, flow := range [][]byte{ab, ba} {
+		if est := s.GetEstimate(flow); est < 1600 || est > 2400 {
+			t.Errorf("Expected an estimate of about 2000 for %v, got %v", flow, est)
+		}
+	}
+
+	c := s.Clone()
+	if est, want := c.GetEstimate(ab), s.GetEstimate(ba); est != want {
+		t.Errorf("Expected the clone to estimate conversations as %v, got %v", want, est)
+	}
+	if ref := s.PrepareFlow(ba); ref.GetEstimate() != s.GetEstimate(ab) {
+		t.Error("Expected a FlowRef to account the conversation")
+	}
+
+	plain, _ := New(1<<16, 64, 32)
+	for k := 0; k < 500; k++ {
+		plain.Increment(ab)
+	}
+	if est := plain.GetEstimate(ba); est > 50 {
+		t.Errorf("Expected directions to be counted apart by default, got %v", est)
+	}
+	if _, err := New(1<<16, 64, 32, WithBidirectional(nil)); err == nil {
+		t.Error("Expected an error for a nil reverse function")
+	}
+}
@@ -0,0 +1,128 @@
+package pmc
+
+import (
+	"math/bits"
+	"sync/atomic"
+)
+
+// bitArray is the bitmap of a sketch, stored as 64-bit words.
+type bitArray []uint64
+
+func newBitArray(l uint) bitArray {
+	return make(bitArray, (l+63)/64)
+}
+
+// test is an atomic load, so that estimates can run concurrently with the
+// increments of lock-free sketches.
+func (b bitArray) test(i uint) bool {
+	return atomic.LoadUint64(&b[i>>6])&(1<<(i&63)) != 0
+}
+
+// set sets bit i and returns whether it was clear before.
+func (b bitArray) set(i uint) bool {
+	word, mask := &b[i>>6], uint64(1)<<(i&63)
+	if *word&mask != 0 {
+		return false
+	}
+	*word |= mask
+	return true
+}
+
+// setAtomic is set for concurrent writers.
+func (b bitArray) setAtomic(i uint) bool {
+	mask := uint64(1) << (i & 63)
+	return atomic.OrUint64(&b[i>>6], mask)&mask == 0
+}
+
+// clearAtomic is clear for concurrent writers.
+func (b bitArray) clearAtomic(i uint) bool {
+	mask := uint64(1) << (i & 63)
+	return atomic.AndUint64(&b[i>>6], ^mask)&mask != 0
+}
+
+// clear clears bit i and returns whether it was set before.
+func (b bitArray) clear(i uint) bool {
+	word, mask := &b[i>>6], uint64(1)<<(i&63)
+	if *word&mask == 0 {
+		return false
+	}
+	*word &^= mask
+	return true
+}
+
+func (b bitArray) count() uint {
+	c := 0
+	for _, word := range b {
+		c += bits.OnesCount64(word)
+	}
+	return uint(c)
+}
+
+// countRange returns the number of set bits in [from, to), masking the
+// partial words at both ends.
+func (b bitArray) countRange(from, to uint) uint {
+	if from >= to {
+		return 0
+	}
+	first, last := from>>6, (to-1)>>6
+	lo, hi := ^uint64(0)<<(from&63), ^uint64(0)>>(63-(to-1)&63)
+	if first == last {
+		return uint(bits.OnesCount64(b[first] & lo & hi))
+	}
+	c := bits.OnesCount64(b[first]&lo) + bits.OnesCount64(b[last]&hi)
+	for _, word := range b[first+1 : last] {
+		c += bits.OnesCount64(word)
+	}
+	return uint(c)
+}
+
+// tail returns the bits of the last word beyond bit l, which are zero in a
+// valid bitmap of l bits.
+func (b bitArray) tail(l uint) uint64 {
+	if l%64 == 0 || len(b) == 0 {
+		return 0
+	}
+	return b[len(b)-1] >> (l % 64)
+}
+
+func (b bitArray) any() bool {
+	for _, word := range b {
+		if word != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+func (b bitArray) union(other bitArray) {
+	for i, word := range other {
+		b[i] |= word
+	}
+}
+
+func (b bitArray) clone() bitArray {
+	c := make(bitArray, len(b))
+	copy(c, b)
+	return c
+}
+
+// cloneAtomic is clone for bitmaps with concurrent writers.
+func (b bitArray) cloneAtomic() bitArray {
+	c := make(bitArray, len(b))
+	for i := range b {
+		c[i] = atomic.LoadUint64(&b[i])
+	}
+	return c
+}
+
+func (b bitArray) equal(other bitArray) bool {
+	if len(b) != len(other) {
+		return false
+	}
+	for i, word := range b {
+		if word != other[i] {
+			return false
+		}
+	}
+	return true
+}
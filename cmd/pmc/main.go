@@ -0,0 +1,225 @@
+/*
+Command pmc manipulates sketch files from the shell.
+
+Usage:
+
+	pmc create [-l bits] [-m rows] [-w columns] [-flows n] [-hashseed n] file
+	pmc add file < keys
+	pmc estimate file [key ...]
+	pmc merge out file [file ...]
+	pmc stats file
+
+add reads one key per line from stdin, optionally followed by a tab and a
+weight. estimate reads the keys from stdin when none are given on the
+command line, and prints each key with its estimate separated by a tab.
+Sketches can only be merged if they were created with the same parameters
+and -hashseed, or copied from the same empty sketch file.
+*/
+package main
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/seiflotfy/pmc"
+)
+
+const usage = `usage:
+	pmc create [-l bits] [-m rows] [-w columns] [-flows n] [-hashseed n] file
+	pmc add file < keys
+	pmc estimate file [key ...]
+	pmc merge out file [file ...]
+	pmc stats file
+`
+
+func main() {
+	if err := run(os.Args[1:], os.Stdin, os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "pmc:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New(usage)
+	}
+	cmds := map[string]func([]string, io.Reader, io.Writer) error{
+		"create":   create,
+		"add":      add,
+		"estimate": estimate,
+		"merge":    merge,
+		"stats":    stats,
+	}
+	cmd, ok := cmds[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+	return cmd(args[1:], stdin, stdout)
+}
+
+func load(path string) (*pmc.Sketch, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	// A sketch decoded into a zero Sketch starts over from the default seed,
+	// and would sample the same cells on every run for the same keys, so it's
+	// decoded into a sketch with a random one instead. ReadFrom replaces all
+	// but the generator, including the parameters and hash seed.
+	var seed [8]byte
+	if _, err := rand.Read(seed[:]); err != nil {
+		return nil, err
+	}
+	sketch, err := pmc.NewWithSeed(1, 1, 2, binary.LittleEndian.Uint64(seed[:])|1)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := sketch.ReadFrom(bufio.NewReader(f)); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return sketch, nil
+}
+
+// save writes the sketch to a temporary file renamed to path, so that a
+// failed write never leaves a truncated sketch behind.
+func save(path string, sketch *pmc.Sketch) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	w := bufio.NewWriter(f)
+	if _, err := sketch.WriteTo(w); err != nil {
+		f.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+func create(args []string, stdin io.Reader, stdout io.Writer) error {
+	fs := flag.NewFlagSet("create", flag.ContinueOnError)
+	l := fs.Uint("l", 8000000, "number of bits of the sketch")
+	m := fs.Uint("m", 256, "number of rows of the virtual matrices")
+	w := fs.Uint("w", 32, "number of columns of the virtual matrices")
+	flows := fs.Uint("flows", 0, "maximum number of flows, overriding -l -m and -w")
+	hashSeed := fs.Uint64("hashseed", 0, "hash seed, random if 0; sketches are only mergeable with the same one")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: pmc create [-l bits] [-m rows] [-w columns] [-flows n] [-hashseed n] file")
+	}
+	var opts []pmc.Option
+	if *hashSeed != 0 {
+		opts = append(opts, pmc.WithHashSeed(*hashSeed))
+	}
+	var sketch *pmc.Sketch
+	var err error
+	if *flows > 0 {
+		sketch, err = pmc.NewForMaxFlows(*flows, opts...)
+	} else {
+		sketch, err = pmc.New(*l, *m, *w, opts...)
+	}
+	if err != nil {
+		return err
+	}
+	return save(fs.Arg(0), sketch)
+}
+
+func add(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: pmc add file < keys")
+	}
+	sketch, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(stdin)
+	for line := 1; scanner.Scan(); line++ {
+		key, weight := scanner.Text(), uint64(1)
+		if i := strings.LastIndexByte(key, '\t'); i >= 0 {
+			if weight, err = strconv.ParseUint(key[i+1:], 10, 64); err != nil {
+				return fmt.Errorf("line %d: invalid weight %q", line, key[i+1:])
+			}
+			key = key[:i]
+		}
+		sketch.Add([]byte(key), weight)
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return save(args[0], sketch)
+}
+
+func estimate(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) == 0 {
+		return errors.New("usage: pmc estimate file [key ...]")
+	}
+	sketch, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(stdout)
+	defer w.Flush()
+	print := func(key string) {
+		fmt.Fprintf(w, "%s\t%.0f\n", key, sketch.GetEstimate([]byte(key)))
+	}
+	if len(args) > 1 {
+		for _, key := range args[1:] {
+			print(key)
+		}
+		return nil
+	}
+	scanner := bufio.NewScanner(stdin)
+	for scanner.Scan() {
+		print(scanner.Text())
+	}
+	return scanner.Err()
+}
+
+func merge(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) < 2 {
+		return errors.New("usage: pmc merge out file [file ...]")
+	}
+	sketches := make([]*pmc.Sketch, len(args)-1)
+	for i, path := range args[1:] {
+		var err error
+		if sketches[i], err = load(path); err != nil {
+			return err
+		}
+	}
+	merged, err := pmc.Union(sketches...)
+	if err != nil {
+		return err
+	}
+	return save(args[0], merged)
+}
+
+func stats(args []string, stdin io.Reader, stdout io.Writer) error {
+	if len(args) != 1 {
+		return errors.New("usage: pmc stats file")
+	}
+	sketch, err := load(args[0])
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(stdout, sketch.Expvar().String())
+	return err
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	a, b, ab := filepath.Join(dir, "a.pmc"), filepath.Join(dir, "b.pmc"), filepath.Join(dir, "ab.pmc")
+	var out bytes.Buffer
+	for _, path := range []string{a, b} {
+		args := []string{"create", "-l", "1048576", "-m", "256", "-w", "32", "-hashseed", "7", path}
+		if err := run(args, nil, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := run([]string{"add", a}, strings.NewReader("x\t5000\ny\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"add", b}, strings.NewReader("x\t5000\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"add", a}, strings.NewReader("x\tmany\n"), &out); err == nil {
+		t.Error("Expected error for an invalid weight, got nil")
+	}
+	if err := run([]string{"merge", ab, a, b}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	out.Reset()
+	if err := run([]string{"estimate", ab}, strings.NewReader("x\n"), &out); err != nil {
+		t.Fatal(err)
+	}
+	fields := strings.Fields(out.String())
+	if len(fields) != 2 || fields[0] != "x" {
+		t.Fatalf("Expected a key and its estimate, got %q", out.String())
+	}
+	est, _ := strconv.ParseFloat(fields[1], 64)
+	if fErr := math.Abs(100 * (1 - est/10000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 10000, got %f", est)
+	}
+
+	out.Reset()
+	if err := run([]string{"stats", ab}, nil, &out); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), `"n":10001`) {
+		t.Error("Expected stats with n == 10001, got", out.String())
+	}
+
+	if err := run([]string{"frobnicate"}, nil, &out); err == nil {
+		t.Error("Expected error for an unknown command, got nil")
+	}
+}
+
+func TestLoadSeed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.pmc")
+	if err := run([]string{"create", "-l", "65536", "-m", "64", "-w", "32", "-hashseed", "7", path}, nil, &bytes.Buffer{}); err != nil {
+		t.Fatal(err)
+	}
+	var loaded [2][]uint64
+	for k := range loaded {
+		sketch, err := load(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sketch.HashSeed() != 7 {
+			t.Fatal("Expected the hash seed of the file, got", sketch.HashSeed())
+		}
+		sketch.Add([]byte("x"), 100)
+		loaded[k] = sketch.Bits()
+	}
+	if slices.Equal(loaded[0], loaded[1]) {
+		t.Error("Expected each load to sample with its own seed")
+	}
+}
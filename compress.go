@@ -0,0 +1,39 @@
+package pmc
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+/*
+SaveCompressed writes a gzip compressed snapshot of the sketch to w. Bitmaps
+are sparse early in the lifetime of a sketch and compress well, which makes
+this form suited for shipping periodic snapshots over the network.
+*/
+func (sketch *Sketch) SaveCompressed(w io.Writer) error {
+	zw := gzip.NewWriter(w)
+	if _, err := sketch.WriteTo(zw); err != nil {
+		zw.Close()
+		return err
+	}
+	return zw.Close()
+}
+
+/*
+LoadCompressed reads a snapshot written by SaveCompressed and returns the
+sketch it holds, which must use the default Hasher, GeoSampler and
+direction, see ReadFrom.
+*/
+func LoadCompressed(r io.Reader) (*Sketch, error) {
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	sketch := &Sketch{}
+	if _, err := sketch.ReadFrom(zr); err != nil {
+		return nil, err
+	}
+	return sketch, nil
+}
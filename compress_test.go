@@ -0,0 +1,29 @@
+package pmc
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestSaveLoadCompressed(t *testing.T) {
+	s, _ := New(1<<20, 16, 16)
+	s.setBit(1)
+	s.setBit(1 << 19)
+	s.n = 2
+
+	var buf bytes.Buffer
+	if err := s.SaveCompressed(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if buf.Len() > (1<<20)/8/10 {
+		t.Errorf("Expected sparse sketch to compress at least 10x, got %d bytes", buf.Len())
+	}
+
+	r, err := LoadCompressed(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.n != s.n || !r.bitmap.equal(s.bitmap) {
+		t.Error("Expected loaded sketch to equal original")
+	}
+}
@@ -0,0 +1,69 @@
+package pmc
+
+import "sync"
+
+/*
+ConcurrentSketch wraps a Sketch so that it can be shared between goroutines.
+All operations are serialized by a mutex.
+*/
+type ConcurrentSketch struct {
+	mu     sync.Mutex
+	sketch *Sketch
+}
+
+/*
+NewConcurrent returns a ConcurrentSketch with the same properties as New.
+*/
+func NewConcurrent(l uint, m uint, w uint, opts ...Option) (*ConcurrentSketch, error) {
+	sketch, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &ConcurrentSketch{sketch: sketch}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (cs *ConcurrentSketch) Increment(flow []byte) {
+	cs.mu.Lock()
+	cs.sketch.Increment(flow)
+	cs.mu.Unlock()
+}
+
+/*
+GetEstimate returns the estimated count of a given flow
+*/
+func (cs *ConcurrentSketch) GetEstimate(flow []byte) float64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.sketch.GetEstimate(flow)
+}
+
+/*
+GetFillRate returns the percentage of bits set in the sketch
+*/
+func (cs *ConcurrentSketch) GetFillRate() float64 {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.sketch.GetFillRate()
+}
+
+/*
+Merge folds other into the sketch, see Sketch.Merge.
+*/
+func (cs *ConcurrentSketch) Merge(other *Sketch) error {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.sketch.Merge(other)
+}
+
+/*
+Snapshot returns a copy of the underlying sketch that is safe to use without
+synchronization while the ConcurrentSketch keeps being updated.
+*/
+func (cs *ConcurrentSketch) Snapshot() *Sketch {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	return cs.sketch.Clone()
+}
@@ -0,0 +1,34 @@
+package pmc
+
+import (
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSketch(t *testing.T) {
+	cs, err := NewConcurrent(1<<16, 16, 16)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			flow := []byte("flow-" + strconv.Itoa(g))
+			for i := 0; i < 1000; i++ {
+				cs.Increment(flow)
+				if i%100 == 0 {
+					cs.GetEstimate(flow)
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+
+	if s := cs.Snapshot(); s.n != 8000 {
+		t.Error("Expected n == 8000, got", s.n)
+	}
+}
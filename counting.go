@@ -0,0 +1,170 @@
+package pmc
+
+import (
+	"math"
+	"sync/atomic"
+)
+
+// maxCount is the value at which the counters of a CountingSketch saturate.
+const maxCount = math.MaxUint8
+
+/*
+CountingSketch is a PMC sketch whose bits are backed by 8-bit saturating
+counters, counting how many additions hit each position. A position reads as
+set as long as its counter is positive, which makes it possible to remove
+additions again. It takes l bytes on top of the bitmap.
+*/
+type CountingSketch struct {
+	sketch   *Sketch
+	counters []uint8
+}
+
+/*
+NewCounting returns a CountingSketch with the same properties as New.
+*/
+func NewCounting(l uint, m uint, w uint, opts ...Option) (*CountingSketch, error) {
+	sketch, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &CountingSketch{sketch: sketch, counters: make([]uint8, l)}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (cs *CountingSketch) Increment(flow []byte) {
+	sketch := cs.sketch
+	sketch.mu.Lock()
+	if i, j, ok := sketch.sample(); ok {
+		key, buf := sketch.key(flow)
+		pos := sketch.getPos(key, i, j)
+		releaseKey(buf)
+		if cs.counters[pos] < maxCount {
+			cs.counters[pos]++
+		}
+		sketch.setBit(pos)
+	}
+	sketch.mu.Unlock()
+	if sketch.hooks != nil {
+		sketch.incremented(flow, 1)
+	}
+}
+
+/*
+Decrement removes one addition of the flow. The removed addition is the one
+of a random position of the flow's virtual matrix, picked with a probability
+proportional to its counter, so that it is the position of a random previous
+addition. Saturated counters are never decremented. It returns false if none
+of the positions of the flow is set.
+*/
+func (cs *CountingSketch) Decrement(flow []byte) bool {
+	sketch := cs.sketch
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	flow, buf := sketch.key(flow)
+	defer releaseKey(buf)
+
+	total := uint(0)
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			total += uint(cs.counters[sketch.getPos(flow, i, j)])
+		}
+	}
+	if total == 0 {
+		return false
+	}
+
+	r := sketch.rand(total)
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			pos := sketch.getPos(flow, i, j)
+			if c := uint(cs.counters[pos]); r >= c {
+				r -= c
+				continue
+			}
+			cs.decrementAt(pos)
+			return true
+		}
+	}
+	return true
+}
+
+func (cs *CountingSketch) decrementAt(pos uint) {
+	sketch := cs.sketch
+	if c := cs.counters[pos]; c > 0 && c < maxCount {
+		cs.counters[pos]--
+		if c == 1 {
+			sketch.clearBit(pos)
+		}
+	}
+	if sketch.N() > 0 {
+		atomic.AddUint64(&sketch.n, ^uint64(0))
+	}
+}
+
+/*
+GetEstimate returns the estimated count of a given flow
+*/
+func (cs *CountingSketch) GetEstimate(flow []byte) float64 {
+	return cs.sketch.GetEstimate(flow)
+}
+
+/*
+N returns the number of additions minus the number of removals.
+*/
+func (cs *CountingSketch) N() uint64 {
+	return cs.sketch.N()
+}
+
+/*
+Sketch returns a copy of the sketch that the counters are backing, e.g. to
+merge it or serialize it.
+*/
+func (cs *CountingSketch) Sketch() *Sketch {
+	return cs.sketch.Clone()
+}
+
+/*
+Clone returns a deep copy of the counting sketch, e.g. to keep a checkpoint
+for Subtract.
+*/
+func (cs *CountingSketch) Clone() *CountingSketch {
+	cs.sketch.mu.Lock()
+	defer cs.sketch.mu.Unlock()
+	counters := make([]uint8, len(cs.counters))
+	copy(counters, cs.counters)
+	return &CountingSketch{sketch: cs.sketch.clone(), counters: counters}
+}
+
+/*
+Subtract returns a new counting sketch holding the additions of a that are
+not in b, where b is an older checkpoint of a, e.g. taken with Clone. The
+estimates of the result are the flow volumes between the checkpoint and a.
+Counters saturated in a stay saturated, since the number of additions they
+account for is unknown.
+*/
+func Subtract(a, b *CountingSketch) (*CountingSketch, error) {
+	if err := a.sketch.checkCompatible(b.sketch); err != nil {
+		return nil, err
+	}
+	d := a.Clone()
+	sketch := d.sketch
+	for pos, c := range d.counters {
+		if c == maxCount {
+			continue
+		}
+		if o := b.counters[pos]; o < c {
+			d.counters[pos] = c - o
+		} else {
+			d.counters[pos] = 0
+			sketch.clearBit(uint(pos))
+		}
+	}
+	n, o := sketch.N(), b.sketch.N()
+	if o > n {
+		o = n
+	}
+	atomic.StoreUint64(&sketch.n, n-o)
+	return d, nil
+}
@@ -0,0 +1,74 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCountingSketch(t *testing.T) {
+	cs, err := NewCounting(1<<20, 128, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := []byte("flow")
+
+	cs.Increment([]byte("single"))
+	if !cs.Decrement([]byte("single")) {
+		t.Fatal("Expected Decrement to remove an addition")
+	}
+	if cs.sketch.bitmap.any() || cs.N() != 0 {
+		t.Error("Expected Decrement to undo a single Increment")
+	}
+	if cs.Decrement([]byte("single")) {
+		t.Error("Expected Decrement of an absent flow to return false")
+	}
+
+	for i := 0; i < 4000; i++ {
+		cs.Increment(flow)
+	}
+	for i := 0; i < 2000; i++ {
+		cs.Decrement(flow)
+	}
+	if cs.N() != 2000 {
+		t.Error("Expected N() == 2000, got", cs.N())
+	}
+	if c := uint64(cs.sketch.bitmap.count()); cs.sketch.ones != c {
+		t.Errorf("Expected %d set bits, got %d", c, cs.sketch.ones)
+	}
+	est := cs.GetEstimate(flow)
+	if fErr := math.Abs(100 * (1 - est/2000)); fErr > 20 {
+		t.Errorf("Expected estimate within 20%% of 2000, got %f", est)
+	}
+}
+
+func TestSubtract(t *testing.T) {
+	a, _ := NewCounting(1<<20, 128, 32)
+	flow := []byte("flow")
+	for i := 0; i < 3000; i++ {
+		a.Increment(flow)
+	}
+	checkpoint := a.Clone()
+	for i := 0; i < 5000; i++ {
+		a.Increment(flow)
+	}
+
+	d, err := Subtract(a, checkpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.N() != 5000 {
+		t.Error("Expected N() == 5000, got", d.N())
+	}
+	est := d.GetEstimate(flow)
+	if fErr := math.Abs(100 * (1 - est/5000)); fErr > 20 {
+		t.Errorf("Expected estimate within 20%% of 5000, got %f", est)
+	}
+	if a.N() != 8000 {
+		t.Error("Expected Subtract to leave its arguments untouched")
+	}
+
+	other, _ := NewCounting(1<<10, 128, 32)
+	if _, err := Subtract(a, other); err == nil {
+		t.Error("Expected error subtracting incompatible sketches, got nil")
+	}
+}
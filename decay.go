@@ -0,0 +1,37 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+)
+
+/*
+GetDecayedEstimate returns the estimated count of the flow with exponential
+aging: the additions of the bucket that became current k rotations ago are
+weighted by decay^k, so that those of the current bucket count fully, and
+the weight of an addition halves every ln(2)/-ln(decay) intervals. decay
+must be in [0, 1]; 0 only counts the current bucket and 1 gives the sum of
+the estimates of the buckets.
+
+The buckets are estimated apart, each from its own bitmap, which decaying
+leaves untouched, so that each estimate stays unbiased. Their signed
+estimates, which are negative when the noise of the other flows of a bucket
+outweighs the flow, are summed before clamping the result at 0, so that this
+noise cancels out instead of biasing the estimates of small flows upwards.
+Since the buckets are cleared on rotation, the sketch never saturates for
+good, however long it runs.
+*/
+func (ws *WindowedSketch) GetDecayedEstimate(flow []byte, decay float64) (float64, error) {
+	if !(decay >= 0 && decay <= 1) {
+		return 0, fmt.Errorf("Expected decay in [0, 1], got %v", decay)
+	}
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	total, weight := 0.0, 1.0
+	for age := 0; age < len(ws.buckets) && weight > 0; age++ {
+		b := ws.buckets[(ws.cur-age+len(ws.buckets))%len(ws.buckets)]
+		total += weight * b.signedEstimate(flow)
+		weight *= decay
+	}
+	return math.Max(total, 0), nil
+}
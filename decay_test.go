@@ -0,0 +1,69 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetDecayedEstimate(t *testing.T) {
+	ws, err := NewWindowed(1<<22, 256, 32, 3, 0, WithSeed(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := []byte("flow")
+	ws.Add(flow, 10000)
+	ws.Rotate()
+	ws.Add(flow, 10000)
+
+	for _, tc := range []struct {
+		decay, want float64
+	}{
+		{0, 10000},
+		{0.5, 15000},
+		{1, 20000},
+	} {
+		est, err := ws.GetDecayedEstimate(flow, tc.decay)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if fErr := math.Abs(100 * (1 - est/tc.want)); fErr > 15 {
+			t.Errorf("Expected decay %v estimate within 15%% of %v, got %f", tc.decay, tc.want, est)
+		}
+	}
+
+	ws.Rotate()
+	ws.Rotate()
+	ws.Rotate()
+	if est, _ := ws.GetDecayedEstimate(flow, 1); est != 0 {
+		t.Error("Expected rotated out additions to be forgotten, got", est)
+	}
+}
+
+func TestGetDecayedEstimateAbsent(t *testing.T) {
+	ws, _ := NewWindowed(1<<16, 64, 32, 4, 0, WithSeed(3))
+	for b := 0; b < 4; b++ {
+		for i := 0; i < 2000; i++ {
+			ws.Add([]byte(fmt.Sprintf("flow-%d", i)), 10)
+		}
+		ws.Rotate()
+	}
+
+	sum := 0.0
+	for i := 0; i < 200; i++ {
+		est, _ := ws.GetDecayedEstimate([]byte(fmt.Sprintf("absent-%d", i)), 1)
+		sum += est
+	}
+	if mean := sum / 200; mean > 10 {
+		t.Errorf("Expected absent flows to average below 10, got %f", mean)
+	}
+}
+
+func TestGetDecayedEstimateInvalid(t *testing.T) {
+	ws, _ := NewWindowed(1024, 8, 8, 2, 0)
+	for _, decay := range []float64{-0.5, 1.5, math.NaN()} {
+		if _, err := ws.GetDecayedEstimate([]byte("flow"), decay); err == nil {
+			t.Errorf("Expected error for decay %v, got nil", decay)
+		}
+	}
+}
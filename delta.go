@@ -0,0 +1,40 @@
+package pmc
+
+import "math"
+
+/*
+Delta estimates the count of flow during the interval between two snapshots
+of the same sketch, e.g. taken with Clone, before being the older one. It
+returns NaN if the snapshots have different parameters, see DeltaChecked.
+*/
+func Delta(before, after *Sketch, flow []byte) float64 {
+	if after.checkCompatible(before) != nil {
+		return math.NaN()
+	}
+	return delta(before, after, flow)
+}
+
+/*
+DeltaChecked is Delta returning an error wrapping ErrIncompatibleParams if
+the snapshots have different parameters, and ErrSaturated if the newer one
+is saturated, rather than an estimate of the noise.
+*/
+func DeltaChecked(before, after *Sketch, flow []byte) (float64, error) {
+	if err := after.checkCompatible(before); err != nil {
+		return 0, err
+	}
+	after.mu.Lock()
+	saturated := after.isSaturated()
+	after.mu.Unlock()
+	if saturated {
+		return 0, ErrSaturated
+	}
+	return delta(before, after, flow), nil
+}
+
+func delta(before, after *Sketch, flow []byte) float64 {
+	if d := after.GetEstimate(flow) - before.GetEstimate(flow); d > 0 {
+		return d
+	}
+	return 0
+}
@@ -0,0 +1,42 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDelta(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	flow := []byte("flow")
+	s.IncrementN(flow, 20000)
+	before := s.Clone()
+	s.IncrementN(flow, 30000)
+
+	d := Delta(before, s, flow)
+	if fErr := math.Abs(100 * (1 - d/30000)); fErr > 15 {
+		t.Errorf("Expected delta within 15%% of 30000, got %f", d)
+	}
+	if d := Delta(s, before, flow); d != 0 {
+		t.Error("Expected reversed snapshots to give 0, got", d)
+	}
+
+	other, _ := New(1024, 8, 8)
+	if d := Delta(before, other, flow); !math.IsNaN(d) {
+		t.Error("Expected NaN for incompatible snapshots, got", d)
+	}
+}
+
+func TestDeltaChecked(t *testing.T) {
+	s, _ := New(1<<22, 256, 32, WithDeterministic(7))
+	flow := []byte("flow")
+	s.IncrementN(flow, 20000)
+	before := s.Clone()
+	s.IncrementN(flow, 30000)
+	d, err := DeltaChecked(before, s, flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := Delta(before, s, flow); d != want {
+		t.Errorf("Expected delta %f, got %f", want, d)
+	}
+}
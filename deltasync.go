@@ -0,0 +1,215 @@
+package pmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+)
+
+// deltaMagic starts the deltas of SnapshotDelta.
+var deltaMagic = [4]byte{'P', 'M', 'C', 'D'}
+
+const deltaVersion = 2
+
+/*
+SnapshotDelta returns the words of the bitmap changed since the baseline
+since, an older copy of the sketch taken with Clone, and the number of
+additions since then, so that periodically syncing a collector with an
+aggregator costs in proportion to the traffic rather than to l. The
+collector keeps a clone of the sketch as of the last delta it sent as the
+next baseline. A nil or incompatible baseline gives the whole sketch.
+*/
+func (sketch *Sketch) SnapshotDelta(since *Sketch) []byte {
+	var base []uint64
+	var n uint64
+	if since != nil && sketch.checkCompatible(since) == nil {
+		since.mu.Lock()
+		base = append(base, since.words()...)
+		n = since.N()
+		since.mu.Unlock()
+	}
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if n > sketch.N() {
+		n = sketch.N()
+	}
+	var buf bytes.Buffer
+	buf.Write(deltaMagic[:])
+	buf.WriteByte(deltaVersion)
+	hdr := sketch.appendParams(nil)
+	hdr = binary.AppendUvarint(hdr, sketch.N()-n)
+	buf.Write(hdr)
+	// Writing to a bytes.Buffer can't fail.
+	writeWords(&buf, base, sketch.words())
+	return buf.Bytes()
+}
+
+/*
+ApplyDelta ORs a delta returned by SnapshotDelta into the sketch and adds
+its additions, merging the traffic it accounts for. It fails with
+ErrIncompatibleParams if the delta is of a sketch with other parameters.
+*/
+func (sketch *Sketch) ApplyDelta(data []byte) error {
+	r := bytes.NewReader(data)
+	var magic [5]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return err
+	}
+	if [4]byte(magic[:4]) != deltaMagic || magic[4] != deltaVersion {
+		return errors.New("Expected a sketch delta")
+	}
+	if err := sketch.readParams(r); err != nil {
+		return err
+	}
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	words := wordCount(sketch.l)
+	if err := checkWords(data[len(data)-r.Len():], sketch.l); err != nil {
+		return err
+	}
+
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	readWords(r, words, func(i int, word uint64) {
+		for rest := word; rest != 0; rest &= rest - 1 {
+			sketch.setBit(uint(i)<<6 | uint(bits.TrailingZeros64(rest)))
+		}
+	})
+	sketch.addN(n)
+	return nil
+}
+
+// appendParams appends l, m, w, the hash seed, the fingerprints of the Hasher
+// and GeoSampler and the column dropping and direction flags of the sketch
+// to buf, as read by readParams.
+func (sketch *Sketch) appendParams(buf []byte) []byte {
+	p := sketch.Params()
+	buf = binary.AppendUvarint(buf, uint64(p.L))
+	buf = binary.AppendUvarint(buf, uint64(p.M))
+	buf = binary.AppendUvarint(buf, uint64(p.W))
+	buf = binary.LittleEndian.AppendUint64(buf, p.HashSeed)
+	buf = binary.LittleEndian.AppendUint64(buf, p.Hasher)
+	buf = binary.LittleEndian.AppendUint64(buf, p.Sampler)
+	var flags byte
+	if p.ColumnDropping {
+		flags |= 1
+	}
+	if p.Bidirectional {
+		flags |= 2
+	}
+	return append(buf, flags)
+}
+
+// readParams reads the parameters written by appendParams, failing with
+// ErrIncompatibleParams if they aren't those of the sketch.
+func (sketch *Sketch) readParams(r *bytes.Reader) error {
+	var params [3]uint64
+	for i := range params {
+		var err error
+		if params[i], err = binary.ReadUvarint(r); err != nil {
+			return err
+		}
+	}
+	var rest [25]byte
+	if _, err := io.ReadFull(r, rest[:]); err != nil {
+		return err
+	}
+	seed := binary.LittleEndian.Uint64(rest[:])
+	if params != [3]uint64{uint64(sketch.l), uint64(sketch.m), uint64(sketch.w)} || seed != sketch.hashSeed {
+		return fmt.Errorf("%w: expected l=%v, m=%v, w=%v and hash seed %#x, got l=%v, m=%v, w=%v and hash seed %#x",
+			ErrIncompatibleParams, sketch.l, sketch.m, sketch.w, sketch.hashSeed,
+			params[0], params[1], params[2], seed)
+	}
+	return sketch.checkCodec(Params{
+		W:              sketch.w,
+		Hasher:         binary.LittleEndian.Uint64(rest[8:]),
+		Sampler:        binary.LittleEndian.Uint64(rest[16:]),
+		ColumnDropping: rest[24]&1 != 0,
+		Bidirectional:  rest[24]&2 != 0,
+	})
+}
+
+// writeWords writes the words that differ from base, which may be nil for
+// all zeros, as their number followed by the gap from the previous index
+// and the value of each.
+func writeWords(w io.Writer, base, words []uint64) error {
+	changed := 0
+	for i, word := range words {
+		if i >= len(base) || word != base[i] {
+			changed++
+		}
+	}
+	buf := binary.AppendUvarint(nil, uint64(changed))
+	prev := 0
+	for i, word := range words {
+		if i < len(base) && word == base[i] {
+			continue
+		}
+		buf = binary.AppendUvarint(buf, uint64(i-prev))
+		buf = binary.LittleEndian.AppendUint64(buf, word)
+		prev = i
+		if len(buf) >= 1<<16 {
+			if _, err := w.Write(buf); err != nil {
+				return err
+			}
+			buf = buf[:0]
+		}
+	}
+	_, err := w.Write(buf)
+	return err
+}
+
+// readWords calls fn with the index and value of the words written by
+// writeWords, failing if an index is beyond n words.
+func readWords(r io.ByteReader, n int, fn func(i int, word uint64)) error {
+	changed, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	i := 0
+	for k := uint64(0); k < changed; k++ {
+		gap, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if gap >= uint64(n-i) {
+			return fmt.Errorf("Expected a word index below %d, got %d", n, uint64(i)+gap)
+		}
+		i += int(gap)
+		var word [8]byte
+		for j := range word {
+			if word[j], err = r.ReadByte(); err != nil {
+				return err
+			}
+		}
+		fn(i, binary.LittleEndian.Uint64(word[:]))
+	}
+	return nil
+}
+
+// checkWords validates the words written by writeWords in data for a bitmap
+// of l bits, so that they are never half applied, failing if they set bits
+// beyond l.
+func checkWords(data []byte, l uint) error {
+	n := wordCount(l)
+	var tail uint64
+	err := readWords(bytes.NewReader(data), n, func(i int, word uint64) {
+		if i == n-1 {
+			tail |= bitArray{word}.tail(l)
+		}
+	})
+	if err == nil && tail != 0 {
+		err = errors.New("Expected the bits beyond l to be zero")
+	}
+	return err
+}
+
+// wordCount returns the number of words of a bitmap of l bits.
+func wordCount(l uint) int {
+	return int((l + 63) / 64)
+}
@@ -0,0 +1,79 @@
+package pmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestSnapshotDelta(t *testing.T) {
+	collector, _ := New(1<<18, 64, 32, WithHashSeed(7))
+	aggregator, _ := New(1<<18, 64, 32, WithHashSeed(7))
+	for k := 0; k < 20000; k++ {
+		collector.Increment([]byte(fmt.Sprintf("flow-%d", k%100)))
+	}
+	full := collector.SnapshotDelta(nil)
+	if err := aggregator.ApplyDelta(full); err != nil {
+		t.Fatal(err)
+	}
+	baseline := collector.Clone()
+
+	for k := 0; k < 100; k++ {
+		collector.Increment([]byte("new"))
+	}
+	delta := collector.SnapshotDelta(baseline)
+	if len(delta) >= len(full)/10 {
+		t.Errorf("Expected a delta much smaller than %d bytes, got %d", len(full), len(delta))
+	}
+	if err := aggregator.ApplyDelta(delta); err != nil {
+		t.Fatal(err)
+	}
+	if aggregator.N() != collector.N() || aggregator.ones != collector.ones {
+		t.Errorf("Expected N = %d and %d bits set, got %d and %d",
+			collector.N(), collector.ones, aggregator.N(), aggregator.ones)
+	}
+	for _, flow := range []string{"new", "flow-0", "flow-99"} {
+		if ea, ec := aggregator.GetEstimate([]byte(flow)), collector.GetEstimate([]byte(flow)); ea != ec {
+			t.Errorf("Expected estimate %v of %s, got %v", ec, flow, ea)
+		}
+	}
+	// The delta of an unchanged sketch holds no words.
+	if empty := collector.SnapshotDelta(collector.Clone()); len(empty) > len(delta) {
+		t.Errorf("Expected an empty delta, got %d bytes", len(empty))
+	}
+}
+
+func TestApplyDeltaErrors(t *testing.T) {
+	sketch, _ := New(1<<16, 64, 32, WithHashSeed(7))
+	other, _ := New(1<<16, 64, 32, WithHashSeed(8))
+	other.Increment([]byte("flow"))
+	if err := sketch.ApplyDelta(other.SnapshotDelta(nil)); !errors.Is(err, ErrIncompatibleParams) {
+		t.Errorf("Expected ErrIncompatibleParams, got %v", err)
+	}
+	if err := sketch.ApplyDelta([]byte("garbage")); err == nil {
+		t.Error("Expected an error for a corrupt delta")
+	}
+	src, _ := New(1<<16, 64, 32, WithHashSeed(7))
+	for k := 0; k < 1000; k++ {
+		src.Increment([]byte(fmt.Sprintf("flow-%d", k)))
+	}
+	data := src.SnapshotDelta(nil)
+	if err := sketch.ApplyDelta(data[:len(data)-3]); err == nil {
+		t.Error("Expected an error for a truncated delta")
+	}
+	if sketch.N() != 0 || sketch.ones != 0 {
+		t.Errorf("Expected a failed delta not to be applied, got N = %d and %d bits set", sketch.N(), sketch.ones)
+	}
+
+	small, _ := New(100, 4, 4, WithHashSeed(7))
+	var crafted bytes.Buffer
+	crafted.Write(deltaMagic[:])
+	crafted.WriteByte(deltaVersion)
+	crafted.Write(binary.AppendUvarint(small.appendParams(nil), 0))
+	writeWords(&crafted, nil, []uint64{0, 1 << 40})
+	if err := small.ApplyDelta(crafted.Bytes()); err == nil || small.ones != 0 {
+		t.Errorf("Expected an error for bits beyond l, got %v and %d bits set", err, small.ones)
+	}
+}
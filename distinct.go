@@ -0,0 +1,101 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+)
+
+// hll is a HyperLogLog counting the distinct flows added to a sketch.
+type hll struct {
+	p         uint8
+	registers []uint8
+}
+
+func newHLL(p uint8) *hll {
+	return &hll{p: p, registers: make([]uint8, 1<<p)}
+}
+
+// add adds x and returns whether a register changed, and so the estimate.
+func (h *hll) add(x uint64) bool {
+	i := x >> (64 - h.p)
+	// The remaining bits, with a sentinel bounding the run of zeros.
+	rho := uint8(bits.LeadingZeros64(x<<h.p|1<<(h.p-1))) + 1
+	if rho > h.registers[i] {
+		h.registers[i] = rho
+		return true
+	}
+	return false
+}
+
+func (h *hll) merge(other *hll) {
+	for i, r := range other.registers {
+		if r > h.registers[i] {
+			h.registers[i] = r
+		}
+	}
+}
+
+func (h *hll) reset() {
+	for i := range h.registers {
+		h.registers[i] = 0
+	}
+}
+
+func (h *hll) clone() *hll {
+	return &hll{p: h.p, registers: append([]uint8(nil), h.registers...)}
+}
+
+func (h *hll) estimate() float64 {
+	m := float64(len(h.registers))
+	sum, zeros := 0.0, 0
+	for _, r := range h.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+	alpha := 0.7213 / (1 + 1.079/m)
+	e := alpha * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		// Linear counting is more accurate for small cardinalities.
+		return m * math.Log(m/float64(zeros))
+	}
+	return e
+}
+
+/*
+WithDistinctFlows keeps a HyperLogLog of 2^precision registers of one byte
+next to the bitmap, so that DistinctFlows estimates the number of distinct
+flows added, with a relative error of about 1.04/sqrt(2^precision). precision
+must be in [4, 18]. The HyperLogLog isn't encoded along with the sketch, and
+lock-free sketches don't support it.
+*/
+func WithDistinctFlows(precision uint8) Option {
+	return func(sketch *Sketch) error {
+		if precision < 4 || precision > 18 {
+			return fmt.Errorf("Expected precision in [4, 18], got %d", precision)
+		}
+		sketch.hll = newHLL(precision)
+		return nil
+	}
+}
+
+// flowHash returns the hash of flow counted by the HyperLogLog.
+func (sketch *Sketch) flowHash(flow []byte) uint64 {
+	return sketch.hasher.Hash(flow, sketch.hashSeed, ^sketch.hashSeed)
+}
+
+/*
+DistinctFlows returns the estimated number of distinct flows added to the
+sketch, or NaN if it wasn't created with WithDistinctFlows. Flows added with
+Increment64 are counted apart from those added with Increment.
+*/
+func (sketch *Sketch) DistinctFlows() float64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if sketch.hll == nil {
+		return math.NaN()
+	}
+	return sketch.hll.estimate()
+}
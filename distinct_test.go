@@ -0,0 +1,50 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestDistinctFlows(t *testing.T) {
+	s, err := New(1<<20, 256, 32, WithDistinctFlows(14))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := s.DistinctFlows(); d != 0 {
+		t.Error("Expected no distinct flows, got", d)
+	}
+	for _, distinct := range []int{100, 50000} {
+		s.Reset()
+		for i := 0; i < 3*distinct; i++ {
+			s.Increment([]byte(fmt.Sprint(i % distinct)))
+		}
+		s.Add([]byte("0"), 10000)
+		if d := s.DistinctFlows(); math.Abs(d-float64(distinct))/float64(distinct) > 0.05 {
+			t.Errorf("Expected about %d distinct flows, got %f", distinct, d)
+		}
+	}
+
+	other, _ := New(1<<20, 256, 32, WithDistinctFlows(14), WithHashSeed(s.HashSeed()))
+	for i := 0; i < 50000; i++ {
+		other.Increment([]byte(fmt.Sprint("other", i)))
+	}
+	s.Merge(other)
+	if d := s.DistinctFlows(); math.Abs(d-100000)/100000 > 0.05 {
+		t.Errorf("Expected about 100000 distinct flows after merging, got %f", d)
+	}
+	if d := s.Clone().DistinctFlows(); d != s.DistinctFlows() {
+		t.Error("Expected the clone to keep the distinct flows")
+	}
+
+	plain, _ := New(1<<20, 256, 32)
+	if !math.IsNaN(plain.DistinctFlows()) {
+		t.Error("Expected NaN without WithDistinctFlows")
+	}
+	if _, err := New(1<<20, 256, 32, WithDistinctFlows(3)); err == nil {
+		t.Error("Expected error for precision 3, got nil")
+	}
+	if _, err := New(1<<20, 256, 32, WithDistinctFlows(14), WithLockFree()); err == nil {
+		t.Error("Expected error for a lock-free sketch, got nil")
+	}
+}
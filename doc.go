@@ -25,5 +25,11 @@ Count-Min Sketches hold counters in a matrix-like organization. A big caveat for
 For details about the algorithm and citations please use this article:
 "High-Speed Per-Flow Traffic Measurement with Probabilistic Multiplicity Counting" by Peter Lieven & Björn Scheuermann
 (https://wwwcn.cs.uni-duesseldorf.de/publications/publications/library/Lieven2010a.pdf)
+
+Estimates hash the flow once per cell of its virtual matrix with the default
+FarmHasher. Sketches created with WithHash(TwoLevelHasher{}), or another
+FlowHasher, hash it once per estimate instead, which is about twice as fast;
+it's opt-in since the bits are placed differently, and sketches only merge
+with sketches of the same Hasher.
 */
 package pmc
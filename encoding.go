@@ -0,0 +1,454 @@
+package pmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"sync/atomic"
+)
+
+// encodingMagic starts every binary encoded sketch.
+const encodingMagic = "PMCS"
+
+// encodingVersion is the version of the binary encoding following the magic,
+// see FORMAT.md. Versions 1 and 2 are big-endian, version 1 lacks the hash
+// seed that follows n in the later ones, and versions 1 to 3 the codec that
+// follows the hash seed in version 4.
+const encodingVersion byte = 4
+
+// headerSize is the size of the magic, the version, l, m, w, n, the hash seed
+// and the codec: the fingerprints of the Hasher and GeoSampler and the flags.
+const headerSize = len(encodingMagic) + 1 + 8*8
+
+// codecSize is the size of the codec of the header.
+const codecSize = 3 * 8
+
+// The flags of the codec of the header.
+const (
+	flagColumnDropping uint64 = 1 << iota
+	flagBidirectional
+)
+
+// chunkWords is the number of bitmap words buffered at once while streaming.
+const chunkWords = 8192
+
+/*
+ErrChecksum is returned when decoding a sketch whose CRC does not match its
+content.
+*/
+var ErrChecksum = errors.New("Checksum mismatch, sketch data is corrupted")
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countingWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
+
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	cr.n += int64(n)
+	return n, err
+}
+
+/*
+WriteTo implements io.WriterTo. The sketch is streamed as a versioned header
+holding the parameters and the number of additions, followed by the bitmap
+words and a CRC-32 of everything written before it, all little-endian. The
+format is stable and documented in FORMAT.md, so that implementations in
+other languages can exchange sketches with this package. The bitmap is
+written in chunks, so no copy of the whole sketch is built in memory.
+*/
+func (sketch *Sketch) WriteTo(w io.Writer) (int64, error) {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+
+	cw := &countingWriter{w: w}
+	crc := crc32.NewIEEE()
+	mw := io.MultiWriter(cw, crc)
+
+	header := make([]byte, headerSize)
+	copy(header, encodingMagic)
+	header[4] = encodingVersion
+	binary.LittleEndian.PutUint64(header[5:], uint64(sketch.l))
+	binary.LittleEndian.PutUint64(header[13:], uint64(sketch.m))
+	binary.LittleEndian.PutUint64(header[21:], uint64(sketch.w))
+	binary.LittleEndian.PutUint64(header[29:], sketch.N())
+	binary.LittleEndian.PutUint64(header[37:], sketch.hashSeed)
+	var st State
+	st.setCodec(sketch.Params())
+	binary.LittleEndian.PutUint64(header[45:], st.Hasher)
+	binary.LittleEndian.PutUint64(header[53:], st.Sampler)
+	binary.LittleEndian.PutUint64(header[61:], st.flags())
+	if _, err := mw.Write(header); err != nil {
+		return cw.n, err
+	}
+
+	buf := make([]byte, 8*chunkWords)
+	err := sketch.forEachChunk(func(words []uint64) error {
+		for i, word := range words {
+			binary.LittleEndian.PutUint64(buf[8*i:], word)
+		}
+		_, err := mw.Write(buf[:8*len(words)])
+		return err
+	})
+	if err != nil {
+		return cw.n, err
+	}
+
+	binary.LittleEndian.PutUint32(buf, crc.Sum32())
+	_, err = cw.Write(buf[:4])
+	return cw.n, err
+}
+
+/*
+ReadFrom implements io.ReaderFrom. It replaces the state of the sketch with
+the one written by WriteTo, and never reads past the end of the encoded
+sketch. Corrupted data is rejected with ErrChecksum, and data written with
+another Hasher, GeoSampler or direction than those of the sketch with
+ErrIncompatibleParams: such data has to be read into a sketch created with
+the same WithHash, WithGeoSampler and WithBidirectional options, whose other
+parameters it replaces. The column dropping of the data is kept.
+*/
+func (sketch *Sketch) ReadFrom(r io.Reader) (int64, error) {
+	cr := &countingReader{r: r}
+	crc := crc32.NewIEEE()
+	tr := io.TeeReader(cr, crc)
+
+	header := make([]byte, headerSize)
+	if _, err := io.ReadFull(tr, header[:5]); err != nil {
+		return cr.n, err
+	}
+	if string(header[:4]) != encodingMagic {
+		return cr.n, errors.New("Invalid sketch header")
+	}
+	size := headerSize
+	var order binary.ByteOrder = binary.LittleEndian
+	switch header[4] {
+	case encodingVersion:
+	case 3:
+		size -= codecSize
+	case 2:
+		size -= codecSize
+		order = binary.BigEndian
+	case 1:
+		size -= codecSize + 8
+		order = binary.BigEndian
+	default:
+		return cr.n, fmt.Errorf("Unsupported encoding version %d", header[4])
+	}
+	if _, err := io.ReadFull(tr, header[5:size]); err != nil {
+		return cr.n, err
+	}
+
+	l := order.Uint64(header[5:])
+	m := order.Uint64(header[13:])
+	w := order.Uint64(header[21:])
+	n := order.Uint64(header[29:])
+	st := State{L: l, M: m, W: w, N: n, HashSeed: order.Uint64(header[37:])}
+	if err := checkParams(l, m, w); err != nil {
+		return cr.n, err
+	}
+	if size == headerSize {
+		st.Hasher, st.Sampler = order.Uint64(header[45:]), order.Uint64(header[53:])
+		if err := st.setFlags(order.Uint64(header[61:])); err != nil {
+			return cr.n, err
+		}
+	}
+	// The codec is checked before reading the bitmap, which would be wasted.
+	if err := sketch.checkState(st); err != nil {
+		return cr.n, err
+	}
+
+	// The bitmap grows with the words read rather than being allocated from l
+	// up front, so that a corrupted header can't exhaust the memory.
+	words := int((l + 63) / 64)
+	bitmap := make(bitArray, 0, min(words, chunkWords))
+	buf := make([]byte, 8*chunkWords)
+	for len(bitmap) < words {
+		k := min(words-len(bitmap), chunkWords)
+		if _, err := io.ReadFull(tr, buf[:8*k]); err != nil {
+			return cr.n, err
+		}
+		for i := 0; i < k; i++ {
+			bitmap = append(bitmap, order.Uint64(buf[8*i:]))
+		}
+	}
+
+	sum := crc.Sum32()
+	if _, err := io.ReadFull(cr, buf[:4]); err != nil {
+		return cr.n, err
+	}
+	if order.Uint32(buf) != sum {
+		return cr.n, ErrChecksum
+	}
+	return cr.n, sketch.load(st, bitmap)
+}
+
+// checkState returns an error if st, whose parameters are valid, can't be
+// loaded into the sketch: if the sketch is memory-mapped and st has another
+// l, or if st has a codec and the sketch another Hasher, GeoSampler or
+// direction.
+func (sketch *Sketch) checkState(st State) error {
+	if sketch.mapping != nil && uint(st.L) != sketch.l {
+		return fmt.Errorf("Expected l = %d for a memory-mapped sketch, got %d", sketch.l, st.L)
+	}
+	if st.Hasher == 0 {
+		// The state predates the codec and is read with that of the sketch.
+		return nil
+	}
+	sketch.setDefaults()
+	return sketch.checkCodec(Params{W: uint(st.W), Hasher: st.Hasher, Sampler: st.Sampler,
+		ColumnDropping: !sketch.noDrop, Bidirectional: st.Bidirectional})
+}
+
+// load replaces the state of the sketch with st and bitmap, whose parameters
+// are valid and whose words match l.
+func (sketch *Sketch) load(st State, bitmap bitArray) error {
+	if bitmap.tail(uint(st.L)) != 0 {
+		return errors.New("Expected the bits beyond l to be zero")
+	}
+	if err := sketch.checkState(st); err != nil {
+		return err
+	}
+
+	sketch.setDefaults()
+	sketch.mu.Lock()
+	sketch.l = uint(st.L)
+	sketch.m = uint(st.M)
+	sketch.w = uint(st.W)
+	atomic.StoreUint64(&sketch.n, st.N)
+	sketch.hashSeed = st.HashSeed
+	if st.Hasher != 0 {
+		sketch.noDrop = !st.ColumnDropping
+	}
+	sketch.setBitmap(bitmap)
+	sketch.mu.Unlock()
+	return nil
+}
+
+/*
+State is the state of a sketch as encoded by WriteTo, for encodings of other
+formats: its parameters, its number of additions, its codec and the words of
+its bitmap, bit i being bit i%64 of word i/64. The codec is made of the
+fingerprints of the Hasher and GeoSampler and the column dropping and
+direction of the sketch, as in Params. A Hasher of 0 stands for a state
+without codec, which is loaded with the codec of the sketch it is loaded
+into, as encodings before version 4 are.
+*/
+type State struct {
+	L, M, W, N, HashSeed          uint64
+	Hasher, Sampler               uint64
+	ColumnDropping, Bidirectional bool
+	Words                         []uint64
+}
+
+// setCodec sets the codec of st to that of p.
+func (st *State) setCodec(p Params) {
+	st.Hasher, st.Sampler = p.Hasher, p.Sampler
+	st.ColumnDropping, st.Bidirectional = p.ColumnDropping, p.Bidirectional
+}
+
+// flags returns the flags of the codec of st.
+func (st *State) flags() uint64 {
+	var flags uint64
+	if st.ColumnDropping {
+		flags |= flagColumnDropping
+	}
+	if st.Bidirectional {
+		flags |= flagBidirectional
+	}
+	return flags
+}
+
+// setFlags sets the codec of st to the flags returned by flags, failing on
+// unknown ones.
+func (st *State) setFlags(flags uint64) error {
+	if flags&^(flagColumnDropping|flagBidirectional) != 0 {
+		return fmt.Errorf("Unknown codec flags %#x", flags)
+	}
+	st.ColumnDropping, st.Bidirectional = flags&flagColumnDropping != 0, flags&flagBidirectional != 0
+	return nil
+}
+
+/*
+State returns a copy of the state of the sketch.
+*/
+func (sketch *Sketch) State() State {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	words := make([]uint64, 0, (sketch.l+63)/64)
+	sketch.forEachChunk(func(chunk []uint64) error {
+		words = append(words, chunk...)
+		return nil
+	})
+	st := State{L: uint64(sketch.l), M: uint64(sketch.m), W: uint64(sketch.w),
+		N: sketch.N(), HashSeed: sketch.hashSeed, Words: words}
+	st.setCodec(sketch.Params())
+	return st
+}
+
+/*
+FromState returns the sketch of st, as returned by State, failing if its
+parameters are invalid, if its words don't match l or if it sets bits beyond
+l, and with ErrIncompatibleParams if it has a codec other than the default
+one, in which case it has to be loaded with the LoadState method of a sketch
+created with the same options. The words are copied, so they are checked
+before anything is allocated.
+*/
+func FromState(st State) (*Sketch, error) {
+	sketch := &Sketch{}
+	if err := sketch.LoadState(st); err != nil {
+		return nil, err
+	}
+	return sketch, nil
+}
+
+/*
+LoadState replaces the state of the sketch with st, as FromState does, but
+keeps the options of the sketch, so that states of sketches created with
+another Hasher, GeoSampler or direction can be loaded into one created with
+the same options.
+*/
+func (sketch *Sketch) LoadState(st State) error {
+	if err := checkParams(st.L, st.M, st.W); err != nil {
+		return err
+	}
+	if words := (st.L + 63) / 64; uint64(len(st.Words)) != words {
+		return fmt.Errorf("Expected %d words for l = %d, got %d", words, st.L, len(st.Words))
+	}
+	return sketch.load(st, append(bitArray(nil), st.Words...))
+}
+
+/*
+MarshalBinary implements encoding.BinaryMarshaler using the format written
+by WriteTo, so that a populated sketch can be persisted and restored later
+on.
+*/
+func (sketch *Sketch) MarshalBinary() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(headerSize + 8*int((sketch.l+63)/64) + 4)
+	if _, err := sketch.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+/*
+UnmarshalBinary implements encoding.BinaryUnmarshaler. It replaces the
+state of the sketch with the one encoded in data by MarshalBinary.
+*/
+func (sketch *Sketch) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	if _, err := sketch.ReadFrom(r); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return fmt.Errorf("Truncated sketch data of %d bytes", len(data))
+		}
+		return err
+	}
+	if r.Len() != 0 {
+		return fmt.Errorf("Unexpected %d trailing bytes after sketch", r.Len())
+	}
+	return nil
+}
+
+/*
+GobEncode implements gob.GobEncoder using the binary encoding.
+*/
+func (sketch *Sketch) GobEncode() ([]byte, error) {
+	return sketch.MarshalBinary()
+}
+
+/*
+GobDecode implements gob.GobDecoder using the binary encoding.
+*/
+func (sketch *Sketch) GobDecode(data []byte) error {
+	return sketch.UnmarshalBinary(data)
+}
+
+// jsonSketch is the JSON representation of a Sketch. The bitmap words are
+// kept as big-endian bytes, which encoding/json turns into a base64 string.
+// The codec is that of State, and is missing from the JSON of older
+// versions.
+type jsonSketch struct {
+	L        uint64 `json:"l"`
+	M        uint64 `json:"m"`
+	W        uint64 `json:"w"`
+	N        uint64 `json:"n"`
+	HashSeed uint64 `json:"hash_seed,omitempty"`
+	Hasher   uint64 `json:"hasher,omitempty"`
+	Sampler  uint64 `json:"sampler,omitempty"`
+	Flags    uint64 `json:"flags,omitempty"`
+	Bitmap   []byte `json:"bitmap"`
+}
+
+/*
+MarshalJSON implements json.Marshaler. The bitmap is encoded as a base64
+string rather than an array of booleans.
+*/
+func (sketch *Sketch) MarshalJSON() ([]byte, error) {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	bits := make([]byte, 0, 8*((sketch.l+63)/64))
+	sketch.forEachChunk(func(words []uint64) error {
+		for _, word := range words {
+			bits = binary.BigEndian.AppendUint64(bits, word)
+		}
+		return nil
+	})
+	var st State
+	st.setCodec(sketch.Params())
+	return json.Marshal(jsonSketch{
+		L:        uint64(sketch.l),
+		M:        uint64(sketch.m),
+		W:        uint64(sketch.w),
+		N:        sketch.N(),
+		HashSeed: sketch.hashSeed,
+		Hasher:   st.Hasher,
+		Sampler:  st.Sampler,
+		Flags:    st.flags(),
+		Bitmap:   bits,
+	})
+}
+
+/*
+UnmarshalJSON implements json.Unmarshaler. Like ReadFrom, it fails with
+ErrIncompatibleParams on the JSON of a sketch whose Hasher, GeoSampler or
+direction differ from those of the sketch.
+*/
+func (sketch *Sketch) UnmarshalJSON(data []byte) error {
+	var js jsonSketch
+	if err := json.Unmarshal(data, &js); err != nil {
+		return err
+	}
+	if err := checkParams(js.L, js.M, js.W); err != nil {
+		return err
+	}
+	if size := 8 * ((js.L + 63) / 64); uint64(len(js.Bitmap)) != size {
+		return fmt.Errorf("Expected bitmap of %d bytes, got %d", size, len(js.Bitmap))
+	}
+
+	bitmap := newBitArray(uint(js.L))
+	for i := range bitmap {
+		bitmap[i] = binary.BigEndian.Uint64(js.Bitmap[8*i:])
+	}
+	st := State{L: js.L, M: js.M, W: js.W, N: js.N, HashSeed: js.HashSeed,
+		Hasher: js.Hasher, Sampler: js.Sampler}
+	if err := st.setFlags(js.Flags); err != nil {
+		return err
+	}
+	return sketch.load(st, bitmap)
+}
@@ -0,0 +1,451 @@
+package pmc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateFixtures = flag.Bool("update-fixtures", false, "rewrite the fixtures of testdata/compat")
+
+// populate sets a fixed pattern of bits, standing in for 1000 additions.
+func populate(s *Sketch) {
+	for i := uint(0); i < s.l; i += 3 {
+		s.setBit(i)
+	}
+	s.n = 1000
+}
+
+func TestMarshalBinary(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+
+	data, err := s.MarshalBinary()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &Sketch{}
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if r.l != s.l || r.m != s.m || r.w != s.w || r.n != s.n || r.hashSeed != s.hashSeed {
+		t.Errorf("Expected params %v %v %v %v, got %v %v %v %v",
+			s.l, s.m, s.w, s.n, r.l, r.m, r.w, r.n)
+	}
+	if !r.bitmap.equal(s.bitmap) {
+		t.Error("Expected restored bitmap to equal original")
+	}
+	if e, g := s.GetEstimate([]byte("flow")), r.GetEstimate([]byte("flow")); e != g {
+		t.Errorf("Expected estimate %f, got %f", e, g)
+	}
+}
+
+func TestUnmarshalBinaryInvalid(t *testing.T) {
+	r := &Sketch{}
+	if err := r.UnmarshalBinary([]byte{1, 2, 3}); err == nil {
+		t.Error("Expected error for truncated data, got nil")
+	}
+
+	s, _ := New(64, 2, 2)
+	data, _ := s.MarshalBinary()
+	data[4] = 42
+	if err := r.UnmarshalBinary(data); err == nil {
+		t.Error("Expected error for unknown version, got nil")
+	}
+}
+
+// encodeCrafted returns a version 3 encoding of the given parameters and
+// words, with a valid CRC.
+func encodeCrafted(l, m, w uint64, words ...uint64) []byte {
+	data := append([]byte(encodingMagic), 3)
+	for _, v := range []uint64{l, m, w, 0, 42} {
+		data = binary.LittleEndian.AppendUint64(data, v)
+	}
+	for _, word := range words {
+		data = binary.LittleEndian.AppendUint64(data, word)
+	}
+	return binary.LittleEndian.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func TestUnmarshalBinaryCrafted(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"huge l":      encodeCrafted(1<<62, 4, 4),
+		"wrapping l":  encodeCrafted(1<<64-1, 4, 4),
+		"huge m":      encodeCrafted(128, 1<<63, 4, 0, 0),
+		"m beyond l":  encodeCrafted(128, 129, 4, 0, 0),
+		"short words": encodeCrafted(1<<30, 4, 4, 0, 0),
+		"tail bits":   encodeCrafted(100, 4, 4, 0, 1<<40),
+	} {
+		var r Sketch
+		if err := r.UnmarshalBinary(data); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+	var r Sketch
+	if err := r.UnmarshalBinary(encodeCrafted(100, 4, 4, 0, 1<<35)); err != nil {
+		t.Error("Expected bits below l to be accepted, got", err)
+	}
+
+	for name, data := range map[string]string{
+		"huge l":     `{"l":4611686018427387904,"m":4,"w":4,"bitmap":""}`,
+		"wrapping l": `{"l":18446744073709551615,"m":4,"w":4,"bitmap":""}`,
+		"huge m":     `{"l":64,"m":9223372036854775808,"w":4,"bitmap":"AAAAAAAAAAA="}`,
+		"tail bits":  `{"l":8,"m":4,"w":4,"bitmap":"AAAAAAAAAQA="}`,
+	} {
+		if err := json.Unmarshal([]byte(data), new(Sketch)); err == nil {
+			t.Errorf("Expected JSON error for %s, got nil", name)
+		}
+	}
+}
+
+func TestGobEncoding(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s); err != nil {
+		t.Fatal(err)
+	}
+	r := &Sketch{}
+	if err := gob.NewDecoder(&buf).Decode(r); err != nil {
+		t.Fatal(err)
+	}
+	if r.n != s.n || !r.bitmap.equal(s.bitmap) {
+		t.Error("Expected gob decoded sketch to equal original")
+	}
+}
+
+func TestJSONEncoding(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+
+	data, err := json.Marshal(struct{ Sketch *Sketch }{s})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var r struct{ Sketch *Sketch }
+	if err := json.Unmarshal(data, &r); err != nil {
+		t.Fatal(err)
+	}
+	if r.Sketch.n != s.n || !r.Sketch.bitmap.equal(s.bitmap) {
+		t.Error("Expected JSON decoded sketch to equal original")
+	}
+}
+
+func TestFromState(t *testing.T) {
+	s, _ := New(1000, 8, 8)
+	populate(s)
+	st := s.State()
+	r, err := FromState(st)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.n != s.n || !r.bitmap.equal(s.bitmap) || r.Params() != s.Params() {
+		t.Error("Expected the sketch of its state to equal the original")
+	}
+	st.Words[0] ^= 1
+	if r.bitmap[0] == st.Words[0] || s.bitmap[0] == st.Words[0] {
+		t.Error("Expected the words to be copied")
+	}
+
+	for name, st := range map[string]State{
+		"huge l":      {L: 1 << 62, M: 4, W: 4},
+		"w = 1":       {L: 64, M: 4, W: 1, Words: make([]uint64, 1)},
+		"short words": {L: 1000, M: 4, W: 4, Words: make([]uint64, 15)},
+		"tail bits":   {L: 100, M: 4, W: 4, Words: []uint64{0, 1 << 40}},
+	} {
+		if _, err := FromState(st); err == nil {
+			t.Errorf("Expected error for %s, got nil", name)
+		}
+	}
+}
+
+func TestWriteToReadFrom(t *testing.T) {
+	s, _ := New(100000, 16, 16)
+	populate(s)
+
+	var buf bytes.Buffer
+	n, err := s.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != int64(buf.Len()) {
+		t.Errorf("Expected WriteTo to report %d bytes, got %d", buf.Len(), n)
+	}
+	buf.WriteString("trailing")
+
+	r := &Sketch{}
+	m, err := r.ReadFrom(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m != n {
+		t.Errorf("Expected ReadFrom to consume %d bytes, got %d", n, m)
+	}
+	if buf.String() != "trailing" {
+		t.Error("Expected ReadFrom to leave trailing data unread")
+	}
+	if r.n != s.n || !r.bitmap.equal(s.bitmap) {
+		t.Error("Expected streamed sketch to equal original")
+	}
+}
+
+func TestReadFromCorrupted(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+	data, _ := s.MarshalBinary()
+	data[headerSize+3] ^= 0xff
+
+	r := &Sketch{}
+	if _, err := r.ReadFrom(bytes.NewReader(data)); err != ErrChecksum {
+		t.Errorf("Expected ErrChecksum, got %v", err)
+	}
+}
+
+// encodeLegacy returns the encoding of s in version 1, 2 or 3, big-endian
+// but for version 3.
+func encodeLegacy(s *Sketch, version byte) []byte {
+	var order binary.AppendByteOrder = binary.BigEndian
+	if version == 3 {
+		order = binary.LittleEndian
+	}
+	data := append([]byte(encodingMagic), version)
+	for _, v := range []uint64{uint64(s.l), uint64(s.m), uint64(s.w), s.n} {
+		data = order.AppendUint64(data, v)
+	}
+	if version > 1 {
+		data = order.AppendUint64(data, s.hashSeed)
+	}
+	for _, word := range s.bitmap {
+		data = order.AppendUint64(data, word)
+	}
+	return order.AppendUint32(data, crc32.ChecksumIEEE(data))
+}
+
+func TestReadFromVersion1(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+
+	r := &Sketch{}
+	if err := r.UnmarshalBinary(encodeLegacy(s, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if r.hashSeed != 0 || !r.bitmap.equal(s.bitmap) {
+		t.Error("Expected version 1 sketch without hash seed")
+	}
+	if r.getPos([]byte("flow"), 1, 2) != uint(FarmHasher{}.Hash([]byte("flow"), 1, 2)%1024) {
+		t.Error("Expected version 1 sketch to hash with raw i and j")
+	}
+}
+
+func TestReadFromVersion2(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	populate(s)
+
+	for _, version := range []byte{2, 3} {
+		r := &Sketch{}
+		if err := r.UnmarshalBinary(encodeLegacy(s, version)); err != nil {
+			t.Fatal(err)
+		}
+		if r.hashSeed != s.hashSeed || r.n != s.n || !r.bitmap.equal(s.bitmap) {
+			t.Errorf("Expected version %d sketch to decode to the original", version)
+		}
+	}
+}
+
+func TestReadFromCodec(t *testing.T) {
+	xx, _ := New(1024, 8, 8, WithHash(XXHasher{}))
+	populate(xx)
+	data, _ := xx.MarshalBinary()
+	js, _ := xx.MarshalJSON()
+
+	if err := (&Sketch{}).UnmarshalBinary(data); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams decoding another Hasher, got", err)
+	}
+	if err := (&Sketch{}).UnmarshalJSON(js); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams decoding the JSON of another Hasher, got", err)
+	}
+	if _, err := FromState(xx.State()); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams loading the state of another Hasher, got", err)
+	}
+	farm, _ := New(1024, 8, 8)
+	if err := farm.Merge(xx.NewLike()); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams merging another Hasher, got", err)
+	}
+
+	// A sketch created with the same options decodes them, whatever its l.
+	r, _ := New(64, 2, 2, WithHash(XXHasher{}))
+	if err := r.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if r.l != xx.l || !r.bitmap.equal(xx.bitmap) || r.GetEstimate([]byte("flow")) != xx.GetEstimate([]byte("flow")) {
+		t.Error("Expected the sketch to decode to the original")
+	}
+	if err := xx.NewLike().UnmarshalJSON(js); err != nil {
+		t.Error("Expected the JSON to decode into a sketch like the original, got", err)
+	}
+	if err := xx.NewLike().LoadState(xx.State()); err != nil {
+		t.Error("Expected the state to load into a sketch like the original, got", err)
+	}
+
+	// Column dropping is restored, the direction has to match.
+	noDrop, _ := New(1024, 8, 8, WithColumnDropping(false))
+	data, _ = noDrop.MarshalBinary()
+	if err := r.UnmarshalBinary(data); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams decoding the default Hasher, got", err)
+	}
+	r = &Sketch{}
+	if err := r.UnmarshalBinary(data); err != nil || !r.noDrop {
+		t.Errorf("Expected column dropping to be decoded, got %v, %v", r.noDrop, err)
+	}
+	bidi, _ := New(1024, 8, 8, WithBidirectional(reverseTuple))
+	data, _ = bidi.MarshalBinary()
+	if err := (&Sketch{}).UnmarshalBinary(data); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams decoding a bidirectional sketch, got", err)
+	}
+	if err := bidi.NewLike().UnmarshalBinary(data); err != nil {
+		t.Error("Expected a bidirectional sketch to decode into one, got", err)
+	}
+
+	data[61] |= 0x80
+	binary.LittleEndian.PutUint32(data[len(data)-4:], crc32.ChecksumIEEE(data[:len(data)-4]))
+	if err := bidi.NewLike().UnmarshalBinary(data); err == nil {
+		t.Error("Expected error for unknown codec flags, got nil")
+	}
+}
+
+// compatFixture describes a sketch of testdata/compat, for implementations
+// in other languages to check their decoding, hashing and estimates against.
+type compatFixture struct {
+	File      string           `json:"file"`
+	Version   byte             `json:"version"`
+	L         uint64           `json:"l"`
+	M         uint64           `json:"m"`
+	W         uint64           `json:"w"`
+	N         uint64           `json:"n"`
+	HashSeed  uint64           `json:"hash_seed,string"`
+	Ones      uint64           `json:"ones"`
+	Positions []compatPosition `json:"positions"`
+	Estimates []compatEstimate `json:"estimates"`
+}
+
+type compatPosition struct {
+	Flow   string `json:"flow"`
+	Row    uint   `json:"row"`
+	Column uint   `json:"column"`
+	Pos    uint   `json:"pos"`
+}
+
+type compatEstimate struct {
+	Flow     string  `json:"flow"`
+	Estimate float64 `json:"estimate"`
+}
+
+// compatSketches returns the sketches of the fixtures, by file name.
+func compatSketches() (names []string, sketches []*Sketch) {
+	empty, _ := New(64, 2, 2, WithHashSeed(1))
+	small, _ := New(1000, 16, 16, WithDeterministic(1))
+	for k := 0; k < 300; k++ {
+		small.Increment([]byte(fmt.Sprintf("flow-%d", k%3)))
+	}
+	zipf, _ := New(1<<16, 64, 32, WithDeterministic(42))
+	for i := 0; i < 20; i++ {
+		zipf.Add([]byte(fmt.Sprintf("flow-%d", i)), uint64(math.Ceil(2000/float64(i+1))))
+	}
+	return []string{"empty", "small", "zipf"}, []*Sketch{empty, small, zipf}
+}
+
+func writeCompatFixtures(t *testing.T, dir string) {
+	var fixtures []compatFixture
+	names, sketches := compatSketches()
+	for i, s := range sketches {
+		data, _ := s.MarshalBinary()
+		for _, enc := range []struct {
+			name    string
+			version byte
+			data    []byte
+		}{
+			{names[i] + ".pmc", encodingVersion, data},
+			{names[i] + "-v3.pmc", 3, encodeLegacy(s, 3)},
+			{names[i] + "-v2.pmc", 2, encodeLegacy(s, 2)},
+		} {
+			if err := os.WriteFile(filepath.Join(dir, enc.name), enc.data, 0644); err != nil {
+				t.Fatal(err)
+			}
+			f := compatFixture{File: enc.name, Version: enc.version,
+				L: uint64(s.l), M: uint64(s.m), W: uint64(s.w), N: s.N(), HashSeed: s.hashSeed, Ones: s.ones}
+			for row := uint(0); row < 3 && row < s.m; row++ {
+				for col := uint(0); col < 3 && col < s.w; col++ {
+					f.Positions = append(f.Positions, compatPosition{"flow-0", row, col, s.getPos([]byte("flow-0"), row, col)})
+				}
+			}
+			for _, flow := range []string{"flow-0", "flow-1", "flow-2", "flow-19", "unseen"} {
+				f.Estimates = append(f.Estimates, compatEstimate{flow, s.GetEstimate([]byte(flow))})
+			}
+			fixtures = append(fixtures, f)
+		}
+	}
+	data, _ := json.MarshalIndent(fixtures, "", "  ")
+	if err := os.WriteFile(filepath.Join(dir, "fixtures.json"), append(data, '\n'), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestCompatFixtures checks the sketches of testdata/compat, which pin the
+// wire format of FORMAT.md. go test -update-fixtures rewrites them.
+func TestCompatFixtures(t *testing.T) {
+	dir := filepath.Join("testdata", "compat")
+	if *updateFixtures {
+		writeCompatFixtures(t, dir)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "fixtures.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fixtures []compatFixture
+	if err := json.Unmarshal(data, &fixtures); err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range fixtures {
+		data, err := os.ReadFile(filepath.Join(dir, f.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := &Sketch{}
+		if err := s.UnmarshalBinary(data); err != nil {
+			t.Errorf("%s: %v", f.File, err)
+			continue
+		}
+		if uint64(s.l) != f.L || uint64(s.m) != f.M || uint64(s.w) != f.W ||
+			s.N() != f.N || s.hashSeed != f.HashSeed || s.ones != f.Ones {
+			t.Errorf("%s: expected l=%d m=%d w=%d n=%d hash seed %d and %d bits set, got %d %d %d %d %d and %d",
+				f.File, f.L, f.M, f.W, f.N, f.HashSeed, f.Ones, s.l, s.m, s.w, s.N(), s.hashSeed, s.ones)
+		}
+		for _, p := range f.Positions {
+			if pos := s.getPos([]byte(p.Flow), p.Row, p.Column); pos != p.Pos {
+				t.Errorf("%s: expected position %d of %s at (%d, %d), got %d", f.File, p.Pos, p.Flow, p.Row, p.Column, pos)
+			}
+		}
+		for _, e := range f.Estimates {
+			if est := s.GetEstimate([]byte(e.Flow)); math.Abs(est-e.Estimate) > 1e-9*math.Max(1, e.Estimate) {
+				t.Errorf("%s: expected estimate %v of %s, got %v", f.File, e.Estimate, e.Flow, est)
+			}
+		}
+		// The current version is written back byte for byte.
+		if f.Version == encodingVersion {
+			if again, _ := s.MarshalBinary(); !bytes.Equal(again, data) {
+				t.Errorf("%s: expected the encoding to round-trip", f.File)
+			}
+		}
+	}
+}
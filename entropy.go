@@ -0,0 +1,130 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"sync"
+)
+
+/*
+EntropyEstimator estimates the entropy of the traffic mix of a sketch, the
+Shannon entropy of the shares of the additions of its flows, a collapse of
+which is a standard indicator of attacks concentrating the traffic on few
+flows. The entropy of N additions is
+
+	H = log2(N) - sum(n_i log2(n_i)) / N
+
+where n_i are the counts of the flows. Both sums over the flows are
+estimated as those of the maxKeys largest flows, tracked as by HeavyHitters,
+plus those of a sample of the others, the at most maxKeys flows of lowest
+salted hash, weighted by the inverse of the sampling rate, the counts being
+estimated by the sketch. Taking N as the sum of the estimates rather than
+the number of additions cancels out most of the error of the estimates.
+Flows added to the sketch behind the back of the estimator are neither
+tracked nor sampled, so the sketch should only be fed through it. An
+EntropyEstimator is safe for concurrent use.
+*/
+type EntropyEstimator struct {
+	mu     sync.Mutex
+	sketch *Sketch
+	salt   uint64
+	// keys are the sampled flows, with their hashes below or at threshold.
+	keys      map[string]uint64
+	threshold uint64
+	maxKeys   int
+	heavy     hitterHeap
+}
+
+/*
+NewEntropyEstimator returns an EntropyEstimator of the traffic added to
+sketch, sampling up to maxKeys flows.
+*/
+func NewEntropyEstimator(sketch *Sketch, maxKeys int) (*EntropyEstimator, error) {
+	if maxKeys <= 0 {
+		return nil, fmt.Errorf("Expected maxKeys > 0, got %d", maxKeys)
+	}
+	return &EntropyEstimator{sketch: sketch, salt: mix64(sketch.hashSeed + 2),
+		keys: make(map[string]uint64), threshold: math.MaxUint64, maxKeys: maxKeys,
+		heavy: hitterHeap{index: make(map[string]int)}}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (e *EntropyEstimator) Increment(flow []byte) {
+	e.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (e *EntropyEstimator) Add(flow []byte, weight uint64) {
+	e.sketch.Add(flow, weight)
+	flow, buf := e.sketch.key(flow)
+	defer releaseKey(buf)
+	h := e.sketch.hasher.Hash(flow, e.salt, ^e.salt)
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.heavy.offer(flow, e.sketch.GetEstimate(flow), e.maxKeys)
+	if h > e.threshold {
+		return
+	}
+	if _, ok := e.keys[string(flow)]; ok {
+		return
+	}
+	e.keys[string(flow)] = h
+	// Halve the sampling rate until the sample fits.
+	for len(e.keys) > e.maxKeys {
+		e.threshold >>= 1
+		for key, kh := range e.keys {
+			if kh > e.threshold {
+				delete(e.keys, key)
+			}
+		}
+	}
+}
+
+/*
+EstimateEntropy returns the estimated entropy of the traffic mix in bits,
+between 0 when a single flow carries all the traffic and log2 of the number
+of flows when they all carry the same share, or 0 if nothing was added.
+*/
+func (e *EntropyEstimator) EstimateEntropy() float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.sketch.N() == 0 {
+		return 0
+	}
+	// The counts and terms of the heavy flows, and of the sampled others.
+	var heavy, sampled [2]float64
+	for _, hh := range e.heavy.items {
+		addEntropy(&heavy, e.sketch.GetEstimate([]byte(hh.Flow)))
+	}
+	for key := range e.keys {
+		if _, ok := e.heavy.index[key]; !ok {
+			addEntropy(&sampled, e.sketch.GetEstimate([]byte(key)))
+		}
+	}
+	rate := math.Ldexp(float64(e.threshold), -64)
+	n := heavy[0] + sampled[0]/rate
+	if n <= 1 {
+		return 0
+	}
+	h := math.Log2(n) - (heavy[1]+sampled[1]/rate)/n
+	return math.Max(0, math.Min(h, math.Log2(n)))
+}
+
+// addEntropy adds the count n of a flow and its term of the entropy to sums.
+func addEntropy(sums *[2]float64, n float64) {
+	sums[0] += n
+	if n > 1 {
+		sums[1] += n * math.Log2(n)
+	}
+}
+
+/*
+Sketch returns the underlying sketch.
+*/
+func (e *EntropyEstimator) Sketch() *Sketch {
+	return e.sketch
+}
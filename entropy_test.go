@@ -0,0 +1,44 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestEstimateEntropy(t *testing.T) {
+	uniform, _ := New(1<<20, 64, 32, WithHashSeed(3))
+	e, err := NewEntropyEstimator(uniform, 256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if h := e.EstimateEntropy(); h != 0 {
+		t.Errorf("Expected entropy 0 without additions, got %v", h)
+	}
+	for i := 0; i < 2000; i++ {
+		e.Add([]byte(fmt.Sprintf("flow-%d", i)), 100)
+	}
+	if h, want := e.EstimateEntropy(), math.Log2(2000); math.Abs(h-want) > 0.5 {
+		t.Errorf("Expected an entropy of about %v for a uniform mix, got %v", want, h)
+	}
+	if len(e.keys) > 256 {
+		t.Errorf("Expected at most 256 sampled flows, got %d", len(e.keys))
+	}
+
+	// A flood of one flow collapses the entropy.
+	attacked, _ := New(1<<20, 64, 32, WithHashSeed(3))
+	a, _ := NewEntropyEstimator(attacked, 256)
+	for i := 0; i < 2000; i++ {
+		a.Add([]byte(fmt.Sprintf("flow-%d", i)), 100)
+	}
+	a.Add([]byte("flood"), 2000000)
+	p := 2000000.0 / 2200000
+	want := -p*math.Log2(p) - (1-p)*math.Log2((1-p)/2000)
+	if h := a.EstimateEntropy(); math.Abs(h-want) > 0.5 {
+		t.Errorf("Expected an entropy of about %v under attack, got %v", want, h)
+	}
+
+	if _, err := NewEntropyEstimator(uniform, 0); err == nil {
+		t.Error("Expected an error for maxKeys = 0")
+	}
+}
@@ -0,0 +1,22 @@
+package pmc
+
+import "expvar"
+
+/*
+Expvar returns an expvar.Var exporting the parameters, the number of
+additions and the fill rate of the sketch as a JSON object, to be published
+with expvar.Publish.
+*/
+func (sketch *Sketch) Expvar() expvar.Var {
+	return expvar.Func(func() interface{} {
+		sketch.mu.Lock()
+		defer sketch.mu.Unlock()
+		return map[string]interface{}{
+			"l":         sketch.l,
+			"m":         sketch.m,
+			"w":         sketch.w,
+			"n":         sketch.N(),
+			"fill_rate": sketch.getP() * 100,
+		}
+	})
+}
@@ -0,0 +1,27 @@
+package pmc
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestExpvar(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	for i := 0; i < 10; i++ {
+		s.Increment([]byte("flow"))
+	}
+
+	var vars struct {
+		L, M, W, N uint64
+		FillRate   float64 `json:"fill_rate"`
+	}
+	if err := json.Unmarshal([]byte(s.Expvar().String()), &vars); err != nil {
+		t.Fatal(err)
+	}
+	if vars.L != 1024 || vars.M != 8 || vars.W != 8 || vars.N != 10 {
+		t.Error("Expected l, m, w, n == 1024, 8, 8, 10, got", vars)
+	}
+	if vars.FillRate != s.GetFillRate() {
+		t.Errorf("Expected fill rate %f, got %f", s.GetFillRate(), vars.FillRate)
+	}
+}
@@ -0,0 +1,173 @@
+/*
+Package flowkey builds the keys of common network flows, to be counted by
+PMC sketches, without allocating: keys are appended to a buffer supplied by
+the caller, which can be reused from one packet to the next.
+
+	var buf [flowkey.MaxLen]byte
+	t := flowkey.FiveTuple{Proto: 6, Src: src, Dst: dst, SrcPort: 51234, DstPort: 443}
+	sketch.Increment(t.Append(buf[:0]))
+
+	// Both directions of a connection have the same key.
+	sketch.Increment(t.Canonical().Append(buf[:0]))
+
+Addresses are appended as their 4 or 16 bytes, IPv4-mapped IPv6 addresses
+as IPv4 ones, and ports in big-endian order, so the keys of different kinds
+of flows have different lengths, and don't collide as long as a sketch
+counts flows of one kind.
+*/
+package flowkey
+
+import (
+	"net"
+	"net/netip"
+)
+
+/*
+MaxLen is the length of the longest key, an IPv6 5-tuple, and thus the size
+of a buffer fitting any key.
+*/
+const MaxLen = 1 + 2*16 + 2*2
+
+/*
+FiveTuple is the protocol, source and destination addresses and ports of a
+flow. Src and Dst must both be IPv4 or IPv6 addresses.
+*/
+type FiveTuple struct {
+	Proto            uint8
+	Src, Dst         netip.Addr
+	SrcPort, DstPort uint16
+}
+
+/*
+Append appends the key of the 5-tuple to b: the protocol, the source and
+destination addresses, and the source and destination ports.
+*/
+func (t FiveTuple) Append(b []byte) []byte {
+	b = append(b, t.Proto)
+	b = AppendAddr(b, t.Src)
+	b = AppendAddr(b, t.Dst)
+	b = AppendPort(b, t.SrcPort)
+	return AppendPort(b, t.DstPort)
+}
+
+/*
+Canonical returns the 5-tuple with its endpoints sorted, the lowest address,
+or the lowest port for equal addresses, being the source, so that both
+directions of a flow have the same canonical 5-tuple.
+*/
+func (t FiveTuple) Canonical() FiveTuple {
+	src, dst := t.Src.Unmap(), t.Dst.Unmap()
+	if c := src.Compare(dst); c > 0 || c == 0 && t.SrcPort > t.DstPort {
+		t.Src, t.Dst = t.Dst, t.Src
+		t.SrcPort, t.DstPort = t.DstPort, t.SrcPort
+	}
+	return t
+}
+
+/*
+Reverse returns the 5-tuple of the opposite direction of the flow.
+*/
+func (t FiveTuple) Reverse() FiveTuple {
+	t.Src, t.Dst = t.Dst, t.Src
+	t.SrcPort, t.DstPort = t.DstPort, t.SrcPort
+	return t
+}
+
+/*
+ReverseFiveTuple appends to dst the key of the opposite direction of key, a
+key appended by FiveTuple.Append, or key itself if it isn't one. It is the
+reverse function of pmc.WithBidirectional for sketches of 5-tuples.
+*/
+func ReverseFiveTuple(dst, key []byte) []byte {
+	var size int
+	switch len(key) {
+	case 1 + 2*4 + 2*2:
+		size = 4
+	case MaxLen:
+		size = 16
+	default:
+		return append(dst, key...)
+	}
+	src, dstAddr, ports := key[1:1+size], key[1+size:1+2*size], key[1+2*size:]
+	dst = append(dst, key[0])
+	dst = append(dst, dstAddr...)
+	dst = append(dst, src...)
+	return append(dst, ports[2], ports[3], ports[0], ports[1])
+}
+
+/*
+AppendFiveTuple4 appends the key of an IPv4 5-tuple to b, as
+FiveTuple.Append does.
+*/
+func AppendFiveTuple4(b []byte, proto uint8, src, dst [4]byte, srcPort, dstPort uint16) []byte {
+	b = append(b, proto)
+	b = append(b, src[:]...)
+	b = append(b, dst[:]...)
+	b = AppendPort(b, srcPort)
+	return AppendPort(b, dstPort)
+}
+
+/*
+AppendFiveTuple6 appends the key of an IPv6 5-tuple to b, as
+FiveTuple.Append does.
+*/
+func AppendFiveTuple6(b []byte, proto uint8, src, dst [16]byte, srcPort, dstPort uint16) []byte {
+	b = append(b, proto)
+	b = append(b, src[:]...)
+	b = append(b, dst[:]...)
+	b = AppendPort(b, srcPort)
+	return AppendPort(b, dstPort)
+}
+
+/*
+AppendAddr appends the key of an address to b, its 4 bytes for IPv4 and
+IPv4-mapped IPv6 addresses, and its 16 bytes for IPv6 ones. The zone and
+invalid addresses append nothing. It keys flows by source or destination
+address.
+*/
+func AppendAddr(b []byte, addr netip.Addr) []byte {
+	addr = addr.Unmap()
+	switch {
+	case addr.Is4():
+		a := addr.As4()
+		return append(b, a[:]...)
+	case addr.Is6():
+		a := addr.As16()
+		return append(b, a[:]...)
+	}
+	return b
+}
+
+/*
+AppendPort appends the key of a port to b, its 2 big-endian bytes.
+*/
+func AppendPort(b []byte, port uint16) []byte {
+	return append(b, byte(port>>8), byte(port))
+}
+
+/*
+AppendProtoPort appends the key of a port of a protocol to b, so that e.g.
+TCP and UDP port 53 are counted apart. It keys flows by destination port.
+*/
+func AppendProtoPort(b []byte, proto uint8, port uint16) []byte {
+	return AppendPort(append(b, proto), port)
+}
+
+/*
+AppendMAC appends the key of a hardware address to b, its bytes.
+*/
+func AppendMAC(b []byte, mac net.HardwareAddr) []byte {
+	return append(b, mac...)
+}
+
+/*
+AppendMACPair appends the key of the frames between two hardware addresses
+to b. If canonical, the lowest address comes first, so that both directions
+have the same key.
+*/
+func AppendMACPair(b []byte, src, dst net.HardwareAddr, canonical bool) []byte {
+	if canonical && string(src) > string(dst) {
+		src, dst = dst, src
+	}
+	return append(append(b, src...), dst...)
+}
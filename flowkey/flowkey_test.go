@@ -0,0 +1,104 @@
+package flowkey
+
+import (
+	"bytes"
+	"net"
+	"net/netip"
+	"testing"
+)
+
+func TestFiveTuple(t *testing.T) {
+	ft := FiveTuple{Proto: 6, Src: netip.MustParseAddr("10.0.0.2"), Dst: netip.MustParseAddr("10.0.0.1"),
+		SrcPort: 51234, DstPort: 443}
+	want := []byte{6, 10, 0, 0, 2, 10, 0, 0, 1, 0xc8, 0x22, 0x01, 0xbb}
+	if key := ft.Append(nil); !bytes.Equal(key, want) {
+		t.Errorf("Expected key %v, got %v", want, key)
+	}
+	if key := AppendFiveTuple4(nil, 6, [4]byte{10, 0, 0, 2}, [4]byte{10, 0, 0, 1}, 51234, 443); !bytes.Equal(key, want) {
+		t.Errorf("Expected key %v, got %v", want, key)
+	}
+	mapped := ft
+	mapped.Src = netip.AddrFrom16(ft.Src.As16())
+	if key := mapped.Append(nil); !bytes.Equal(key, want) {
+		t.Errorf("Expected IPv4-mapped addresses to be keyed as IPv4, got %v", key)
+	}
+
+	six := FiveTuple{Proto: 17, Src: netip.MustParseAddr("2001:db8::1"), Dst: netip.MustParseAddr("2001:db8::2"),
+		SrcPort: 53, DstPort: 5353}
+	if key := six.Append(nil); len(key) != MaxLen ||
+		!bytes.Equal(key, AppendFiveTuple6(nil, 17, six.Src.As16(), six.Dst.As16(), 53, 5353)) {
+		t.Errorf("Expected an IPv6 key of %d bytes, got %v", MaxLen, key)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	ft := FiveTuple{Proto: 6, Src: netip.MustParseAddr("10.0.0.2"), Dst: netip.MustParseAddr("10.0.0.1"),
+		SrcPort: 51234, DstPort: 443}
+	c := ft.Canonical()
+	if c != ft.Reverse() || ft.Reverse().Canonical() != c {
+		t.Errorf("Expected both directions to have the canonical 5-tuple %v, got %v and %v",
+			ft.Reverse(), c, ft.Reverse().Canonical())
+	}
+	same := FiveTuple{Proto: 17, Src: ft.Src, Dst: ft.Src, SrcPort: 2000, DstPort: 1000}
+	if c := same.Canonical(); c.SrcPort != 1000 || same.Reverse().Canonical() != c {
+		t.Errorf("Expected endpoints of the same address to be sorted by port, got %v", c)
+	}
+
+	for _, ft := range []FiveTuple{ft, {Proto: 17, Src: netip.MustParseAddr("2001:db8::2"),
+		Dst: netip.MustParseAddr("2001:db8::1"), SrcPort: 53, DstPort: 5353}} {
+		key := ft.Append(nil)
+		if rev := ReverseFiveTuple(nil, key); !bytes.Equal(rev, ft.Reverse().Append(nil)) {
+			t.Errorf("Expected the reverse key %v, got %v", ft.Reverse().Append(nil), rev)
+		}
+		// The lowest of both keys is the canonical one.
+		low := key
+		if rev := ReverseFiveTuple(nil, key); bytes.Compare(rev, low) < 0 {
+			low = rev
+		}
+		if !bytes.Equal(low, ft.Canonical().Append(nil)) {
+			t.Errorf("Expected the lowest key to be canonical, got %v", low)
+		}
+	}
+	if rev := ReverseFiveTuple(nil, []byte{1, 2, 3}); !bytes.Equal(rev, []byte{1, 2, 3}) {
+		t.Errorf("Expected other keys to be their own reverse, got %v", rev)
+	}
+
+	a, _ := net.ParseMAC("00:00:5e:00:53:02")
+	b, _ := net.ParseMAC("00:00:5e:00:53:01")
+	if !bytes.Equal(AppendMACPair(nil, a, b, true), AppendMACPair(nil, b, a, true)) {
+		t.Error("Expected both directions to have the same canonical MAC pair")
+	}
+	if bytes.Equal(AppendMACPair(nil, a, b, false), AppendMACPair(nil, b, a, false)) {
+		t.Error("Expected directions to have different MAC pairs")
+	}
+	if key := AppendMAC(nil, a); !bytes.Equal(key, a) {
+		t.Errorf("Expected key %v, got %v", []byte(a), key)
+	}
+}
+
+func TestKeys(t *testing.T) {
+	if key := AppendProtoPort(nil, 17, 53); !bytes.Equal(key, []byte{17, 0, 53}) {
+		t.Errorf("Expected key [17 0 53], got %v", key)
+	}
+	if key := AppendAddr(nil, netip.Addr{}); len(key) != 0 {
+		t.Errorf("Expected no key for an invalid address, got %v", key)
+	}
+	if key := AppendAddr(nil, netip.MustParseAddr("fe80::1%eth0")); len(key) != 16 {
+		t.Errorf("Expected the zone not to be keyed, got %v", key)
+	}
+}
+
+func TestAllocs(t *testing.T) {
+	ft := FiveTuple{Proto: 17, Src: netip.MustParseAddr("2001:db8::1"), Dst: netip.MustParseAddr("2001:db8::2"),
+		SrcPort: 53, DstPort: 5353}
+	mac, _ := net.ParseMAC("00:00:5e:00:53:01")
+	buf := make([]byte, 0, MaxLen)
+	allocs := testing.AllocsPerRun(100, func() {
+		buf = ft.Canonical().Append(buf[:0])
+		buf = AppendProtoPort(buf[:0], 6, 443)
+		buf = AppendMACPair(buf[:0], mac, mac, true)
+	})
+	if allocs != 0 {
+		t.Errorf("Expected no allocations, got %v", allocs)
+	}
+}
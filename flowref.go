@@ -0,0 +1,85 @@
+package pmc
+
+/*
+FlowRef is a flow prepared by PrepareFlow. It caches the positions of the
+flow's whole virtual matrix, so that incrementing or estimating it doesn't
+hash the flow again. A FlowRef takes m*w words and is only valid for the
+sketch that prepared it, as long as the sketch isn't decoded into. It keeps
+working after Fold, which leaves every flow on its positions modulo the new
+l; the sketch returned by Rebuild has other positions, so its flows must be
+prepared again.
+*/
+type FlowRef struct {
+	sketch *Sketch
+	pos    []uint
+	// l is the length of the bitmap the positions were computed for.
+	l    uint
+	hash uint64
+	// flow is a copy of the flow, passed to the hooks of the sketch.
+	flow []byte
+}
+
+/*
+PrepareFlow returns a FlowRef for repeatedly incrementing or estimating a hot
+flow.
+*/
+func (sketch *Sketch) PrepareFlow(flow []byte) *FlowRef {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+
+	ref := &FlowRef{sketch: sketch, pos: make([]uint, sketch.m*sketch.w), l: sketch.l}
+	if sketch.hooks != nil {
+		ref.flow = append([]byte(nil), flow...)
+	}
+	flow, buf := sketch.key(flow)
+	defer releaseKey(buf)
+	ref.hash = sketch.flowHash(flow)
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			ref.pos[i*sketch.w+j] = sketch.getPos(flow, i, j)
+		}
+	}
+	return ref
+}
+
+func (ref *FlowRef) getPos(i, j uint) uint {
+	pos := ref.pos[i*ref.sketch.w+j]
+	if ref.l != ref.sketch.l {
+		// The sketch was folded since the flow was prepared.
+		pos %= ref.sketch.l
+	}
+	return pos
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (ref *FlowRef) Increment() {
+	sketch := ref.sketch
+	sketch.mu.Lock()
+	if sketch.hll != nil {
+		sketch.hll.add(ref.hash)
+	}
+	if i, j, ok := sketch.sample(); ok {
+		sketch.setBit(ref.getPos(i, j))
+	}
+	sketch.mu.Unlock()
+	if sketch.hooks != nil {
+		sketch.incremented(ref.flow, 1)
+	}
+}
+
+/*
+GetEstimate returns the estimated count of the flow
+*/
+func (ref *FlowRef) GetEstimate() float64 {
+	sketch := ref.sketch
+	sketch.mu.Lock()
+	n, p := float64(sketch.N()), sketch.getP()
+	e, _ := sketch.estimate(ref.getPos, p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	sketch.mu.Unlock()
+	sketch.estimated(ref.flow, e)
+	return e
+}
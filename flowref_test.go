@@ -0,0 +1,51 @@
+package pmc
+
+import "testing"
+
+func TestFlowRef(t *testing.T) {
+	a, _ := New(1<<20, 64, 32, WithSeed(7))
+	b, _ := New(1<<20, 64, 32, WithSeed(7))
+	ref := b.PrepareFlow([]byte("flow"))
+
+	for i := 0; i < 10000; i++ {
+		a.Increment([]byte("flow"))
+		ref.Increment()
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected FlowRef increments to match Increment")
+	}
+	if e, g := a.GetEstimate([]byte("flow")), ref.GetEstimate(); e != g {
+		t.Errorf("Expected FlowRef estimate %f, got %f", e, g)
+	}
+}
+
+func TestFlowRefFold(t *testing.T) {
+	a, _ := New(1<<16, 64, 32, WithSeed(7))
+	b, _ := New(1<<16, 64, 32, WithSeed(7))
+	ref := b.PrepareFlow([]byte("flow"))
+	for _, s := range []*Sketch{a, b} {
+		s.Add([]byte("flow"), 1000)
+		if err := s.Fold(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		a.Increment([]byte("flow"))
+		ref.Increment()
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected FlowRef increments to match Increment after Fold")
+	}
+	if e, g := a.GetEstimate([]byte("flow")), ref.GetEstimate(); e != g {
+		t.Errorf("Expected FlowRef estimate %f after Fold, got %f", e, g)
+	}
+}
+
+func BenchmarkFlowRefIncrement(b *testing.B) {
+	s, _ := New(8000000, 256, 64)
+	ref := s.PrepareFlow([]byte("flow"))
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		ref.Increment()
+	}
+}
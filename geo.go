@@ -0,0 +1,144 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+/*
+GeoSampler picks the column of the virtual matrix an addition sets, from a
+uniformly distributed 64-bit random value. Columns are expected to follow a
+geometric distribution, and Tail gives the distribution to the parts of the
+sketch that compute with it, such as the phi correction of the estimator and
+the virtual additions of IncrementN. Sketches use BinarySampler unless
+created with WithGeoSampler.
+
+The estimator of the PMC paper is derived for BinarySampler: other samplers
+are meant for experiments with the distribution of the columns, and their
+estimates are only calibrated through phi.
+*/
+type GeoSampler interface {
+	// Sample returns the column picked by the random value r. It isn't
+	// bounded by the number of columns, larger columns are clamped to the
+	// last one by the sketch.
+	Sample(r uint64) uint
+	// Tail returns the probability of Sample returning j or more, so that
+	// Tail(0) == 1.
+	Tail(j uint) float64
+}
+
+/*
+BinarySampler is the default GeoSampler of the PMC paper, picking column j
+with probability 2^-(j+1) from the leading zeros of the random value.
+*/
+type BinarySampler struct{}
+
+/*
+Sample implements GeoSampler.
+*/
+func (BinarySampler) Sample(r uint64) uint {
+	return uint(bits.LeadingZeros64(r))
+}
+
+/*
+Tail implements GeoSampler.
+*/
+func (BinarySampler) Tail(j uint) float64 {
+	return math.Ldexp(1, -int(j))
+}
+
+/*
+TableSampler is a GeoSampler of any distribution over the MaxW columns,
+given by its tail: Sample looks the random value up in a table of
+thresholds, in O(log(MaxW)).
+*/
+type TableSampler struct {
+	// thresholds[j] is Tail(j+1) scaled to [0, 2^64].
+	thresholds [MaxW]uint64
+	tail       func(j uint) float64
+}
+
+/*
+NewTableSampler returns a TableSampler of the distribution with the given
+tail, which must be non-increasing from tail(0) == 1.
+*/
+func NewTableSampler(tail func(j uint) float64) (*TableSampler, error) {
+	if tail == nil {
+		return nil, errors.New("Expected a non nil tail")
+	}
+	if t := tail(0); t != 1 {
+		return nil, fmt.Errorf("Expected tail(0) == 1, got %v", t)
+	}
+	s := &TableSampler{tail: tail}
+	prev := 1.0
+	for j := range s.thresholds {
+		t := tail(uint(j + 1))
+		if t < 0 || t > prev {
+			return nil, fmt.Errorf("Expected a non-increasing tail in [0, 1], got %v at %d", t, j+1)
+		}
+		prev = t
+		if t >= 1 {
+			s.thresholds[j] = math.MaxUint64
+		} else {
+			s.thresholds[j] = uint64(math.Ldexp(t, 64))
+		}
+	}
+	return s, nil
+}
+
+/*
+NewGeometricSampler returns a TableSampler picking column j with probability
+(1-1/base)*base^-j, of which BinarySampler is the case of base 2.
+*/
+func NewGeometricSampler(base float64) (*TableSampler, error) {
+	if !(base > 1) || math.IsInf(base, 0) {
+		return nil, fmt.Errorf("Expected base > 1, got %v", base)
+	}
+	return NewTableSampler(func(j uint) float64 {
+		return math.Pow(base, -float64(j))
+	})
+}
+
+/*
+Sample implements GeoSampler.
+*/
+func (s *TableSampler) Sample(r uint64) uint {
+	// The thresholds are non-increasing, the column is the number of them
+	// above r.
+	return uint(sort.Search(len(s.thresholds), func(j int) bool {
+		return r >= s.thresholds[j]
+	}))
+}
+
+/*
+Tail implements GeoSampler.
+*/
+func (s *TableSampler) Tail(j uint) float64 {
+	return s.tail(j)
+}
+
+/*
+WithGeoSampler makes the sketch pick columns with g instead of
+BinarySampler. Sketches meant to be merged must share it.
+*/
+func WithGeoSampler(g GeoSampler) Option {
+	return func(sketch *Sketch) error {
+		if g == nil {
+			return errors.New("Expected non-nil GeoSampler")
+		}
+		sketch.sampler = g
+		return nil
+	}
+}
+
+// columnProb returns the probability of georand picking column j, the last
+// column taking the whole tail.
+func (sketch *Sketch) columnProb(j uint) float64 {
+	if j == sketch.w-1 {
+		return sketch.sampler.Tail(j)
+	}
+	return sketch.sampler.Tail(j) - sketch.sampler.Tail(j+1)
+}
@@ -0,0 +1,64 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBinarySampler(t *testing.T) {
+	var s BinarySampler
+	if j := s.Sample(1 << 63); j != 0 {
+		t.Error("Expected column 0 for a leading 1, got", j)
+	}
+	if j := s.Sample(1); j != 63 {
+		t.Error("Expected column 63 for 63 leading zeros, got", j)
+	}
+	if tail := s.Tail(3); tail != 0.125 {
+		t.Error("Expected tail 0.125 for column 3, got", tail)
+	}
+}
+
+func TestGeometricSampler(t *testing.T) {
+	binary, err := NewGeometricSampler(2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range []uint64{math.MaxUint64, 1 << 63, 1<<63 - 1, 1 << 40, 12345, 1} {
+		if a, b := binary.Sample(r), (BinarySampler{}).Sample(r); a != b {
+			t.Errorf("Expected column %d of %x for base 2, got %d", b, r, a)
+		}
+	}
+
+	s, err := NewGeometricSampler(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sketch, _ := New(1<<16, 64, 32, WithSeed(7), WithGeoSampler(s))
+	counts := make([]int, 4)
+	for i := 0; i < 100000; i++ {
+		if j := sketch.georand(sketch.w); j < 4 {
+			counts[j]++
+		}
+	}
+	for j, c := range counts {
+		want := 100000 * 0.75 * math.Pow(4, -float64(j))
+		if math.Abs(float64(c)-want) > 5*math.Sqrt(want) {
+			t.Errorf("Expected about %.0f draws of column %d, got %d", want, j, c)
+		}
+	}
+	if p := sketch.columnProb(1); math.Abs(p-0.1875) > 1e-12 {
+		t.Error("Expected probability 0.1875 for column 1, got", p)
+	}
+
+	for _, base := range []float64{0, 1, math.NaN(), math.Inf(1)} {
+		if _, err := NewGeometricSampler(base); err == nil {
+			t.Errorf("Expected error for base %v, got nil", base)
+		}
+	}
+	if _, err := NewTableSampler(func(j uint) float64 { return float64(j) }); err == nil {
+		t.Error("Expected error for an increasing tail, got nil")
+	}
+	if _, err := New(1<<16, 64, 32, WithGeoSampler(nil)); err == nil {
+		t.Error("Expected error for a nil sampler, got nil")
+	}
+}
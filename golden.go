@@ -0,0 +1,111 @@
+package pmc
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+)
+
+/*
+GoldenVector is a flow of a golden file, with its number of increments and
+its expected estimate.
+*/
+type GoldenVector struct {
+	Flow       string  `json:"flow"`
+	Increments uint64  `json:"increments"`
+	Estimate   float64 `json:"estimate"`
+	Tolerance  float64 `json:"tolerance"`
+}
+
+/*
+Golden is a golden file pinning the estimates of a deterministic sketch,
+see GenerateGolden. Its vectors are added in rounds: every round increments
+each flow whose increments aren't exhausted once, in order.
+*/
+type Golden struct {
+	L       uint           `json:"l"`
+	M       uint           `json:"m"`
+	W       uint           `json:"w"`
+	Seed    uint64         `json:"seed"`
+	Vectors []GoldenVector `json:"vectors"`
+}
+
+// replay returns the sketch built from the vectors of g.
+func (g *Golden) replay() (*Sketch, error) {
+	sketch, err := New(g.L, g.M, g.W, WithDeterministic(g.Seed))
+	if err != nil {
+		return nil, err
+	}
+	flows := make([][]byte, len(g.Vectors))
+	for i, v := range g.Vectors {
+		flows[i] = []byte(v.Flow)
+	}
+	for round, more := uint64(0), true; more; round++ {
+		more = false
+		for i, v := range g.Vectors {
+			if v.Increments > round {
+				sketch.Increment(flows[i])
+				more = true
+			}
+		}
+	}
+	return sketch, nil
+}
+
+/*
+GenerateGolden writes to w a golden file of a sketch created with l, m, w
+and WithDeterministic(seed), fed flows flows of Zipf distributed sizes, the
+i-th flow being incremented ceil(10000/(i+1)) times. The tolerance of each
+estimate is its standard error, see GetEstimateWithError, so that
+VerifyGolden catches the upgrades that change estimates by more than their
+expected error; the estimates themselves don't change between runs of the
+same version. JSON has no infinity, so the infinite standard errors of
+saturated flows are written as math.MaxFloat64, which no change exceeds
+either.
+*/
+func GenerateGolden(w io.Writer, l, m, width uint, seed uint64, flows int) error {
+	g := &Golden{L: l, M: m, W: width, Seed: seed, Vectors: make([]GoldenVector, flows)}
+	for i := range g.Vectors {
+		g.Vectors[i] = GoldenVector{
+			Flow:       fmt.Sprintf("flow-%d", i),
+			Increments: uint64(math.Ceil(10000 / float64(i+1))),
+		}
+	}
+	sketch, err := g.replay()
+	if err != nil {
+		return err
+	}
+	for i := range g.Vectors {
+		v := &g.Vectors[i]
+		v.Estimate, v.Tolerance = sketch.GetEstimateWithError([]byte(v.Flow))
+		if math.IsInf(v.Tolerance, 1) {
+			v.Tolerance = math.MaxFloat64
+		}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+/*
+VerifyGolden replays a golden file written by GenerateGolden and returns an
+error for the first estimate out of its tolerance.
+*/
+func VerifyGolden(r io.Reader) error {
+	var g Golden
+	if err := json.NewDecoder(r).Decode(&g); err != nil {
+		return err
+	}
+	sketch, err := g.replay()
+	if err != nil {
+		return err
+	}
+	for _, v := range g.Vectors {
+		if est := sketch.GetEstimate([]byte(v.Flow)); math.Abs(est-v.Estimate) > v.Tolerance {
+			return fmt.Errorf("Expected estimate %v ± %v for flow %q, got %v",
+				v.Estimate, v.Tolerance, v.Flow, est)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package pmc
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithDeterministic(t *testing.T) {
+	a, _ := New(1<<16, 64, 32, WithDeterministic(7))
+	b, _ := New(1<<16, 64, 32, WithDeterministic(7))
+	for _, s := range []*Sketch{a, b} {
+		s.Add([]byte("flow"), 1000)
+		s.Increment([]byte("other"))
+	}
+	if a.HashSeed() != b.HashSeed() || !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected sketches with the same seed and additions to be identical")
+	}
+	if _, err := New(1<<16, 64, 32, WithDeterministic(7), WithLockFree()); err == nil {
+		t.Error("Expected error for a deterministic lock-free sketch, got nil")
+	}
+}
+
+func TestGolden(t *testing.T) {
+	var buf bytes.Buffer
+	if err := GenerateGolden(&buf, 1<<16, 64, 32, 42, 20); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyGolden(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Error(err)
+	}
+	tampered := strings.Replace(buf.String(), `"estimate": `, `"estimate": 1`, 1)
+	if err := VerifyGolden(strings.NewReader(tampered)); err == nil {
+		t.Error("Expected error for a wrong estimate, got nil")
+	}
+
+	// Saturated flows have an infinite standard error, which JSON can't hold.
+	buf.Reset()
+	if err := GenerateGolden(&buf, 64, 8, 8, 42, 20); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "1.7976931348623157e+308") {
+		t.Error("Expected a saturated flow in", buf.String())
+	}
+	if err := VerifyGolden(bytes.NewReader(buf.Bytes())); err != nil {
+		t.Error(err)
+	}
+
+	// The golden file of the repository pins the estimates across changes.
+	f, err := os.Open("testdata/golden.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := VerifyGolden(f); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,253 @@
+package pmc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/bits"
+	"sync"
+	"time"
+)
+
+// gossipMagic starts the messages of Peer.Delta.
+var gossipMagic = [4]byte{'P', 'M', 'C', 'G'}
+
+const gossipVersion = 2
+
+/*
+Peer replicates a sketch across a cluster of collectors without a central
+aggregator: every peer counts its own additions, and periodically sends to
+the others the words of its bitmap set since it last sent them one, which
+they OR into theirs. All peers thus converge on the sketch of the whole
+traffic. Since ORing is idempotent, messages may be duplicated or reordered;
+the number of additions, which isn't, is replicated as the count of each
+peer, of which receivers keep the largest seen.
+
+The transport is left to the user: Delta returns the message for a peer,
+and Apply applies a message received from one. The sketches of all peers
+must be compatible, see Compatible, and only fed through their Peer. A Peer
+is safe for concurrent use. The HyperLogLog of WithDistinctFlows isn't
+replicated.
+*/
+type Peer struct {
+	mu     sync.Mutex
+	id     string
+	sketch *Sketch
+	// counts are the additions of each peer, by id.
+	counts map[string]uint64
+	// sent are the words last sent to, or received from, each peer.
+	sent map[string][]uint64
+}
+
+/*
+NewPeer returns the Peer id of a cluster, replicating sketch. The additions
+already counted by sketch are those of the peer.
+*/
+func NewPeer(id string, sketch *Sketch) (*Peer, error) {
+	if sketch.remote != nil {
+		return nil, errors.New("Shared backends are already replicated")
+	}
+	return &Peer{id: id, sketch: sketch,
+		counts: map[string]uint64{id: sketch.N()}, sent: map[string][]uint64{}}, nil
+}
+
+/*
+Sketch returns the replicated sketch.
+*/
+func (p *Peer) Sketch() *Sketch {
+	return p.sketch
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (p *Peer) Increment(flow []byte) {
+	p.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (p *Peer) Add(flow []byte, weight uint64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sketch.Add(flow, weight)
+	p.counts[p.id] += weight
+}
+
+/*
+Delta returns the message to send to the peer to, holding the words set
+since the last message sent to it or received from it, and the counts of
+all peers known. If the message is lost, Forget makes the next one complete.
+*/
+func (p *Peer) Delta(to string) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.sketch.mu.Lock()
+	words := append([]uint64(nil), p.sketch.words()...)
+	p.sketch.mu.Unlock()
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := p.writeDelta(zw, p.sent[to], words); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	p.sent[to] = words
+	return buf.Bytes(), nil
+}
+
+func (p *Peer) writeDelta(w io.Writer, base, words []uint64) error {
+	var hdr []byte
+	hdr = append(hdr, gossipMagic[:]...)
+	hdr = append(hdr, gossipVersion)
+	hdr = p.sketch.appendParams(hdr)
+	hdr = binary.AppendUvarint(hdr, uint64(len(p.counts)))
+	for id, n := range p.counts {
+		hdr = binary.AppendUvarint(hdr, uint64(len(id)))
+		hdr = append(hdr, id...)
+		hdr = binary.AppendUvarint(hdr, n)
+	}
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	return writeWords(w, base, words)
+}
+
+/*
+Apply ORs a message sent by the peer from into the sketch, and keeps the
+largest counts of the peers. It fails with ErrIncompatibleParams if the
+sketch of from has other parameters.
+*/
+func (p *Peer) Apply(from string, data []byte) error {
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer zr.Close()
+	payload, err := io.ReadAll(io.LimitReader(zr, int64(maxGossipSize(p.sketch.l))))
+	if err != nil {
+		return err
+	}
+	r := bytes.NewReader(payload)
+
+	var magic [5]byte
+	if _, err := io.ReadFull(r, magic[:]); err != nil {
+		return err
+	}
+	if [4]byte(magic[:4]) != gossipMagic || magic[4] != gossipVersion {
+		return errors.New("Expected a gossip message")
+	}
+	if err := p.sketch.readParams(r); err != nil {
+		return err
+	}
+	peers, err := binary.ReadUvarint(r)
+	if err != nil {
+		return err
+	}
+	counts := map[string]uint64{}
+	for k := uint64(0); k < peers; k++ {
+		size, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if size > uint64(r.Len()) {
+			return io.ErrUnexpectedEOF
+		}
+		id := make([]byte, size)
+		if _, err := io.ReadFull(r, id); err != nil {
+			return err
+		}
+		if counts[string(id)], err = binary.ReadUvarint(r); err != nil {
+			return err
+		}
+	}
+
+	words := wordCount(p.sketch.l)
+	if err := checkWords(payload[len(payload)-r.Len():], p.sketch.l); err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	sketch := p.sketch
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	sent := p.sent[from]
+	if sent == nil {
+		sent = make([]uint64, words)
+		p.sent[from] = sent
+	}
+	readWords(r, words, func(i int, word uint64) {
+		// from already has the words it sent.
+		sent[i] |= word
+		for rest := word; rest != 0; rest &= rest - 1 {
+			sketch.setBit(uint(i)<<6 | uint(bits.TrailingZeros64(rest)))
+		}
+	})
+	for id, c := range counts {
+		if id != p.id && c > p.counts[id] {
+			p.counts[id] = c
+		}
+	}
+	total := uint64(0)
+	for _, c := range p.counts {
+		total += c
+	}
+	sketch.addN(total - sketch.N())
+	return nil
+}
+
+// maxGossipSize bounds the decompressed size of a message for l bits: every
+// word, with its gap, and the counts of the peers.
+func maxGossipSize(l uint) int {
+	return 18*wordCount(l) + 1<<20
+}
+
+/*
+Forget makes the next message to the peer to complete, e.g. after failing to
+send one, or when it restarted.
+*/
+func (p *Peer) Forget(to string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.sent, to)
+}
+
+/*
+N returns the number of additions of all peers known.
+*/
+func (p *Peer) N() uint64 {
+	return p.sketch.N()
+}
+
+/*
+Run sends a message to each of peers with send every interval, until ctx is
+done. Peers for which send fails are forgotten, so that they get a complete
+message next time.
+*/
+func (p *Peer) Run(ctx context.Context, interval time.Duration, send func(ctx context.Context, to string, data []byte) error, peers ...string) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			for _, to := range peers {
+				data, err := p.Delta(to)
+				if err != nil {
+					return err
+				}
+				if send(ctx, to, data) != nil {
+					p.Forget(to)
+				}
+			}
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,177 @@
+package pmc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newTestPeers(t *testing.T, ids ...string) []*Peer {
+	peers := make([]*Peer, len(ids))
+	for i, id := range ids {
+		sketch, err := New(1<<18, 64, 32, WithSeed(uint64(i+1)), WithHashSeed(42))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if peers[i], err = NewPeer(id, sketch); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return peers
+}
+
+// exchange sends a message from every peer to every other.
+func exchange(t *testing.T, peers []*Peer) {
+	for _, from := range peers {
+		for _, to := range peers {
+			if from == to {
+				continue
+			}
+			data, err := from.Delta(to.id)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := to.Apply(from.id, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestPeerConverges(t *testing.T) {
+	peers := newTestPeers(t, "a", "b", "c")
+	total := uint64(0)
+	for round := 0; round < 3; round++ {
+		for i, p := range peers {
+			for k := 0; k < 1000*(i+1); k++ {
+				flow := []byte(fmt.Sprintf("flow-%d", k%50))
+				p.Increment(flow)
+			}
+			p.Add([]byte("heavy"), 500)
+		}
+		exchange(t, peers)
+	}
+	for i := range peers {
+		total += 3 * uint64(1000*(i+1)+500)
+	}
+	for _, p := range peers {
+		if p.N() != total {
+			t.Errorf("Expected N = %d for peer %s, got %d", total, p.id, p.N())
+		}
+	}
+
+	a, b := peers[0].Sketch(), peers[1].Sketch()
+	if a.ones != b.ones {
+		t.Errorf("Expected the same bits set, got %d and %d", a.ones, b.ones)
+	}
+	for k := 0; k < 50; k++ {
+		flow := []byte(fmt.Sprintf("flow-%d", k))
+		if ea, eb := a.GetEstimate(flow), b.GetEstimate(flow); ea != eb {
+			t.Errorf("Expected the same estimates of %s, got %v and %v", flow, ea, eb)
+		}
+	}
+	// 3 rounds of 6000 increments over 50 flows.
+	if est := a.GetEstimate([]byte("flow-0")); math.Abs(est-360)/360 > 0.3 {
+		t.Errorf("Expected an estimate of about 360 for flow-0, got %v", est)
+	}
+}
+
+func TestPeerDeltaIncremental(t *testing.T) {
+	peers := newTestPeers(t, "a", "b")
+	a, b := peers[0], peers[1]
+	for k := 0; k < 10000; k++ {
+		a.Increment([]byte(fmt.Sprintf("flow-%d", k)))
+	}
+	full, err := a.Delta("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Increment([]byte("one"))
+	small, err := a.Delta("b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(small) >= len(full)/10 {
+		t.Errorf("Expected a delta much smaller than %d bytes, got %d", len(full), len(small))
+	}
+	// Messages are idempotent and can be applied out of order.
+	for _, data := range [][]byte{small, full, small} {
+		if err := b.Apply("a", data); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if b.N() != a.N() || b.Sketch().ones != a.Sketch().ones {
+		t.Errorf("Expected N = %d and %d bits set, got %d and %d",
+			a.N(), a.Sketch().ones, b.N(), b.Sketch().ones)
+	}
+	// b doesn't send back the words it got from a.
+	back, err := b.Delta("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back) >= len(small)*2 {
+		t.Errorf("Expected an empty delta, got %d bytes", len(back))
+	}
+}
+
+func TestPeerApplyErrors(t *testing.T) {
+	peers := newTestPeers(t, "a")
+	other, _ := New(1<<16, 64, 32, WithHashSeed(42))
+	p, err := NewPeer("b", other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := p.Delta("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := peers[0].Apply("b", data); !errors.Is(err, ErrIncompatibleParams) {
+		t.Errorf("Expected ErrIncompatibleParams, got %v", err)
+	}
+	if err := peers[0].Apply("b", []byte("garbage")); err == nil {
+		t.Error("Expected an error for a corrupt message")
+	}
+	if err := peers[0].Apply("b", data[:len(data)/2]); err == nil {
+		t.Error("Expected an error for a truncated message")
+	}
+}
+
+func TestPeerRun(t *testing.T) {
+	peers := newTestPeers(t, "a", "b")
+	a, b := peers[0], peers[1]
+	for k := 0; k < 1000; k++ {
+		a.Increment([]byte(fmt.Sprintf("flow-%d", k)))
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	fail := true
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.Run(ctx, time.Millisecond, func(_ context.Context, to string, data []byte) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if fail {
+				// The first message is lost, the next ones must be complete.
+				fail = false
+				return errors.New("lost")
+			}
+			return b.Apply("a", data)
+		}, "b")
+	}()
+	deadline := time.Now().Add(5 * time.Second)
+	for b.N() != a.N() && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	cancel()
+	wg.Wait()
+	if b.N() != a.N() || b.Sketch().ones != a.Sketch().ones {
+		t.Errorf("Expected N = %d and %d bits set, got %d and %d",
+			a.N(), a.Sketch().ones, b.N(), b.Sketch().ones)
+	}
+}
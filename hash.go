@@ -0,0 +1,136 @@
+package pmc
+
+import (
+	"encoding/binary"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/dchest/siphash"
+	"github.com/dgryski/go-farm"
+)
+
+/*
+Hasher maps a flow and the row i and column j of its virtual matrix to a
+uniformly distributed 64-bit value, from which the position of the
+corresponding bit in the sketch is derived. Sketches use FarmHasher unless
+created with WithHash.
+*/
+type Hasher interface {
+	Hash(flow []byte, i, j uint64) uint64
+}
+
+/*
+FlowHasher is a Hasher hashing the flow once, the value of each cell of its
+virtual matrix being mixed from the hash of the flow, i and j, so that
+estimates hash the flow once instead of once per cell. Hash(flow, i, j) must
+equal Mix(HashFlow(flow), i, j).
+*/
+type FlowHasher interface {
+	Hasher
+	HashFlow(flow []byte) uint64
+	Mix(h, i, j uint64) uint64
+}
+
+/*
+FarmHasher is the default Hasher, seeding farmhash with i and j.
+*/
+type FarmHasher struct{}
+
+/*
+Hash implements Hasher.
+*/
+func (FarmHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return farm.Hash64WithSeeds(flow, i, j)
+}
+
+/*
+SipHasher is a Hasher keyed with a secret 128-bit key, so that positions
+can't be predicted, and flows colliding with a victim flow can't be crafted,
+without knowing the key.
+*/
+type SipHasher struct {
+	k0, k1 uint64
+}
+
+/*
+NewSipHasher returns a SipHasher keyed with key.
+*/
+func NewSipHasher(key [16]byte) *SipHasher {
+	return &SipHasher{
+		k0: binary.LittleEndian.Uint64(key[:8]),
+		k1: binary.LittleEndian.Uint64(key[8:]),
+	}
+}
+
+/*
+Hash implements Hasher. Each cell of the virtual matrix gets its own SipHash
+key, derived from the secret key, i and j.
+*/
+func (h *SipHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return siphash.Hash(h.k0^i, h.k1^j, flow)
+}
+
+/*
+XXHasher is a Hasher based on xxHash, which is faster than farmhash on long
+flow keys.
+*/
+type XXHasher struct{}
+
+/*
+Hash implements Hasher. The xxHash of the flow is mixed with i and j.
+*/
+func (h XXHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return h.Mix(h.HashFlow(flow), i, j)
+}
+
+/*
+HashFlow implements FlowHasher.
+*/
+func (XXHasher) HashFlow(flow []byte) uint64 {
+	return xxhash.Sum64(flow)
+}
+
+/*
+Mix implements FlowHasher.
+*/
+func (XXHasher) Mix(h, i, j uint64) uint64 {
+	return mix64(h ^ mix64(i<<32^j))
+}
+
+/*
+TwoLevelHasher is a FlowHasher based on farmhash: the flow is hashed once
+with farmhash, and the hash is mixed with the row and then the column by
+splitmix64, which takes a few nanoseconds instead of a farmhash of the flow
+per cell. The positions differ from those of FarmHasher, so sketches must
+use the same Hasher to be merged.
+*/
+type TwoLevelHasher struct{}
+
+/*
+Hash implements Hasher.
+*/
+func (h TwoLevelHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return h.Mix(h.HashFlow(flow), i, j)
+}
+
+/*
+HashFlow implements FlowHasher.
+*/
+func (TwoLevelHasher) HashFlow(flow []byte) uint64 {
+	return farm.Hash64(flow)
+}
+
+/*
+Mix implements FlowHasher.
+*/
+func (TwoLevelHasher) Mix(h, i, j uint64) uint64 {
+	return mix64(mix64(h^i) ^ j)
+}
+
+// mix64 is the finalizer of splitmix64, a bijection spreading each input bit
+// over all output bits.
+func mix64(x uint64) uint64 {
+	x += 0x9e3779b97f4a7c15
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
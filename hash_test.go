@@ -0,0 +1,106 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestHashers(t *testing.T) {
+	hashers := map[string]Hasher{
+		"farm": FarmHasher{},
+		"sip":  NewSipHasher([16]byte{1, 2, 3}),
+		"xx":   XXHasher{},
+		"two":  TwoLevelHasher{},
+	}
+	for name, h := range hashers {
+		seen := make(map[uint64]bool)
+		for i := uint64(0); i < 16; i++ {
+			for j := uint64(0); j < 16; j++ {
+				seen[h.Hash([]byte("flow"), i, j)] = true
+			}
+		}
+		if len(seen) != 256 {
+			t.Errorf("Expected %s hasher to give 256 distinct values, got %d", name, len(seen))
+		}
+
+		s, _ := New(1<<22, 256, 32, WithHash(h))
+		s.IncrementN([]byte("flow"), 50000)
+		est := s.GetEstimate([]byte("flow"))
+		if fErr := math.Abs(100 * (1 - est/50000)); fErr > 15 {
+			t.Errorf("Expected %s hasher estimate within 15%% of 50000, got %f", name, est)
+		}
+	}
+}
+
+func TestSipHasherKey(t *testing.T) {
+	a := NewSipHasher([16]byte{1})
+	b := NewSipHasher([16]byte{2})
+	if a.Hash([]byte("flow"), 0, 0) == b.Hash([]byte("flow"), 0, 0) {
+		t.Error("Expected different keys to give different hashes")
+	}
+}
+
+func TestFlowHashers(t *testing.T) {
+	for name, h := range map[string]FlowHasher{"xx": XXHasher{}, "two": TwoLevelHasher{}} {
+		for _, flow := range []string{"", "flow", "a longer flow key of 40 bytes, or so..."} {
+			if h.Hash([]byte(flow), 3, 7) != h.Mix(h.HashFlow([]byte(flow)), 3, 7) {
+				t.Errorf("Expected %s Hash to mix HashFlow for %q", name, flow)
+			}
+		}
+
+		s, _ := New(1<<16, 32, 32, WithHash(h))
+		getPos := s.flowPositions([]byte("flow"))
+		for _, i := range []uint{0, 0, 5, 31, 5} {
+			for j := uint(0); j < s.w; j++ {
+				if getPos(i, j) != s.getPos([]byte("flow"), i, j) {
+					t.Fatalf("Expected %s flowPositions(%d, %d) to match getPos", name, i, j)
+				}
+			}
+		}
+	}
+}
+
+func BenchmarkHashers(b *testing.B) {
+	flow := make([]byte, 40)
+	for name, h := range map[string]Hasher{
+		"farm": FarmHasher{},
+		"sip":  NewSipHasher([16]byte{}),
+		"xx":   XXHasher{},
+		"two":  TwoLevelHasher{},
+	} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				h.Hash(flow, uint64(i), 3)
+			}
+		})
+	}
+}
+
+func BenchmarkGetEstimateHashers(b *testing.B) {
+	flow := make([]byte, 40)
+	for name, h := range map[string]Hasher{
+		"farm": FarmHasher{},
+		"xx":   XXHasher{},
+		"two":  TwoLevelHasher{},
+	} {
+		s, _ := New(1<<22, 256, 32, WithHash(h))
+		s.IncrementN(flow, 100000)
+		b.Run(name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				s.GetEstimate(flow)
+			}
+		})
+	}
+}
+
+func TestGetEstimateAllocs(t *testing.T) {
+	flow := []byte("flow")
+	for name, h := range map[string]Hasher{"farm": FarmHasher{}, "two": TwoLevelHasher{}} {
+		s, _ := New(1<<20, 64, 32, WithHash(h))
+		s.IncrementN(flow, 1000)
+		if allocs := testing.AllocsPerRun(100, func() { s.GetEstimate(flow) }); allocs != 0 {
+			t.Errorf("Expected GetEstimate with %s not to allocate, got %v", name, allocs)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package pmc
+
+/*
+getPos64 is getPos for flows given by a 64-bit hash h of their key. Positions
+are derived by mixing h with the hash seeds of the row and column rather
+than by the Hasher of the sketch, so flows counted by Increment64 must be
+estimated by Estimate64.
+*/
+func (sketch *Sketch) getPos64(h uint64, i, j uint) uint {
+	si, sj := uint64(i), uint64(j)
+	if sketch.hashSeed != 0 {
+		si = mix64(sketch.hashSeed + si)
+		sj = mix64(^sketch.hashSeed + sj)
+	}
+	return uint(mix64(mix64(h^si)+sj) % uint64(sketch.l))
+}
+
+/*
+Increment64 increments the count of the flow whose key hashes to h by 1, for
+callers that already hash their keys, or whose keys fit in 64 bits. h needs
+not be uniformly distributed, it is mixed before use.
+*/
+func (sketch *Sketch) Increment64(h uint64) {
+	if sketch.lockFree {
+		sketch.increment64(h)
+	} else {
+		sketch.mu.Lock()
+		sketch.increment64(h)
+		sketch.mu.Unlock()
+	}
+	if sketch.hooks != nil {
+		sketch.incremented(nil, 1)
+	}
+}
+
+func (sketch *Sketch) increment64(h uint64) {
+	if sketch.hll != nil {
+		sketch.hll.add(mix64(h ^ sketch.hashSeed))
+	}
+	if i, j, ok := sketch.sample(); ok {
+		sketch.setBit(sketch.getPos64(h, i, j))
+	}
+}
+
+/*
+Estimate64 returns the estimated count of the flow whose key hashes to h,
+counted by Increment64.
+*/
+func (sketch *Sketch) Estimate64(h uint64) float64 {
+	sketch.mu.Lock()
+	n, p := float64(sketch.N()), sketch.getP()
+	e, _ := sketch.estimate(func(i, j uint) uint {
+		return sketch.getPos64(h, i, j)
+	}, p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	sketch.mu.Unlock()
+	sketch.estimated(nil, e)
+	return e
+}
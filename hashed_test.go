@@ -0,0 +1,31 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIncrement64(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	for i := 0; i < 10000; i++ {
+		s.Increment64(1)
+	}
+	for i := uint64(2); i < 1000; i++ {
+		s.Increment64(i)
+	}
+	est := s.Estimate64(1)
+	if fErr := math.Abs(100 * (1 - est/10000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 10000, got %f", est)
+	}
+	if est := s.Estimate64(1 << 40); est > 100 {
+		t.Error("Expected a small estimate for an unseen key, got", est)
+	}
+}
+
+func BenchmarkIncrement64(b *testing.B) {
+	s, _ := New(1<<22, 256, 32)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Increment64(uint64(i & 1023))
+	}
+}
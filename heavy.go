@@ -0,0 +1,176 @@
+package pmc
+
+import (
+	"container/heap"
+	"errors"
+	"math/bits"
+	"sort"
+	"sync"
+
+	"github.com/lazybeaver/xorshift"
+)
+
+/*
+HeavyHitter is a flow reported by HeavyHitters.Top along with its estimated
+count.
+*/
+type HeavyHitter struct {
+	Flow     string
+	Estimate float64
+}
+
+// hitterHeap is a min-heap of the tracked flows on their estimates.
+type hitterHeap struct {
+	items []HeavyHitter
+	index map[string]int
+}
+
+func (h *hitterHeap) Len() int           { return len(h.items) }
+func (h *hitterHeap) Less(a, b int) bool { return h.items[a].Estimate < h.items[b].Estimate }
+
+func (h *hitterHeap) Swap(a, b int) {
+	h.items[a], h.items[b] = h.items[b], h.items[a]
+	h.index[h.items[a].Flow] = a
+	h.index[h.items[b].Flow] = b
+}
+
+func (h *hitterHeap) Push(x interface{}) {
+	hh := x.(HeavyHitter)
+	h.index[hh.Flow] = len(h.items)
+	h.items = append(h.items, hh)
+}
+
+func (h *hitterHeap) Pop() interface{} {
+	hh := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	delete(h.index, hh.Flow)
+	return hh
+}
+
+// offer refreshes the estimate of flow, tracking it if it is among the k
+// largest.
+func (h *hitterHeap) offer(flow []byte, est float64, k int) {
+	if i, ok := h.index[string(flow)]; ok {
+		h.items[i].Estimate = est
+		heap.Fix(h, i)
+		return
+	}
+	if h.Len() < k {
+		heap.Push(h, HeavyHitter{Flow: string(flow), Estimate: est})
+		return
+	}
+	if est > h.items[0].Estimate {
+		delete(h.index, h.items[0].Flow)
+		h.items[0] = HeavyHitter{Flow: string(flow), Estimate: est}
+		h.index[h.items[0].Flow] = 0
+		heap.Fix(h, 0)
+	}
+}
+
+// top returns the tracked flows ordered by decreasing estimate.
+func (h *hitterHeap) top() []HeavyHitter {
+	top := make([]HeavyHitter, len(h.items))
+	copy(top, h.items)
+	sort.Slice(top, func(a, b int) bool { return top[a].Estimate > top[b].Estimate })
+	return top
+}
+
+// heavyChecks is the number of times an untracked flow is estimated on
+// average by the time its count reaches the smallest tracked estimate.
+const heavyChecks = 8
+
+/*
+HeavyHitters keeps track of the k flows with the highest estimates seen so
+far next to a sketch, since the sketch alone cannot enumerate its flows.
+Estimates cost m*w hashes, so additions don't all estimate their flow: the
+additions of a tracked flow are added to its estimate, which Top refreshes,
+and an untracked flow is estimated with a probability of 8 times the weight
+over the smallest tracked estimate, replacing the smallest tracked flow once
+it is larger. A flow is thus caught about an eighth of the smallest
+tracked estimate after overtaking it. The samples are drawn from a
+generator seeded from that of the sketch. HeavyHitters are safe for
+concurrent use if the sketch is.
+*/
+type HeavyHitters struct {
+	sketch *Sketch
+	k      int
+
+	mu   sync.Mutex
+	heap hitterHeap
+	rnd  xorshift.XorShift
+}
+
+/*
+NewHeavyHitters returns a HeavyHitters tracking the top k flows counted in
+sketch.
+*/
+func NewHeavyHitters(sketch *Sketch, k int) (*HeavyHitters, error) {
+	if k <= 0 {
+		return nil, errors.New("Expected k > 0, got 0")
+	}
+	sketch.mu.Lock()
+	rnd := sketch.deriveRand()
+	sketch.mu.Unlock()
+	return &HeavyHitters{sketch: sketch, k: k,
+		heap: hitterHeap{index: make(map[string]int)}, rnd: rnd}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (hh *HeavyHitters) Increment(flow []byte) {
+	hh.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (hh *HeavyHitters) Add(flow []byte, weight uint64) {
+	hh.sketch.Add(flow, weight)
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	h := &hh.heap
+	if i, ok := h.index[string(flow)]; ok {
+		h.items[i].Estimate += float64(weight)
+		heap.Fix(h, i)
+		return
+	}
+	if h.Len() == hh.k {
+		// The high word of r * every is below weight with a probability of
+		// weight/every.
+		every := h.items[0].Estimate / heavyChecks
+		if hi, _ := bits.Mul64(hh.rnd.Next(), uint64(min(every, 1<<62))); every > 1 && hi >= weight {
+			return
+		}
+	}
+	h.offer(flow, hh.sketch.GetEstimate(flow), hh.k)
+}
+
+/*
+Top returns the tracked flows ordered by decreasing estimate, refreshing
+their estimates.
+*/
+func (hh *HeavyHitters) Top() []HeavyHitter {
+	hh.mu.Lock()
+	defer hh.mu.Unlock()
+	h := &hh.heap
+	for i := range h.items {
+		h.items[i].Estimate = hh.sketch.GetEstimate([]byte(h.items[i].Flow))
+	}
+	heap.Init(h)
+	return h.top()
+}
+
+/*
+GetEstimate returns the estimated count of the flow, see Sketch.GetEstimate.
+*/
+func (hh *HeavyHitters) GetEstimate(flow []byte) float64 {
+	return hh.sketch.GetEstimate(flow)
+}
+
+/*
+Sketch returns the underlying sketch.
+*/
+func (hh *HeavyHitters) Sketch() *Sketch {
+	return hh.sketch
+}
@@ -0,0 +1,96 @@
+package pmc
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+func TestHeavyHitters(t *testing.T) {
+	s, _ := New(1<<22, 256, 32, WithSeed(DefaultSeed))
+	hh, err := NewHeavyHitters(s, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewHeavyHitters(s, 0); err == nil {
+		t.Error("Expected error for k == 0, got nil")
+	}
+
+	for i := 0; i < 100; i++ {
+		hh.Increment([]byte(strconv.Itoa(i)))
+	}
+	for _, flow := range []string{"a", "b", "c"} {
+		hh.Add([]byte(flow), 20000)
+	}
+	hh.Add([]byte("a"), 20000)
+	for i := 100; i < 200; i++ {
+		hh.Increment([]byte(strconv.Itoa(i)))
+	}
+
+	top := hh.Top()
+	if len(top) != 3 {
+		t.Fatal("Expected 3 heavy hitters, got", len(top))
+	}
+	if top[0].Flow != "a" {
+		t.Error("Expected top flow a, got", top[0].Flow)
+	}
+	seen := map[string]bool{}
+	for i, h := range top {
+		seen[h.Flow] = true
+		if i > 0 && h.Estimate > top[i-1].Estimate {
+			t.Error("Expected heavy hitters in decreasing order")
+		}
+	}
+	if !seen["b"] || !seen["c"] {
+		t.Error("Expected b and c among the heavy hitters, got", top)
+	}
+}
+
+func TestHeavyHittersSampling(t *testing.T) {
+	estimates := 0
+	s, _ := New(1<<20, 64, 32, WithSeed(3), WithHooks(Hooks{OnEstimate: func([]byte, float64) { estimates++ }}))
+	hh, _ := NewHeavyHitters(s, 5)
+	for _, flow := range []string{"a", "b", "c", "d", "e"} {
+		hh.Add([]byte(flow), 10000)
+	}
+	estimates = 0
+	for i := 0; i < 20000; i++ {
+		hh.Increment([]byte(strconv.Itoa(i % 5000)))
+		hh.Increment([]byte("a"))
+	}
+	if estimates > 1000 {
+		t.Error("Expected untracked small flows to be sampled, got", estimates, "estimates")
+	}
+
+	// A flow overtaking the tracked ones is caught.
+	for i := 0; i < 20000; i++ {
+		hh.Increment([]byte("z"))
+	}
+	top := hh.Top()
+	if top[0].Flow != "a" || top[1].Flow != "z" {
+		t.Error("Expected a, then z, as top flows, got", top)
+	}
+	if math.Abs(top[0].Estimate-s.GetEstimate([]byte("a"))) > 0 {
+		t.Error("Expected Top to refresh the estimates, got", top[0].Estimate)
+	}
+}
+
+func TestHeavyHittersConcurrent(t *testing.T) {
+	s, _ := New(1<<16, 64, 32, WithThreadSafety())
+	hh, _ := NewHeavyHitters(s, 3)
+	var wg sync.WaitGroup
+	for g := 0; g < 4; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				hh.Increment([]byte(strconv.Itoa(i % 10)))
+			}
+		}()
+	}
+	wg.Wait()
+	if top := hh.Top(); len(top) != 3 {
+		t.Error("Expected 3 heavy hitters, got", top)
+	}
+}
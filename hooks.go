@@ -0,0 +1,66 @@
+package pmc
+
+import "errors"
+
+/*
+Hooks are callbacks observing a sketch, e.g. for tracing, metrics or
+sampling, see WithHooks. They are called after the sketch is unlocked, so
+they can use it, and must not retain the flows they are passed. Nil
+callbacks are skipped.
+
+The hooks of the sketches underlying a ShardedSketch, a CountingSketch or a
+FlowRef observe them too, the flows being those passed to them or to
+PrepareFlow. Flows counted and estimated by Increment64 and Estimate64 are
+only known by their hash, so they are passed as nil. The hooks of a
+ShardedSketch are those of each of its shards: OnSaturation watches the fill
+rate of the shard an addition went to, which trails that of the union.
+Removals of CountingSketch.Decrement aren't observed.
+*/
+type Hooks struct {
+	// OnIncrement is called after weight additions of flow by Increment,
+	// IncrementN, Add, IncrementBatch and Increment64.
+	OnIncrement func(flow []byte, weight uint64)
+	// OnEstimate is called with the result of GetEstimate, of each flow of
+	// GetEstimates and of Estimate64.
+	OnEstimate func(flow []byte, estimate float64)
+	// OnSaturation is called once an addition makes the fill rate, in
+	// percent, cross the saturation threshold of Stats, and is called again
+	// on the next crossing after the fill rate fell back, e.g. by Reset.
+	OnSaturation func(fillRate float64)
+}
+
+/*
+WithHooks calls hooks on the additions to and estimates of the sketch.
+*/
+func WithHooks(hooks Hooks) Option {
+	return func(sketch *Sketch) error {
+		if hooks.OnIncrement == nil && hooks.OnEstimate == nil && hooks.OnSaturation == nil {
+			return errors.New("Expected at least one hook")
+		}
+		sketch.hooks = &hooks
+		return nil
+	}
+}
+
+// estimated runs the hooks following the estimate e of flow.
+func (sketch *Sketch) estimated(flow []byte, e float64) {
+	if sketch.hooks != nil && sketch.hooks.OnEstimate != nil {
+		sketch.hooks.OnEstimate(flow, e)
+	}
+}
+
+// incremented runs the hooks following weight additions of flow.
+func (sketch *Sketch) incremented(flow []byte, weight uint64) {
+	h := sketch.hooks
+	if h.OnIncrement != nil {
+		h.OnIncrement(flow, weight)
+	}
+	if h.OnSaturation != nil {
+		p := sketch.getP()
+		if p <= saturationFill {
+			sketch.saturated.Store(false)
+		} else if sketch.saturated.CompareAndSwap(false, true) {
+			h.OnSaturation(p * 100)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package pmc
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestHooks(t *testing.T) {
+	var increments, weight uint64
+	var estimates []float64
+	var saturations []float64
+	var s *Sketch
+	s, err := New(1024, 64, 32, WithHooks(Hooks{
+		OnIncrement: func(flow []byte, n uint64) {
+			increments++
+			weight += n
+		},
+		OnEstimate: func(flow []byte, est float64) {
+			estimates = append(estimates, est)
+		},
+		OnSaturation: func(fillRate float64) {
+			// Hooks run unlocked and can use the sketch.
+			saturations = append(saturations, s.GetFillRate())
+		},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Increment([]byte("a"))
+	s.Add([]byte("b"), 100)
+	s.IncrementBatch([][]byte{[]byte("c"), []byte("d")})
+	if increments != 4 || weight != 103 {
+		t.Errorf("Expected 4 increments of 103 additions, got %d of %d", increments, weight)
+	}
+	if est := s.GetEstimate([]byte("b")); len(estimates) != 1 || estimates[0] != est {
+		t.Error("Expected OnEstimate to get the estimate, got", estimates)
+	}
+
+	for i := 0; i < 5000 && len(saturations) == 0; i++ {
+		s.Increment([]byte{byte(i), byte(i >> 8)})
+	}
+	if len(saturations) != 1 || saturations[0] <= 100*saturationFill {
+		t.Fatal("Expected OnSaturation once past the threshold, got", saturations)
+	}
+	s.Increment([]byte("e"))
+	if len(saturations) != 1 {
+		t.Error("Expected OnSaturation to fire once per crossing")
+	}
+	s.Reset()
+	for i := 0; i < 5000 && len(saturations) == 1; i++ {
+		s.Increment([]byte{byte(i), byte(i >> 8)})
+	}
+	if len(saturations) != 2 {
+		t.Error("Expected OnSaturation to fire again after a reset")
+	}
+
+	if _, err := New(1024, 64, 32, WithHooks(Hooks{})); err == nil {
+		t.Error("Expected error without hooks, got nil")
+	}
+}
+
+func TestHooksCoverage(t *testing.T) {
+	var flows []string
+	var weight uint64
+	var estimates int
+	hooks := WithHooks(Hooks{
+		OnIncrement: func(flow []byte, n uint64) {
+			flows = append(flows, string(flow))
+			weight += n
+		},
+		OnEstimate: func(flow []byte, est float64) { estimates++ },
+	})
+	check := func(name string, wantFlows []string, wantWeight uint64, wantEstimates int) {
+		t.Helper()
+		if !reflect.DeepEqual(flows, wantFlows) || weight != wantWeight || estimates != wantEstimates {
+			t.Errorf("%s: expected increments of %q weighing %d and %d estimates, got %q weighing %d and %d",
+				name, wantFlows, wantWeight, wantEstimates, flows, weight, estimates)
+		}
+		flows, weight, estimates = nil, 0, 0
+	}
+
+	s, _ := New(1<<16, 64, 32, hooks)
+	s.Increment64(7)
+	s.Estimate64(7)
+	check("Increment64", []string{""}, 1, 1)
+	s.GetEstimates([][]byte{[]byte("a"), []byte("b")})
+	check("GetEstimates", nil, 0, 2)
+
+	ref := s.PrepareFlow([]byte("ref"))
+	ref.Increment()
+	ref.GetEstimate()
+	check("FlowRef", []string{"ref"}, 1, 1)
+
+	cs, _ := NewCounting(1<<16, 64, 32, hooks)
+	cs.Increment([]byte("counted"))
+	cs.Decrement([]byte("counted"))
+	cs.GetEstimate([]byte("counted"))
+	check("CountingSketch", []string{"counted"}, 1, 1)
+
+	ss, _ := NewSharded(1<<16, 64, 32, 2, hooks)
+	ss.Increment([]byte("sharded"))
+	ss.Add([]byte("sharded"), 10)
+	ss.GetEstimate([]byte("sharded"))
+	check("ShardedSketch", []string{"sharded", "sharded"}, 11, 1)
+}
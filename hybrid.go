@@ -0,0 +1,93 @@
+package pmc
+
+import "errors"
+
+/*
+Hybrid counts flows exactly until they reach a threshold, and then moves
+them to a sketch. Rare flows get exact answers while the heavy tail is
+accounted probabilistically.
+
+At most maxExact flows are ever admitted for exact counting, which bounds
+the memory of the exact counters; once that many have been admitted, new
+flows go to the sketch alone, so a flow's count is never split between both.
+*/
+type Hybrid struct {
+	sketch    *Sketch
+	threshold uint64
+	maxExact  int
+	admitted  int
+	exact     map[string]uint64
+	heavy     map[string]struct{}
+}
+
+/*
+NewHybrid returns a Hybrid spilling flows into sketch once their count
+reaches threshold.
+*/
+func NewHybrid(sketch *Sketch, threshold uint64, maxExact int) (*Hybrid, error) {
+	if threshold == 0 {
+		return nil, errors.New("Expected threshold > 0, got 0")
+	}
+	if maxExact <= 0 {
+		return nil, errors.New("Expected maxExact > 0, got 0")
+	}
+	return &Hybrid{sketch: sketch, threshold: threshold, maxExact: maxExact,
+		exact: make(map[string]uint64), heavy: make(map[string]struct{})}, nil
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (h *Hybrid) Increment(flow []byte) {
+	h.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (h *Hybrid) Add(flow []byte, weight uint64) {
+	if c, ok := h.exact[string(flow)]; ok {
+		if c+weight < h.threshold {
+			h.exact[string(flow)] = c + weight
+			return
+		}
+		delete(h.exact, string(flow))
+		h.heavy[string(flow)] = struct{}{}
+		h.sketch.Add(flow, c+weight)
+		return
+	}
+	if _, ok := h.heavy[string(flow)]; !ok && h.admitted < h.maxExact {
+		h.admitted++
+		h.exact[string(flow)] = 0
+		h.Add(flow, weight)
+		return
+	}
+	h.sketch.Add(flow, weight)
+}
+
+/*
+Exact returns the exact count of the flow, and whether it is counted
+exactly.
+*/
+func (h *Hybrid) Exact(flow []byte) (uint64, bool) {
+	c, ok := h.exact[string(flow)]
+	return c, ok
+}
+
+/*
+Estimate returns the count of the flow, exact if it is counted exactly and
+estimated by the sketch otherwise.
+*/
+func (h *Hybrid) Estimate(flow []byte) float64 {
+	if c, ok := h.exact[string(flow)]; ok {
+		return float64(c)
+	}
+	return h.sketch.GetEstimate(flow)
+}
+
+/*
+Sketch returns the sketch holding the heavy flows.
+*/
+func (h *Hybrid) Sketch() *Sketch {
+	return h.sketch
+}
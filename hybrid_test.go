@@ -0,0 +1,48 @@
+package pmc
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestHybrid(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	h, err := NewHybrid(s, 100, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		h.Increment([]byte("mouse"))
+	}
+	h.Add([]byte("elephant"), 99)
+	h.Add([]byte("elephant"), 50000)
+
+	if c, ok := h.Exact([]byte("mouse")); !ok || c != 50 || h.Estimate([]byte("mouse")) != 50 {
+		t.Error("Expected exact count 50 for the mouse flow, got", c)
+	}
+	if _, ok := h.Exact([]byte("elephant")); ok {
+		t.Error("Expected the elephant flow to be moved to the sketch")
+	}
+	est := h.Estimate([]byte("elephant"))
+	if fErr := math.Abs(100 * (1 - est/50099)); fErr > 15 {
+		t.Errorf("Expected elephant estimate within 15%% of 50099, got %f", est)
+	}
+
+	// The elephant flow keeps being counted in the sketch.
+	h.Increment([]byte("elephant"))
+	if _, ok := h.Exact([]byte("elephant")); ok {
+		t.Error("Expected the elephant flow not to be counted exactly again")
+	}
+
+	for i := 0; i < 20; i++ {
+		h.Increment([]byte(strconv.Itoa(i)))
+	}
+	if len(h.exact)+len(h.heavy) > 10 {
+		t.Error("Expected at most 10 flows to be admitted, got", len(h.exact)+len(h.heavy))
+	}
+	if _, ok := h.Exact([]byte("19")); ok {
+		t.Error("Expected flows past maxExact to go to the sketch")
+	}
+}
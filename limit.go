@@ -0,0 +1,37 @@
+package pmc
+
+/*
+Allow reports whether the flow is below limit, in which case the addition is
+counted. Flows over the limit are not incremented, so that their estimate
+stops growing while they are throttled. Estimates are approximate, so flows
+close to the limit may be let through or throttled early; see
+GetEstimateWithError for the expected error. Combined with the decay of a
+sliding window, see WindowedSketch.Allow, this limits the rate of the flows
+in bounded memory.
+*/
+func (sketch *Sketch) Allow(flow []byte, limit float64) bool {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if sketch.getEstimate(flow) >= limit {
+		return false
+	}
+	sketch.increment(flow)
+	return true
+}
+
+/*
+Allow reports whether the flow is below limit over the window, in which case
+the addition is counted, so that at most about limit additions of the flow
+are allowed per window. Throttled flows are checked against the kept merge
+of the buckets, see GetEstimate, while allowed ones invalidate it.
+*/
+func (ws *WindowedSketch) Allow(flow []byte, limit float64) bool {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.window().GetEstimate(flow) >= limit {
+		return false
+	}
+	ws.buckets[ws.cur].Increment(flow)
+	ws.stale = true
+	return true
+}
@@ -0,0 +1,47 @@
+package pmc
+
+import "testing"
+
+func TestAllow(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	allowed := 0
+	for i := 0; i < 5000; i++ {
+		if s.Allow([]byte("flow"), 1000) {
+			allowed++
+		}
+	}
+	if allowed < 850 || allowed > 1150 {
+		t.Error("Expected about 1000 additions to be allowed, got", allowed)
+	}
+	if s.N() != uint64(allowed) {
+		t.Error("Expected only allowed additions to be counted, got", s.N())
+	}
+	if !s.Allow([]byte("other"), 1000) {
+		t.Error("Expected another flow to be allowed")
+	}
+}
+
+func TestWindowedAllow(t *testing.T) {
+	ws, _ := NewWindowed(1<<22, 256, 32, 2, 0)
+	count := func() int {
+		allowed := 0
+		for i := 0; i < 2000; i++ {
+			if ws.Allow([]byte("flow"), 500) {
+				allowed++
+			}
+		}
+		return allowed
+	}
+	if allowed := count(); allowed < 400 || allowed > 600 {
+		t.Error("Expected about 500 additions to be allowed, got", allowed)
+	}
+	ws.Rotate()
+	if allowed := count(); allowed > 100 {
+		t.Error("Expected the window to still throttle the flow, got", allowed)
+	}
+	ws.Rotate()
+	ws.Rotate()
+	if allowed := count(); allowed < 400 {
+		t.Error("Expected the flow to be allowed again once out of the window, got", allowed)
+	}
+}
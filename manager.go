@@ -0,0 +1,374 @@
+package pmc
+
+import (
+	"container/list"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// managed is a sketch of a Manager, in its LRU list.
+type managed struct {
+	name   string
+	sketch *Sketch
+	// bytes is the memory of the sketch accounted against the budget.
+	bytes uint64
+	// n is N() of the sketch as of active, the last time it was seen to
+	// change.
+	n      uint64
+	active time.Time
+}
+
+/*
+Manager owns many named sketches, e.g. one per tenant, interface or VLAN,
+creating them on first use and evicting the least recently used ones when
+there are more than maxSketches of them, or when their memory exceeds the
+budget. Each sketch is accounted for its MemoryUsage when created. A
+Manager is safe for concurrent use; the sketches it returns are shared by
+all callers, so the factory should create them WithThreadSafety if they are
+used concurrently. A caller still holding an evicted sketch can keep using
+it, but its additions are lost to the Manager. Sketches that aren't
+incremented anymore can be evicted as well, see ExpireIdle.
+*/
+type Manager struct {
+	mu          sync.Mutex
+	factory     func(name string) (*Sketch, error)
+	maxSketches int
+	budget      uint64
+	used        uint64
+	lru         *list.List
+	sketches    map[string]*list.Element
+	onEvict     func(name string, sketch *Sketch)
+	// evicting holds the names of the evicted sketches whose onEvict hasn't
+	// returned yet, with a channel closed once it has.
+	evicting map[string]chan struct{}
+	stop     chan struct{}
+}
+
+/*
+NewManager returns a Manager creating its sketches with factory, and holding
+at most maxSketches sketches of at most budget bytes in total; 0 doesn't
+limit either.
+*/
+func NewManager(maxSketches int, budget uint64, factory func(name string) (*Sketch, error)) (*Manager, error) {
+	if maxSketches < 0 {
+		return nil, fmt.Errorf("Expected maxSketches >= 0, got %d", maxSketches)
+	}
+	if factory == nil {
+		return nil, errors.New("Expected a non nil factory")
+	}
+	return &Manager{factory: factory, maxSketches: maxSketches, budget: budget,
+		lru: list.New(), sketches: make(map[string]*list.Element),
+		evicting: make(map[string]chan struct{})}, nil
+}
+
+/*
+Get returns the sketch of name, creating it with the factory if the Manager
+doesn't hold it, which may evict the least recently used sketches. It fails
+if the factory does, or if the new sketch alone exceeds the budget. A sketch
+of name being evicted is only created again once the function set by
+SetOnEvict returned for it, so that the factory can restore what it saved.
+*/
+func (mgr *Manager) Get(name string) (*Sketch, error) {
+	mgr.mu.Lock()
+	for {
+		done, ok := mgr.evicting[name]
+		if !ok {
+			break
+		}
+		mgr.mu.Unlock()
+		<-done
+		mgr.mu.Lock()
+	}
+	sketch, evicted, err := mgr.get(name)
+	onEvict := mgr.onEvict
+	mgr.mu.Unlock()
+	mgr.notify(onEvict, evicted)
+	return sketch, err
+}
+
+func (mgr *Manager) get(name string) (*Sketch, []*managed, error) {
+	if e, ok := mgr.sketches[name]; ok {
+		mgr.lru.MoveToFront(e)
+		return e.Value.(*managed).sketch, nil, nil
+	}
+
+	sketch, err := mgr.factory(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	bytes := sketch.MemoryUsage()
+	if mgr.budget > 0 && bytes > mgr.budget {
+		return nil, nil, fmt.Errorf("Expected a sketch of at most %d bytes, got %d", mgr.budget, bytes)
+	}
+	var evicted []*managed
+	for mgr.lru.Len() > 0 && (mgr.maxSketches > 0 && mgr.lru.Len() >= mgr.maxSketches ||
+		mgr.budget > 0 && mgr.used+bytes > mgr.budget) {
+		evicted = append(evicted, mgr.evict(mgr.lru.Back()))
+	}
+	mgr.sketches[name] = mgr.lru.PushFront(&managed{name: name, sketch: sketch, bytes: bytes,
+		n: sketch.N(), active: time.Now()})
+	mgr.used += bytes
+	return sketch, evicted, nil
+}
+
+// notify calls onEvict, if any, for the evicted sketches, releasing the name
+// of each once done.
+func (mgr *Manager) notify(onEvict func(name string, sketch *Sketch), evicted []*managed) {
+	for _, m := range evicted {
+		if onEvict != nil {
+			onEvict(m.name, m.sketch)
+		}
+		mgr.mu.Lock()
+		close(mgr.evicting[m.name])
+		delete(mgr.evicting, m.name)
+		mgr.mu.Unlock()
+	}
+}
+
+/*
+Lookup returns the sketch of name if the Manager holds it, without creating
+it nor marking it as used.
+*/
+func (mgr *Manager) Lookup(name string) (*Sketch, bool) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	e, ok := mgr.sketches[name]
+	if !ok {
+		return nil, false
+	}
+	return e.Value.(*managed).sketch, true
+}
+
+/*
+Remove drops the sketch of name, and returns whether the Manager held it.
+*/
+func (mgr *Manager) Remove(name string) bool {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	e, ok := mgr.sketches[name]
+	if ok {
+		mgr.remove(e)
+	}
+	return ok
+}
+
+// evict removes the sketch of e and reserves its name until notify is done
+// with it.
+func (mgr *Manager) evict(e *list.Element) *managed {
+	m := mgr.remove(e)
+	mgr.evicting[m.name] = make(chan struct{})
+	return m
+}
+
+func (mgr *Manager) remove(e *list.Element) *managed {
+	m := mgr.lru.Remove(e).(*managed)
+	delete(mgr.sketches, m.name)
+	mgr.used -= m.bytes
+	return m
+}
+
+/*
+Names returns the names of the sketches held, in lexical order.
+*/
+func (mgr *Manager) Names() []string {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	names := make([]string, 0, len(mgr.sketches))
+	for name := range mgr.sketches {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+/*
+Len returns the number of sketches held.
+*/
+func (mgr *Manager) Len() int {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	return mgr.lru.Len()
+}
+
+/*
+MemoryUsage returns the current MemoryUsage of each sketch held, by name.
+Their sum may exceed the memory accounted against the budget if sketches
+grew since they were created, as sparse backends do.
+*/
+func (mgr *Manager) MemoryUsage() map[string]uint64 {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	usage := make(map[string]uint64, len(mgr.sketches))
+	for name, e := range mgr.sketches {
+		usage[name] = e.Value.(*managed).sketch.MemoryUsage()
+	}
+	return usage
+}
+
+/*
+Snapshot returns a Snapshot of each sketch held, by name, all taken while
+no sketch is created or evicted. Snapshots don't copy the bitmaps, see
+Sketch.Snapshot, so this is cheap even for many sketches.
+*/
+func (mgr *Manager) Snapshot() map[string]*Snapshot {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	snapshots := make(map[string]*Snapshot, len(mgr.sketches))
+	for name, e := range mgr.sketches {
+		snapshots[name] = e.Value.(*managed).sketch.Snapshot()
+	}
+	return snapshots
+}
+
+/*
+SetOnEvict sets the function called after a sketch is evicted, because of
+the limits of the Manager or by ExpireIdle, but not by Remove, e.g. to flush
+the sketch to disk with SaveToDir. It's called outside of the lock of the
+Manager, and Get waits for it to return before creating a sketch of the same
+name again, so fn must not call Get with that name.
+*/
+func (mgr *Manager) SetOnEvict(fn func(name string, sketch *Sketch)) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	mgr.onEvict = fn
+}
+
+/*
+ExpireIdle evicts the sketches whose number of additions hasn't changed for
+at least ttl, and returns how many were evicted. A sketch is seen to change
+when created and by each call of ExpireIdle, so ttl is only accurate to the
+interval between calls.
+*/
+func (mgr *Manager) ExpireIdle(ttl time.Duration) int {
+	return mgr.expireIdle(ttl, time.Now())
+}
+
+func (mgr *Manager) expireIdle(ttl time.Duration, now time.Time) int {
+	mgr.mu.Lock()
+	var evicted []*managed
+	for e := mgr.lru.Front(); e != nil; {
+		next := e.Next()
+		m := e.Value.(*managed)
+		if n := m.sketch.N(); n != m.n {
+			m.n, m.active = n, now
+		} else if now.Sub(m.active) >= ttl {
+			evicted = append(evicted, mgr.evict(e))
+		}
+		e = next
+	}
+	onEvict := mgr.onEvict
+	mgr.mu.Unlock()
+	mgr.notify(onEvict, evicted)
+	return len(evicted)
+}
+
+/*
+ExpireEvery calls ExpireIdle with ttl every interval until Close is called.
+*/
+func (mgr *Manager) ExpireEvery(ttl, interval time.Duration) {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.stop != nil {
+		close(mgr.stop)
+	}
+	mgr.stop = make(chan struct{})
+	go mgr.expireEvery(ttl, interval, mgr.stop)
+}
+
+func (mgr *Manager) expireEvery(ttl, interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			mgr.expireIdle(ttl, now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Close stops the expiry started by ExpireEvery.
+*/
+func (mgr *Manager) Close() {
+	mgr.mu.Lock()
+	defer mgr.mu.Unlock()
+	if mgr.stop != nil {
+		close(mgr.stop)
+		mgr.stop = nil
+	}
+}
+
+// sketchPath returns the file of the sketch of name in dir.
+func sketchPath(dir, name string) string {
+	return filepath.Join(dir, url.PathEscape(name)+".pmc")
+}
+
+/*
+SaveToDir returns a function for SetOnEvict writing evicted sketches to dir,
+one file per name, from which LoadFromDir restores them. Each sketch is
+written to a temporary file renamed over its file once synced, so that a
+crash leaves either the previous file or the new one. Errors are passed to
+onError if not nil.
+*/
+func SaveToDir(dir string, onError func(name string, err error)) func(name string, sketch *Sketch) {
+	return func(name string, sketch *Sketch) {
+		err := func() error {
+			f, err := os.CreateTemp(dir, ".pmc-*")
+			if err != nil {
+				return err
+			}
+			defer os.Remove(f.Name())
+			if _, err := sketch.WriteTo(f); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Sync(); err != nil {
+				f.Close()
+				return err
+			}
+			if err := f.Close(); err != nil {
+				return err
+			}
+			return os.Rename(f.Name(), sketchPath(dir, name))
+		}()
+		if err != nil && onError != nil {
+			onError(name, err)
+		}
+	}
+}
+
+/*
+LoadFromDir returns a factory for NewManager restoring the sketches saved to
+dir by SaveToDir, and removing their file, into sketches created by factory,
+so that they keep its options. Sketches that weren't saved are created by
+factory.
+*/
+func LoadFromDir(dir string, factory func(name string) (*Sketch, error)) func(name string) (*Sketch, error) {
+	return func(name string) (*Sketch, error) {
+		sketch, err := factory(name)
+		if err != nil {
+			return nil, err
+		}
+		path := sketchPath(dir, name)
+		f, err := os.Open(path)
+		if errors.Is(err, os.ErrNotExist) {
+			return sketch, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		if _, err := sketch.ReadFrom(f); err != nil {
+			return nil, err
+		}
+		return sketch, os.Remove(path)
+	}
+}
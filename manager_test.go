@@ -0,0 +1,179 @@
+package pmc
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func newTestManager(t *testing.T, maxSketches int, budget uint64) *Manager {
+	mgr, err := NewManager(maxSketches, budget, func(string) (*Sketch, error) {
+		return New(1<<16, 64, 32)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return mgr
+}
+
+func TestManager(t *testing.T) {
+	mgr := newTestManager(t, 2, 0)
+	a, err := mgr.Get("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.Add([]byte("flow"), 1000)
+	if again, _ := mgr.Get("a"); again != a {
+		t.Error("Expected Get to return the existing sketch")
+	}
+	mgr.Get("b")
+	mgr.Get("a")
+	// b is the least recently used.
+	mgr.Get("c")
+	if names := mgr.Names(); !reflect.DeepEqual(names, []string{"a", "c"}) {
+		t.Error("Expected the least recently used sketch to be evicted, got", names)
+	}
+
+	snapshots := mgr.Snapshot()
+	if len(snapshots) != 2 || snapshots["a"].N() != 1000 {
+		t.Error("Expected a snapshot of every sketch, got", snapshots)
+	}
+	if usage := mgr.MemoryUsage(); usage["a"] != a.MemoryUsage() {
+		t.Error("Expected the memory usage of every sketch, got", usage)
+	}
+	if !mgr.Remove("a") || mgr.Remove("a") || mgr.Len() != 1 {
+		t.Error("Expected Remove to drop a sketch once")
+	}
+	if _, ok := mgr.Lookup("a"); ok {
+		t.Error("Expected Lookup not to create sketches")
+	}
+}
+
+func TestManagerBudget(t *testing.T) {
+	s, _ := New(1<<16, 64, 32)
+	size := s.MemoryUsage()
+	mgr := newTestManager(t, 0, 3*size)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		if _, err := mgr.Get(name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := mgr.Names(); !reflect.DeepEqual(names, []string{"b", "c", "d"}) {
+		t.Error("Expected the budget to hold 3 sketches, got", names)
+	}
+
+	small := newTestManager(t, 0, size/2)
+	if _, err := small.Get("a"); err == nil {
+		t.Error("Expected error for a sketch larger than the budget, got nil")
+	}
+	failing, _ := NewManager(0, 0, func(string) (*Sketch, error) {
+		return nil, errors.New("failed")
+	})
+	if _, err := failing.Get("a"); err == nil || failing.Len() != 0 {
+		t.Error("Expected the error of the factory, got", err)
+	}
+}
+
+func TestManagerExpireIdle(t *testing.T) {
+	mgr := newTestManager(t, 0, 0)
+	var evicted []string
+	mgr.SetOnEvict(func(name string, sketch *Sketch) {
+		evicted = append(evicted, name)
+	})
+	idle, _ := mgr.Get("idle")
+	busy, _ := mgr.Get("busy")
+	idle.Increment([]byte("flow"))
+	start := time.Now()
+	mgr.expireIdle(time.Minute, start)
+	busy.Increment([]byte("flow"))
+	if n := mgr.expireIdle(time.Minute, start.Add(2*time.Minute)); n != 1 {
+		t.Error("Expected 1 idle sketch to expire, got", n)
+	}
+	if !reflect.DeepEqual(evicted, []string{"idle"}) || mgr.Len() != 1 {
+		t.Error("Expected the idle sketch to be evicted, got", evicted)
+	}
+}
+
+func TestManagerSaveToDir(t *testing.T) {
+	dir := t.TempDir()
+	factory := func(string) (*Sketch, error) {
+		return New(1<<16, 64, 32, WithThreadSafety())
+	}
+	failed := false
+	mgr, _ := NewManager(1, 0, LoadFromDir(dir, factory))
+	mgr.SetOnEvict(SaveToDir(dir, func(string, error) { failed = true }))
+
+	a, _ := mgr.Get("tenant/a")
+	a.Add([]byte("flow"), 1000)
+	est := a.GetEstimate([]byte("flow"))
+	mgr.Get("tenant/b")
+	if _, err := os.Stat(sketchPath(dir, "tenant/a")); err != nil || failed {
+		t.Fatal("Expected the evicted sketch to be saved, got", err)
+	}
+
+	restored, err := mgr.Get("tenant/a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e := restored.GetEstimate([]byte("flow")); e != est || restored.N() != 1000 {
+		t.Errorf("Expected the restored sketch to estimate %f, got %f", est, e)
+	}
+	if _, err := os.Stat(sketchPath(dir, "tenant/a")); !os.IsNotExist(err) {
+		t.Error("Expected the file of the restored sketch to be removed, got", err)
+	}
+}
+
+func TestManagerGetWhileEvicting(t *testing.T) {
+	dir := t.TempDir()
+	mgr, _ := NewManager(0, 0, LoadFromDir(dir, func(string) (*Sketch, error) {
+		return New(1<<16, 64, 32, WithThreadSafety())
+	}))
+	save := SaveToDir(dir, func(_ string, err error) { t.Error(err) })
+	saving, release := make(chan struct{}), make(chan struct{})
+	mgr.SetOnEvict(func(name string, sketch *Sketch) {
+		close(saving)
+		<-release
+		save(name, sketch)
+	})
+	a, _ := mgr.Get("a")
+	a.Add([]byte("flow"), 1000)
+	start := time.Now()
+	mgr.expireIdle(time.Minute, start)
+	go mgr.expireIdle(time.Minute, start.Add(2*time.Minute))
+	<-saving
+
+	got := make(chan *Sketch)
+	go func() {
+		sketch, err := mgr.Get("a")
+		if err != nil {
+			t.Error(err)
+		}
+		got <- sketch
+	}()
+	select {
+	case <-got:
+		t.Fatal("Expected Get to wait for the evicted sketch to be saved")
+	case <-time.After(10 * time.Millisecond):
+	}
+	close(release)
+	if restored := <-got; restored.N() != 1000 {
+		t.Error("Expected the saved sketch to be restored, got", restored.N())
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Error("Expected no file left behind, got", entries)
+	}
+}
+
+func TestManagerExpireEvery(t *testing.T) {
+	mgr := newTestManager(t, 0, 0)
+	evicted := make(chan string, 1)
+	mgr.SetOnEvict(func(name string, sketch *Sketch) { evicted <- name })
+	mgr.Get("a")
+	mgr.ExpireEvery(time.Millisecond, time.Millisecond)
+	defer mgr.Close()
+	if name := <-evicted; name != "a" {
+		t.Error("Expected a to expire, got", name)
+	}
+}
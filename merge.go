@@ -0,0 +1,84 @@
+package pmc
+
+import "errors"
+
+/*
+Merge folds other into the sketch by OR-ing the bitmaps, so the result is
+the sketch that would have been built from both streams of additions. Both
+sketches must have been created with the same l, m, w and hash seed, and
+other must not be modified during the merge, nor be the sketch itself, whose
+additions would be counted twice. Sketches meant to be merged should draw
+from generators with different seeds, or identical sequences of additions
+would set the same bits in both, but share the hash seed: WithSeed derives
+the hash seed from the seed too, so it must be combined with the same
+WithHashSeed on all of them, e.g. WithHashSeed(first.HashSeed()).
+*/
+func (sketch *Sketch) Merge(other *Sketch) error {
+	if err := sketch.checkMergeable(other); err != nil {
+		return err
+	}
+	sketch.mu.Lock()
+	sketch.merge(other)
+	sketch.mu.Unlock()
+	return nil
+}
+
+// errSelfMerge is returned when merging a sketch into itself.
+var errSelfMerge = errors.New("Expected another sketch than the one merged into")
+
+// checkMergeable returns an error unless other can be merged into the sketch.
+func (sketch *Sketch) checkMergeable(other *Sketch) error {
+	if other == sketch {
+		return errSelfMerge
+	}
+	return sketch.checkCompatible(other)
+}
+
+func (sketch *Sketch) merge(other *Sketch) {
+	sketch.union(other)
+	sketch.recount()
+	if sketch.hll != nil && other.hll != nil && sketch.hll.p == other.hll.p {
+		sketch.hll.merge(other.hll)
+	}
+	sketch.addN(other.N())
+}
+
+/*
+MergeAll merges all of others into the sketch. The sketch is left untouched
+if any of them has different parameters or is the sketch itself.
+*/
+func (sketch *Sketch) MergeAll(others ...*Sketch) error {
+	for _, other := range others {
+		if err := sketch.checkMergeable(other); err != nil {
+			return err
+		}
+	}
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	for _, other := range others {
+		sketch.merge(other)
+	}
+	return nil
+}
+
+/*
+Union returns a new sketch holding the merge of all given sketches, leaving
+them untouched.
+*/
+func Union(sketches ...*Sketch) (*Sketch, error) {
+	if len(sketches) == 0 {
+		return nil, errors.New("Expected at least 1 sketch, got 0")
+	}
+	first := sketches[0]
+	for _, other := range sketches[1:] {
+		if err := first.checkCompatible(other); err != nil {
+			return nil, err
+		}
+	}
+
+	union := first.Clone()
+	for _, other := range sketches[1:] {
+		union.merge(other)
+	}
+	return union, nil
+}
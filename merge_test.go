@@ -0,0 +1,89 @@
+package pmc
+
+import "testing"
+
+func TestMerge(t *testing.T) {
+	a, _ := New(1024, 8, 8)
+	b, _ := New(1024, 8, 8, WithHashSeed(a.HashSeed()))
+	a.setBit(1)
+	a.n = 1
+	b.setBit(2)
+	b.n = 2
+
+	if err := a.Merge(b); err != nil {
+		t.Fatal(err)
+	}
+	if !a.bitmap.test(1) || !a.bitmap.test(2) {
+		t.Error("Expected merged bitmap to hold the bits of both sketches")
+	}
+	if a.n != 3 {
+		t.Error("Expected merged n == 3, got", a.n)
+	}
+
+	c, _ := New(1024, 8, 4, WithHashSeed(a.HashSeed()))
+	if err := a.Merge(c); err == nil {
+		t.Error("Expected error merging sketches with different w, got nil")
+	}
+	d, _ := New(1024, 8, 8)
+	if err := a.Merge(d); err == nil {
+		t.Error("Expected error merging sketches with different hash seeds, got nil")
+	}
+}
+
+func TestUnion(t *testing.T) {
+	sketches := make([]*Sketch, 3)
+	for i := range sketches {
+		sketches[i], _ = New(1024, 8, 8, WithHashSeed(42))
+		sketches[i].setBit(uint(i))
+		sketches[i].n = 1
+	}
+
+	u, err := Union(sketches...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range sketches {
+		if !u.bitmap.test(uint(i)) {
+			t.Errorf("Expected union to hold bit %d", i)
+		}
+		if sketches[i].bitmap.count() != 1 || sketches[i].n != 1 {
+			t.Errorf("Expected sketch %d to be left untouched", i)
+		}
+	}
+	if u.n != 3 {
+		t.Error("Expected union n == 3, got", u.n)
+	}
+
+	if _, err := Union(); err == nil {
+		t.Error("Expected error for empty union, got nil")
+	}
+}
+
+func TestMergeAllIncompatible(t *testing.T) {
+	a, _ := New(1024, 8, 8, WithHashSeed(42))
+	b, _ := New(1024, 8, 8, WithHashSeed(42))
+	c, _ := New(2048, 8, 8, WithHashSeed(42))
+	b.setBit(5)
+
+	if err := a.MergeAll(b, c); err == nil {
+		t.Error("Expected error merging incompatible sketches, got nil")
+	}
+	if a.bitmap.any() {
+		t.Error("Expected sketch to be left untouched on error")
+	}
+}
+
+func TestMergeSelf(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	s.IncrementN([]byte("flow"), 100)
+	if err := s.Merge(s); err == nil {
+		t.Error("Expected error merging a sketch into itself, got nil")
+	}
+	other := s.Clone()
+	if err := s.MergeAll(other, s); err == nil {
+		t.Error("Expected error merging a sketch into itself, got nil")
+	}
+	if s.N() != 100 {
+		t.Error("Expected n = 100 after failed self merges, got", s.N())
+	}
+}
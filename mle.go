@@ -0,0 +1,108 @@
+package pmc
+
+import "math"
+
+/*
+WithMLE makes all estimates of the sketch, from GetEstimate to FlowRef and
+GetEstimates, use the maximum likelihood estimator of GetEstimateMLE instead
+of the estimator of the PMC paper.
+*/
+func WithMLE() Option {
+	return func(sketch *Sketch) error {
+		sketch.mle = true
+		return nil
+	}
+}
+
+/*
+GetEstimateMLE returns the maximum likelihood estimate of the count of a
+given flow: the count under which the bits of its virtual matrix, set by its
+own additions or by the noise of the other flows at the fill rate of the
+sketch, are the most likely. Unlike GetEstimate it uses every bit of the
+virtual matrix, not only the leading runs of the rows, which makes it more
+accurate at high fill rates, at the cost of a few dozen passes over the w
+columns. It returns 0 if none of the rows of the flow has been hit or the
+sketch has no additions.
+*/
+func (sketch *Sketch) GetEstimateMLE(flow []byte) float64 {
+	sketch.mu.Lock()
+	key, buf := sketch.key(flow)
+	e := sketch.estimateMLE(sketch.flowPositions(key), sketch.getP())
+	releaseKey(buf)
+	sketch.mu.Unlock()
+	if sketch.hooks != nil && sketch.hooks.OnEstimate != nil {
+		sketch.hooks.OnEstimate(flow, e)
+	}
+	return e
+}
+
+// estimateMLE returns the maximum likelihood estimate of the count of the
+// flow at getPos given the fill rate p. The cells of the virtual matrix are
+// taken to be independent: a cell of column j is set with probability
+// 1-(1-p)*r_j^n after n additions, r_j being the probability of an addition
+// missing it. The log-likelihood is concave in n, so its maximum is found by
+// bisecting its derivative.
+func (sketch *Sketch) estimateMLE(getPos positions, p float64) float64 {
+	if sketch.N() == 0 {
+		return 0
+	}
+	m := float64(sketch.m)
+	set := make([]float64, sketch.w)
+	total := 0.0
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			if sketch.test(getPos(i, j)) {
+				set[j]++
+				total++
+			}
+		}
+	}
+	if total == 0 || sketch.getEmptyRows(getPos) == m {
+		return 0
+	}
+
+	logR := make([]float64, sketch.w)
+	for j := range logR {
+		logR[j] = math.Log1p(-sketch.columnProb(uint(j)) * sketch.keepProb(uint(j)) / m)
+	}
+	logC := math.Log1p(-p)
+	// derivative returns the derivative of the log-likelihood at n.
+	derivative := func(n float64) float64 {
+		d := 0.0
+		for j, lr := range logR {
+			if lr == 0 {
+				continue
+			}
+			d += (m - set[j]) * lr
+			if set[j] > 0 {
+				// c*r^n is the probability of a cell staying unset.
+				miss := math.Exp(logC + n*lr)
+				d -= set[j] * miss * lr / -math.Expm1(logC+n*lr)
+			}
+		}
+		return d
+	}
+
+	// The count is bounded by the capacity of the virtual matrix.
+	limit := math.Ldexp(m, int(sketch.w))
+	hi := 1.0
+	for derivative(hi) > 0 {
+		if hi >= limit {
+			return limit
+		}
+		hi *= 2
+	}
+	lo := hi / 2
+	if hi == 1 {
+		lo = 0
+	}
+	for k := 0; k < 100 && hi-lo > 1e-9*hi; k++ {
+		mid := (lo + hi) / 2
+		if derivative(mid) > 0 {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return (lo + hi) / 2
+}
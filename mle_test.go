@@ -0,0 +1,43 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetEstimateMLE(t *testing.T) {
+	s, _ := New(1<<18, 64, 32, WithDeterministic(7))
+	// Noise bringing the fill rate to about 70%.
+	for i := 0; s.GetFillRate() < 70; i++ {
+		s.Add([]byte(fmt.Sprint("noise-", i)), 500)
+	}
+	for i := 0; i < 100; i++ {
+		s.Add([]byte(fmt.Sprint("flow-", i)), 1000)
+	}
+	var mleErr, pmcErr float64
+	for i := 0; i < 100; i++ {
+		flow := []byte(fmt.Sprint("flow-", i))
+		mleErr += math.Abs(s.GetEstimateMLE(flow)-1000) / 1000 / 100
+		pmcErr += math.Abs(s.GetEstimate(flow)-1000) / 1000 / 100
+	}
+	if mleErr > 0.25 || mleErr > pmcErr {
+		t.Errorf("Expected a mean relative error below 25%% and %f, got %f", pmcErr, mleErr)
+	}
+
+	empty, _ := New(1<<16, 64, 32)
+	if e := empty.GetEstimateMLE([]byte("flow")); e != 0 {
+		t.Error("Expected 0 on an empty sketch, got", e)
+	}
+}
+
+func TestWithMLE(t *testing.T) {
+	a, _ := New(1<<20, 256, 32, WithDeterministic(7), WithMLE())
+	a.Add([]byte("flow"), 5000)
+	if e, mle := a.GetEstimate([]byte("flow")), a.GetEstimateMLE([]byte("flow")); e != mle {
+		t.Errorf("Expected GetEstimate to use the MLE %f, got %f", mle, e)
+	}
+	if e := a.Clone().GetEstimate([]byte("flow")); math.Abs(e-5000) > 750 {
+		t.Error("Expected estimate within 15% of 5000, got", e)
+	}
+}
@@ -0,0 +1,277 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"unsafe"
+)
+
+// mappedMagic starts every memory-mapped sketch file.
+const mappedMagic = "PMCM"
+
+// mappedHeaderSize is the size of the page holding the mappedHeader, which
+// keeps the bitmap page aligned.
+const mappedHeaderSize = 4096
+
+// mappedHeader starts a memory-mapped sketch file. The fields are in the
+// native byte order, so files are not portable between architectures; use
+// WriteTo for that.
+type mappedHeader struct {
+	magic    [4]byte
+	version  uint8
+	_        [3]byte
+	l, m, w  uint64
+	hashSeed uint64
+	n, ones  uint64
+}
+
+// mapping is the memory-mapped file of a sketch.
+type mapping struct {
+	file   *os.File
+	data   []byte
+	header *mappedHeader
+}
+
+func mapFile(f *os.File, size int, writable bool) (*mapping, error) {
+	data, err := mmap(f, size, writable)
+	if err != nil {
+		return nil, err
+	}
+	return &mapping{file: f, data: data, header: (*mappedHeader)(unsafe.Pointer(&data[0]))}, nil
+}
+
+func (mp *mapping) words(l uint) bitArray {
+	return unsafe.Slice((*uint64)(unsafe.Pointer(&mp.data[mappedHeaderSize])), (l+63)/64)
+}
+
+func (mp *mapping) close() error {
+	err := munmap(mp.data)
+	if cerr := mp.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+func mappedSize(l uint) int {
+	return mappedHeaderSize + 8*int((l+63)/64)
+}
+
+/*
+OpenMapped returns a sketch whose bitmap lives in the memory-mapped file at
+path, so that a restarted process recovers its state without decoding it,
+and other processes can estimate while it is written to, see
+OpenMappedReader. The file is created with the given parameters if it
+doesn't exist, otherwise its parameters must match them, and its hash seed
+is kept.
+
+The number of additions and set bits are stored in the file by Sync and
+Close, which have to be called for them to survive the process. The set bits
+are counted again on open, so a file left by a crashed process only loses
+the additions since its last Sync.
+*/
+func OpenMapped(path string, l, m, w uint, opts ...Option) (*Sketch, error) {
+	if err := checkParams(uint64(l), uint64(m), uint64(w)); err != nil {
+		return nil, err
+	}
+	// The bitmap of one bit allocated by New is replaced by the mapped one.
+	sketch, err := New(1, 1, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sketch.m = m
+	if sketch.backend != nil {
+		return nil, errors.New("Memory-mapped sketches only support the default bitmap")
+	}
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	created := info.Size() == 0
+	if created {
+		if err := f.Truncate(int64(mappedSize(l))); err != nil {
+			f.Close()
+			return nil, err
+		}
+	} else if info.Size() != int64(mappedSize(l)) {
+		f.Close()
+		return nil, fmt.Errorf("Expected a mapped sketch of %d bytes, got %d", mappedSize(l), info.Size())
+	}
+	mp, err := mapFile(f, mappedSize(l), true)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	h := mp.header
+	if created {
+		copy(h.magic[:], mappedMagic)
+		h.version = 1
+		h.l, h.m, h.w, h.hashSeed = uint64(l), uint64(m), uint64(w), sketch.hashSeed
+	} else if err := h.check(); err != nil {
+		mp.close()
+		return nil, err
+	} else if h.l != uint64(l) || h.m != uint64(m) || h.w != uint64(w) {
+		err := fmt.Errorf("Expected l, m, w = %d, %d, %d, got %d, %d, %d", l, m, w, h.l, h.m, h.w)
+		mp.close()
+		return nil, err
+	}
+
+	bitmap := mp.words(l)
+	if bitmap.tail(l) != 0 {
+		mp.close()
+		return nil, errors.New("Expected the bits beyond l to be zero")
+	}
+	sketch.l = l
+	sketch.bitmap = bitmap
+	sketch.hashSeed = h.hashSeed
+	sketch.n = atomic.LoadUint64(&h.n)
+	// The stored count of set bits is stale if the process died between an
+	// addition and the next Sync, so it's counted again.
+	sketch.ones = uint64(bitmap.count())
+	atomic.StoreUint64(&h.ones, sketch.ones)
+	sketch.mapping = mp
+	return sketch, nil
+}
+
+func (h *mappedHeader) check() error {
+	if string(h.magic[:]) != mappedMagic {
+		return errors.New("Invalid mapped sketch header")
+	}
+	if h.version != 1 {
+		return fmt.Errorf("Unsupported mapped sketch version %d", h.version)
+	}
+	return checkParams(h.l, h.m, h.w)
+}
+
+/*
+Sync stores the number of additions and set bits of a memory-mapped sketch
+in its file, and flushes the file to disk.
+*/
+func (sketch *Sketch) Sync() error {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	return sketch.sync()
+}
+
+func (sketch *Sketch) sync() error {
+	mp := sketch.mapping
+	if mp == nil {
+		return errors.New("Sketch is not memory-mapped")
+	}
+	h := mp.header
+	h.m, h.w, h.hashSeed = uint64(sketch.m), uint64(sketch.w), sketch.hashSeed
+	atomic.StoreUint64(&h.ones, atomic.LoadUint64(&sketch.ones))
+	atomic.StoreUint64(&h.n, sketch.N())
+	return msync(mp.data)
+}
+
+/*
+Close syncs a memory-mapped sketch and unmaps it. The sketch must not be used
+afterwards.
+*/
+func (sketch *Sketch) Close() error {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	err := sketch.sync()
+	if sketch.mapping != nil {
+		if cerr := sketch.mapping.close(); err == nil {
+			err = cerr
+		}
+		sketch.mapping, sketch.bitmap = nil, nil
+	}
+	return err
+}
+
+/*
+MappedReader estimates flows from the file of a memory-mapped sketch while
+another process writes to it. The bitmap is read live, while the number of
+additions and set bits are those of the last Sync of the writer. A
+MappedReader is safe for concurrent use, and hashes flows with the default
+FarmHasher.
+*/
+type MappedReader struct {
+	sketch  *Sketch
+	mapping *mapping
+}
+
+/*
+OpenMappedReader opens the file of a memory-mapped sketch read-only.
+*/
+func OpenMappedReader(path string) (*MappedReader, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if info.Size() < mappedHeaderSize {
+		f.Close()
+		return nil, errors.New("Invalid mapped sketch header")
+	}
+	hdr, err := mapFile(f, mappedHeaderSize, false)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	h := *hdr.header
+	munmap(hdr.data)
+	if err := h.check(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	l := uint(h.l)
+	if info.Size() != int64(mappedSize(l)) {
+		f.Close()
+		return nil, fmt.Errorf("Expected a mapped sketch of %d bytes, got %d", mappedSize(l), info.Size())
+	}
+	mp, err := mapFile(f, mappedSize(l), false)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	sketch := &Sketch{l: l, m: uint(h.m), w: uint(h.w), bitmap: mp.words(l), hashSeed: h.hashSeed,
+		mu: &sync.Mutex{}}
+	sketch.setDefaults()
+	return &MappedReader{sketch: sketch, mapping: mp}, nil
+}
+
+// refresh loads the number of additions and set bits last synced.
+func (r *MappedReader) refresh() {
+	atomic.StoreUint64(&r.sketch.n, atomic.LoadUint64(&r.mapping.header.n))
+	atomic.StoreUint64(&r.sketch.ones, atomic.LoadUint64(&r.mapping.header.ones))
+}
+
+/*
+GetEstimate returns the estimated count of a given flow
+*/
+func (r *MappedReader) GetEstimate(flow []byte) float64 {
+	r.sketch.mu.Lock()
+	defer r.sketch.mu.Unlock()
+	r.refresh()
+	return r.sketch.getEstimate(flow)
+}
+
+/*
+N returns the number of additions of the sketch at its last Sync.
+*/
+func (r *MappedReader) N() uint64 {
+	return atomic.LoadUint64(&r.mapping.header.n)
+}
+
+/*
+Close unmaps the file.
+*/
+func (r *MappedReader) Close() error {
+	return r.mapping.close()
+}
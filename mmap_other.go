@@ -0,0 +1,20 @@
+//go:build !unix
+
+package pmc
+
+import (
+	"errors"
+	"os"
+)
+
+func mmap(f *os.File, size int, writable bool) ([]byte, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func munmap(data []byte) error {
+	return errors.ErrUnsupported
+}
+
+func msync(data []byte) error {
+	return errors.ErrUnsupported
+}
@@ -0,0 +1,113 @@
+//go:build unix
+
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpenMapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sketch.pmc")
+	sketch, err := OpenMapped(path, 8000000, 256, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := []byte("hello")
+	for i := 0; i < 100000; i++ {
+		sketch.Increment(flow)
+	}
+	for i := 0; i < 10000; i++ {
+		sketch.Increment([]byte(fmt.Sprint(i)))
+	}
+	want := sketch.GetEstimate(flow)
+	if err := sketch.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := OpenMapped(path, 8000000, 256, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer restored.Close()
+	if restored.N() != 110000 {
+		t.Errorf("Expected n = 110000, got %d", restored.N())
+	}
+	if got := restored.GetEstimate(flow); got != want {
+		t.Errorf("Expected restored estimate %f, got %f", want, got)
+	}
+
+	if _, err := OpenMapped(path, 8000000, 128, 32); err == nil {
+		t.Error("Expected an error for mismatching parameters")
+	}
+}
+
+func TestOpenMappedCrashed(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sketch.pmc")
+	sketch, err := OpenMapped(path, 1<<20, 64, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sketch.Increment([]byte("synced"))
+	sketch.Sync()
+	for i := 0; i < 1000; i++ {
+		sketch.Increment([]byte(fmt.Sprint(i)))
+	}
+	ones := sketch.ones
+	// A crash leaves the bits in the file but not the counts since Sync.
+	sketch.mapping.close()
+
+	restored, err := OpenMapped(path, 1<<20, 64, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if restored.N() != 1 || restored.ones != ones {
+		t.Errorf("Expected n = 1 and %d set bits, got %d and %d", ones, restored.N(), restored.ones)
+	}
+	restored.Close()
+
+	small := filepath.Join(t.TempDir(), "small.pmc")
+	s, _ := OpenMapped(small, 100, 4, 4)
+	s.Close()
+	f, _ := os.OpenFile(small, os.O_RDWR, 0)
+	// Bit 100 is bit 36 of the second word.
+	f.WriteAt([]byte{1 << 4}, mappedHeaderSize+8+4)
+	f.Close()
+	if _, err := OpenMapped(small, 100, 4, 4); err == nil {
+		t.Error("Expected an error for bits beyond l")
+	}
+}
+
+func TestMappedReader(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sketch.pmc")
+	sketch, err := OpenMapped(path, 8000000, 256, 32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sketch.Close()
+	reader, err := OpenMappedReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer reader.Close()
+
+	flow := []byte("hello")
+	for i := 0; i < 100000; i++ {
+		sketch.Increment(flow)
+	}
+	if err := sketch.Sync(); err != nil {
+		t.Fatal(err)
+	}
+	if reader.N() != 100000 {
+		t.Errorf("Expected n = 100000, got %d", reader.N())
+	}
+	if got := reader.GetEstimate(flow); math.Abs(got-100000)/100000 > 0.1 {
+		t.Errorf("Expected estimate close to 100000, got %f", got)
+	}
+	if got, want := reader.GetEstimate(flow), sketch.GetEstimate(flow); got != want {
+		t.Errorf("Expected reader estimate %f, got %f", want, got)
+	}
+}
@@ -0,0 +1,260 @@
+package pmc
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"errors"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+
+	"github.com/lazybeaver/xorshift"
+)
+
+/*
+Option configures a Sketch created by New.
+*/
+type Option func(*Sketch) error
+
+/*
+WithSeed seeds the random number generator of the sketch, so that sketches
+created with the same parameters and seed, and fed the same additions, end up
+in the same state. Unless set by WithHashSeed, the hash seed of the sketch is
+derived from seed as well. The seed must not be 0.
+*/
+func WithSeed(seed uint64) Option {
+	return func(sketch *Sketch) error {
+		if seed == 0 {
+			return errors.New("Expected seed != 0, got 0")
+		}
+		sketch.rnd = xorshift.NewXorShift64Star(seed)
+		if sketch.hashSeed == 0 {
+			sketch.hashSeed = mix64(seed) | 1
+		}
+		return nil
+	}
+}
+
+/*
+WithDeterministic makes the state of the sketch, and so its estimates, a
+function of seed and of the sequence of additions only: the random number
+generator and the hash seed are derived from seed as by WithSeed, and
+options meant for concurrent writers, such as WithLockFree, are rejected.
+Sketches are then reproducible across runs, see GenerateGolden. Only the
+sequence of additions is pinned: concurrent writers still add in varying
+orders.
+*/
+func WithDeterministic(seed uint64) Option {
+	return func(sketch *Sketch) error {
+		if err := WithSeed(seed)(sketch); err != nil {
+			return err
+		}
+		sketch.hashSeed = mix64(seed) | 1
+		sketch.deterministic = true
+		return nil
+	}
+}
+
+/*
+WithChaCha8 makes the sketch draw its rows and columns from the ChaCha8
+cryptographically secure generator of math/rand/v2 seeded with seed, instead
+of xorshift, so that the choices of the sketch can't be predicted from the
+ones it made before, e.g. by an adversary shaping traffic to saturate the
+rows of a flow. Unless set by WithHashSeed, the hash seed of the sketch is
+drawn from the generator. The seed must be kept secret.
+*/
+func WithChaCha8(seed [32]byte) Option {
+	return func(sketch *Sketch) error {
+		r := &chachaRand{ChaCha8: rand.NewChaCha8(seed)}
+		sketch.rnd = r
+		if sketch.hashSeed == 0 {
+			sketch.hashSeed = r.Next() | 1
+		}
+		return nil
+	}
+}
+
+/*
+WithCryptoRand is WithChaCha8 seeded from crypto/rand, so that not even the
+seed is known. It can't be combined with WithDeterministic, and lock-free
+sketches have their own generator, see WithLockFree.
+*/
+func WithCryptoRand() Option {
+	return func(sketch *Sketch) error {
+		var seed [32]byte
+		if _, err := crand.Read(seed[:]); err != nil {
+			return err
+		}
+		if err := WithChaCha8(seed)(sketch); err != nil {
+			return err
+		}
+		sketch.rnd.(*chachaRand).random = true
+		return nil
+	}
+}
+
+/*
+WithHashSeed sets the secret value the hash seeds of the sketch are derived
+from, instead of a random one. Sketches meant to be merged must share it,
+e.g. by creating them with WithHashSeed(first.HashSeed()). The seed must not
+be 0.
+*/
+func WithHashSeed(seed uint64) Option {
+	return func(sketch *Sketch) error {
+		if seed == 0 {
+			return errors.New("Expected hash seed != 0, got 0")
+		}
+		sketch.hashSeed = seed
+		return nil
+	}
+}
+
+/*
+WithHash makes the sketch derive bit positions from h instead of farmhash.
+Estimates are an order of magnitude cheaper with a FlowHasher such as
+TwoLevelHasher, which hashes the flow once per estimate.
+*/
+func WithHash(h Hasher) Option {
+	return func(sketch *Sketch) error {
+		if h == nil {
+			return errors.New("Expected non-nil Hasher")
+		}
+		sketch.hasher = h
+		return nil
+	}
+}
+
+/*
+WithBitmapBackend stores the bits of the sketch in b instead of a dense
+bitmap of l bits, which is then never allocated. b is cleared first, unless
+it's a SharedBackend, which is joined as it is.
+*/
+func WithBitmapBackend(b BitmapBackend) Option {
+	return func(sketch *Sketch) error {
+		if b == nil {
+			return errors.New("Expected non-nil BitmapBackend")
+		}
+		sketch.remote, _ = b.(SharedBackend)
+		if sketch.remote == nil {
+			b.Reset()
+		}
+		sketch.backend = b
+		return nil
+	}
+}
+
+/*
+WithColumnDropping sets whether additions are dropped with a probability
+growing with their column: an addition picking column j of its virtual
+matrix is discarded, only counting in N, with probability j/l. This isn't
+part of the PMC paper, it thins out the deep columns, which few flows should
+reach, and is enabled by default. Either way the phi correction of the
+estimator accounts for it, so estimates aren't biased by dropped additions;
+disabling it makes the sketch follow the paper exactly. Sketches meant to be
+merged must agree on it.
+*/
+func WithColumnDropping(enabled bool) Option {
+	return func(sketch *Sketch) error {
+		sketch.noDrop = !enabled
+		return nil
+	}
+}
+
+/*
+WithThreadSafety makes all methods of the sketch safe for concurrent use by
+serializing them with a mutex.
+*/
+func WithThreadSafety() Option {
+	return func(sketch *Sketch) error {
+		sketch.mu = &sync.Mutex{}
+		return nil
+	}
+}
+
+/*
+WithLockFree makes Increment safe for concurrent use without locking: bits are
+set with atomic operations, and random numbers come from a SplitMix64
+generator of the sketch advanced atomically, seeded from the generator set
+by WithSeed or WithChaCha8 if any, from crypto/rand otherwise. The other
+methods are serialized by a mutex, as with WithThreadSafety, and estimates
+and Clone may run concurrently with Increment, on a bitmap that keeps
+changing while they read it. Methods replacing or rewriting the whole
+bitmap, like Merge, Reset or ReadFrom, must not run concurrently with
+Increment.
+*/
+func WithLockFree() Option {
+	return func(sketch *Sketch) error {
+		sketch.lockFree = true
+		sketch.mu = &sync.Mutex{}
+		return nil
+	}
+}
+
+// atomicRand is the random number generator of lock-free sketches, a
+// SplitMix64 whose state is advanced atomically so that it's safe for
+// concurrent use.
+type atomicRand struct {
+	state atomic.Uint64
+}
+
+func newAtomicRand(seed uint64) *atomicRand {
+	r := &atomicRand{}
+	r.state.Store(seed)
+	return r
+}
+
+func (r *atomicRand) Next() uint64 {
+	return mix64(r.state.Add(0x9e3779b97f4a7c15))
+}
+
+// chachaRand is the random number generator of sketches created with
+// WithChaCha8, random is set for WithCryptoRand.
+type chachaRand struct {
+	*rand.ChaCha8
+	random bool
+}
+
+func (r *chachaRand) Next() uint64 {
+	return r.Uint64()
+}
+
+// deriveRand returns a generator of the same kind as that of the sketch,
+// seeded from it, for a copy of the sketch.
+func (sketch *Sketch) deriveRand() xorshift.XorShift {
+	switch r := sketch.rnd.(type) {
+	case nil:
+		return nil
+	case *atomicRand:
+		return newAtomicRand(r.Next())
+	case *chachaRand:
+		var seed [32]byte
+		for i := 0; i < len(seed); i += 8 {
+			binary.LittleEndian.PutUint64(seed[i:], r.Next())
+		}
+		return &chachaRand{ChaCha8: rand.NewChaCha8(seed), random: r.random}
+	default:
+		return xorshift.NewXorShift64Star(r.Next() | 1)
+	}
+}
+
+// newSibling returns a sketch created by New with l and the m, w and opts
+// of first, to be merged or summed with it: it shares its hash seed, and
+// draws from a generator derived from that of first, since sketches fed the
+// same sequence of additions with the same generator would set the same bits.
+// opts are copied rather than appended to, so that the array of the caller
+// is left untouched.
+func newSibling(first *Sketch, l uint, opts []Option) (*Sketch, error) {
+	opts = append(opts[:len(opts):len(opts)], WithHashSeed(first.hashSeed))
+	sketch, err := New(l, first.m, first.w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	sketch.rnd = first.deriveRand()
+	return sketch, nil
+}
+
+// nopLocker is the locker of sketches that are not shared between goroutines.
+type nopLocker struct{}
+
+func (nopLocker) Lock()   {}
+func (nopLocker) Unlock() {}
@@ -0,0 +1,207 @@
+package pmc
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"testing"
+)
+
+type constHasher struct{}
+
+func (constHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return 7
+}
+
+func TestWithHash(t *testing.T) {
+	s, err := New(1024, 8, 8, WithHash(constHasher{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		s.Increment([]byte(strconv.Itoa(i)))
+	}
+	if s.bitmap.count() != 1 || !s.bitmap.test(7) {
+		t.Error("Expected a single bit at position 7, got", s.bitmap.count())
+	}
+
+	if _, err := New(1024, 8, 8, WithHash(nil)); err == nil {
+		t.Error("Expected error for nil Hasher, got nil")
+	}
+}
+
+func TestWithSeed(t *testing.T) {
+	a, _ := New(4096, 16, 16, WithSeed(7))
+	b, _ := NewWithSeed(4096, 16, 16, 7)
+	for i := 0; i < 1000; i++ {
+		a.Increment([]byte("flow"))
+		b.Increment([]byte("flow"))
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected WithSeed to match NewWithSeed")
+	}
+}
+
+func TestWithThreadSafety(t *testing.T) {
+	s, _ := New(1<<16, 16, 16, WithThreadSafety())
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			flow := []byte("flow-" + strconv.Itoa(g))
+			for i := 0; i < 1000; i++ {
+				s.Increment(flow)
+				if i%100 == 0 {
+					s.GetEstimate(flow)
+				}
+			}
+			s.IncrementN(flow, 100000)
+		}(g)
+	}
+	wg.Wait()
+
+	if s.n != 8*101000 {
+		t.Error("Expected n == 808000, got", s.n)
+	}
+}
+
+func TestHashSeed(t *testing.T) {
+	a, _ := New(1<<16, 16, 16)
+	b, _ := New(1<<16, 16, 16)
+	if a.HashSeed() == 0 || a.HashSeed() == b.HashSeed() {
+		t.Error("Expected distinct random hash seeds, got", a.HashSeed(), b.HashSeed())
+	}
+	if a.getPos([]byte("flow"), 1, 1) == b.getPos([]byte("flow"), 1, 1) &&
+		a.getPos([]byte("flow"), 2, 2) == b.getPos([]byte("flow"), 2, 2) {
+		t.Error("Expected different hash seeds to give different positions")
+	}
+
+	c, _ := New(1<<16, 16, 16, WithHashSeed(a.HashSeed()))
+	if c.getPos([]byte("flow"), 1, 1) != a.getPos([]byte("flow"), 1, 1) {
+		t.Error("Expected the same hash seed to give the same positions")
+	}
+
+	if _, err := New(1<<16, 16, 16, WithHashSeed(0)); err == nil {
+		t.Error("Expected error for hash seed 0, got nil")
+	}
+}
+
+func TestWithLockFree(t *testing.T) {
+	s, err := New(1<<22, 256, 32, WithLockFree())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2500; i++ {
+				s.Increment([]byte("flow"))
+			}
+		}()
+	}
+	// Estimates and snapshots run concurrently with the increments.
+	s.GetEstimate([]byte("flow"))
+	s.Clone()
+	wg.Wait()
+
+	if s.N() != 20000 {
+		t.Error("Expected N() == 20000, got", s.N())
+	}
+	if ones := uint64(s.bitmap.count()); ones != s.ones {
+		t.Errorf("Expected %d set bits to be counted, got %d", ones, s.ones)
+	}
+	est := s.GetEstimate([]byte("flow"))
+	if fErr := math.Abs(100 * (1 - est/20000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 20000, got %f", est)
+	}
+	if c := s.Clone(); !c.lockFree || c.GetEstimate([]byte("flow")) != est {
+		t.Error("Expected a lock-free clone estimating like the original")
+	}
+}
+
+func TestWithLockFreeSeed(t *testing.T) {
+	a, _ := New(1<<16, 64, 32, WithLockFree(), WithSeed(7))
+	b, _ := New(1<<16, 64, 32, WithLockFree(), WithSeed(7))
+	c, _ := New(1<<16, 64, 32, WithLockFree(), WithHashSeed(a.HashSeed()))
+	for i := 0; i < 1000; i++ {
+		a.Increment([]byte("flow"))
+		b.Increment([]byte("flow"))
+		c.Increment([]byte("flow"))
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected lock-free sketches with the same seed to be equal")
+	}
+	if a.bitmap.equal(c.bitmap) {
+		t.Error("Expected an unseeded lock-free sketch to draw its own randomness")
+	}
+}
+
+func TestWithChaCha8(t *testing.T) {
+	seed := [32]byte{1, 2, 3}
+	a, _ := New(1<<16, 64, 32, WithChaCha8(seed))
+	b, _ := New(1<<16, 64, 32, WithChaCha8(seed))
+	if a.HashSeed() != b.HashSeed() {
+		t.Error("Expected sketches with the same seed to share their hash seed")
+	}
+	for i := 0; i < 10000; i++ {
+		a.Increment([]byte("flow"))
+		b.Increment([]byte("flow"))
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected sketches with the same seed to be equal")
+	}
+	if e := a.GetEstimate([]byte("flow")); math.Abs(e-10000) > 2000 {
+		t.Error("Expected estimate within 20% of 10000, got", e)
+	}
+}
+
+func TestWithCryptoRand(t *testing.T) {
+	a, err := New(1<<16, 64, 32, WithCryptoRand(), WithHashSeed(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := New(1<<16, 64, 32, WithCryptoRand(), WithHashSeed(1))
+	for i := 0; i < 10000; i++ {
+		a.Increment([]byte("flow"))
+		b.Increment([]byte("flow"))
+	}
+	if a.bitmap.equal(b.bitmap) {
+		t.Error("Expected sketches with random generators to differ")
+	}
+	if _, err := New(1<<16, 64, 32, WithDeterministic(1), WithCryptoRand()); err == nil {
+		t.Error("Expected error for a deterministic sketch with a random generator, got nil")
+	}
+}
+
+func TestWithColumnDropping(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		s, _ := New(64, 4, 32, WithSeed(7), WithColumnDropping(enabled))
+		dropped := 0
+		for i := 0; i < 100000; i++ {
+			if _, _, ok := s.sample(); !ok {
+				dropped++
+			}
+		}
+		// Columns average 1, so 1/64 of the additions are dropped.
+		if enabled && math.Abs(float64(dropped)-100000/64) > 200 {
+			t.Error("Expected about 1562 dropped additions, got", dropped)
+		}
+		if !enabled && dropped != 0 {
+			t.Error("Expected no dropped additions, got", dropped)
+		}
+	}
+
+	a, _ := New(1<<20, 256, 32, WithDeterministic(7))
+	b, _ := New(1<<20, 256, 32, WithDeterministic(7), WithColumnDropping(false))
+	a.Add([]byte("flow"), 100000)
+	b.Add([]byte("flow"), 100000)
+	for _, s := range []*Sketch{a, b} {
+		if e := s.GetEstimate([]byte("flow")); math.Abs(e-100000) > 15000 {
+			t.Error("Expected estimate within 15% of 100000, got", e)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package pmc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/dgryski/go-farm"
+)
+
+/*
+MaxL is the largest number of bits of a sketch, whose bitmap then takes
+128GiB. Decoders reject larger sketches before allocating them.
+*/
+const MaxL = 1 << 40
+
+/*
+ErrIncompatibleParams is returned, wrapped, by the operations combining
+sketches that weren't created with the same parameters, see Compatible.
+*/
+var ErrIncompatibleParams = errors.New("Incompatible sketch parameters")
+
+/*
+ErrSaturated is returned by the operations comparing sketches, such as
+DeltaChecked and Jaccard, when one of them is saturated, see Stats: the
+noise of the other flows then dominates the bits they compare.
+*/
+var ErrSaturated = errors.New("Sketch is saturated")
+
+/*
+Params are the parameters a sketch was created with that its bits depend on.
+Sketches can only be merged or compared if they share them. Hasher and
+Sampler are fingerprints of the Hasher and GeoSampler of the sketch, hashes
+of what they return for fixed inputs, so that sketches placing their bits
+differently are told apart, see WithHash and WithGeoSampler.
+*/
+type Params struct {
+	L, M, W         uint
+	HashSeed        uint64
+	Hasher, Sampler uint64
+	// ColumnDropping is set unless the sketch was created with
+	// WithColumnDropping(false), and Bidirectional by WithBidirectional.
+	ColumnDropping, Bidirectional bool
+}
+
+// checkParams returns an error unless l is in [1, MaxL], m in [1, l], w in
+// [2, MaxW] and m*w fits in a uint. New and the decoders share it, so that
+// decoded sketches hold the same invariants as created ones.
+func checkParams(l, m, w uint64) error {
+	if l == 0 {
+		return errors.New("Expected l > 0, got 0")
+	}
+	if l > MaxL || l > math.MaxUint {
+		return fmt.Errorf("Expected l <= %d, got %d", uint64(min(MaxL, math.MaxUint)), l)
+	}
+	if m == 0 || m > l {
+		return fmt.Errorf("Expected m in [1, l], got %d", m)
+	}
+	if w < 2 || w > MaxW {
+		return fmt.Errorf("Expected w in [2, %d], got %d", MaxW, w)
+	}
+	if m > math.MaxUint/w {
+		return fmt.Errorf("Expected m*w to fit in a uint, got m=%d, w=%d", m, w)
+	}
+	return nil
+}
+
+/*
+Params returns the parameters of the sketch.
+*/
+func (sketch *Sketch) Params() Params {
+	hasher, sampler := sketch.fingerprints(sketch.w)
+	return Params{L: sketch.l, M: sketch.m, W: sketch.w, HashSeed: sketch.hashSeed,
+		Hasher: hasher, Sampler: sampler,
+		ColumnDropping: !sketch.noDrop, Bidirectional: sketch.reverse != nil}
+}
+
+// fingerprintKey is the flow hashed by the fingerprint of a Hasher.
+var fingerprintKey = []byte("pmc fingerprint")
+
+// fingerprints returns the fingerprints of the Hasher and GeoSampler of the
+// sketch: the farmhash of the values the Hasher gives for a few cells of
+// fingerprintKey, and of those the GeoSampler gives for w columns and a few
+// random values.
+func (sketch *Sketch) fingerprints(w uint) (hasher, sampler uint64) {
+	if sketch.hasher == nil {
+		// The sketch is a zero Sketch, yet to be decoded into.
+		return 0, 0
+	}
+	buf := make([]byte, 0, 8*max(4, w+4))
+	for k := uint64(0); k < 4; k++ {
+		buf = binary.LittleEndian.AppendUint64(buf, sketch.hasher.Hash(fingerprintKey, k, k+1))
+	}
+	hasher = farm.Hash64(buf)
+
+	buf = buf[:0]
+	for j := uint(0); j < w; j++ {
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(sketch.sampler.Tail(j)))
+	}
+	for _, r := range []uint64{0, 1, 1 << 32, math.MaxUint64} {
+		buf = binary.LittleEndian.AppendUint64(buf, uint64(sketch.sampler.Sample(r)))
+	}
+	return hasher, farm.Hash64(buf)
+}
+
+/*
+Compatible returns whether other has the same parameters as the sketch, so
+that they can be merged or compared.
+*/
+func (sketch *Sketch) Compatible(other *Sketch) bool {
+	return sketch.checkCompatible(other) == nil
+}
+
+func (sketch *Sketch) checkCompatible(other *Sketch) error {
+	if sketch.l != other.l || sketch.m != other.m || sketch.w != other.w {
+		return fmt.Errorf("%w: expected sketch with l=%v, m=%v, w=%v, got l=%v, m=%v, w=%v",
+			ErrIncompatibleParams, sketch.l, sketch.m, sketch.w, other.l, other.m, other.w)
+	}
+	if sketch.hashSeed != other.hashSeed {
+		return fmt.Errorf("%w: expected sketch with the same hash seed", ErrIncompatibleParams)
+	}
+	return sketch.checkCodec(other.Params())
+}
+
+// checkCodec returns an error unless p, of p.W columns, has the Hasher,
+// GeoSampler, column dropping and direction of the sketch, which place and
+// thin out its bits.
+func (sketch *Sketch) checkCodec(p Params) error {
+	own := sketch.Params()
+	own.Hasher, own.Sampler = sketch.fingerprints(p.W)
+	switch {
+	case p.Hasher != own.Hasher:
+		return fmt.Errorf("%w: expected sketch with the same Hasher", ErrIncompatibleParams)
+	case p.Sampler != own.Sampler:
+		return fmt.Errorf("%w: expected sketch with the same GeoSampler", ErrIncompatibleParams)
+	case p.ColumnDropping != own.ColumnDropping:
+		return fmt.Errorf("%w: expected sketch with column dropping %v, got %v",
+			ErrIncompatibleParams, own.ColumnDropping, p.ColumnDropping)
+	case p.Bidirectional != own.Bidirectional:
+		return fmt.Errorf("%w: expected sketch with bidirectional %v, got %v",
+			ErrIncompatibleParams, own.Bidirectional, p.Bidirectional)
+	}
+	return nil
+}
+
+// isSaturated returns whether the fill rate of the sketch is past
+// saturationFill.
+func (sketch *Sketch) isSaturated() bool {
+	return sketch.getP() > saturationFill
+}
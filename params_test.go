@@ -0,0 +1,62 @@
+package pmc
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestParams(t *testing.T) {
+	sampler, _ := NewTableSampler(func(j uint) float64 { return math.Pow(0.4, float64(j)) })
+	reverse := func(dst, flow []byte) []byte { return append(dst, flow...) }
+	a, _ := New(1<<16, 64, 32, WithHashSeed(3))
+	b, _ := New(1<<16, 64, 32, WithHashSeed(3))
+	if p := a.Params(); p != (Params{L: 1 << 16, M: 64, W: 32, HashSeed: 3,
+		Hasher: p.Hasher, Sampler: p.Sampler, ColumnDropping: true}) || p.Hasher == 0 {
+		t.Error("Expected the parameters of the sketch, got", p)
+	}
+	if !a.Compatible(b) {
+		t.Error("Expected sketches with the same parameters to be compatible")
+	}
+
+	for _, other := range []*Sketch{
+		func() *Sketch { s, _ := New(1<<16, 32, 32, WithHashSeed(3)); return s }(),
+		func() *Sketch { s, _ := New(1<<16, 64, 32, WithHashSeed(4)); return s }(),
+		func() *Sketch { s, _ := New(1<<16, 64, 32, WithHashSeed(3), WithHash(XXHasher{})); return s }(),
+		func() *Sketch { s, _ := New(1<<16, 64, 32, WithHashSeed(3), WithGeoSampler(sampler)); return s }(),
+		func() *Sketch { s, _ := New(1<<16, 64, 32, WithHashSeed(3), WithColumnDropping(false)); return s }(),
+		func() *Sketch { s, _ := New(1<<16, 64, 32, WithHashSeed(3), WithBidirectional(reverse)); return s }(),
+	} {
+		if a.Compatible(other) {
+			t.Error("Expected incompatible sketches, got", other.Params())
+		}
+		if err := a.Merge(other); !errors.Is(err, ErrIncompatibleParams) {
+			t.Error("Expected ErrIncompatibleParams from Merge, got", err)
+		}
+		if _, err := Jaccard(a, other); !errors.Is(err, ErrIncompatibleParams) {
+			t.Error("Expected ErrIncompatibleParams from Jaccard, got", err)
+		}
+		if _, err := DeltaChecked(a, other, []byte("flow")); !errors.Is(err, ErrIncompatibleParams) {
+			t.Error("Expected ErrIncompatibleParams from DeltaChecked, got", err)
+		}
+		if err := a.ApplyDelta(other.SnapshotDelta(nil)); !errors.Is(err, ErrIncompatibleParams) {
+			t.Error("Expected ErrIncompatibleParams from ApplyDelta, got", err)
+		}
+	}
+}
+
+func TestErrSaturated(t *testing.T) {
+	a, _ := New(1<<12, 64, 32, WithHashSeed(3))
+	before := a.Clone()
+	for i := 0; !a.Stats().Saturated; i++ {
+		a.Add([]byte(strconv.Itoa(i)), 1000)
+	}
+	b := a.Clone()
+	if _, err := DeltaChecked(before, a, []byte("flow")); err != ErrSaturated {
+		t.Error("Expected ErrSaturated from DeltaChecked, got", err)
+	}
+	if _, err := Overlap(a, b); err != ErrSaturated {
+		t.Error("Expected ErrSaturated from Overlap, got", err)
+	}
+}
@@ -1,34 +1,24 @@
 package pmc
 
 import (
+	crand "crypto/rand"
+	"encoding/binary"
 	"errors"
 	"fmt"
+	"io"
 	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+	"unsafe"
 
-	"github.com/dgryski/go-bits"
 	"github.com/dgryski/go-farm"
 	"github.com/lazybeaver/xorshift"
-	"github.com/willf/bitset"
-
-	random "math/rand"
 )
 
-var rnd = xorshift.NewXorShift64Star(42)
-
-// non-receiver methods
-func georand(w uint) uint {
-	val := rnd.Next()
-	// Calculate the position of the leftmost 1-bit.
-	res := uint(bits.Clz(uint64(val) ^ 0))
-	if res >= w {
-		res = w - 1
-	}
-	return res
-}
-
-func rand(m uint) uint {
-	return uint(rnd.Next()) % m
-}
+// DefaultSeed is the seed of the random number generator of sketches created
+// without an explicit seed.
+const DefaultSeed uint64 = 42
 
 /*
 We start with the probability qk(n) that at least the first k bits in a sketch row are set after n additions as given in (4).
@@ -48,12 +38,35 @@ that is capable of accounting traffic per flow probabilistically, that can be
 used as an alternative to Count-min sketch.
 */
 type Sketch struct {
-	l      float64
-	m      float64
-	w      float64
-	bitmap *bitset.BitSet // FIXME: Get Rid of bitmap and use uint32 array
-	p      float64
-	n      uint
+	l        uint
+	m        uint
+	w        uint
+	bitmap   bitArray
+	n        uint64
+	ones     uint64
+	phiCache *phiCache
+	rnd      xorshift.XorShift
+	hasher   Hasher
+	sampler  GeoSampler
+	hashSeed uint64
+	mu       sync.Locker
+	lockFree bool
+	noDrop   bool
+	mle      bool
+	// small is the threshold of WithSmallThreshold.
+	small   float64
+	shared  bool
+	backend BitmapBackend
+	remote  SharedBackend
+	mapping *mapping
+	hll     *hll
+	hooks   *Hooks
+	// deterministic is set by WithDeterministic.
+	deterministic bool
+	// reverse is the reversal of flow keys of WithBidirectional.
+	reverse func(dst, flow []byte) []byte
+	// saturated is whether OnSaturation was fired for the last crossing.
+	saturated atomic.Bool
 }
 
 /*
@@ -61,144 +74,642 @@ New returns a PMC Sketch with the properties:
 l = total number of bits for sketch
 m = total number of rows for each flow
 w = total number of columns for each flow
+l must be in [1, MaxL], m in [1, l], w in [2, MaxW], and m*w must fit in a
+uint; m needs not be a power of two, rows are picked uniformly whatever its
+value.
+*/
+func New(l uint, m uint, w uint, opts ...Option) (*Sketch, error) {
+	if err := checkParams(uint64(l), uint64(m), uint64(w)); err != nil {
+		return nil, err
+	}
+	sketch := &Sketch{l: l, m: m, w: w, n: 0}
+	for _, opt := range opts {
+		if err := opt(sketch); err != nil {
+			return nil, err
+		}
+	}
+	if sketch.backend == nil {
+		sketch.bitmap = newBitArray(l)
+	} else if sketch.lockFree {
+		return nil, errors.New("Lock-free sketches only support the default bitmap")
+	}
+	if sketch.lockFree && sketch.deterministic {
+		return nil, errors.New("Lock-free sketches can't be deterministic")
+	}
+	if r, ok := sketch.rnd.(*chachaRand); ok && r.random && sketch.deterministic {
+		return nil, errors.New("Sketches with a random generator can't be deterministic")
+	}
+	if sketch.lockFree && sketch.hll != nil {
+		return nil, errors.New("Lock-free sketches don't support distinct flow counting")
+	}
+	for sketch.hashSeed == 0 {
+		var seed [8]byte
+		if _, err := crand.Read(seed[:]); err != nil {
+			return nil, err
+		}
+		sketch.hashSeed = binary.LittleEndian.Uint64(seed[:])
+	}
+	if sketch.lockFree {
+		var seed [8]byte
+		if sketch.rnd != nil {
+			binary.LittleEndian.PutUint64(seed[:], sketch.rnd.Next())
+		} else if _, err := crand.Read(seed[:]); err != nil {
+			return nil, err
+		}
+		sketch.rnd = newAtomicRand(binary.LittleEndian.Uint64(seed[:]))
+	}
+	sketch.setDefaults()
+	return sketch, nil
+}
+
+/*
+NewWithSeed returns a PMC Sketch like New, whose random number generator is
+seeded with seed, see WithSeed.
 */
-func New(l uint, m uint, w uint) (*Sketch, error) {
-	if l == 0 {
-		return nil, errors.New("Expected l > 0, got 0")
+func NewWithSeed(l uint, m uint, w uint, seed uint64) (*Sketch, error) {
+	return New(l, m, w, WithSeed(seed))
+}
+
+// setDefaults fills in the random number generator, hasher, sampler, small
+// multiplicity threshold and locker of sketches that were not given one.
+func (sketch *Sketch) setDefaults() {
+	if sketch.rnd == nil {
+		sketch.rnd = xorshift.NewXorShift64Star(DefaultSeed)
+	}
+	if sketch.hasher == nil {
+		sketch.hasher = FarmHasher{}
 	}
-	if m == 0 {
-		return nil, errors.New("Expected m > 0, got 0")
+	if sketch.sampler == nil {
+		sketch.sampler = BinarySampler{}
 	}
-	if w == 0 {
-		return nil, errors.New("Expected w > 0, got 0")
+	if sketch.small == 0 {
+		sketch.small = DefaultSmallThreshold
+	}
+	if sketch.mu == nil {
+		sketch.mu = nopLocker{}
 	}
-	return &Sketch{l: float64(l), m: float64(m), w: float64(w),
-		bitmap: bitset.New(l), n: 0}, nil
 }
 
 /*
 NewForMaxFlows returns a PMC Sketch adapted to the size of the max number of
-flows expected.
+flows expected. Its 256 rows of 32 columns and 32 bits per flow are a
+generic default, Advise gives parameters fitted to the expected traffic and
+accuracy.
 */
-func NewForMaxFlows(maxFlows uint) (*Sketch, error) {
+func NewForMaxFlows(maxFlows uint, opts ...Option) (*Sketch, error) {
 	l := maxFlows * 32
-	return New(l, 256, 32)
+	return New(l, 256, 32, opts...)
+}
+
+/*
+NewWithAccuracy returns a PMC Sketch sized for expectedFlows flows whose
+estimates have a relative standard error of about relativeError. The number
+of rows is derived from the 0.78/sqrt(m) standard error of the estimator,
+and the bitmap keeps the bits per flow to rows ratio of NewForMaxFlows.
+*/
+func NewWithAccuracy(expectedFlows uint, relativeError float64, opts ...Option) (*Sketch, error) {
+	if expectedFlows == 0 {
+		return nil, errors.New("Expected expectedFlows > 0, got 0")
+	}
+	if relativeError <= 0 || relativeError >= 1 {
+		return nil, fmt.Errorf("Expected 0 < relativeError < 1, got %v", relativeError)
+	}
+	m := uint(math.Ceil(math.Pow(0.78/relativeError, 2)))
+	l := expectedFlows * (m/8 + 1)
+	return New(l, m, 32, opts...)
+}
+
+/*
+NewWithMemoryBudget returns a PMC Sketch sized for expectedFlows flows whose
+MemoryUsage is at most budget bytes. The bitmap takes the whole budget, and
+the number of rows follows from the bits available per flow, keeping the
+ratio of NewWithAccuracy with up to 1024 rows. It fails if the budget leaves
+less than 2 bits per flow.
+*/
+func NewWithMemoryBudget(budget uint64, expectedFlows uint, opts ...Option) (*Sketch, error) {
+	if expectedFlows == 0 {
+		return nil, errors.New("Expected expectedFlows > 0, got 0")
+	}
+	overhead := uint64(unsafe.Sizeof(Sketch{}))
+	for {
+		if budget <= overhead {
+			return nil, fmt.Errorf("Memory budget of %d bytes too small", budget)
+		}
+		l := uint((budget - overhead) / 8 * 64)
+		perFlow := l / expectedFlows
+		if perFlow < 2 {
+			return nil, fmt.Errorf("Memory budget of %d bytes too small for %d flows", budget, expectedFlows)
+		}
+		m := 8 * (perFlow - 1)
+		if m > 1024 {
+			m = 1024
+		}
+		sketch, err := New(l, m, 32, opts...)
+		if err != nil {
+			return nil, err
+		}
+		// Options such as WithDistinctFlows take memory from the bitmap.
+		usage := sketch.MemoryUsage()
+		if usage <= budget {
+			return sketch, nil
+		}
+		overhead += usage - budget
+	}
 }
 
-func (sketch *Sketch) printVirtualMatrix(flow []byte) {
-	for i := 0.0; i < sketch.m; i++ {
-		for j := 0.0; j < sketch.w; j++ {
-			pos := sketch.getPos(flow, i, j)
-			if sketch.bitmap.Test(pos) == false {
-				fmt.Print(0)
+/*
+VirtualMatrix returns the m by w virtual matrix of a flow, holding whether
+each of its bits is set in the sketch, for debugging.
+*/
+func (sketch *Sketch) VirtualMatrix(flow []byte) [][]bool {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	flow, buf := sketch.key(flow)
+	defer releaseKey(buf)
+	matrix := make([][]bool, sketch.m)
+	for i := range matrix {
+		matrix[i] = make([]bool, sketch.w)
+		for j := range matrix[i] {
+			matrix[i][j] = sketch.test(sketch.getPos(flow, uint(i), uint(j)))
+		}
+	}
+	return matrix
+}
+
+/*
+DumpMatrix writes the virtual matrix of a flow to w, one row of 0s and 1s per
+line.
+*/
+func (sketch *Sketch) DumpMatrix(w io.Writer, flow []byte) error {
+	row := make([]byte, 0, sketch.w+1)
+	for _, cells := range sketch.VirtualMatrix(flow) {
+		row = row[:0]
+		for _, set := range cells {
+			if set {
+				row = append(row, '1')
 			} else {
-				fmt.Print(1)
+				row = append(row, '0')
 			}
 		}
-		fmt.Println("")
+		if _, err := w.Write(append(row, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+MaxW is the largest number of columns of a sketch. Columns are picked from
+the leading zeros of a single 64-bit random value, column j with probability
+2^-(j+1) and the last one taking the remaining mass, and 64 columns already
+count up to about 2^64 additions per row. Wider sketches would need chained
+geometric sampling, drawing another random value whenever the first one is
+all zeros and adding its leading zeros to the 64 of the first, which no
+realistic traffic needs.
+*/
+const MaxW = 64
+
+func (sketch *Sketch) georand(w uint) uint {
+	var res uint
+	if _, ok := sketch.sampler.(BinarySampler); ok {
+		res = BinarySampler{}.Sample(sketch.next())
+	} else {
+		res = sketch.sampler.Sample(sketch.next())
+	}
+	if res >= w {
+		res = w - 1
+	}
+	return res
+}
+
+// rand returns a uniformly distributed value in [0, m) using Lemire's method:
+// the high word of a 64-bit random value times m, rejecting the few low words
+// that would make some results more likely than others.
+func (sketch *Sketch) rand(m uint) uint {
+	bound := uint64(m)
+	hi, lo := bits.Mul64(sketch.next(), bound)
+	if lo < bound {
+		threshold := -bound % bound
+		for lo < threshold {
+			hi, lo = bits.Mul64(sketch.next(), bound)
+		}
+	}
+	return uint(hi)
+}
+
+// float returns a uniformly distributed value in [0, 1).
+func (sketch *Sketch) float() float64 {
+	return float64(sketch.next()>>11) / (1 << 53)
+}
+
+// next returns the next random value, calling the default generator directly
+// so that it can be inlined in the hot path.
+func (sketch *Sketch) next() uint64 {
+	if r, ok := sketch.rnd.(*atomicRand); ok {
+		return r.Next()
+	}
+	return sketch.rnd.Next()
+}
+
+// drop returns whether an addition picking column j is dropped, which it is
+// with probability j/l: a random value r is below j * 2^64 / l exactly when
+// the high word of r * l is below j, sparing the float conversions.
+func (sketch *Sketch) drop(j uint) bool {
+	hi, _ := bits.Mul64(sketch.next(), uint64(sketch.l))
+	return hi < uint64(j)
+}
+
+/*
+N returns the total number of additions to the sketch. It is safe to call
+concurrently with additions.
+*/
+func (sketch *Sketch) N() uint64 {
+	if sketch.remote != nil {
+		return sketch.remote.N()
+	}
+	return atomic.LoadUint64(&sketch.n)
+}
+
+/*
+TotalIncrements is an alias for N.
+*/
+func (sketch *Sketch) TotalIncrements() uint64 {
+	return sketch.N()
+}
+
+/*
+Bits returns the words backing the bitmap of the sketch, bit i of the bitmap
+being bit i%64 of word i/64. The slice is shared with the sketch and must not
+be modified. Sketches with a BitmapBackend return a copy of their bits
+expanded into words.
+*/
+func (sketch *Sketch) Bits() []uint64 {
+	if sketch.backend != nil {
+		words := newBitArray(sketch.l)
+		sketch.backend.ForEach(func(i uint) bool {
+			words[i>>6] |= 1 << (i & 63)
+			return true
+		})
+		return words
 	}
+	return sketch.bitmap
 }
 
 /*
 GetFillRate ...
 */
 func (sketch *Sketch) GetFillRate() float64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
 	return sketch.getP() * 100
 }
 
+/*
+HashSeed returns the secret value the hash seeds of the sketch are derived
+from. Sketches can only be merged if they share it, see WithHashSeed.
+*/
+func (sketch *Sketch) HashSeed() uint64 {
+	return sketch.hashSeed
+}
+
 /*
 It is straightforward to use any uniformly distributed hash function with
 sufficiently random output in the role of H: the input parameters can
 simply be concatenated to a single bit string.
+The hash seeds are derived from i, j and the hash seed of the sketch, so that
+positions can't be precomputed without knowing the latter. Sketches decoded
+from the first version of the binary encoding have no hash seed and use i and
+j as they are.
 */
-func (sketch *Sketch) getPos(f []byte, i, j float64) uint {
-	hash := farm.Hash64WithSeeds(f, uint64(i), uint64(j))
-	return uint(hash) % uint(sketch.l)
+func (sketch *Sketch) getPos(f []byte, i, j uint) uint {
+	si, sj := sketch.rowSeed(i), sketch.colSeed(j)
+	var hash uint64
+	if _, ok := sketch.hasher.(FarmHasher); ok {
+		hash = farm.Hash64WithSeeds(f, si, sj)
+	} else {
+		hash = sketch.hasher.Hash(f, si, sj)
+	}
+	return uint(hash % uint64(sketch.l))
+}
+
+// rowSeed returns the hash seed of row i, see getPos.
+func (sketch *Sketch) rowSeed(i uint) uint64 {
+	if sketch.hashSeed == 0 {
+		return uint64(i)
+	}
+	return mix64(sketch.hashSeed + uint64(i))
+}
+
+// colSeed returns the hash seed of column j, see getPos.
+func (sketch *Sketch) colSeed(j uint) uint64 {
+	if sketch.hashSeed == 0 {
+		return uint64(j)
+	}
+	return mix64(^sketch.hashSeed + uint64(j))
 }
 
 /*
 Increment the count of the flow by 1
 */
 func (sketch *Sketch) Increment(flow []byte) {
-	sketch.p = 0
-	i := rand(uint(sketch.m))
-	j := georand(uint(sketch.w))
+	if sketch.lockFree {
+		sketch.increment(flow)
+	} else {
+		sketch.mu.Lock()
+		sketch.increment(flow)
+		sketch.mu.Unlock()
+	}
+	if sketch.hooks != nil {
+		sketch.incremented(flow, 1)
+	}
+}
 
-	pos := sketch.getPos(flow, float64(i), float64(j))
+func (sketch *Sketch) increment(flow []byte) {
+	flow, buf := sketch.key(flow)
+	if sketch.hll != nil {
+		sketch.hll.add(sketch.flowHash(flow))
+	}
+	if i, j, ok := sketch.sample(); ok {
+		sketch.setBit(sketch.getPos(flow, i, j))
+	}
+	releaseKey(buf)
+}
 
-	sketch.n++
-	if random.Float64() < float64(j)/float64(sketch.l) {
+// setBit sets the bit at pos, keeping track of the number of set bits.
+func (sketch *Sketch) setBit(pos uint) {
+	if sketch.backend != nil {
+		if sketch.backend.Set(pos) {
+			sketch.ones++
+		}
 		return
 	}
+	if sketch.lockFree {
+		if sketch.bitmap.setAtomic(pos) {
+			atomic.AddUint64(&sketch.ones, 1)
+		}
+		return
+	}
+	sketch.own()
+	if sketch.bitmap.set(pos) {
+		sketch.ones++
+	}
+}
+
+// clearBit clears the bit at pos, keeping track of the number of set bits.
+func (sketch *Sketch) clearBit(pos uint) {
+	if sketch.backend != nil {
+		if sketch.backend.Clear(pos) {
+			sketch.ones--
+		}
+		return
+	}
+	if sketch.lockFree {
+		if sketch.bitmap.clearAtomic(pos) {
+			atomic.AddUint64(&sketch.ones, ^uint64(0))
+		}
+		return
+	}
+	sketch.own()
+	if sketch.bitmap.clear(pos) {
+		sketch.ones--
+	}
+}
+
+// sample accounts for one addition and picks the row i and column j it sets,
+// or returns false if the addition is dropped, see WithColumnDropping.
+func (sketch *Sketch) sample() (i, j uint, ok bool) {
+	i = sketch.rand(sketch.m)
+	j = sketch.georand(sketch.w)
+
+	sketch.addN(1)
+	if !sketch.noDrop && sketch.drop(j) {
+		return i, j, false
+	}
+	return i, j, true
+}
+
+// keepProb returns the probability of an addition picking column j not to be
+// dropped.
+func (sketch *Sketch) keepProb(j uint) float64 {
+	if sketch.noDrop {
+		return 1
+	}
+	return 1 - float64(j)/float64(sketch.l)
+}
+
+// positions maps the row i and column j of a flow's virtual matrix to the
+// position of the corresponding bit in the sketch.
+type positions func(i, j uint) uint
 
-	sketch.bitmap.Set(pos)
+// flowPositions returns the positions of key, as returned by the key method.
+// It's small enough to be inlined, so that the flowPos and its method value
+// stay on the stack of the caller and estimates don't allocate.
+func (sketch *Sketch) flowPositions(key []byte) positions {
+	fp := sketch.newFlowPos(key)
+	return fp.at
+}
+
+// flowPos are the positions of a flow. With a FlowHasher, the flow is hashed
+// once into h and the seed si of the last row is kept, estimates walking the
+// columns of a row in turn.
+type flowPos struct {
+	sketch *Sketch
+	flow   []byte
+	hasher FlowHasher
+	h, si  uint64
+	row    uint
+}
+
+func (sketch *Sketch) newFlowPos(key []byte) flowPos {
+	fp := flowPos{sketch: sketch, flow: key, row: ^uint(0)}
+	if h, ok := sketch.hasher.(FlowHasher); ok {
+		fp.hasher, fp.h = h, h.HashFlow(fp.flow)
+	}
+	return fp
 }
 
-func (sketch *Sketch) getZSum(flow []byte) float64 {
-	z := 0.0
-	for i := 0.0; i < sketch.m; i++ {
-		for j := 0.0; j < sketch.w; j++ {
-			pos := sketch.getPos(flow, i, j)
-			if sketch.bitmap.Test(pos) == false {
+func (fp *flowPos) at(i, j uint) uint {
+	sketch := fp.sketch
+	if fp.hasher == nil {
+		return sketch.getPos(fp.flow, i, j)
+	}
+	if i != fp.row {
+		fp.row, fp.si = i, sketch.rowSeed(i)
+	}
+	return uint(fp.hasher.Mix(fp.h, fp.si, sketch.colSeed(j)) % uint64(sketch.l))
+}
+
+func (sketch *Sketch) getZSum(getPos positions) float64 {
+	z := uint(0)
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			pos := getPos(i, j)
+			if sketch.test(pos) == false {
 				z += j
 				break
 			}
 		}
 	}
-	return z
+	return float64(z)
 }
 
-func (sketch *Sketch) getEmptyRows(flow []byte) float64 {
-	k := 0.0
-	for i := 0.0; i < sketch.m; i++ {
-		pos := sketch.getPos(flow, i, 0)
-		if sketch.bitmap.Test(pos) == false {
+func (sketch *Sketch) getEmptyRows(getPos positions) float64 {
+	k := uint(0)
+	for i := uint(0); i < sketch.m; i++ {
+		pos := getPos(i, 0)
+		if sketch.test(pos) == false {
 			k++
 		}
 	}
-	return k
+	return float64(k)
 }
 
 func (sketch *Sketch) getP() float64 {
-	ones := 0.0
-	for i := uint(0); i < uint(sketch.l); i++ {
-		if sketch.bitmap.Test(i) == true {
-			ones++
-		}
+	return float64(sketch.getOnes()) / float64(sketch.l)
+}
+
+// getOnes returns the number of set bits.
+func (sketch *Sketch) getOnes() uint64 {
+	if sketch.remote != nil {
+		return uint64(sketch.remote.Count())
 	}
-	return ones / sketch.l
+	return atomic.LoadUint64(&sketch.ones)
 }
 
+// getE returns sum(k * (qk(k, n, p) - qk(k+1, n, p))) for k in [1, w]. Each
+// qk(k+1, n, p) is obtained from qk(k, n, p) by one more factor of its
+// product, making this O(w) rather than O(w^2).
 func (sketch *Sketch) getE(n, p float64) float64 {
 	result := 0.0
-	for k := 1.0; k <= sketch.w; k++ {
-		result += (k * (qk(k, n, p) - qk(k+1, n, p)))
+	tail := sketch.sampler.Tail
+	// Probability of an addition setting column k, once dropped ones are
+	// accounted for.
+	prob := func(k uint) float64 {
+		return (tail(k) - tail(k+1)) * sketch.keepProb(k)
+	}
+	q := 1 - math.Exp(n*math.Log1p(-prob(0)))*(1-p)
+	for k := uint(1); k <= sketch.w; k++ {
+		next := q * (1 - math.Exp(n*math.Log1p(-prob(k)))*(1-p))
+		result += float64(k) * (q - next)
+		q = next
 	}
 	return result
 }
 
+// phiTolerance is the relative change of n, and the absolute change of p,
+// below which a memoized phi is reused.
+const phiTolerance = 1e-6
+
+type phiCache struct {
+	n, p, phi float64
+}
+
+// phi is memoized, since it only depends on n and p, which change little
+// between the estimates of a busy sketch.
 func (sketch *Sketch) phi(n, p float64) float64 {
-	return math.Pow(2, sketch.getE(n, p)) / n
+	if c := sketch.phiCache; c != nil &&
+		math.Abs(c.n-n) <= n*phiTolerance && math.Abs(c.p-p) <= phiTolerance {
+		return c.phi
+	}
+	phi := math.Pow(2, sketch.getE(n, p)) / n
+	sketch.phiCache = &phiCache{n: n, p: p, phi: phi}
+	return phi
+}
+
+/*
+ErrNoAdditions is returned by GetEstimateChecked for sketches without any
+additions.
+*/
+var ErrNoAdditions = errors.New("Sketch has no additions")
+
+/*
+GetEstimateChecked returns the estimated count of a given flow like
+GetEstimate, but fails with ErrNoAdditions on an empty sketch and with an
+error if the estimate isn't a finite number.
+*/
+func (sketch *Sketch) GetEstimateChecked(flow []byte) (float64, error) {
+	if sketch.N() == 0 {
+		return 0, ErrNoAdditions
+	}
+	e := sketch.GetEstimate(flow)
+	if math.IsNaN(e) || math.IsInf(e, 0) {
+		return 0, fmt.Errorf("Expected a finite estimate, got %v", e)
+	}
+	return e, nil
 }
 
 /*
-GetEstimate returns the estimated count of a given flow
+GetEstimate returns the estimated count of a given flow, 0 if none of its
+rows has been hit or the sketch has no additions.
 */
 func (sketch *Sketch) GetEstimate(flow []byte) float64 {
-	if sketch.p == 0 {
-		sketch.p = sketch.getP()
+	sketch.mu.Lock()
+	e := sketch.getEstimate(flow)
+	sketch.mu.Unlock()
+	sketch.estimated(flow, e)
+	return e
+}
+
+func (sketch *Sketch) getEstimate(flow []byte) float64 {
+	n, p := float64(sketch.N()), sketch.getP()
+	key, buf := sketch.key(flow)
+	e, _ := sketch.estimate(sketch.flowPositions(key), p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	releaseKey(buf)
+	return e
+}
+
+// signedEstimate is GetEstimate before negative estimates are folded back
+// up, for summing the estimates of several sketches, whose noise then
+// cancels out instead of adding up.
+func (sketch *Sketch) signedEstimate(flow []byte) float64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	n, p := float64(sketch.N()), sketch.getP()
+	key, buf := sketch.key(flow)
+	defer releaseKey(buf)
+	e, _ := sketch.estimateSigned(sketch.flowPositions(key), p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	return e
+}
+
+// estimate returns the estimated count of the flow at getPos given the fill
+// rate p, and the regime it was obtained in. The phi correction only depends
+// on the sketch, so it is supplied by the caller to be shared between
+// estimates.
+func (sketch *Sketch) estimate(getPos positions, p float64, phi func() float64) (float64, Regime) {
+	e, regime := sketch.estimateSigned(getPos, p, phi)
+	return math.Abs(e), regime
+}
+
+// estimateSigned is estimate before negative estimates, which the noise
+// correction of small multiplicities yields when the flow has more empty rows
+// than expected, are folded back up.
+func (sketch *Sketch) estimateSigned(getPos positions, p float64, phi func() float64) (float64, Regime) {
+	if sketch.mle {
+		return sketch.estimateMLE(getPos, p), RegimeMLE
+	}
+	if sketch.N() == 0 {
+		return 0, RegimeNone
+	}
+	k := sketch.getEmptyRows(getPos)
+	m := float64(sketch.m)
+	if k == m {
+		// None of the rows of the flow has been hit.
+		return 0, RegimeNone
 	}
-	k := sketch.getEmptyRows(flow)
-	n := float64(sketch.n)
-	m := sketch.m
 
 	e := 0.0
+	regime := RegimeZSum
 	// Dealing with small multiplicities
-	if kp := k / (1 - sketch.p); kp > 0.3*sketch.m {
-		e = -2 * sketch.m * math.Log(kp/sketch.m)
+	if kp := k / (1 - p); kp > sketch.small*m {
+		e = -2 * m * math.Log(kp/m)
+		regime = RegimeSmall
 	} else {
-		z := sketch.getZSum(flow)
-		e = m * math.Pow(2, z/m) / sketch.phi(n, sketch.p)
+		z := sketch.getZSum(getPos)
+		e = m * math.Pow(2, z/m) / phi()
 	}
-	return math.Abs(e)
+	return e, regime
 }
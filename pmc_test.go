@@ -1,9 +1,12 @@
 package pmc
 
 import (
+	"bytes"
+	"encoding/json"
 	"math"
 	random "math/rand"
 	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -11,8 +14,8 @@ func TestPMCHash(t *testing.T) {
 	s, _ := New(1024, 4, 4)
 	dist := make(map[uint]uint)
 	for k := 0; k < 100000; k++ {
-		i := float64(rand(uint(s.m)))
-		j := float64(georand(uint(s.w)))
+		i := s.rand(s.m)
+		j := s.georand(s.w)
 		pos := s.getPos([]byte("pmc"), i, j)
 		dist[pos]++
 	}
@@ -23,12 +26,13 @@ func TestPMCHash(t *testing.T) {
 
 func TestPMCHashAdd(t *testing.T) {
 	flows := make([]string, 100, 100)
+	rnd := random.New(random.NewSource(42))
 
 	for i := 0; i < len(flows); i++ {
-		flows[i] = strconv.Itoa(random.Int()) + "-flow-" + strconv.Itoa(random.Int())
+		flows[i] = strconv.Itoa(rnd.Int()) + "-flow-" + strconv.Itoa(rnd.Int())
 	}
 
-	s, _ := New(8000000, 256, 64)
+	s, _ := New(8000000, 256, 64, WithSeed(DefaultSeed))
 	for j := range flows {
 		for i := 0; i < 1000000; i++ {
 			if i%(j+1) == 0 {
@@ -47,10 +51,327 @@ func TestPMCHashAdd(t *testing.T) {
 }
 
 func TestRand(t *testing.T) {
+	s, _ := New(1024, 32, 4)
 	for i := 0; i < 10000; i++ {
-		r := rand(32)
+		r := s.rand(32)
 		if r >= 32 {
 			t.Error("Expected rand to return r < 32, got", r)
 		}
 	}
 }
+
+func TestSeedDeterminism(t *testing.T) {
+	a, _ := NewWithSeed(4096, 16, 16, 7)
+	b, _ := NewWithSeed(4096, 16, 16, 7)
+	c, _ := NewWithSeed(4096, 16, 16, 8)
+	for i := 0; i < 10000; i++ {
+		a.Increment([]byte("flow"))
+		c.Increment([]byte("flow"))
+		b.Increment([]byte("flow"))
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected sketches with the same seed to be equal")
+	}
+	if a.bitmap.equal(c.bitmap) {
+		t.Error("Expected sketches with different seeds to differ")
+	}
+
+	if _, err := NewWithSeed(4096, 16, 16, 0); err == nil {
+		t.Error("Expected error for seed 0, got nil")
+	}
+}
+
+func TestBits(t *testing.T) {
+	s, _ := New(130, 4, 4)
+	if len(s.Bits()) != 3 {
+		t.Error("Expected 3 words for 130 bits, got", len(s.Bits()))
+	}
+	s.setBit(129)
+	if s.Bits()[2] != 2 {
+		t.Error("Expected bit 129 to be bit 1 of word 2, got", s.Bits())
+	}
+}
+
+func TestCountRange(t *testing.T) {
+	s, _ := New(300, 4, 4)
+	for pos := uint(0); pos < 300; pos += 7 {
+		s.setBit(pos)
+	}
+	for _, r := range [][2]uint{{0, 300}, {0, 0}, {5, 6}, {7, 8}, {3, 60}, {63, 65}, {60, 200}, {128, 192}, {299, 300}} {
+		want := uint(0)
+		for pos := r[0]; pos < r[1]; pos++ {
+			if s.bitmap.test(pos) {
+				want++
+			}
+		}
+		if c := s.bitmap.countRange(r[0], r[1]); c != want {
+			t.Errorf("Expected %d set bits in [%d, %d), got %d", want, r[0], r[1], c)
+		}
+	}
+}
+
+func BenchmarkIncrement(b *testing.B) {
+	s, _ := New(8000000, 256, 64)
+	flow := []byte("flow")
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.Increment(flow)
+	}
+}
+
+func TestIncrementAllocs(t *testing.T) {
+	flow := []byte("flow")
+	for name, opts := range map[string][]Option{
+		"default":       nil,
+		"thread-safe":   {WithThreadSafety()},
+		"lock-free":     {WithLockFree()},
+		"deterministic": {WithDeterministic(42)},
+		"no-drop":       {WithColumnDropping(false)},
+		"distinct":      {WithDistinctFlows(10)},
+		"bidirectional": {WithBidirectional(reverseTuple)},
+	} {
+		s, err := New(1<<20, 64, 32, opts...)
+		if err != nil {
+			t.Fatal(name, err)
+		}
+		if allocs := testing.AllocsPerRun(1000, func() { s.Increment(flow) }); allocs != 0 {
+			t.Errorf("Expected Increment of a %s sketch not to allocate, got %v", name, allocs)
+		}
+	}
+	// The reverse of ba is its key.
+	s, _ := New(1<<20, 64, 32, WithBidirectional(reverseTuple))
+	ba := []byte{2, 2, 1, 1}
+	if allocs := testing.AllocsPerRun(1000, func() { s.Increment(ba); s.GetEstimate(ba) }); allocs != 0 {
+		t.Error("Expected a reversed flow not to allocate, got", allocs)
+	}
+	if testing.Short() {
+		return
+	}
+	if allocs := testing.Benchmark(BenchmarkIncrement).AllocsPerOp(); allocs != 0 {
+		t.Error("Expected BenchmarkIncrement not to allocate, got", allocs)
+	}
+}
+
+func TestDrop(t *testing.T) {
+	s, _ := New(1000, 4, 4, WithSeed(42))
+	for _, j := range []uint{0, 100, 500} {
+		dropped := 0
+		for k := 0; k < 100000; k++ {
+			if s.drop(j) {
+				dropped++
+			}
+		}
+		if want := 100000 * float64(j) / 1000; math.Abs(float64(dropped)-want) > 4*math.Sqrt(want)+1 {
+			t.Errorf("Expected about %v drops for column %d, got %d", want, j, dropped)
+		}
+	}
+}
+
+func TestWidth(t *testing.T) {
+	for _, w := range []uint{0, 1, MaxW + 1} {
+		if _, err := New(4096, 16, w); err == nil {
+			t.Errorf("Expected error for w == %d, got nil", w)
+		}
+	}
+	s, err := New(1<<20, 256, MaxW)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100000; i++ {
+		if j := s.georand(s.w); j >= MaxW {
+			t.Fatal("Expected a column < 64, got", j)
+		}
+	}
+	s.Add([]byte("flow"), 100000)
+	if e := s.GetEstimate([]byte("flow")); math.Abs(e-100000) > 15000 {
+		t.Error("Expected estimate within 15% of 100000, got", e)
+	}
+
+	data, _ := json.Marshal(s)
+	data = bytes.Replace(data, []byte(`"w":64`), []byte(`"w":65`), 1)
+	if err := json.Unmarshal(data, new(Sketch)); err == nil {
+		t.Error("Expected error decoding w == 65, got nil")
+	}
+}
+
+func TestNewWithAccuracy(t *testing.T) {
+	s, err := NewWithAccuracy(1000, 0.05)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.m != 244 {
+		t.Error("Expected m == 244 for 5% error, got", s.m)
+	}
+	if s.l < 1000*s.m/8 {
+		t.Error("Expected at least m/8 bits per flow, got", s.l/1000)
+	}
+
+	for _, e := range []float64{0, -1, 1} {
+		if _, err := NewWithAccuracy(1000, e); err == nil {
+			t.Errorf("Expected error for relative error %v, got nil", e)
+		}
+	}
+	if _, err := NewWithAccuracy(0, 0.05); err == nil {
+		t.Error("Expected error for 0 flows, got nil")
+	}
+}
+
+func TestNewWithMemoryBudget(t *testing.T) {
+	for _, opts := range [][]Option{nil, {WithDistinctFlows(14)}} {
+		s, err := NewWithMemoryBudget(1<<20, 100000, opts...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if usage := s.MemoryUsage(); usage > 1<<20 || usage < 1<<20-1<<15 {
+			t.Error("Expected a memory usage just below 1MiB, got", usage)
+		}
+		if s.m < 8 || s.m > 1024 {
+			t.Error("Expected m in [8, 1024], got", s.m)
+		}
+	}
+
+	if s, _ := NewWithMemoryBudget(1<<22, 100); s.m != 1024 {
+		t.Error("Expected m to be capped at 1024, got", s.m)
+	}
+	if _, err := NewWithMemoryBudget(1<<10, 100000); err == nil {
+		t.Error("Expected error for a budget of less than 2 bits per flow, got nil")
+	}
+	if _, err := NewWithMemoryBudget(1<<20, 0); err == nil {
+		t.Error("Expected error for 0 flows, got nil")
+	}
+}
+
+func TestN(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	s.Increment([]byte("flow"))
+	s.IncrementN([]byte("flow"), 1<<40)
+	if s.N() != 1<<40+1 || s.TotalIncrements() != s.N() {
+		t.Error("Expected N() == 1<<40+1, got", s.N())
+	}
+}
+
+func TestSetBitCount(t *testing.T) {
+	s, _ := New(1<<16, 32, 32)
+	o, _ := New(1<<16, 32, 32, WithHashSeed(s.HashSeed()))
+	check := func(step string) {
+		if c := uint64(s.bitmap.count()); s.ones != c {
+			t.Errorf("Expected %d set bits after %s, got %d", c, step, s.ones)
+		}
+	}
+
+	for i := 0; i < 10000; i++ {
+		s.Increment([]byte(strconv.Itoa(i % 100)))
+	}
+	check("Increment")
+	s.IncrementN([]byte("flow"), 1000000)
+	check("IncrementN")
+	o.IncrementN([]byte("other"), 1000000)
+	s.Merge(o)
+	check("Merge")
+	if fr := s.GetFillRate(); fr != 100*float64(s.bitmap.count())/float64(s.l) {
+		t.Error("Expected fill rate to match the bitmap, got", fr)
+	}
+}
+
+func TestGetE(t *testing.T) {
+	// qk is the estimator of the paper, without dropped additions.
+	s, _ := New(1024, 64, 32, WithColumnDropping(false))
+	for _, n := range []float64{1, 100, 1e6, 1e9} {
+		for _, p := range []float64{0, 0.1, 0.5} {
+			naive := 0.0
+			for k := 1.0; k <= float64(s.w); k++ {
+				naive += k * (qk(k, n, p) - qk(k+1, n, p))
+			}
+			if e := s.getE(n, p); math.Abs(e-naive) > 1e-8*naive {
+				t.Errorf("Expected getE(%v, %v) == %v, got %v", n, p, naive, e)
+			}
+		}
+	}
+}
+
+func TestPhiCache(t *testing.T) {
+	s, _ := New(1024, 64, 32)
+	phi := s.phi(1e6, 0.1)
+	if s.phi(1e6+0.5, 0.1) != phi {
+		t.Error("Expected memoized phi for a nearby n")
+	}
+	if s.phi(2e6, 0.1) == phi {
+		t.Error("Expected phi to be recomputed for a different n")
+	}
+}
+
+func BenchmarkGetEstimate(b *testing.B) {
+	s, _ := New(8000000, 256, 64)
+	s.IncrementN([]byte("flow"), 1000000)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		s.GetEstimate([]byte("flow"))
+	}
+}
+
+func TestRandUniform(t *testing.T) {
+	s, _ := New(1024, 3, 4)
+	counts := make([]int, 3)
+	for i := 0; i < 300000; i++ {
+		counts[s.rand(3)]++
+	}
+	for i, c := range counts {
+		if c < 99000 || c > 101000 {
+			t.Errorf("Expected about 100000 draws of row %d, got %d", i, c)
+		}
+	}
+
+	if _, err := New(1024, math.MaxUint/2, 3); err == nil {
+		t.Error("Expected error for overflowing m*w, got nil")
+	}
+}
+
+func TestGetEstimateEmpty(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	if e := s.GetEstimate([]byte("flow")); e != 0 {
+		t.Error("Expected estimate 0 for an empty sketch, got", e)
+	}
+	if _, err := s.GetEstimateChecked([]byte("flow")); err != ErrNoAdditions {
+		t.Error("Expected ErrNoAdditions, got", err)
+	}
+
+	s.Increment([]byte("other"))
+	e, err := s.GetEstimateChecked([]byte("flow"))
+	if err != nil || e != 0 {
+		t.Errorf("Expected estimate 0 for an unseen flow, got %v, %v", e, err)
+	}
+	if e := s.GetEstimate([]byte("other")); e <= 0 || math.IsInf(e, 0) {
+		t.Error("Expected finite positive estimate, got", e)
+	}
+}
+
+func BenchmarkLockFreeIncrement(b *testing.B) {
+	s, _ := New(1<<22, 256, 32, WithLockFree())
+	flow := []byte("flow")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			s.Increment(flow)
+		}
+	})
+}
+
+func TestVirtualMatrix(t *testing.T) {
+	s, _ := New(1<<16, 8, 4)
+	s.Add([]byte("flow"), 100)
+	matrix := s.VirtualMatrix([]byte("flow"))
+	if len(matrix) != 8 || len(matrix[0]) != 4 {
+		t.Fatalf("Expected an 8x4 matrix, got %dx%d", len(matrix), len(matrix[0]))
+	}
+	if !matrix[0][0] || s.test(s.getPos([]byte("flow"), 3, 2)) != matrix[3][2] {
+		t.Error("Expected the matrix to hold the bits of the flow")
+	}
+
+	var buf bytes.Buffer
+	if err := s.DumpMatrix(&buf, []byte("flow")); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 8 || len(lines[0]) != 4 || lines[0][0] != '1' {
+		t.Errorf("Expected 8 rows of 4 bits, got %q", buf.String())
+	}
+}
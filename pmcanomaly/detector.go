@@ -0,0 +1,268 @@
+/*
+Package pmcanomaly flags the flows whose traffic deviates from their
+baseline, such as sudden floods or outages:
+
+	d, err := pmcanomaly.NewDetector(pmcanomaly.Config{
+		L: 1 << 20, M: 64, W: 32, Window: time.Minute, Baseline: 10, Factor: 5})
+	defer d.Close()
+	go func() {
+		for ev := range d.Events() {
+			log.Printf("%x: %v in the last window, %v usually", ev.Flow, ev.Current, ev.Baseline)
+		}
+	}()
+	d.Increment(flow)
+
+Traffic is counted in windows, each a sketch. The baseline of a flow is its
+mean estimate over the previous windows, and at the end of every window the
+estimates of the flows tracked in it, its largest flows and those of the
+previous window, are compared with their baselines.
+*/
+package pmcanomaly
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+)
+
+// Defaults of Config.
+const (
+	DefaultTopK        = 100
+	DefaultEventBuffer = 256
+)
+
+/*
+Config configures a Detector.
+*/
+type Config struct {
+	// L, M, W and Options create the sketch of every window, see pmc.New.
+	L, M, W uint
+	Options []pmc.Option
+	// Window is the length of a window. If 0, windows end when Rotate is
+	// called.
+	Window time.Duration
+	// Baseline is the number of previous windows the baseline is averaged
+	// over, at least 1.
+	Baseline int
+	// Factor is how many times larger, or smaller, than its baseline the
+	// estimate of a flow must be to be flagged, more than 1.
+	Factor float64
+	// MinCount is the count below which estimates are raised, so that small
+	// flows aren't flagged for their noise, 1 if 0.
+	MinCount float64
+	// TopK is the number of largest flows of a window which are tracked,
+	// DefaultTopK if 0.
+	TopK int
+	// EventBuffer is the capacity of the channel of events,
+	// DefaultEventBuffer if 0. Events not fitting are dropped.
+	EventBuffer int
+}
+
+/*
+Event is a flow whose estimate in a window deviates from its baseline.
+*/
+type Event struct {
+	// Time is the end of the window.
+	Time time.Time
+	Flow []byte
+	// Current is the estimate of the flow in the window, and Baseline its
+	// mean estimate over the previous windows.
+	Current, Baseline float64
+}
+
+/*
+Spike returns whether the flow grew rather than dropped.
+*/
+func (ev Event) Spike() bool {
+	return ev.Current > ev.Baseline
+}
+
+/*
+Detector counts traffic in windows, and emits the Events of the flows of
+every window deviating from their baseline. A Detector is safe for
+concurrent use.
+*/
+type Detector struct {
+	mu      sync.Mutex
+	cfg     Config
+	current *pmc.HeavyHitters
+	// previous are the last windows, most recent first.
+	previous []*pmc.Sketch
+	// watched are the flows tracked in the last window.
+	watched []pmc.HeavyHitter
+	events  chan Event
+	dropped uint64
+	stop    chan struct{}
+	closed  bool
+}
+
+/*
+NewDetector returns a Detector configured by cfg. If cfg.Window is positive
+the windows end every cfg.Window until Close is called.
+*/
+func NewDetector(cfg Config) (*Detector, error) {
+	if cfg.Baseline < 1 {
+		return nil, fmt.Errorf("Expected baseline >= 1, got %d", cfg.Baseline)
+	}
+	if !(cfg.Factor > 1) {
+		return nil, fmt.Errorf("Expected factor > 1, got %v", cfg.Factor)
+	}
+	if cfg.MinCount < 0 || cfg.TopK < 0 || cfg.EventBuffer < 0 || cfg.Window < 0 {
+		return nil, errors.New("Expected a non-negative min count, top k, event buffer and window")
+	}
+	if cfg.MinCount == 0 {
+		cfg.MinCount = 1
+	}
+	if cfg.TopK == 0 {
+		cfg.TopK = DefaultTopK
+	}
+	if cfg.EventBuffer == 0 {
+		cfg.EventBuffer = DefaultEventBuffer
+	}
+	d := &Detector{cfg: cfg, events: make(chan Event, cfg.EventBuffer)}
+	var err error
+	if d.current, err = d.newWindow(nil); err != nil {
+		return nil, err
+	}
+	if cfg.Window > 0 {
+		d.stop = make(chan struct{})
+		go d.rotateEvery(cfg.Window, d.stop)
+	}
+	return d, nil
+}
+
+// newWindow returns the tracker of a new window, reusing the sketch of an
+// expired one if not nil.
+func (d *Detector) newWindow(expired *pmc.Sketch) (*pmc.HeavyHitters, error) {
+	sketch := expired
+	if sketch == nil {
+		var err error
+		if sketch, err = pmc.New(d.cfg.L, d.cfg.M, d.cfg.W, d.cfg.Options...); err != nil {
+			return nil, err
+		}
+	} else {
+		sketch.Reset()
+	}
+	return pmc.NewHeavyHitters(sketch, d.cfg.TopK)
+}
+
+func (d *Detector) rotateEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			d.Rotate(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Increment the count of the flow by 1 in the current window
+*/
+func (d *Detector) Increment(flow []byte) {
+	d.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow in the current window, see
+pmc.Sketch.Add.
+*/
+func (d *Detector) Add(flow []byte, weight uint64) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.current.Add(flow, weight)
+}
+
+/*
+Rotate ends the current window at now, emitting the events of its flows, and
+starts a new one.
+*/
+func (d *Detector) Rotate(now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	top := d.current.Top()
+	if len(d.previous) > 0 {
+		seen := make(map[string]bool, len(top)+len(d.watched))
+		for _, flows := range [][]pmc.HeavyHitter{top, d.watched} {
+			for _, hh := range flows {
+				if !seen[hh.Flow] {
+					seen[hh.Flow] = true
+					d.check(now, []byte(hh.Flow))
+				}
+			}
+		}
+	}
+	d.watched = top
+
+	sketch := d.current.Sketch()
+	var expired *pmc.Sketch
+	if len(d.previous) == d.cfg.Baseline {
+		expired = d.previous[len(d.previous)-1]
+		d.previous = d.previous[:len(d.previous)-1]
+	}
+	d.previous = append([]*pmc.Sketch{sketch}, d.previous...)
+	// The parameters were validated by the first window.
+	d.current, _ = d.newWindow(expired)
+}
+
+// check emits the event of flow if its estimate in the current window
+// deviates from its baseline.
+func (d *Detector) check(now time.Time, flow []byte) {
+	current := d.current.GetEstimate(flow)
+	baseline := 0.0
+	for _, sketch := range d.previous {
+		baseline += sketch.GetEstimate(flow)
+	}
+	baseline /= float64(len(d.previous))
+	c, b := math.Max(current, d.cfg.MinCount), math.Max(baseline, d.cfg.MinCount)
+	if c < d.cfg.Factor*b && b < d.cfg.Factor*c {
+		return
+	}
+	select {
+	case d.events <- Event{Time: now, Flow: flow, Current: current, Baseline: baseline}:
+	default:
+		d.dropped++
+	}
+}
+
+/*
+Events returns the channel of the events, closed by Close.
+*/
+func (d *Detector) Events() <-chan Event {
+	return d.events
+}
+
+/*
+Dropped returns the number of events dropped because the channel was full.
+*/
+func (d *Detector) Dropped() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.dropped
+}
+
+/*
+Close stops the windows and closes the channel of the events.
+*/
+func (d *Detector) Close() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.closed {
+		return
+	}
+	d.closed = true
+	if d.stop != nil {
+		close(d.stop)
+	}
+	close(d.events)
+}
@@ -0,0 +1,97 @@
+package pmcanomaly
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func newTestDetector(t *testing.T) *Detector {
+	d, err := NewDetector(Config{L: 1 << 18, M: 64, W: 32, Baseline: 3, Factor: 4, MinCount: 50, TopK: 20,
+		Options: []pmc.Option{pmc.WithHashSeed(1)}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d
+}
+
+// window adds the steady traffic of a window, 200 additions to each of 10
+// flows, and the given extra additions.
+func window(d *Detector, extra map[string]uint64) {
+	for i := 0; i < 10; i++ {
+		flow := fmt.Sprintf("flow-%d", i)
+		if w, ok := extra[flow]; ok {
+			d.Add([]byte(flow), w)
+			continue
+		}
+		d.Add([]byte(flow), 200)
+	}
+	for flow, w := range extra {
+		if len(flow) < 5 || flow[:5] != "flow-" {
+			d.Add([]byte(flow), w)
+		}
+	}
+}
+
+func TestDetector(t *testing.T) {
+	d := newTestDetector(t)
+	defer d.Close()
+	start := time.Unix(0, 0)
+	for k := 0; k < 4; k++ {
+		window(d, nil)
+		d.Rotate(start.Add(time.Duration(k) * time.Minute))
+	}
+	select {
+	case ev := <-d.Events():
+		t.Fatalf("Expected no events for steady traffic, got %+v", ev)
+	default:
+	}
+
+	// flood appears, and flow-3 goes quiet.
+	window(d, map[string]uint64{"flood": 5000, "flow-3": 0})
+	end := start.Add(10 * time.Minute)
+	d.Rotate(end)
+	got := map[string]Event{}
+	for len(d.Events()) > 0 {
+		ev := <-d.Events()
+		got[string(ev.Flow)] = ev
+	}
+	if ev, ok := got["flood"]; !ok || !ev.Spike() || !ev.Time.Equal(end) || ev.Current < 4000 {
+		t.Errorf("Expected a spike of flood, got %+v", got)
+	}
+	if ev, ok := got["flow-3"]; !ok || ev.Spike() || ev.Baseline < 150 {
+		t.Errorf("Expected a drop of flow-3, got %+v", got)
+	}
+	if len(got) != 2 {
+		t.Errorf("Expected 2 events, got %+v", got)
+	}
+}
+
+func TestDetectorConfig(t *testing.T) {
+	for _, cfg := range []Config{
+		{L: 1 << 10, M: 8, W: 8, Baseline: 0, Factor: 2},
+		{L: 1 << 10, M: 8, W: 8, Baseline: 1, Factor: 1},
+		{L: 1 << 10, M: 8, W: 8, Baseline: 1, Factor: 2, TopK: -1},
+		{L: 0, M: 8, W: 8, Baseline: 1, Factor: 2},
+	} {
+		if _, err := NewDetector(cfg); err == nil {
+			t.Errorf("Expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestDetectorClose(t *testing.T) {
+	d, err := NewDetector(Config{L: 1 << 10, M: 8, W: 8, Baseline: 1, Factor: 2, Window: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.Increment([]byte("flow"))
+	time.Sleep(5 * time.Millisecond)
+	d.Close()
+	d.Close()
+	d.Rotate(time.Now())
+	for range d.Events() {
+	}
+}
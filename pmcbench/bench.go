@@ -0,0 +1,317 @@
+/*
+Package pmcbench compares PMC sketches against Count-Min sketches and exact
+counting on synthetic Zipf traffic, measuring the ingestion and query costs
+and the accuracy of each counter on the hardware it runs on:
+
+	report, err := pmcbench.Run(pmcbench.Traffic{Flows: 100000, Packets: 10000000, Zipf: 1.1},
+		pmcbench.Candidates(1<<20, 100000))
+	fmt.Print(report)
+
+Costs are measured with testing.Benchmark, in ns/op, bytes/op and allocs/op
+of Increment and Estimate. Accuracy is measured on a counter fed the traffic
+once, as the relative error of the estimates of all flows, and memory as the
+growth of the live heap while it is created and fed.
+*/
+package pmcbench
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Counter is a frequency counter under comparison.
+*/
+type Counter interface {
+	Increment(flow []byte)
+	Estimate(flow []byte) float64
+}
+
+/*
+Candidate is a named counter under comparison. New is called for each
+measurement, so that every one starts from an empty counter.
+*/
+type Candidate struct {
+	Name string
+	New  func() (Counter, error)
+}
+
+/*
+Candidates returns a PMC sketch sized by pmc.NewWithMemoryBudget, a
+Count-Min sketch of 4 rows of 32-bit counters taking the same budget, and
+exact counting, for flows flows.
+*/
+func Candidates(budget uint64, flows uint) []Candidate {
+	return []Candidate{
+		{"pmc", func() (Counter, error) { return NewPMC(budget, flows) }},
+		{"count-min", func() (Counter, error) { return NewCountMin(4, uint(budget/16)) }},
+		{"exact", func() (Counter, error) { return NewExact(), nil }},
+	}
+}
+
+type pmcCounter struct {
+	sketch *pmc.Sketch
+}
+
+/*
+NewPMC returns a Counter backed by a PMC sketch created with
+pmc.NewWithMemoryBudget.
+*/
+func NewPMC(budget uint64, flows uint, opts ...pmc.Option) (Counter, error) {
+	sketch, err := pmc.NewWithMemoryBudget(budget, flows, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return pmcCounter{sketch}, nil
+}
+
+func (c pmcCounter) Increment(flow []byte) {
+	c.sketch.Increment(flow)
+}
+
+func (c pmcCounter) Estimate(flow []byte) float64 {
+	return c.sketch.GetEstimate(flow)
+}
+
+/*
+CountMin is a Count-Min sketch of depth rows of width 32-bit counters. The
+counters of a flow are picked by double hashing of its xxHash.
+*/
+type CountMin struct {
+	depth, width uint
+	counters     []uint32
+}
+
+/*
+NewCountMin returns an empty Count-Min sketch.
+*/
+func NewCountMin(depth, width uint) (*CountMin, error) {
+	if depth == 0 || width == 0 {
+		return nil, fmt.Errorf("Expected depth, width > 0, got %d, %d", depth, width)
+	}
+	return &CountMin{depth: depth, width: width, counters: make([]uint32, depth*width)}, nil
+}
+
+// index returns the index of the counter of flow hashed to h in row i.
+func (c *CountMin) index(h uint64, i uint) uint {
+	// The high half of the hash steps through the rows, odd to cover them all.
+	g := uint(h) + i*uint(h>>32|1)
+	return i*c.width + g%c.width
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (c *CountMin) Increment(flow []byte) {
+	h := xxhash.Sum64(flow)
+	for i := uint(0); i < c.depth; i++ {
+		c.counters[c.index(h, i)]++
+	}
+}
+
+/*
+Estimate returns the smallest counter of the flow, which never underestimates
+its count.
+*/
+func (c *CountMin) Estimate(flow []byte) float64 {
+	h := xxhash.Sum64(flow)
+	est := uint32(math.MaxUint32)
+	for i := uint(0); i < c.depth; i++ {
+		est = min(est, c.counters[c.index(h, i)])
+	}
+	return float64(est)
+}
+
+/*
+Exact counts flows exactly in a map, the baseline the sketches are compared
+to.
+*/
+type Exact struct {
+	counts map[string]uint64
+}
+
+/*
+NewExact returns an empty exact counter.
+*/
+func NewExact() *Exact {
+	return &Exact{counts: make(map[string]uint64)}
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (e *Exact) Increment(flow []byte) {
+	e.counts[string(flow)]++
+}
+
+/*
+Estimate returns the count of the flow.
+*/
+func (e *Exact) Estimate(flow []byte) float64 {
+	return float64(e.counts[string(flow)])
+}
+
+/*
+Traffic describes the synthetic traffic: Packets increments spread over
+Flows flows, flow i receiving a share proportional to 1/(i+1)^Zipf, so that
+0 gives uniform traffic. Seed seeds the traffic, pmc.DefaultSeed by default.
+*/
+type Traffic struct {
+	Flows   int
+	Packets int
+	Zipf    float64
+	Seed    uint64
+}
+
+// generate returns the keys of the flows and the sequence of flows of the
+// packets.
+func (t Traffic) generate() (keys [][]byte, packets []int32) {
+	cdf := make([]float64, t.Flows)
+	total := 0.0
+	for i := range cdf {
+		total += math.Pow(float64(i+1), -t.Zipf)
+		cdf[i] = total
+	}
+	rnd := rand.New(rand.NewPCG(t.Seed, t.Seed>>1|1))
+	packets = make([]int32, t.Packets)
+	for k := range packets {
+		f := sort.SearchFloat64s(cdf, rnd.Float64()*total)
+		packets[k] = int32(min(f, t.Flows-1))
+	}
+	keys = make([][]byte, t.Flows)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("flow-%d", i))
+	}
+	return keys, packets
+}
+
+/*
+Result is the outcome of the measurements of a candidate.
+*/
+type Result struct {
+	Name string
+	// Ingest and Query are the costs of Increment and Estimate.
+	Ingest, Query testing.BenchmarkResult
+	// MemoryBytes is the growth of the live heap while the counter is created
+	// and fed the traffic.
+	MemoryBytes uint64
+	// The errors are relative to the exact counts of the flows seen.
+	MeanError, MedianError, MaxError float64
+}
+
+/*
+Report is the outcome of a comparison.
+*/
+type Report struct {
+	Traffic Traffic
+	Results []Result
+}
+
+/*
+Run measures the candidates on traffic t.
+*/
+func Run(t Traffic, candidates []Candidate) (*Report, error) {
+	if t.Flows <= 0 || t.Packets <= 0 {
+		return nil, errors.New("Expected flows and packets > 0")
+	}
+	if t.Zipf < 0 {
+		return nil, fmt.Errorf("Expected zipf >= 0, got %v", t.Zipf)
+	}
+	if t.Seed == 0 {
+		t.Seed = pmc.DefaultSeed
+	}
+	keys, packets := t.generate()
+	counts := make([]uint64, t.Flows)
+	for _, f := range packets {
+		counts[f]++
+	}
+
+	report := &Report{Traffic: t}
+	for _, c := range candidates {
+		r, err := measure(c, keys, packets, counts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", c.Name, err)
+		}
+		report.Results = append(report.Results, r)
+	}
+	return report, nil
+}
+
+// measure returns the result of candidate c on the packets.
+func measure(c Candidate, keys [][]byte, packets []int32, counts []uint64) (Result, error) {
+	r := Result{Name: c.Name}
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	counter, err := c.New()
+	if err != nil {
+		return r, err
+	}
+	for _, f := range packets {
+		counter.Increment(keys[f])
+	}
+	runtime.GC()
+	runtime.ReadMemStats(&after)
+	if after.HeapAlloc > before.HeapAlloc {
+		r.MemoryBytes = after.HeapAlloc - before.HeapAlloc
+	}
+
+	var errs []float64
+	for i, count := range counts {
+		if count > 0 {
+			est := counter.Estimate(keys[i])
+			errs = append(errs, math.Abs(est-float64(count))/float64(count))
+		}
+	}
+	sort.Float64s(errs)
+	for _, e := range errs {
+		r.MeanError += e
+	}
+	r.MeanError /= float64(len(errs))
+	r.MedianError, r.MaxError = errs[len(errs)/2], errs[len(errs)-1]
+
+	r.Query = testing.Benchmark(func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			counter.Estimate(keys[packets[i%len(packets)]])
+		}
+	})
+	r.Ingest = testing.Benchmark(func(b *testing.B) {
+		fresh, err := c.New()
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			fresh.Increment(keys[packets[i%len(packets)]])
+		}
+	})
+	return r, nil
+}
+
+/*
+String returns the report as a table of costs and errors by candidate.
+*/
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "flows=%d packets=%d zipf=%v\n", r.Traffic.Flows, r.Traffic.Packets, r.Traffic.Zipf)
+	fmt.Fprintf(&b, "%-10s %12s %10s %12s %10s %12s %8s %8s %8s\n",
+		"counter", "ingest ns/op", "B/op", "query ns/op", "B/op", "memory", "mean", "median", "max")
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "%-10s %12d %10d %12d %10d %12d %7.2f%% %7.2f%% %7.2f%%\n",
+			res.Name, res.Ingest.NsPerOp(), res.Ingest.AllocedBytesPerOp(),
+			res.Query.NsPerOp(), res.Query.AllocedBytesPerOp(), res.MemoryBytes,
+			100*res.MeanError, 100*res.MedianError, 100*res.MaxError)
+	}
+	return b.String()
+}
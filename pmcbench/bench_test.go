@@ -0,0 +1,100 @@
+package pmcbench
+
+import (
+	"flag"
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping the measurements in short mode")
+	}
+	// testing.Benchmark runs for -test.benchtime, 1s by default.
+	benchtime := flag.Lookup("test.benchtime").Value.String()
+	defer flag.Set("test.benchtime", benchtime)
+	flag.Set("test.benchtime", "10ms")
+
+	report, err := Run(Traffic{Flows: 1000, Packets: 100000, Zipf: 1.1}, Candidates(1<<17, 1000))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Results) != 3 {
+		t.Fatal("Expected 3 results, got", len(report.Results))
+	}
+	for _, r := range report.Results {
+		if r.Ingest.N == 0 || r.Query.N == 0 {
+			t.Errorf("Expected %s to be benchmarked, got %+v", r.Name, r)
+		}
+	}
+	if exact := report.Results[2]; exact.MaxError != 0 {
+		t.Error("Expected exact counting to be exact, got", exact.MaxError)
+	}
+	if pmc := report.Results[0]; pmc.MedianError > 0.5 || pmc.Ingest.AllocsPerOp() != 0 {
+		t.Errorf("Expected a median PMC error below 50%% without allocations, got %+v", pmc)
+	}
+	if s := report.String(); !strings.Contains(s, "count-min") || strings.Count(s, "\n") != 5 {
+		t.Error("Expected a table of 3 counters, got", s)
+	}
+
+	if _, err := Run(Traffic{}, nil); err == nil {
+		t.Error("Expected error without traffic, got nil")
+	}
+}
+
+func TestCountMin(t *testing.T) {
+	c, err := NewCountMin(4, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		c.Increment([]byte("flow"))
+	}
+	c.Increment([]byte("other"))
+	if est := c.Estimate([]byte("flow")); est < 100 || est > 101 {
+		t.Error("Expected an estimate of 100, got", est)
+	}
+	if est := c.Estimate([]byte("unseen")); est > 1 {
+		t.Error("Expected an estimate of 0 for an unseen flow, got", est)
+	}
+	if _, err := NewCountMin(0, 1024); err == nil {
+		t.Error("Expected error for depth 0, got nil")
+	}
+}
+
+func BenchmarkIngest(b *testing.B) {
+	keys, packets := Traffic{Flows: 100000, Packets: 1 << 20, Zipf: 1.1, Seed: 1}.generate()
+	for _, c := range Candidates(1<<20, 100000) {
+		b.Run(c.Name, func(b *testing.B) {
+			counter, err := c.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				counter.Increment(keys[packets[i%len(packets)]])
+			}
+		})
+	}
+}
+
+func BenchmarkQuery(b *testing.B) {
+	keys, packets := Traffic{Flows: 100000, Packets: 1 << 20, Zipf: 1.1, Seed: 1}.generate()
+	for _, c := range Candidates(1<<20, 100000) {
+		b.Run(c.Name, func(b *testing.B) {
+			counter, err := c.New()
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, f := range packets {
+				counter.Increment(keys[f])
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				counter.Estimate(keys[packets[i%len(packets)]])
+			}
+		})
+	}
+}
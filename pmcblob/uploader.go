@@ -0,0 +1,193 @@
+/*
+Package pmcblob periodically uploads compressed snapshots of PMC sketches to
+object storage, such as S3 or GCS, under timestamped keys, for fleets that
+aggregate sketches in batch pipelines:
+
+	import _ "gocloud.dev/blob/s3blob"
+
+	sketch, err := pmc.New(l, m, w, pmc.WithThreadSafety())
+	bucket, err := blob.OpenBucket(ctx, "s3://my-bucket?region=us-east-1")
+	up := pmcblob.NewUploader(bucket, "collectors/host-1/")
+	up.OnError = func(t time.Time, err error) { log.Print(err) }
+	go up.Run(ctx, sketch, time.Minute)
+	...
+	sketch, at, err := up.LoadLatest(ctx)
+
+Buckets are opened with the Go CDK, gocloud.dev/blob, whose drivers are
+registered by importing gocloud.dev/blob/s3blob or gocloud.dev/blob/gcsblob.
+Snapshots are written by pmc.Sketch.SaveCompressed, under the key made of
+the prefix and the UTC time of the snapshot, so that keys sort by time. Run
+snapshots the sketch while it's being added to, so the sketch must be created
+with pmc.WithThreadSafety.
+*/
+package pmcblob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+	"gocloud.dev/blob"
+)
+
+// keyLayout formats the times of the keys, with a fixed width so that keys
+// sort by time.
+const keyLayout = "20060102T150405.000000000Z"
+
+// keySuffix ends the keys of the snapshots.
+const keySuffix = ".pmc.gz"
+
+/*
+ErrNoSnapshot is returned when no snapshot was uploaded under the prefix.
+*/
+var ErrNoSnapshot = errors.New("No snapshot under the prefix")
+
+/*
+Uploader uploads snapshots of sketches to a bucket under Prefix. Keeping
+Retain snapshots, it deletes the older ones as it uploads, or keeps them
+all if Retain is 0. The errors of the uploads of Run are passed to OnError
+if not nil. An Uploader is safe for concurrent use.
+*/
+type Uploader struct {
+	bucket  *blob.Bucket
+	Prefix  string
+	Retain  int
+	OnError func(t time.Time, err error)
+}
+
+/*
+NewUploader returns an Uploader to bucket under prefix, keeping all
+snapshots.
+*/
+func NewUploader(bucket *blob.Bucket, prefix string) *Uploader {
+	return &Uploader{bucket: bucket, Prefix: prefix}
+}
+
+/*
+Key returns the key of the snapshot taken at t.
+*/
+func (up *Uploader) Key(t time.Time) string {
+	return up.Prefix + t.UTC().Format(keyLayout) + keySuffix
+}
+
+// keyTime returns the time of the snapshot of key, and false if key isn't
+// that of a snapshot.
+func (up *Uploader) keyTime(key string) (time.Time, bool) {
+	stamp, ok := strings.CutPrefix(key, up.Prefix)
+	if !ok {
+		return time.Time{}, false
+	}
+	if stamp, ok = strings.CutSuffix(stamp, keySuffix); !ok {
+		return time.Time{}, false
+	}
+	t, err := time.Parse(keyLayout, stamp)
+	return t, err == nil
+}
+
+/*
+Save uploads a snapshot of sketch taken at t, and deletes the oldest
+snapshots beyond Retain.
+*/
+func (up *Uploader) Save(ctx context.Context, sketch *pmc.Sketch, t time.Time) error {
+	w, err := up.bucket.NewWriter(ctx, up.Key(t), &blob.WriterOptions{ContentType: "application/gzip"})
+	if err != nil {
+		return err
+	}
+	if err := sketch.SaveCompressed(w); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	if up.Retain == 0 {
+		return nil
+	}
+	times, err := up.Times(ctx)
+	if err != nil {
+		return err
+	}
+	for _, old := range times[:max(0, len(times)-up.Retain)] {
+		if err := up.bucket.Delete(ctx, up.Key(old)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+Run uploads a snapshot of sketch every interval until ctx is done, and
+returns the error of ctx. A failed upload, e.g. while the bucket is
+unreachable, is passed to OnError and the next one is made at the next
+interval as usual.
+*/
+func (up *Uploader) Run(ctx context.Context, sketch *pmc.Sketch, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t := <-ticker.C:
+			if err := up.Save(ctx, sketch, t); err != nil && up.OnError != nil {
+				up.OnError(t, err)
+			}
+		}
+	}
+}
+
+/*
+Times returns the times of the snapshots under the prefix, oldest first.
+*/
+func (up *Uploader) Times(ctx context.Context) ([]time.Time, error) {
+	var times []time.Time
+	iter := up.bucket.List(&blob.ListOptions{Prefix: up.Prefix})
+	for {
+		obj, err := iter.Next(ctx)
+		if err == io.EOF {
+			return times, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		// Keys are listed in lexical order, which is that of their times.
+		if t, ok := up.keyTime(obj.Key); ok && !obj.IsDir {
+			times = append(times, t)
+		}
+	}
+}
+
+/*
+Load returns the sketch of the snapshot taken at t.
+*/
+func (up *Uploader) Load(ctx context.Context, t time.Time) (*pmc.Sketch, error) {
+	r, err := up.bucket.NewReader(ctx, up.Key(t), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return pmc.LoadCompressed(r)
+}
+
+/*
+LoadLatest returns the sketch of the latest snapshot under the prefix, along
+with the time it was taken. It returns ErrNoSnapshot if there is none.
+*/
+func (up *Uploader) LoadLatest(ctx context.Context) (*pmc.Sketch, time.Time, error) {
+	times, err := up.Times(ctx)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	if len(times) == 0 {
+		return nil, time.Time{}, ErrNoSnapshot
+	}
+	at := times[len(times)-1]
+	sketch, err := up.Load(ctx, at)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return sketch, at, nil
+}
@@ -0,0 +1,93 @@
+package pmcblob
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+	"gocloud.dev/blob/memblob"
+)
+
+func TestUploader(t *testing.T) {
+	ctx := context.Background()
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+	up := NewUploader(bucket, "collectors/a/")
+	up.Retain = 2
+
+	if _, _, err := up.LoadLatest(ctx); err != ErrNoSnapshot {
+		t.Error("Expected ErrNoSnapshot, got", err)
+	}
+	sketch, _ := pmc.New(1<<16, 64, 32)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 6, time.UTC)
+	for i := 0; i < 3; i++ {
+		for k := 0; k < 100; k++ {
+			sketch.Increment([]byte("flow"))
+		}
+		if err := up.Save(ctx, sketch, start.Add(time.Duration(i)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Keys of other prefixes aren't snapshots of up.
+	if err := bucket.WriteAll(ctx, "collectors/b/x", []byte("x"), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	times, err := up.Times(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 || !times[0].Equal(start.Add(time.Hour)) || !times[1].Equal(start.Add(2*time.Hour)) {
+		t.Errorf("Expected the last 2 snapshots, got %v", times)
+	}
+	latest, at, err := up.LoadLatest(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.Equal(start.Add(2*time.Hour)) || latest.N() != 300 {
+		t.Errorf("Expected the snapshot of %v with N = 300, got %v with N = %d", start.Add(2*time.Hour), at, latest.N())
+	}
+	older, err := up.Load(ctx, times[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if older.N() != 200 {
+		t.Errorf("Expected N = 200, got %d", older.N())
+	}
+}
+
+func TestUploaderRun(t *testing.T) {
+	bucket := memblob.OpenBucket(nil)
+	defer bucket.Close()
+	up := NewUploader(bucket, "")
+	sketch, _ := pmc.New(1<<16, 64, 32)
+	sketch.Increment([]byte("flow"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := up.Run(ctx, sketch, 5*time.Millisecond); err != context.DeadlineExceeded {
+		t.Error("Expected context.DeadlineExceeded, got", err)
+	}
+	times, err := up.Times(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) == 0 {
+		t.Error("Expected snapshots to be uploaded")
+	}
+
+	closed := memblob.OpenBucket(nil)
+	closed.Close()
+	failing := NewUploader(closed, "")
+	failures := 0
+	failing.OnError = func(time.Time, error) { failures++ }
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := failing.Run(ctx, sketch, 5*time.Millisecond); err != context.DeadlineExceeded {
+		t.Error("Expected Run to keep going until context.DeadlineExceeded, got", err)
+	}
+	if failures < 2 {
+		t.Error("Expected the failed uploads to be reported and retried, got", failures)
+	}
+}
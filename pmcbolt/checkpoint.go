@@ -0,0 +1,195 @@
+/*
+Package pmcbolt checkpoints PMC sketches into a bbolt database, keeping the
+last checkpoints so that the state of a sketch as of some time can be loaded
+back, and the traffic of a flow between two times estimated:
+
+	cp, err := pmcbolt.Open("flows.db", 24)
+	go cp.Run(ctx, sketch, time.Hour)
+	...
+	count, err := cp.Delta(time.Now().Add(-6*time.Hour), time.Now(), flow)
+*/
+package pmcbolt
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+	bolt "go.etcd.io/bbolt"
+)
+
+// bucket holds the checkpoints, keyed by their big-endian Unix time in
+// nanoseconds so that they sort by time.
+var bucket = []byte("checkpoints")
+
+/*
+ErrNoCheckpoint is returned when no checkpoint was taken at or before the
+requested time.
+*/
+var ErrNoCheckpoint = errors.New("No checkpoint at or before the requested time")
+
+/*
+Checkpointer persists checkpoints of sketches into a bbolt database, keeping
+the most recent Retain ones. A Checkpointer is safe for concurrent use.
+*/
+type Checkpointer struct {
+	db     *bolt.DB
+	Retain int
+}
+
+/*
+Open opens or creates the database at path, keeping the last retain
+checkpoints, or all of them if retain is 0.
+*/
+func Open(path string, retain int) (*Checkpointer, error) {
+	if retain < 0 {
+		return nil, fmt.Errorf("Expected retain >= 0, got %d", retain)
+	}
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(bucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Checkpointer{db: db, Retain: retain}, nil
+}
+
+/*
+Close closes the database.
+*/
+func (cp *Checkpointer) Close() error {
+	return cp.db.Close()
+}
+
+func timeKey(t time.Time) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(t.UnixNano()))
+}
+
+func keyTime(key []byte) time.Time {
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
+
+/*
+Save stores a checkpoint of sketch taken at t, and drops the oldest
+checkpoints beyond Retain.
+*/
+func (cp *Checkpointer) Save(sketch *pmc.Sketch, t time.Time) error {
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return cp.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(bucket)
+		if err := b.Put(timeKey(t), data); err != nil {
+			return err
+		}
+		if cp.Retain == 0 {
+			return nil
+		}
+		excess := -cp.Retain
+		c := b.Cursor()
+		for k, _ := c.First(); k != nil; k, _ = c.Next() {
+			excess++
+		}
+		for k, _ := c.First(); k != nil && excess > 0; k, _ = c.First() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+			excess--
+		}
+		return nil
+	})
+}
+
+/*
+Run saves a checkpoint of sketch every interval until ctx is done, and
+returns the first error met, or the error of ctx.
+*/
+func (cp *Checkpointer) Run(ctx context.Context, sketch *pmc.Sketch, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case t := <-ticker.C:
+			if err := cp.Save(sketch, t); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+/*
+Times returns the times of the checkpoints kept, oldest first.
+*/
+func (cp *Checkpointer) Times() ([]time.Time, error) {
+	var times []time.Time
+	err := cp.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).ForEach(func(k, _ []byte) error {
+			times = append(times, keyTime(k))
+			return nil
+		})
+	})
+	return times, err
+}
+
+/*
+Load returns the sketch as of t, which is the last checkpoint taken at or
+before t, along with the time it was taken. It returns ErrNoCheckpoint if
+there is none. Checkpoints of sketches created with another Hasher,
+GeoSampler or direction than the default ones are rejected, see
+Sketch.ReadFrom.
+*/
+func (cp *Checkpointer) Load(t time.Time) (*pmc.Sketch, time.Time, error) {
+	var sketch pmc.Sketch
+	var at time.Time
+	err := cp.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+		key := timeKey(t)
+		k, v := c.Seek(key)
+		if k == nil {
+			k, v = c.Last()
+		} else if string(k) != string(key) {
+			k, v = c.Prev()
+		}
+		if k == nil {
+			return ErrNoCheckpoint
+		}
+		at = keyTime(k)
+		return sketch.UnmarshalBinary(v)
+	})
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return &sketch, at, nil
+}
+
+/*
+Delta estimates the count of flow between the checkpoints as of from and to,
+see pmc.DeltaChecked. If there is no checkpoint as of from, the flow is
+counted from the start of the sketch.
+*/
+func (cp *Checkpointer) Delta(from, to time.Time, flow []byte) (float64, error) {
+	after, _, err := cp.Load(to)
+	if err != nil {
+		return 0, err
+	}
+	before, _, err := cp.Load(from)
+	if err == ErrNoCheckpoint {
+		return after.GetEstimate(flow), nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return pmc.DeltaChecked(before, after, flow)
+}
@@ -0,0 +1,61 @@
+package pmcbolt
+
+import (
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestCheckpointer(t *testing.T) {
+	cp, err := Open(filepath.Join(t.TempDir(), "flows.db"), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer cp.Close()
+
+	sketch, _ := pmc.New(1<<20, 256, 32)
+	flow := []byte("flow")
+	start := time.Unix(1000, 0)
+	for h := 0; h < 3; h++ {
+		sketch.Add(flow, 5000)
+		if err := cp.Save(sketch, start.Add(time.Duration(h)*time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	times, err := cp.Times()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(times) != 2 || !times[0].Equal(start.Add(time.Hour)) {
+		t.Fatalf("Expected the last 2 checkpoints to be kept, got %v", times)
+	}
+
+	if _, _, err := cp.Load(start); err != ErrNoCheckpoint {
+		t.Errorf("Expected ErrNoCheckpoint before the first checkpoint, got %v", err)
+	}
+	old, at, err := cp.Load(start.Add(90 * time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !at.Equal(start.Add(time.Hour)) || old.N() != 10000 {
+		t.Errorf("Expected the checkpoint of the second hour, got %v with n = %d", at, old.N())
+	}
+	if _, at, _ := cp.Load(start.Add(time.Hour)); !at.Equal(start.Add(time.Hour)) {
+		t.Errorf("Expected the checkpoint taken at the requested time, got %v", at)
+	}
+	if _, at, _ := cp.Load(start.Add(10 * time.Hour)); !at.Equal(start.Add(2 * time.Hour)) {
+		t.Errorf("Expected the last checkpoint, got %v", at)
+	}
+
+	d, err := cp.Delta(start.Add(time.Hour), start.Add(2*time.Hour), flow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(d-5000)/5000 > 0.2 {
+		t.Errorf("Expected a delta close to 5000, got %f", d)
+	}
+}
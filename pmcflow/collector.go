@@ -0,0 +1,141 @@
+/*
+Package pmcflow collects NetFlow v5, v9, IPFIX and sFlow v5 exports over UDP
+into a PMC sketch, adding to each flow the packet or byte counters of its
+records. The counters of sFlow records are scaled by their sampling rate.
+*/
+package pmcflow
+
+import (
+	"net"
+	"sync"
+
+	"github.com/seiflotfy/pmc"
+	"github.com/seiflotfy/pmc/flowkey"
+)
+
+/*
+KeyFunc returns the flow key of a record, or nil for records not to be
+accounted.
+*/
+type KeyFunc func(r *Record) []byte
+
+/*
+WeightFunc returns the weight of a record.
+*/
+type WeightFunc func(r *Record) uint64
+
+/*
+FiveTuple keys records by protocol, source and destination addresses and
+ports.
+*/
+func FiveTuple(r *Record) []byte {
+	t := flowkey.FiveTuple{Proto: r.Proto, Src: r.SrcAddr, Dst: r.DstAddr, SrcPort: r.SrcPort, DstPort: r.DstPort}
+	return t.Append(make([]byte, 0, flowkey.MaxLen))
+}
+
+/*
+SrcAddr keys records by source address.
+*/
+func SrcAddr(r *Record) []byte {
+	return r.SrcAddr.AsSlice()
+}
+
+/*
+DstAddr keys records by destination address.
+*/
+func DstAddr(r *Record) []byte {
+	return r.DstAddr.AsSlice()
+}
+
+/*
+Packets weighs records by their number of packets.
+*/
+func Packets(r *Record) uint64 {
+	return r.Packets
+}
+
+/*
+Bytes weighs records by their number of bytes.
+*/
+func Bytes(r *Record) uint64 {
+	return r.Bytes
+}
+
+/*
+Collector decodes flow exports and adds their records to a sketch. The
+templates of NetFlow v9 and IPFIX are kept per exporter address and
+observation domain, for up to 1024 exporters of 256 templates, the least
+recently announced being dropped first; data records received before their
+template are dropped.
+A Collector is safe for concurrent use.
+*/
+type Collector struct {
+	sketch *pmc.Sketch
+	key    KeyFunc
+	weight WeightFunc
+
+	mu      sync.Mutex
+	decoder *decoder
+}
+
+/*
+NewCollector returns a Collector adding to sketch the weight of every record
+under its key.
+*/
+func NewCollector(sketch *pmc.Sketch, key KeyFunc, weight WeightFunc) *Collector {
+	return &Collector{sketch: sketch, key: key, weight: weight, decoder: newDecoder()}
+}
+
+/*
+Handle decodes a datagram sent by exporter and adds its records to the
+sketch. It returns the number of records added.
+*/
+func (c *Collector) Handle(exporter string, data []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	records, err := c.decoder.decode(exporter, data)
+	n := 0
+	for i := range records {
+		k := c.key(&records[i])
+		if k == nil {
+			continue
+		}
+		if w := c.weight(&records[i]); w > 0 {
+			c.sketch.Add(k, w)
+			n++
+		}
+	}
+	return n, err
+}
+
+/*
+ListenAndServe listens on the UDP address addr and calls Serve.
+*/
+func (c *Collector) ListenAndServe(addr string) error {
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return c.Serve(conn)
+}
+
+/*
+Serve handles the datagrams received on conn until reading from it fails,
+e.g. once it is closed. Malformed datagrams are ignored.
+*/
+func (c *Collector) Serve(conn net.PacketConn) error {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		exporter := addr.String()
+		if udp, ok := addr.(*net.UDPAddr); ok {
+			// Exporters may change source port, not their templates.
+			exporter = udp.IP.String()
+		}
+		c.Handle(exporter, buf[:n])
+	}
+}
@@ -0,0 +1,54 @@
+package pmcflow
+
+import (
+	"math"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestCollector(t *testing.T) {
+	sketch, _ := pmc.New(1<<20, 256, 32, pmc.WithThreadSafety())
+	c := NewCollector(sketch, SrcAddr, Packets)
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- c.Serve(conn) }()
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	for i := 0; i < 5; i++ {
+		client.Write(v5Datagram("192.168.0.1", 1000, 1))
+	}
+	client.Write(v5Datagram("192.168.0.2", 7, 1))
+
+	src := netip.MustParseAddr("192.168.0.1").AsSlice()
+	deadline := time.Now().Add(5 * time.Second)
+	for sketch.N() < 5007 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if sketch.N() != 5007 {
+		t.Fatal("Expected 5007 packets accounted, got", sketch.N())
+	}
+	est := sketch.GetEstimate(src)
+	if fErr := math.Abs(100 * (1 - est/5000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 5000, got %f", est)
+	}
+
+	conn.Close()
+	if err := <-done; err == nil {
+		t.Error("Expected Serve to fail once the connection is closed")
+	}
+
+	if n, _ := NewCollector(sketch, FiveTuple, Bytes).Handle("r1", v5Datagram("192.168.0.1", 1, 0)); n != 0 {
+		t.Error("Expected records of weight 0 to be skipped, got", n)
+	}
+}
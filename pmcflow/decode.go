@@ -0,0 +1,364 @@
+package pmcflow
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/netip"
+)
+
+/*
+Record is a flow record exported by a router.
+*/
+type Record struct {
+	SrcAddr, DstAddr netip.Addr
+	SrcPort, DstPort uint16
+	Proto            uint8
+	Packets, Bytes   uint64
+}
+
+// Information elements, shared by NetFlow v9 and IPFIX.
+const (
+	fieldBytes       = 1
+	fieldPackets     = 2
+	fieldProto       = 4
+	fieldSrcPort     = 7
+	fieldSrcIPv4     = 8
+	fieldDstPort     = 11
+	fieldDstIPv4     = 12
+	fieldSrcIPv6     = 27
+	fieldDstIPv6     = 28
+	fieldTotalBytes  = 85
+	fieldTotalPacket = 86
+)
+
+// variableLength is the IPFIX field length of variable length fields.
+const variableLength = 0xffff
+
+type field struct {
+	id, length uint16
+}
+
+// templateKey identifies a template of an exporter.
+type templateKey struct {
+	domain uint32
+	id     uint16
+}
+
+// maxExporters and maxTemplates bound the number of exporters whose templates
+// are kept and the number of templates kept per exporter, since exporters
+// are only known by the source address of their datagrams, which can be
+// spoofed. Routers announce a few dozen templates at most.
+const (
+	maxExporters = 1024
+	maxTemplates = 256
+)
+
+/*
+ErrTruncated is returned when decoding a datagram shorter than its content.
+*/
+var ErrTruncated = errors.New("Truncated flow export datagram")
+
+// decoder keeps the NetFlow v9 and IPFIX templates announced by exporters.
+// Past maxExporters, the templates of the exporter that announced one least
+// recently are dropped, as are those an exporter announced least recently
+// past maxTemplates.
+type decoder struct {
+	exporters map[string]*exporter
+	// lru holds the exporters, the one that announced a template least
+	// recently first.
+	lru *list.List
+}
+
+// exporter holds the templates of an exporter and their order of
+// announcement, the least recent first.
+type exporter struct {
+	name      string
+	templates map[templateKey]*list.Element
+	lru       *list.List
+	elem      *list.Element
+}
+
+// knownTemplate is a template of an exporter.
+type knownTemplate struct {
+	key    templateKey
+	fields []field
+}
+
+func newDecoder() *decoder {
+	return &decoder{exporters: make(map[string]*exporter), lru: list.New()}
+}
+
+// template returns the fields of a template of an exporter.
+func (d *decoder) template(name string, key templateKey) ([]field, bool) {
+	e, ok := d.exporters[name]
+	if !ok {
+		return nil, false
+	}
+	elem, ok := e.templates[key]
+	if !ok {
+		return nil, false
+	}
+	return elem.Value.(*knownTemplate).fields, true
+}
+
+// setTemplate sets a template of an exporter, evicting the least recently
+// announced templates and exporters past the bounds.
+func (d *decoder) setTemplate(name string, key templateKey, fields []field) {
+	e, ok := d.exporters[name]
+	if !ok {
+		if d.lru.Len() >= maxExporters {
+			oldest := d.lru.Remove(d.lru.Front()).(*exporter)
+			delete(d.exporters, oldest.name)
+		}
+		e = &exporter{name: name, templates: make(map[templateKey]*list.Element), lru: list.New()}
+		e.elem = d.lru.PushBack(e)
+		d.exporters[name] = e
+	}
+	d.lru.MoveToBack(e.elem)
+	if elem, ok := e.templates[key]; ok {
+		elem.Value.(*knownTemplate).fields = fields
+		e.lru.MoveToBack(elem)
+		return
+	}
+	if e.lru.Len() >= maxTemplates {
+		oldest := e.lru.Remove(e.lru.Front()).(*knownTemplate)
+		delete(e.templates, oldest.key)
+	}
+	e.templates[key] = e.lru.PushBack(&knownTemplate{key: key, fields: fields})
+}
+
+// withdrawTemplate removes a template of an exporter.
+func (d *decoder) withdrawTemplate(name string, key templateKey) {
+	if e, ok := d.exporters[name]; ok {
+		if elem, ok := e.templates[key]; ok {
+			e.lru.Remove(elem)
+			delete(e.templates, key)
+		}
+	}
+}
+
+// decode returns the records of a NetFlow v5, v9, IPFIX or sFlow v5
+// datagram sent by exporter. Data records whose template is unknown yet are
+// skipped.
+func (d *decoder) decode(exporter string, data []byte) ([]Record, error) {
+	if len(data) < 4 {
+		return nil, ErrTruncated
+	}
+	// sFlow starts with a 32 bits version, NetFlow and IPFIX with 16 bits.
+	if binary.BigEndian.Uint32(data) == 5 {
+		return decodeSFlow(data)
+	}
+	switch version := binary.BigEndian.Uint16(data); version {
+	case 5:
+		return decodeV5(data)
+	case 9:
+		if len(data) < 20 {
+			return nil, ErrTruncated
+		}
+		return d.decodeSets(exporter, binary.BigEndian.Uint32(data[16:]), data[20:], 0, 1)
+	case 10:
+		if len(data) < 16 {
+			return nil, ErrTruncated
+		}
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if length < 16 || length > len(data) {
+			return nil, ErrTruncated
+		}
+		return d.decodeSets(exporter, binary.BigEndian.Uint32(data[12:]), data[16:length], 2, 3)
+	default:
+		return nil, fmt.Errorf("Unsupported flow export version %d", version)
+	}
+}
+
+func decodeV5(data []byte) ([]Record, error) {
+	const headerSize, recordSize = 24, 48
+	if len(data) < headerSize {
+		return nil, ErrTruncated
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	if len(data) < headerSize+count*recordSize {
+		return nil, ErrTruncated
+	}
+	records := make([]Record, count)
+	for i := range records {
+		r := data[headerSize+i*recordSize:]
+		records[i] = Record{
+			SrcAddr: netip.AddrFrom4([4]byte(r[0:4])),
+			DstAddr: netip.AddrFrom4([4]byte(r[4:8])),
+			Packets: uint64(binary.BigEndian.Uint32(r[16:])),
+			Bytes:   uint64(binary.BigEndian.Uint32(r[20:])),
+			SrcPort: binary.BigEndian.Uint16(r[32:]),
+			DstPort: binary.BigEndian.Uint16(r[34:]),
+			Proto:   r[38],
+		}
+	}
+	return records, nil
+}
+
+// decodeSets decodes the flow sets of NetFlow v9, or the sets of IPFIX,
+// which differ in the ids of their template and options template sets.
+func (d *decoder) decodeSets(exporter string, domain uint32, data []byte, templateSet, optionsSet uint16) ([]Record, error) {
+	var records []Record
+	ipfix := templateSet == 2
+	for len(data) > 0 {
+		if len(data) < 4 {
+			return records, ErrTruncated
+		}
+		id := binary.BigEndian.Uint16(data)
+		length := int(binary.BigEndian.Uint16(data[2:]))
+		if length < 4 || length > len(data) {
+			return records, ErrTruncated
+		}
+		set := data[4:length]
+		data = data[length:]
+
+		switch {
+		case id == templateSet:
+			if err := d.decodeTemplates(exporter, domain, set, ipfix); err != nil {
+				return records, err
+			}
+		case id == optionsSet:
+			// Options carry exporter metadata, not flows.
+		case id >= 256:
+			fields, ok := d.template(exporter, templateKey{domain, id})
+			if !ok {
+				continue
+			}
+			var err error
+			if records, err = decodeData(records, set, fields); err != nil {
+				return records, err
+			}
+		}
+	}
+	return records, nil
+}
+
+func (d *decoder) decodeTemplates(exporter string, domain uint32, set []byte, ipfix bool) error {
+	// Sets are padded to a multiple of 4 bytes.
+	for len(set) >= 4 {
+		id := binary.BigEndian.Uint16(set)
+		count := int(binary.BigEndian.Uint16(set[2:]))
+		set = set[4:]
+		if id < 256 {
+			if id == 0 && count == 0 {
+				return nil
+			}
+			return fmt.Errorf("Invalid template id %d", id)
+		}
+		key := templateKey{domain, id}
+		if count == 0 {
+			// A template without fields withdraws the template.
+			d.withdrawTemplate(exporter, key)
+			continue
+		}
+		fields := make([]field, count)
+		for i := range fields {
+			if len(set) < 4 {
+				return ErrTruncated
+			}
+			fields[i] = field{binary.BigEndian.Uint16(set), binary.BigEndian.Uint16(set[2:])}
+			set = set[4:]
+			if ipfix && fields[i].id&0x8000 != 0 {
+				// Enterprise specific elements carry their enterprise number.
+				if len(set) < 4 {
+					return ErrTruncated
+				}
+				set = set[4:]
+				fields[i].id = 0
+			}
+		}
+		if empty(fields) {
+			return fmt.Errorf("Invalid template %d of empty records", id)
+		}
+		d.setTemplate(exporter, key, fields)
+	}
+	return nil
+}
+
+// empty returns whether the records of a template take no bytes, so that
+// decoding its data sets would never consume them.
+func empty(fields []field) bool {
+	for _, f := range fields {
+		if f.length != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// recordLength returns the length of the records of a template, or 0 if
+// some of their fields are of variable length.
+func recordLength(fields []field) int {
+	n := 0
+	for _, f := range fields {
+		if f.length == variableLength {
+			return 0
+		}
+		n += int(f.length)
+	}
+	return n
+}
+
+func decodeData(records []Record, set []byte, fields []field) ([]Record, error) {
+	fixed := recordLength(fields)
+	for len(set) > 0 {
+		// Anything shorter than a record is padding.
+		if fixed > 0 && len(set) < fixed || fixed == 0 && len(set) < 4 {
+			break
+		}
+		var r Record
+		for _, f := range fields {
+			length := int(f.length)
+			if f.length == variableLength {
+				if len(set) < 1 {
+					return records, ErrTruncated
+				}
+				length, set = int(set[0]), set[1:]
+				if length == 255 {
+					if len(set) < 2 {
+						return records, ErrTruncated
+					}
+					length, set = int(binary.BigEndian.Uint16(set)), set[2:]
+				}
+			}
+			if len(set) < length {
+				return records, ErrTruncated
+			}
+			setField(&r, f.id, set[:length])
+			set = set[length:]
+		}
+		records = append(records, r)
+	}
+	return records, nil
+}
+
+// readUint returns the big-endian unsigned integer of up to 8 bytes in b,
+// since exporters may shorten integer fields.
+func readUint(b []byte) uint64 {
+	var v uint64
+	for _, c := range b {
+		v = v<<8 | uint64(c)
+	}
+	return v
+}
+
+func setField(r *Record, id uint16, b []byte) {
+	switch id {
+	case fieldBytes, fieldTotalBytes:
+		r.Bytes = readUint(b)
+	case fieldPackets, fieldTotalPacket:
+		r.Packets = readUint(b)
+	case fieldProto:
+		r.Proto = uint8(readUint(b))
+	case fieldSrcPort:
+		r.SrcPort = uint16(readUint(b))
+	case fieldDstPort:
+		r.DstPort = uint16(readUint(b))
+	case fieldSrcIPv4, fieldSrcIPv6:
+		r.SrcAddr, _ = netip.AddrFromSlice(b)
+	case fieldDstIPv4, fieldDstIPv6:
+		r.DstAddr, _ = netip.AddrFromSlice(b)
+	}
+}
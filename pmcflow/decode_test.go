@@ -0,0 +1,180 @@
+package pmcflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"testing"
+)
+
+func be16(b []byte, v uint16) []byte { return binary.BigEndian.AppendUint16(b, v) }
+func be32(b []byte, v uint32) []byte { return binary.BigEndian.AppendUint32(b, v) }
+
+func v5Datagram(src string, packets, bytes uint32) []byte {
+	d := be16(nil, 5)
+	d = be16(d, 1)
+	d = append(d, make([]byte, 20)...)
+	r := make([]byte, 48)
+	a := netip.MustParseAddr(src).As4()
+	copy(r, a[:])
+	copy(r[4:], []byte{10, 0, 0, 1})
+	binary.BigEndian.PutUint32(r[16:], packets)
+	binary.BigEndian.PutUint32(r[20:], bytes)
+	binary.BigEndian.PutUint16(r[32:], 1234)
+	binary.BigEndian.PutUint16(r[34:], 53)
+	r[38] = 17
+	return append(d, r...)
+}
+
+// template is a template of source and destination IPv4, destination port,
+// packets on 4 bytes and bytes on 8 bytes.
+var template = []field{{fieldSrcIPv4, 4}, {fieldDstIPv4, 4}, {fieldDstPort, 2},
+	{fieldPackets, 4}, {fieldBytes, 8}}
+
+func templateSet(id uint16) []byte {
+	s := be16(nil, id)
+	s = be16(s, uint16(4+4+4*len(template)))
+	s = be16(s, 256)
+	s = be16(s, uint16(len(template)))
+	for _, f := range template {
+		s = be16(s, f.id)
+		s = be16(s, f.length)
+	}
+	return s
+}
+
+func dataSet(packets []uint32) []byte {
+	var records []byte
+	for _, p := range packets {
+		records = append(records, 192, 168, 0, 1, 10, 0, 0, 1)
+		records = be16(records, 80)
+		records = be32(records, p)
+		records = binary.BigEndian.AppendUint64(records, uint64(p)*100)
+	}
+	// Padding to a multiple of 4 bytes.
+	for len(records)%4 != 0 {
+		records = append(records, 0)
+	}
+	return append(be16(be16(nil, 256), uint16(4+len(records))), records...)
+}
+
+func TestDecodeV5(t *testing.T) {
+	records, err := newDecoder().decode("r1", v5Datagram("192.168.0.1", 10, 1500))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{SrcAddr: netip.MustParseAddr("192.168.0.1"), DstAddr: netip.MustParseAddr("10.0.0.1"),
+		SrcPort: 1234, DstPort: 53, Proto: 17, Packets: 10, Bytes: 1500}
+	if len(records) != 1 || records[0] != want {
+		t.Errorf("Expected %v, got %v", want, records)
+	}
+	if _, err := newDecoder().decode("r1", v5Datagram("192.168.0.1", 1, 1)[:50]); err != ErrTruncated {
+		t.Error("Expected ErrTruncated, got", err)
+	}
+}
+
+func TestDecodeV9(t *testing.T) {
+	d := newDecoder()
+	header := be16(nil, 9)
+	header = append(header, make([]byte, 14)...)
+	header = be32(header, 7)
+
+	// Data before its template is dropped.
+	records, err := d.decode("r1", append(append([]byte{}, header...), dataSet([]uint32{1})...))
+	if err != nil || len(records) != 0 {
+		t.Error("Expected no records without a template, got", records, err)
+	}
+
+	data := append(append(append([]byte{}, header...), templateSet(0)...), dataSet([]uint32{3, 5})...)
+	records, err = d.decode("r1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[1].Packets != 5 || records[1].Bytes != 500 || records[1].DstPort != 80 {
+		t.Error("Expected 2 records, got", records)
+	}
+	if records[0].SrcAddr != netip.MustParseAddr("192.168.0.1") {
+		t.Error("Expected source 192.168.0.1, got", records[0].SrcAddr)
+	}
+
+	// Templates are per exporter.
+	if records, _ := d.decode("r2", append(append([]byte{}, header...), dataSet([]uint32{1})...)); len(records) != 0 {
+		t.Error("Expected no records for another exporter, got", records)
+	}
+}
+
+func TestDecodeEmptyTemplate(t *testing.T) {
+	header := be16(nil, 9)
+	header = append(header, make([]byte, 14)...)
+	header = be32(header, 7)
+	d := newDecoder()
+	if _, err := d.decode("r1", append(append([]byte{}, header...), templateSet(0)...)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Records of zero-length fields would never consume their data set.
+	empty := be16(be16(be16(be16(nil, 0), 16), 256), 2)
+	empty = be16(be16(be16(be16(empty, fieldPackets), 0), fieldBytes), 0)
+	data := append(append(append([]byte{}, header...), empty...), dataSet([]uint32{1})...)
+	if _, err := d.decode("r1", data); err == nil {
+		t.Error("Expected error for a template of empty records, got nil")
+	}
+
+	// A template without fields withdraws it.
+	withdrawal := be16(be16(be16(be16(nil, 0), 8), 256), 0)
+	data = append(append(append([]byte{}, header...), withdrawal...), dataSet([]uint32{1})...)
+	if records, err := d.decode("r1", data); err != nil || len(records) != 0 {
+		t.Error("Expected no records after the withdrawal of the template, got", records, err)
+	}
+}
+
+func TestDecodeIPFIX(t *testing.T) {
+	sets := append(templateSet(2), dataSet([]uint32{4})...)
+	data := be16(nil, 10)
+	data = be16(data, uint16(16+len(sets)))
+	data = append(data, make([]byte, 8)...)
+	data = be32(data, 1)
+	data = append(data, sets...)
+
+	records, err := newDecoder().decode("r1", data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Packets != 4 || records[0].Bytes != 400 {
+		t.Error("Expected a record of 4 packets and 400 bytes, got", records)
+	}
+	if _, err := newDecoder().decode("r1", []byte{0, 7, 0, 0}); err == nil {
+		t.Error("Expected error for an unsupported version, got nil")
+	}
+}
+
+func TestDecoderBounds(t *testing.T) {
+	d := newDecoder()
+	fields := []field{{fieldPackets, 4}}
+	for id := uint16(256); id < 256+maxTemplates+1; id++ {
+		d.setTemplate("r1", templateKey{1, id}, fields)
+	}
+	if _, ok := d.template("r1", templateKey{1, 256}); ok {
+		t.Error("Expected the oldest template to be evicted")
+	}
+	if _, ok := d.template("r1", templateKey{1, 256 + maxTemplates}); !ok {
+		t.Error("Expected the newest template to be kept")
+	}
+
+	// Announcing a template again makes it the most recent.
+	d.setTemplate("r1", templateKey{1, 257}, fields)
+	d.setTemplate("r1", templateKey{1, 1000}, fields)
+	if _, ok := d.template("r1", templateKey{1, 257}); !ok {
+		t.Error("Expected a template announced again to be kept")
+	}
+
+	for i := 0; i < maxExporters; i++ {
+		d.setTemplate(fmt.Sprint("spoofed-", i), templateKey{1, 256}, fields)
+	}
+	if _, ok := d.template("r1", templateKey{1, 257}); ok {
+		t.Error("Expected the templates of the oldest exporter to be evicted")
+	}
+	if len(d.exporters) != maxExporters || d.lru.Len() != maxExporters {
+		t.Errorf("Expected %d exporters, got %d", maxExporters, len(d.exporters))
+	}
+}
@@ -0,0 +1,180 @@
+package pmcflow
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+)
+
+// sFlow v5 structures, see https://sflow.org/sflow_version_5.txt.
+const (
+	sflowFlowSample         = 1
+	sflowExpandedFlowSample = 3
+	sflowRawHeader          = 1
+
+	sflowEthernet = 1
+	sflowIPv4     = 11
+	sflowIPv6     = 12
+)
+
+// xdr reads the big-endian words of sFlow datagrams, recording whether it
+// ran out of data.
+type xdr struct {
+	b   []byte
+	err error
+}
+
+func (x *xdr) uint32() uint32 {
+	if len(x.b) < 4 {
+		x.b, x.err = nil, ErrTruncated
+		return 0
+	}
+	v := binary.BigEndian.Uint32(x.b)
+	x.b = x.b[4:]
+	return v
+}
+
+// bytes returns the next n bytes, skipping the padding to a multiple of 4.
+func (x *xdr) bytes(n uint32) []byte {
+	padded := (uint64(n) + 3) &^ 3
+	if uint64(len(x.b)) < padded {
+		x.b, x.err = nil, ErrTruncated
+		return nil
+	}
+	v := x.b[:n]
+	x.b = x.b[padded:]
+	return v
+}
+
+// decodeSFlow returns a record for each sampled packet header of an sFlow
+// v5 datagram. Samples stand for sampling rate packets each, so the packets
+// and bytes of the records are scaled by it, to account for the traffic
+// rather than the samples. Datagrams of another agent address type than IPv4
+// and IPv6 are rejected, their samples being found past an address of
+// unknown length; samples and records of other formats, which carry their
+// length, are skipped.
+func decodeSFlow(data []byte) ([]Record, error) {
+	x := &xdr{b: data}
+	x.uint32() // version
+	switch addressType := x.uint32(); addressType {
+	case 1:
+		x.bytes(4)
+	case 2:
+		x.bytes(16)
+	default:
+		if x.err != nil {
+			return nil, x.err
+		}
+		return nil, fmt.Errorf("Unsupported sFlow agent address type %d", addressType)
+	}
+	x.bytes(12) // sub agent id, sequence number and uptime
+	samples := x.uint32()
+
+	var records []Record
+	for i := uint32(0); i < samples && x.err == nil; i++ {
+		format := x.uint32()
+		sample := &xdr{b: x.bytes(x.uint32())}
+		if x.err != nil {
+			break
+		}
+		var rate uint32
+		switch format {
+		case sflowFlowSample:
+			sample.bytes(8) // sequence number and source id
+			rate = sample.uint32()
+			sample.bytes(16) // sample pool, drops, input and output
+		case sflowExpandedFlowSample:
+			sample.bytes(12)
+			rate = sample.uint32()
+			sample.bytes(24)
+		default:
+			continue
+		}
+		if rate == 0 {
+			rate = 1
+		}
+		n := sample.uint32()
+		for j := uint32(0); j < n && sample.err == nil; j++ {
+			recordFormat := sample.uint32()
+			record := &xdr{b: sample.bytes(sample.uint32())}
+			if recordFormat != sflowRawHeader {
+				continue
+			}
+			proto := record.uint32()
+			frameLength := record.uint32()
+			record.uint32() // stripped
+			header := record.bytes(record.uint32())
+			if record.err != nil {
+				continue
+			}
+			r, ok := parseHeader(proto, header)
+			if !ok {
+				continue
+			}
+			r.Packets = uint64(rate)
+			r.Bytes = uint64(rate) * uint64(frameLength)
+			records = append(records, r)
+		}
+		if sample.err != nil {
+			return records, sample.err
+		}
+	}
+	return records, x.err
+}
+
+// parseHeader extracts the addresses, protocol and ports of a sampled packet
+// header, which may be cut anywhere by the agent.
+func parseHeader(proto uint32, h []byte) (Record, bool) {
+	var r Record
+	if proto == sflowEthernet {
+		if len(h) < 14 {
+			return r, false
+		}
+		etherType := binary.BigEndian.Uint16(h[12:])
+		h = h[14:]
+		for etherType == 0x8100 && len(h) >= 4 {
+			// 802.1Q tags.
+			etherType = binary.BigEndian.Uint16(h[2:])
+			h = h[4:]
+		}
+		switch etherType {
+		case 0x0800:
+			proto = sflowIPv4
+		case 0x86dd:
+			proto = sflowIPv6
+		default:
+			return r, false
+		}
+	}
+
+	switch proto {
+	case sflowIPv4:
+		if len(h) < 20 {
+			return r, false
+		}
+		r.Proto = h[9]
+		r.SrcAddr = netip.AddrFrom4([4]byte(h[12:16]))
+		r.DstAddr = netip.AddrFrom4([4]byte(h[16:20]))
+		if ihl := int(h[0]&0x0f) * 4; ihl >= 20 && ihl <= len(h) {
+			h = h[ihl:]
+		} else {
+			h = nil
+		}
+	case sflowIPv6:
+		if len(h) < 40 {
+			return r, false
+		}
+		r.Proto = h[6]
+		r.SrcAddr = netip.AddrFrom16([16]byte(h[8:24]))
+		r.DstAddr = netip.AddrFrom16([16]byte(h[24:40]))
+		h = h[40:]
+	default:
+		return r, false
+	}
+	// TCP and UDP both start with the ports.
+	if (r.Proto == 6 || r.Proto == 17) && len(h) >= 4 {
+		r.SrcPort = binary.BigEndian.Uint16(h)
+		r.DstPort = binary.BigEndian.Uint16(h[2:])
+	}
+	return r, true
+}
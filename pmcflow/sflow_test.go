@@ -0,0 +1,82 @@
+package pmcflow
+
+import (
+	"math"
+	"net/netip"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+// sflowDatagram returns an sFlow datagram with a flow sample of rate of a
+// UDP packet from 192.168.0.1 to 10.0.0.1:53.
+func sflowDatagram(rate uint32) []byte {
+	header := make([]byte, 14+20+8)
+	header[12], header[13] = 0x08, 0x00
+	ip := header[14:]
+	ip[0], ip[9] = 0x45, 17
+	copy(ip[12:], []byte{192, 168, 0, 1, 10, 0, 0, 1})
+	udp := ip[20:]
+	udp[0], udp[1], udp[2], udp[3] = 0x04, 0xd2, 0, 53
+	header = append(header, 0, 0) // padding of the 42 bytes header
+
+	record := be32(nil, sflowEthernet)
+	record = be32(record, 100)
+	record = be32(record, 0)
+	record = be32(record, 42)
+	record = append(record, header...)
+
+	sample := be32(nil, 1)
+	sample = be32(sample, 1)
+	sample = be32(sample, rate)
+	sample = append(sample, make([]byte, 16)...)
+	sample = be32(sample, 1)
+	sample = be32(sample, sflowRawHeader)
+	sample = be32(sample, uint32(len(record)))
+	sample = append(sample, record...)
+
+	d := be32(nil, 5)
+	d = be32(d, 1)
+	d = append(d, 127, 0, 0, 1)
+	d = append(d, make([]byte, 12)...)
+	d = be32(d, 1)
+	d = be32(d, sflowFlowSample)
+	d = be32(d, uint32(len(sample)))
+	return append(d, sample...)
+}
+
+func TestDecodeSFlow(t *testing.T) {
+	records, err := newDecoder().decode("agent", sflowDatagram(512))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Record{SrcAddr: netip.MustParseAddr("192.168.0.1"), DstAddr: netip.MustParseAddr("10.0.0.1"),
+		SrcPort: 1234, DstPort: 53, Proto: 17, Packets: 512, Bytes: 51200}
+	if len(records) != 1 || records[0] != want {
+		t.Errorf("Expected %v, got %v", want, records)
+	}
+
+	data := sflowDatagram(512)
+	if _, err := decodeSFlow(data[:len(data)-8]); err != ErrTruncated {
+		t.Error("Expected ErrTruncated, got", err)
+	}
+	data[7] = 3
+	if records, err := decodeSFlow(data); err == nil {
+		t.Error("Expected error for an unknown agent address type, got", records)
+	}
+}
+
+func TestCollectorSFlow(t *testing.T) {
+	sketch, _ := pmc.New(1<<20, 256, 32)
+	c := NewCollector(sketch, SrcAddr, Packets)
+	for i := 0; i < 20; i++ {
+		c.Handle("agent", sflowDatagram(256))
+	}
+	if sketch.N() != 20*256 {
+		t.Error("Expected 5120 packets accounted, got", sketch.N())
+	}
+	est := sketch.GetEstimate(netip.MustParseAddr("192.168.0.1").AsSlice())
+	if fErr := math.Abs(100 * (1 - est/5120)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 5120, got %f", est)
+	}
+}
@@ -0,0 +1,96 @@
+package pmcgrpc
+
+import (
+	"context"
+
+	"github.com/seiflotfy/pmc"
+	"github.com/seiflotfy/pmc/pmcgrpc/pmcpb"
+	"google.golang.org/grpc"
+)
+
+/*
+Client talks to a Server, translating between sketches and their binary
+encoding on the wire.
+*/
+type Client struct {
+	c pmcpb.SketchClient
+}
+
+/*
+NewClient returns a Client using the connection cc.
+*/
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{c: pmcpb.NewSketchClient(cc)}
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (c *Client) Increment(ctx context.Context, flow []byte) error {
+	return c.Add(ctx, flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (c *Client) Add(ctx context.Context, flow []byte, weight uint64) error {
+	_, err := c.c.Increment(ctx, &pmcpb.IncrementRequest{Flow: flow, N: weight})
+	return err
+}
+
+/*
+IncrementBatch increments the count of each of the flows by 1.
+*/
+func (c *Client) IncrementBatch(ctx context.Context, flows [][]byte) error {
+	_, err := c.c.IncrementBatch(ctx, &pmcpb.IncrementBatchRequest{Flows: flows})
+	return err
+}
+
+/*
+GetEstimate returns the estimated count of a given flow
+*/
+func (c *Client) GetEstimate(ctx context.Context, flow []byte) (float64, error) {
+	resp, err := c.c.Estimate(ctx, &pmcpb.EstimateRequest{Flow: flow})
+	if err != nil {
+		return 0, err
+	}
+	return resp.GetEstimate(), nil
+}
+
+/*
+Merge merges sketch into the one of the server, see Sketch.Merge.
+*/
+func (c *Client) Merge(ctx context.Context, sketch *pmc.Sketch) error {
+	data, err := sketch.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	_, err = c.c.Merge(ctx, &pmcpb.MergeRequest{Sketch: data})
+	return err
+}
+
+/*
+Snapshot returns a copy of the sketch of the server, which fails with
+pmc.ErrIncompatibleParams unless the server uses the default Hasher,
+GeoSampler and direction, see SnapshotInto.
+*/
+func (c *Client) Snapshot(ctx context.Context) (*pmc.Sketch, error) {
+	sketch := &pmc.Sketch{}
+	if err := c.SnapshotInto(ctx, sketch); err != nil {
+		return nil, err
+	}
+	return sketch, nil
+}
+
+/*
+SnapshotInto replaces the state of sketch with that of the sketch of the
+server, as ReadFrom does, so that it keeps the options sketch was created
+with, which must match those of the server.
+*/
+func (c *Client) SnapshotInto(ctx context.Context, sketch *pmc.Sketch) error {
+	resp, err := c.c.Snapshot(ctx, &pmcpb.SnapshotRequest{})
+	if err != nil {
+		return err
+	}
+	return sketch.UnmarshalBinary(resp.GetSketch())
+}
@@ -0,0 +1,59 @@
+package pmcgrpc
+
+import (
+	"context"
+	"net"
+
+	"github.com/seiflotfy/pmc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+/*
+CallKey returns the flow key under which the interceptors count the calls
+of method from the peer host, e.g. to query their estimate with
+sketch.GetEstimate(CallKey("/pkg.Service/Method", "10.0.0.1")).
+*/
+func CallKey(method, host string) []byte {
+	key := make([]byte, 0, len(method)+1+len(host))
+	key = append(key, method...)
+	key = append(key, 0)
+	return append(key, host...)
+}
+
+// peerHost returns the host of the peer of ctx, without its port, since
+// clients use a new ephemeral port for every connection.
+func peerHost(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+	addr := p.Addr.String()
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
+
+/*
+UnaryServerInterceptor returns an interceptor counting the unary calls per
+method and peer host in sketch. Calls are served concurrently, so the sketch
+should be created WithThreadSafety.
+*/
+func UnaryServerInterceptor(sketch *pmc.Sketch) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		sketch.Increment(CallKey(info.FullMethod, peerHost(ctx)))
+		return handler(ctx, req)
+	}
+}
+
+/*
+StreamServerInterceptor returns an interceptor counting the streams per
+method and peer host in sketch, see UnaryServerInterceptor.
+*/
+func StreamServerInterceptor(sketch *pmc.Sketch) grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		sketch.Increment(CallKey(info.FullMethod, peerHost(ss.Context())))
+		return handler(srv, ss)
+	}
+}
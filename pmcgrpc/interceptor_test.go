@@ -0,0 +1,48 @@
+package pmcgrpc
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/peer"
+)
+
+func TestInterceptors(t *testing.T) {
+	calls, _ := pmc.New(1<<20, 256, 32)
+	ctx := peer.NewContext(context.Background(),
+		&peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.1"), Port: 4321}})
+
+	unary := UnaryServerInterceptor(calls)
+	info := &grpc.UnaryServerInfo{FullMethod: "/pmc.v1.Sketch/Estimate"}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return req, nil }
+	for i := 0; i < 1000; i++ {
+		if resp, _ := unary(ctx, "req", info, handler); resp != "req" {
+			t.Fatal("Expected the handler response, got", resp)
+		}
+	}
+	est := calls.GetEstimate(CallKey("/pmc.v1.Sketch/Estimate", "10.0.0.1"))
+	if est < 850 || est > 1150 {
+		t.Error("Expected estimate close to 1000, got", est)
+	}
+	if est := calls.GetEstimate(CallKey("/pmc.v1.Sketch/Merge", "10.0.0.1")); est > 100 {
+		t.Error("Expected a small estimate for another method, got", est)
+	}
+
+	stream := StreamServerInterceptor(calls)
+	n := calls.N()
+	stream(nil, &fakeStream{ctx: ctx}, &grpc.StreamServerInfo{FullMethod: "/pkg.S/Watch"},
+		func(srv interface{}, ss grpc.ServerStream) error { return nil })
+	if calls.N() != n+1 {
+		t.Error("Expected the stream to be counted")
+	}
+}
+
+type fakeStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *fakeStream) Context() context.Context { return s.ctx }
@@ -0,0 +1,496 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: pmcpb/pmc.proto
+
+package pmcpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type IncrementRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Flow          []byte                 `protobuf:"bytes,1,opt,name=flow,proto3" json:"flow,omitempty"`
+	N             uint64                 `protobuf:"varint,2,opt,name=n,proto3" json:"n,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementRequest) Reset() {
+	*x = IncrementRequest{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementRequest) ProtoMessage() {}
+
+func (x *IncrementRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementRequest.ProtoReflect.Descriptor instead.
+func (*IncrementRequest) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *IncrementRequest) GetFlow() []byte {
+	if x != nil {
+		return x.Flow
+	}
+	return nil
+}
+
+func (x *IncrementRequest) GetN() uint64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+type IncrementBatchRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Flows         [][]byte               `protobuf:"bytes,1,rep,name=flows,proto3" json:"flows,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementBatchRequest) Reset() {
+	*x = IncrementBatchRequest{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementBatchRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementBatchRequest) ProtoMessage() {}
+
+func (x *IncrementBatchRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementBatchRequest.ProtoReflect.Descriptor instead.
+func (*IncrementBatchRequest) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *IncrementBatchRequest) GetFlows() [][]byte {
+	if x != nil {
+		return x.Flows
+	}
+	return nil
+}
+
+type IncrementResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *IncrementResponse) Reset() {
+	*x = IncrementResponse{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *IncrementResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*IncrementResponse) ProtoMessage() {}
+
+func (x *IncrementResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use IncrementResponse.ProtoReflect.Descriptor instead.
+func (*IncrementResponse) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{2}
+}
+
+type EstimateRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Flow          []byte                 `protobuf:"bytes,1,opt,name=flow,proto3" json:"flow,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EstimateRequest) Reset() {
+	*x = EstimateRequest{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EstimateRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EstimateRequest) ProtoMessage() {}
+
+func (x *EstimateRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EstimateRequest.ProtoReflect.Descriptor instead.
+func (*EstimateRequest) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *EstimateRequest) GetFlow() []byte {
+	if x != nil {
+		return x.Flow
+	}
+	return nil
+}
+
+type EstimateResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Estimate      float64                `protobuf:"fixed64,1,opt,name=estimate,proto3" json:"estimate,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EstimateResponse) Reset() {
+	*x = EstimateResponse{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EstimateResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EstimateResponse) ProtoMessage() {}
+
+func (x *EstimateResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EstimateResponse.ProtoReflect.Descriptor instead.
+func (*EstimateResponse) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *EstimateResponse) GetEstimate() float64 {
+	if x != nil {
+		return x.Estimate
+	}
+	return 0
+}
+
+type MergeRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sketch        []byte                 `protobuf:"bytes,1,opt,name=sketch,proto3" json:"sketch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MergeRequest) Reset() {
+	*x = MergeRequest{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MergeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeRequest) ProtoMessage() {}
+
+func (x *MergeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeRequest.ProtoReflect.Descriptor instead.
+func (*MergeRequest) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *MergeRequest) GetSketch() []byte {
+	if x != nil {
+		return x.Sketch
+	}
+	return nil
+}
+
+type MergeResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MergeResponse) Reset() {
+	*x = MergeResponse{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MergeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MergeResponse) ProtoMessage() {}
+
+func (x *MergeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MergeResponse.ProtoReflect.Descriptor instead.
+func (*MergeResponse) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{6}
+}
+
+type SnapshotRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotRequest) Reset() {
+	*x = SnapshotRequest{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotRequest) ProtoMessage() {}
+
+func (x *SnapshotRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotRequest.ProtoReflect.Descriptor instead.
+func (*SnapshotRequest) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{7}
+}
+
+type SnapshotResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Sketch        []byte                 `protobuf:"bytes,1,opt,name=sketch,proto3" json:"sketch,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SnapshotResponse) Reset() {
+	*x = SnapshotResponse{}
+	mi := &file_pmcpb_pmc_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SnapshotResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SnapshotResponse) ProtoMessage() {}
+
+func (x *SnapshotResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_pmcpb_pmc_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SnapshotResponse.ProtoReflect.Descriptor instead.
+func (*SnapshotResponse) Descriptor() ([]byte, []int) {
+	return file_pmcpb_pmc_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *SnapshotResponse) GetSketch() []byte {
+	if x != nil {
+		return x.Sketch
+	}
+	return nil
+}
+
+var File_pmcpb_pmc_proto protoreflect.FileDescriptor
+
+const file_pmcpb_pmc_proto_rawDesc = "" +
+	"\n" +
+	"\x0fpmcpb/pmc.proto\x12\x06pmc.v1\"4\n" +
+	"\x10IncrementRequest\x12\x12\n" +
+	"\x04flow\x18\x01 \x01(\fR\x04flow\x12\f\n" +
+	"\x01n\x18\x02 \x01(\x04R\x01n\"-\n" +
+	"\x15IncrementBatchRequest\x12\x14\n" +
+	"\x05flows\x18\x01 \x03(\fR\x05flows\"\x13\n" +
+	"\x11IncrementResponse\"%\n" +
+	"\x0fEstimateRequest\x12\x12\n" +
+	"\x04flow\x18\x01 \x01(\fR\x04flow\".\n" +
+	"\x10EstimateResponse\x12\x1a\n" +
+	"\bestimate\x18\x01 \x01(\x01R\bestimate\"&\n" +
+	"\fMergeRequest\x12\x16\n" +
+	"\x06sketch\x18\x01 \x01(\fR\x06sketch\"\x0f\n" +
+	"\rMergeResponse\"\x11\n" +
+	"\x0fSnapshotRequest\"*\n" +
+	"\x10SnapshotResponse\x12\x16\n" +
+	"\x06sketch\x18\x01 \x01(\fR\x06sketch2\xca\x02\n" +
+	"\x06Sketch\x12@\n" +
+	"\tIncrement\x12\x18.pmc.v1.IncrementRequest\x1a\x19.pmc.v1.IncrementResponse\x12J\n" +
+	"\x0eIncrementBatch\x12\x1d.pmc.v1.IncrementBatchRequest\x1a\x19.pmc.v1.IncrementResponse\x12=\n" +
+	"\bEstimate\x12\x17.pmc.v1.EstimateRequest\x1a\x18.pmc.v1.EstimateResponse\x124\n" +
+	"\x05Merge\x12\x14.pmc.v1.MergeRequest\x1a\x15.pmc.v1.MergeResponse\x12=\n" +
+	"\bSnapshot\x12\x17.pmc.v1.SnapshotRequest\x1a\x18.pmc.v1.SnapshotResponseB(Z&github.com/seiflotfy/pmc/pmcgrpc/pmcpbb\x06proto3"
+
+var (
+	file_pmcpb_pmc_proto_rawDescOnce sync.Once
+	file_pmcpb_pmc_proto_rawDescData []byte
+)
+
+func file_pmcpb_pmc_proto_rawDescGZIP() []byte {
+	file_pmcpb_pmc_proto_rawDescOnce.Do(func() {
+		file_pmcpb_pmc_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_pmcpb_pmc_proto_rawDesc), len(file_pmcpb_pmc_proto_rawDesc)))
+	})
+	return file_pmcpb_pmc_proto_rawDescData
+}
+
+var file_pmcpb_pmc_proto_msgTypes = make([]protoimpl.MessageInfo, 9)
+var file_pmcpb_pmc_proto_goTypes = []any{
+	(*IncrementRequest)(nil),      // 0: pmc.v1.IncrementRequest
+	(*IncrementBatchRequest)(nil), // 1: pmc.v1.IncrementBatchRequest
+	(*IncrementResponse)(nil),     // 2: pmc.v1.IncrementResponse
+	(*EstimateRequest)(nil),       // 3: pmc.v1.EstimateRequest
+	(*EstimateResponse)(nil),      // 4: pmc.v1.EstimateResponse
+	(*MergeRequest)(nil),          // 5: pmc.v1.MergeRequest
+	(*MergeResponse)(nil),         // 6: pmc.v1.MergeResponse
+	(*SnapshotRequest)(nil),       // 7: pmc.v1.SnapshotRequest
+	(*SnapshotResponse)(nil),      // 8: pmc.v1.SnapshotResponse
+}
+var file_pmcpb_pmc_proto_depIdxs = []int32{
+	0, // 0: pmc.v1.Sketch.Increment:input_type -> pmc.v1.IncrementRequest
+	1, // 1: pmc.v1.Sketch.IncrementBatch:input_type -> pmc.v1.IncrementBatchRequest
+	3, // 2: pmc.v1.Sketch.Estimate:input_type -> pmc.v1.EstimateRequest
+	5, // 3: pmc.v1.Sketch.Merge:input_type -> pmc.v1.MergeRequest
+	7, // 4: pmc.v1.Sketch.Snapshot:input_type -> pmc.v1.SnapshotRequest
+	2, // 5: pmc.v1.Sketch.Increment:output_type -> pmc.v1.IncrementResponse
+	2, // 6: pmc.v1.Sketch.IncrementBatch:output_type -> pmc.v1.IncrementResponse
+	4, // 7: pmc.v1.Sketch.Estimate:output_type -> pmc.v1.EstimateResponse
+	6, // 8: pmc.v1.Sketch.Merge:output_type -> pmc.v1.MergeResponse
+	8, // 9: pmc.v1.Sketch.Snapshot:output_type -> pmc.v1.SnapshotResponse
+	5, // [5:10] is the sub-list for method output_type
+	0, // [0:5] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_pmcpb_pmc_proto_init() }
+func file_pmcpb_pmc_proto_init() {
+	if File_pmcpb_pmc_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_pmcpb_pmc_proto_rawDesc), len(file_pmcpb_pmc_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   9,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_pmcpb_pmc_proto_goTypes,
+		DependencyIndexes: file_pmcpb_pmc_proto_depIdxs,
+		MessageInfos:      file_pmcpb_pmc_proto_msgTypes,
+	}.Build()
+	File_pmcpb_pmc_proto = out.File
+	file_pmcpb_pmc_proto_goTypes = nil
+	file_pmcpb_pmc_proto_depIdxs = nil
+}
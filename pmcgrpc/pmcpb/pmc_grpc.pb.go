@@ -0,0 +1,287 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.6.2
+// - protoc             (unknown)
+// source: pmcpb/pmc.proto
+
+package pmcpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Sketch_Increment_FullMethodName      = "/pmc.v1.Sketch/Increment"
+	Sketch_IncrementBatch_FullMethodName = "/pmc.v1.Sketch/IncrementBatch"
+	Sketch_Estimate_FullMethodName       = "/pmc.v1.Sketch/Estimate"
+	Sketch_Merge_FullMethodName          = "/pmc.v1.Sketch/Merge"
+	Sketch_Snapshot_FullMethodName       = "/pmc.v1.Sketch/Snapshot"
+)
+
+// SketchClient is the client API for Sketch service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Sketch accounts flows into a central PMC sketch.
+type SketchClient interface {
+	// Increment accounts n additions, 1 if unset, to a flow.
+	Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	// IncrementBatch accounts one addition to each of the flows.
+	IncrementBatch(ctx context.Context, in *IncrementBatchRequest, opts ...grpc.CallOption) (*IncrementResponse, error)
+	// Estimate returns the estimated count of a flow.
+	Estimate(ctx context.Context, in *EstimateRequest, opts ...grpc.CallOption) (*EstimateResponse, error)
+	// Merge merges a binary encoded sketch into the served one.
+	Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*MergeResponse, error)
+	// Snapshot returns the binary encoding of the served sketch.
+	Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error)
+}
+
+type sketchClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewSketchClient(cc grpc.ClientConnInterface) SketchClient {
+	return &sketchClient{cc}
+}
+
+func (c *sketchClient) Increment(ctx context.Context, in *IncrementRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, Sketch_Increment_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sketchClient) IncrementBatch(ctx context.Context, in *IncrementBatchRequest, opts ...grpc.CallOption) (*IncrementResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(IncrementResponse)
+	err := c.cc.Invoke(ctx, Sketch_IncrementBatch_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sketchClient) Estimate(ctx context.Context, in *EstimateRequest, opts ...grpc.CallOption) (*EstimateResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(EstimateResponse)
+	err := c.cc.Invoke(ctx, Sketch_Estimate_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sketchClient) Merge(ctx context.Context, in *MergeRequest, opts ...grpc.CallOption) (*MergeResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(MergeResponse)
+	err := c.cc.Invoke(ctx, Sketch_Merge_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *sketchClient) Snapshot(ctx context.Context, in *SnapshotRequest, opts ...grpc.CallOption) (*SnapshotResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(SnapshotResponse)
+	err := c.cc.Invoke(ctx, Sketch_Snapshot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// SketchServer is the server API for Sketch service.
+// All implementations must embed UnimplementedSketchServer
+// for forward compatibility.
+//
+// Sketch accounts flows into a central PMC sketch.
+type SketchServer interface {
+	// Increment accounts n additions, 1 if unset, to a flow.
+	Increment(context.Context, *IncrementRequest) (*IncrementResponse, error)
+	// IncrementBatch accounts one addition to each of the flows.
+	IncrementBatch(context.Context, *IncrementBatchRequest) (*IncrementResponse, error)
+	// Estimate returns the estimated count of a flow.
+	Estimate(context.Context, *EstimateRequest) (*EstimateResponse, error)
+	// Merge merges a binary encoded sketch into the served one.
+	Merge(context.Context, *MergeRequest) (*MergeResponse, error)
+	// Snapshot returns the binary encoding of the served sketch.
+	Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error)
+	mustEmbedUnimplementedSketchServer()
+}
+
+// UnimplementedSketchServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedSketchServer struct{}
+
+func (UnimplementedSketchServer) Increment(context.Context, *IncrementRequest) (*IncrementResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Increment not implemented")
+}
+func (UnimplementedSketchServer) IncrementBatch(context.Context, *IncrementBatchRequest) (*IncrementResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method IncrementBatch not implemented")
+}
+func (UnimplementedSketchServer) Estimate(context.Context, *EstimateRequest) (*EstimateResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Estimate not implemented")
+}
+func (UnimplementedSketchServer) Merge(context.Context, *MergeRequest) (*MergeResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Merge not implemented")
+}
+func (UnimplementedSketchServer) Snapshot(context.Context, *SnapshotRequest) (*SnapshotResponse, error) {
+	return nil, status.Error(codes.Unimplemented, "method Snapshot not implemented")
+}
+func (UnimplementedSketchServer) mustEmbedUnimplementedSketchServer() {}
+func (UnimplementedSketchServer) testEmbeddedByValue()                {}
+
+// UnsafeSketchServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to SketchServer will
+// result in compilation errors.
+type UnsafeSketchServer interface {
+	mustEmbedUnimplementedSketchServer()
+}
+
+func RegisterSketchServer(s grpc.ServiceRegistrar, srv SketchServer) {
+	// If the following call panics, it indicates UnimplementedSketchServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Sketch_ServiceDesc, srv)
+}
+
+func _Sketch_Increment_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SketchServer).Increment(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sketch_Increment_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SketchServer).Increment(ctx, req.(*IncrementRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sketch_IncrementBatch_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(IncrementBatchRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SketchServer).IncrementBatch(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sketch_IncrementBatch_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SketchServer).IncrementBatch(ctx, req.(*IncrementBatchRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sketch_Estimate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EstimateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SketchServer).Estimate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sketch_Estimate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SketchServer).Estimate(ctx, req.(*EstimateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sketch_Merge_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MergeRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SketchServer).Merge(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sketch_Merge_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SketchServer).Merge(ctx, req.(*MergeRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Sketch_Snapshot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SnapshotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(SketchServer).Snapshot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Sketch_Snapshot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(SketchServer).Snapshot(ctx, req.(*SnapshotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+// Sketch_ServiceDesc is the grpc.ServiceDesc for Sketch service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Sketch_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "pmc.v1.Sketch",
+	HandlerType: (*SketchServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Increment",
+			Handler:    _Sketch_Increment_Handler,
+		},
+		{
+			MethodName: "IncrementBatch",
+			Handler:    _Sketch_IncrementBatch_Handler,
+		},
+		{
+			MethodName: "Estimate",
+			Handler:    _Sketch_Estimate_Handler,
+		},
+		{
+			MethodName: "Merge",
+			Handler:    _Sketch_Merge_Handler,
+		},
+		{
+			MethodName: "Snapshot",
+			Handler:    _Sketch_Snapshot_Handler,
+		},
+	},
+	Streams:  []grpc.StreamDesc{},
+	Metadata: "pmcpb/pmc.proto",
+}
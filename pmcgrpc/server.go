@@ -0,0 +1,99 @@
+/*
+Package pmcgrpc serves a PMC sketch over gRPC, so that several collectors can
+push their flows into a central aggregator. The service is defined in
+pmcpb/pmc.proto; run go generate after changing it.
+*/
+package pmcgrpc
+
+//go:generate buf generate
+
+import (
+	"context"
+	"errors"
+
+	"github.com/seiflotfy/pmc"
+	"github.com/seiflotfy/pmc/pmcgrpc/pmcpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+/*
+Server implements pmcpb.SketchServer on top of a sketch. Requests are served
+concurrently, so the sketch should be created WithThreadSafety.
+*/
+type Server struct {
+	pmcpb.UnimplementedSketchServer
+	sketch *pmc.Sketch
+}
+
+/*
+NewServer returns a Server accounting into sketch.
+*/
+func NewServer(sketch *pmc.Sketch) *Server {
+	return &Server{sketch: sketch}
+}
+
+/*
+Register registers the server on s.
+*/
+func (srv *Server) Register(s grpc.ServiceRegistrar) {
+	pmcpb.RegisterSketchServer(s, srv)
+}
+
+/*
+Increment implements pmcpb.SketchServer.
+*/
+func (srv *Server) Increment(ctx context.Context, req *pmcpb.IncrementRequest) (*pmcpb.IncrementResponse, error) {
+	n := req.GetN()
+	if n == 0 {
+		n = 1
+	}
+	srv.sketch.Add(req.GetFlow(), n)
+	return &pmcpb.IncrementResponse{}, nil
+}
+
+/*
+IncrementBatch implements pmcpb.SketchServer.
+*/
+func (srv *Server) IncrementBatch(ctx context.Context, req *pmcpb.IncrementBatchRequest) (*pmcpb.IncrementResponse, error) {
+	srv.sketch.IncrementBatch(req.GetFlows())
+	return &pmcpb.IncrementResponse{}, nil
+}
+
+/*
+Estimate implements pmcpb.SketchServer.
+*/
+func (srv *Server) Estimate(ctx context.Context, req *pmcpb.EstimateRequest) (*pmcpb.EstimateResponse, error) {
+	return &pmcpb.EstimateResponse{Estimate: srv.sketch.GetEstimate(req.GetFlow())}, nil
+}
+
+/*
+Merge implements pmcpb.SketchServer.
+*/
+func (srv *Server) Merge(ctx context.Context, req *pmcpb.MergeRequest) (*pmcpb.MergeResponse, error) {
+	// The sketch is decoded with the options of the server, which an encoded
+	// sketch only carries the fingerprints of.
+	other := srv.sketch.NewLike()
+	if err := other.UnmarshalBinary(req.GetSketch()); err != nil {
+		if errors.Is(err, pmc.ErrIncompatibleParams) {
+			return nil, status.Error(codes.FailedPrecondition, err.Error())
+		}
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := srv.sketch.Merge(other); err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+	return &pmcpb.MergeResponse{}, nil
+}
+
+/*
+Snapshot implements pmcpb.SketchServer.
+*/
+func (srv *Server) Snapshot(ctx context.Context, req *pmcpb.SnapshotRequest) (*pmcpb.SnapshotResponse, error) {
+	data, err := srv.sketch.MarshalBinary()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pmcpb.SnapshotResponse{Sketch: data}, nil
+}
@@ -0,0 +1,105 @@
+package pmcgrpc
+
+import (
+	"context"
+	"errors"
+	"math"
+	"net"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serve serves sketch until the end of the test, and returns a client of
+// it.
+func serve(t *testing.T, sketch *pmc.Sketch) *Client {
+	lis := bufconn.Listen(1 << 20)
+	s := grpc.NewServer()
+	NewServer(sketch).Register(s)
+	go s.Serve(lis)
+	t.Cleanup(s.Stop)
+
+	cc, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return NewClient(cc)
+}
+
+func TestServer(t *testing.T) {
+	sketch, _ := pmc.New(1<<20, 256, 32, pmc.WithThreadSafety())
+	c := serve(t, sketch)
+	ctx := context.Background()
+
+	if err := c.Add(ctx, []byte("a"), 5000); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.IncrementBatch(ctx, [][]byte{[]byte("b"), []byte("c")}); err != nil {
+		t.Fatal(err)
+	}
+	if sketch.N() != 5002 {
+		t.Error("Expected N() == 5002, got", sketch.N())
+	}
+	est, err := c.GetEstimate(ctx, []byte("a"))
+	if fErr := math.Abs(100 * (1 - est/5000)); err != nil || fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 5000, got %f, %v", est, err)
+	}
+
+	snap, err := c.Snapshot(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Merge(ctx, snap); err != nil {
+		t.Fatal(err)
+	}
+	if sketch.N() != 2*5002 {
+		t.Error("Expected N() == 10004 after merging the snapshot, got", sketch.N())
+	}
+
+	other, _ := pmc.New(1024, 8, 8)
+	if err := c.Merge(ctx, other); err == nil {
+		t.Error("Expected error merging an incompatible sketch, got nil")
+	}
+}
+
+func TestServerMergeHashers(t *testing.T) {
+	sketch, _ := pmc.New(1<<16, 64, 32, pmc.WithThreadSafety(), pmc.WithHash(pmc.TwoLevelHasher{}), pmc.WithHashSeed(1))
+	c := serve(t, sketch)
+	ctx := context.Background()
+
+	peer, _ := pmc.New(1<<16, 64, 32, pmc.WithHash(pmc.TwoLevelHasher{}), pmc.WithHashSeed(1))
+	peer.Add([]byte("a"), 5000)
+	if err := c.Merge(ctx, peer); err != nil {
+		t.Fatal(err)
+	}
+	if est, want := sketch.GetEstimate([]byte("a")), peer.GetEstimate([]byte("a")); est != want {
+		t.Errorf("Expected merged estimate %f, got %f", want, est)
+	}
+
+	snap := sketch.NewLike()
+	if err := c.SnapshotInto(ctx, snap); err != nil {
+		t.Fatal(err)
+	}
+	if est := snap.GetEstimate([]byte("a")); est != sketch.GetEstimate([]byte("a")) {
+		t.Errorf("Expected the snapshot to estimate as the server, got %f", est)
+	}
+	if _, err := c.Snapshot(ctx); !errors.Is(err, pmc.ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams decoding into a default sketch, got", err)
+	}
+
+	other, _ := pmc.New(1<<16, 64, 32, pmc.WithHash(pmc.XXHasher{}), pmc.WithHashSeed(1))
+	other.Add([]byte("b"), 5000)
+	if err := c.Merge(ctx, other); status.Code(err) != codes.FailedPrecondition {
+		t.Error("Expected FailedPrecondition merging another Hasher, got", err)
+	}
+}
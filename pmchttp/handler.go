@@ -0,0 +1,131 @@
+/*
+Package pmchttp serves a PMC sketch over HTTP, for ingestion and queries
+from tools like curl.
+
+	POST /increment?flow=key[&n=count]  account count (1 to 2^32, 1 by default) to the flow
+	GET  /estimate?flow=key             estimated count of the flow, as JSON
+	GET  /stats                         parameters, additions and fill rate, as JSON
+	GET  /snapshot                      binary encoding of the sketch
+
+Counter provides a middleware counting the requests of a web service per
+client.
+*/
+package pmchttp
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/seiflotfy/pmc"
+)
+
+// maxN bounds the count of an increment, so that requests can't wrap the
+// number of additions of the sketch.
+const maxN = 1 << 32
+
+type handler struct {
+	sketch *pmc.Sketch
+	mux    *http.ServeMux
+}
+
+/*
+New returns an http.Handler serving sketch. Requests are served
+concurrently, so the sketch should be created WithThreadSafety. A snapshot
+is streamed under the lock of the sketch, holding up the additions until the
+client has read it.
+*/
+func New(sketch *pmc.Sketch) http.Handler {
+	h := &handler{sketch: sketch, mux: http.NewServeMux()}
+	h.mux.HandleFunc("/increment", h.increment)
+	h.mux.HandleFunc("/estimate", h.estimate)
+	h.mux.HandleFunc("/stats", h.stats)
+	h.mux.HandleFunc("/snapshot", h.snapshot)
+	return h
+}
+
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+func allow(w http.ResponseWriter, r *http.Request, method string) bool {
+	if r.Method != method {
+		w.Header().Set("Allow", method)
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return false
+	}
+	return true
+}
+
+func flow(w http.ResponseWriter, r *http.Request) (string, bool) {
+	f := r.URL.Query().Get("flow")
+	if f == "" {
+		http.Error(w, "Missing flow parameter", http.StatusBadRequest)
+		return "", false
+	}
+	return f, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func (h *handler) increment(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodPost) {
+		return
+	}
+	f, ok := flow(w, r)
+	if !ok {
+		return
+	}
+	n := uint64(1)
+	if s := r.URL.Query().Get("n"); s != "" {
+		var err error
+		if n, err = strconv.ParseUint(s, 10, 64); err != nil || n == 0 || n > maxN {
+			http.Error(w, fmt.Sprintf("Expected 0 < n <= %d, got %s", uint64(maxN), s), http.StatusBadRequest)
+			return
+		}
+	}
+	if n > math.MaxUint64-h.sketch.N() {
+		http.Error(w, "Expected fewer additions than 2^64", http.StatusRequestEntityTooLarge)
+		return
+	}
+	h.sketch.Add([]byte(f), n)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) estimate(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	f, ok := flow(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, map[string]interface{}{
+		"flow":     f,
+		"estimate": h.sketch.GetEstimate([]byte(f)),
+	})
+}
+
+func (h *handler) stats(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte(h.sketch.Expvar().String()))
+}
+
+func (h *handler) snapshot(w http.ResponseWriter, r *http.Request) {
+	if !allow(w, r, http.MethodGet) {
+		return
+	}
+	// The sketch is streamed, so an error past the headers can only cut the
+	// response short, which the CRC of the encoding detects.
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="sketch.pmc"`)
+	h.sketch.WriteTo(w)
+}
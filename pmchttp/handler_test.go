@@ -0,0 +1,92 @@
+package pmchttp
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestHandler(t *testing.T) {
+	sketch, _ := pmc.New(1<<20, 256, 32, pmc.WithThreadSafety())
+	srv := httptest.NewServer(New(sketch))
+	defer srv.Close()
+
+	resp, err := http.Post(srv.URL+"/increment?flow=a&n=5000", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		t.Fatal("Expected status 204, got", resp.StatusCode)
+	}
+	if sketch.N() != 5000 {
+		t.Error("Expected N() == 5000, got", sketch.N())
+	}
+
+	for _, url := range []string{"/increment?flow=a&n=0", "/increment?flow=a&n=4294967297", "/increment"} {
+		resp, _ := http.Post(srv.URL+url, "", nil)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("Expected status 400 for %s, got %d", url, resp.StatusCode)
+		}
+	}
+	resp, _ = http.Get(srv.URL + "/increment?flow=a")
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Error("Expected status 405, got", resp.StatusCode)
+	}
+
+	resp, _ = http.Get(srv.URL + "/estimate?flow=a")
+	var est struct {
+		Flow     string
+		Estimate float64
+	}
+	json.NewDecoder(resp.Body).Decode(&est)
+	resp.Body.Close()
+	if fErr := math.Abs(100 * (1 - est.Estimate/5000)); est.Flow != "a" || fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 5000 for a, got %v", est)
+	}
+
+	resp, _ = http.Get(srv.URL + "/stats")
+	var stats struct{ N uint64 }
+	json.NewDecoder(resp.Body).Decode(&stats)
+	resp.Body.Close()
+	if stats.N != 5000 {
+		t.Error("Expected stats n == 5000, got", stats.N)
+	}
+
+	resp, _ = http.Get(srv.URL + "/snapshot")
+	data, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	var restored pmc.Sketch
+	if err := restored.UnmarshalBinary(data); err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetEstimate([]byte("a")) != sketch.GetEstimate([]byte("a")) {
+		t.Error("Expected the snapshot to estimate like the sketch")
+	}
+}
+
+func TestHandlerWrap(t *testing.T) {
+	sketch, _ := pmc.New(1<<10, 8, 8, pmc.WithThreadSafety())
+	srv := httptest.NewServer(New(sketch))
+	defer srv.Close()
+
+	for i := 0; i < 4; i++ {
+		sketch.Add([]byte("a"), math.MaxUint64/4)
+	}
+	n := sketch.N()
+	resp, _ := http.Post(srv.URL+"/increment?flow=a&n=4294967296", "", nil)
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusRequestEntityTooLarge {
+		t.Error("Expected status 413 for an increment wrapping N(), got", resp.StatusCode)
+	}
+	if sketch.N() != n {
+		t.Error("Expected N() ==", n, "got", sketch.N())
+	}
+}
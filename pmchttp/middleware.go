@@ -0,0 +1,86 @@
+package pmchttp
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+KeyFunc returns the flow key of a request, or nil for requests not to be
+counted.
+*/
+type KeyFunc func(r *http.Request) []byte
+
+/*
+RemoteIP keys requests by the IP address of the client connection.
+*/
+func RemoteIP(r *http.Request) []byte {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+		return ip
+	}
+	return []byte(host)
+}
+
+/*
+Header keys requests by the value of the header name, e.g. X-Forwarded-For
+behind a trusted proxy, or an API key. Requests without it are not counted.
+*/
+func Header(name string) KeyFunc {
+	return func(r *http.Request) []byte {
+		if v := r.Header.Get(name); v != "" {
+			return []byte(v)
+		}
+		return nil
+	}
+}
+
+/*
+Counter counts requests per client in a sketch, with bounded memory however
+many clients there are.
+*/
+type Counter struct {
+	sketch *pmc.Sketch
+	key    KeyFunc
+}
+
+/*
+NewCounter returns a Counter counting requests in sketch by key. Requests
+are served concurrently, so the sketch should be created WithThreadSafety.
+*/
+func NewCounter(sketch *pmc.Sketch, key KeyFunc) *Counter {
+	return &Counter{sketch: sketch, key: key}
+}
+
+/*
+Middleware returns a handler counting every request before passing it on to
+next.
+*/
+func (c *Counter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if k := c.key(r); k != nil {
+			c.sketch.Increment(k)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+/*
+Estimate returns the estimated number of requests of the client of r,
+e.g. to throttle it from a handler.
+*/
+func (c *Counter) Estimate(r *http.Request) float64 {
+	k := c.key(r)
+	if k == nil {
+		return 0
+	}
+	return c.sketch.GetEstimate(k)
+}
@@ -0,0 +1,44 @@
+package pmchttp
+
+import (
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestCounter(t *testing.T) {
+	sketch, _ := pmc.New(1<<20, 256, 32, pmc.WithThreadSafety())
+	c := NewCounter(sketch, RemoteIP)
+	var last float64
+	h := c.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		last = c.Estimate(r)
+	}))
+
+	for i := 0; i < 3000; i++ {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	if fErr := math.Abs(100 * (1 - last/3000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 3000, got %f", last)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.RemoteAddr = "[2001:db8::1]:1234"
+	if est := c.Estimate(r); est > 100 {
+		t.Error("Expected a small estimate for another client, got", est)
+	}
+
+	byKey := NewCounter(sketch, Header("X-Api-Key"))
+	h = byKey.Middleware(http.NotFoundHandler())
+	n := sketch.N()
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if sketch.N() != n {
+		t.Error("Expected requests without the header not to be counted")
+	}
+	if est := byKey.Estimate(httptest.NewRequest("GET", "/", nil)); est != 0 {
+		t.Error("Expected estimate 0 without the header, got", est)
+	}
+}
@@ -0,0 +1,163 @@
+/*
+Package pmckafka applies keys consumed from a Kafka topic to a PMC sketch.
+
+The pipeline does not depend on a Kafka client: it consumes through the
+Consumer interface, which the readers of the common clients satisfy with a
+few lines of glue. With github.com/segmentio/kafka-go for instance:
+
+	type reader struct{ r *kafka.Reader }
+
+	func (r reader) Fetch(ctx context.Context) (pmckafka.Message, error) {
+		m, err := r.r.FetchMessage(ctx)
+		return pmckafka.Message{Key: m.Key, Value: m.Value, Raw: m}, err
+	}
+
+	func (r reader) Commit(ctx context.Context, msgs ...pmckafka.Message) error {
+		raw := make([]kafka.Message, len(msgs))
+		for i, m := range msgs {
+			raw[i] = m.Raw.(kafka.Message)
+		}
+		return r.r.CommitMessages(ctx, raw...)
+	}
+*/
+package pmckafka
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Message is a consumed Kafka message. Raw holds the message of the client,
+for the Consumer to commit it.
+*/
+type Message struct {
+	Key   []byte
+	Value []byte
+	Raw   interface{}
+}
+
+/*
+Consumer fetches messages from a topic and commits their offsets.
+*/
+type Consumer interface {
+	Fetch(ctx context.Context) (Message, error)
+	Commit(ctx context.Context, msgs ...Message) error
+}
+
+/*
+DecodeFunc returns the flow key and weight of a message.
+*/
+type DecodeFunc func(msg Message) (flow []byte, weight uint64, err error)
+
+/*
+KeyWeight is the default DecodeFunc, keying flows by the message key, which
+is weighed by the decimal value of the message, or 1 if it is empty.
+*/
+func KeyWeight(msg Message) ([]byte, uint64, error) {
+	if len(msg.Value) == 0 {
+		return msg.Key, 1, nil
+	}
+	weight, err := strconv.ParseUint(string(msg.Value), 10, 64)
+	return msg.Key, weight, err
+}
+
+/*
+Pipeline consumes messages in batches, applies them to a sketch and then
+commits them, so every message is accounted at least once: a batch that
+fails to commit is consumed again on restart. The sketch is updated by
+Parallelism goroutines, so it should be created WithThreadSafety when
+Parallelism is more than 1.
+*/
+type Pipeline struct {
+	Sketch   *pmc.Sketch
+	Consumer Consumer
+
+	// Decode defaults to KeyWeight. Messages it fails on are skipped.
+	Decode DecodeFunc
+	// BatchSize is the number of messages applied before committing, 1000
+	// by default.
+	BatchSize int
+	// Parallelism is the number of goroutines applying a batch, 1 by
+	// default.
+	Parallelism int
+}
+
+/*
+Run consumes messages until ctx is done or the consumer fails. The pending
+batch is applied and committed on return.
+*/
+func (p *Pipeline) Run(ctx context.Context) error {
+	if p.Sketch == nil || p.Consumer == nil {
+		return errors.New("Expected a sketch and a consumer")
+	}
+	size := p.BatchSize
+	if size <= 0 {
+		size = 1000
+	}
+	batch := make([]Message, 0, size)
+	for {
+		msg, err := p.Consumer.Fetch(ctx)
+		if err != nil {
+			if cerr := p.flush(batch); cerr != nil {
+				return cerr
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+		batch = append(batch, msg)
+		if len(batch) == size {
+			if err := p.flush(batch); err != nil {
+				return err
+			}
+			batch = batch[:0]
+		}
+	}
+}
+
+// flush applies the batch and commits it, independently of the context of
+// Run, so that applied messages are committed even when stopping.
+func (p *Pipeline) flush(batch []Message) error {
+	if len(batch) == 0 {
+		return nil
+	}
+	p.apply(batch)
+	return p.Consumer.Commit(context.Background(), batch...)
+}
+
+func (p *Pipeline) apply(batch []Message) {
+	decode := p.Decode
+	if decode == nil {
+		decode = KeyWeight
+	}
+	workers := p.Parallelism
+	if workers <= 1 || len(batch) < 2*workers {
+		workers = 1
+	}
+	chunk := (len(batch) + workers - 1) / workers
+	var wg sync.WaitGroup
+	for lo := 0; lo < len(batch); lo += chunk {
+		hi := lo + chunk
+		if hi > len(batch) {
+			hi = len(batch)
+		}
+		wg.Add(1)
+		go func(msgs []Message) {
+			defer wg.Done()
+			for _, msg := range msgs {
+				flow, weight, err := decode(msg)
+				if err != nil || weight == 0 {
+					continue
+				}
+				p.Sketch.Add(flow, weight)
+			}
+		}(batch[lo:hi])
+	}
+	wg.Wait()
+}
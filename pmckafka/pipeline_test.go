@@ -0,0 +1,82 @@
+package pmckafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+type fakeConsumer struct {
+	msgs      []Message
+	committed int
+	failAt    int
+}
+
+func (c *fakeConsumer) Fetch(ctx context.Context) (Message, error) {
+	if err := ctx.Err(); err != nil {
+		return Message{}, err
+	}
+	if len(c.msgs) == 0 {
+		return Message{}, io.EOF
+	}
+	msg := c.msgs[0]
+	c.msgs = c.msgs[1:]
+	return msg, nil
+}
+
+func (c *fakeConsumer) Commit(ctx context.Context, msgs ...Message) error {
+	if c.failAt > 0 && c.committed+len(msgs) >= c.failAt {
+		return errors.New("commit failed")
+	}
+	c.committed += len(msgs)
+	return nil
+}
+
+func TestPipeline(t *testing.T) {
+	c := &fakeConsumer{}
+	for i := 0; i < 2500; i++ {
+		c.msgs = append(c.msgs, Message{Key: []byte("a"), Value: []byte("2")})
+	}
+	c.msgs = append(c.msgs, Message{Key: []byte("b")}, Message{Key: []byte("c"), Value: []byte("x")})
+
+	sketch, _ := pmc.New(1<<20, 256, 32, pmc.WithThreadSafety())
+	p := &Pipeline{Sketch: sketch, Consumer: c, Parallelism: 4}
+	if err := p.Run(context.Background()); err != io.EOF {
+		t.Error("Expected io.EOF, got", err)
+	}
+	if c.committed != 2502 {
+		t.Error("Expected all 2502 messages to be committed, got", c.committed)
+	}
+	if sketch.N() != 5001 {
+		t.Error("Expected N() == 5001, got", sketch.N())
+	}
+	est := sketch.GetEstimate([]byte("a"))
+	if fErr := math.Abs(100 * (1 - est/5000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 5000, got %f", est)
+	}
+}
+
+func TestPipelineCommitFailure(t *testing.T) {
+	c := &fakeConsumer{failAt: 15}
+	for i := 0; i < 30; i++ {
+		c.msgs = append(c.msgs, Message{Key: []byte("a")})
+	}
+	sketch, _ := pmc.New(1024, 8, 8)
+	p := &Pipeline{Sketch: sketch, Consumer: c, BatchSize: 10}
+	if err := p.Run(context.Background()); err == nil || err == io.EOF {
+		t.Error("Expected the commit error, got", err)
+	}
+	if c.committed != 10 || len(c.msgs) != 10 {
+		t.Errorf("Expected to stop after the first batch, committed %d", c.committed)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := (&Pipeline{Sketch: sketch, Consumer: c}).Run(ctx); err != context.Canceled {
+		t.Error("Expected context.Canceled, got", err)
+	}
+}
@@ -0,0 +1,183 @@
+/*
+Package pmcotel instruments PMC sketches with OpenTelemetry metrics and
+traces, keeping the dependencies on OpenTelemetry out of the core package.
+
+	s, err := pmcotel.Wrap(sketch, "flows")
+	defer s.Close()
+	s.Increment(flow)
+	s.IncrementBatch(ctx, flows)
+
+The wrapped sketch reports:
+
+	pmc.additions          counter of additions, whose rate is the ingestion rate
+	pmc.estimate.duration  histogram of the latency of GetEstimate, in seconds
+	pmc.fill_ratio         gauge of the fraction of the bits set
+
+and traces IncrementBatch and MergeAll as spans. All metrics carry the name
+of the sketch as the pmc.sketch attribute.
+*/
+package pmcotel
+
+import (
+	"context"
+	"time"
+
+	"github.com/seiflotfy/pmc"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// scope is the instrumentation scope of the meter and the tracer.
+const scope = "github.com/seiflotfy/pmc/pmcotel"
+
+type config struct {
+	meters  metric.MeterProvider
+	tracers trace.TracerProvider
+}
+
+/*
+Option configures Wrap.
+*/
+type Option func(*config)
+
+/*
+WithMeterProvider sets the MeterProvider, otel.GetMeterProvider() by default.
+*/
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(c *config) { c.meters = mp }
+}
+
+/*
+WithTracerProvider sets the TracerProvider, otel.GetTracerProvider() by
+default.
+*/
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(c *config) { c.tracers = tp }
+}
+
+/*
+Sketch is an instrumented pmc.Sketch. The methods it doesn't redefine are
+those of the sketch, and aren't instrumented.
+*/
+type Sketch struct {
+	*pmc.Sketch
+
+	attrs     metric.MeasurementOption
+	spanAttrs trace.SpanStartOption
+	additions metric.Int64Counter
+	latency   metric.Float64Histogram
+	fill      metric.Registration
+	tracer    trace.Tracer
+}
+
+/*
+Wrap returns an instrumented sketch reporting under name. Close must be
+called to stop reporting its fill ratio.
+*/
+func Wrap(sketch *pmc.Sketch, name string, opts ...Option) (*Sketch, error) {
+	c := config{meters: otel.GetMeterProvider(), tracers: otel.GetTracerProvider()}
+	for _, opt := range opts {
+		opt(&c)
+	}
+	meter := c.meters.Meter(scope)
+	attr := attribute.String("pmc.sketch", name)
+	s := &Sketch{
+		Sketch:    sketch,
+		attrs:     metric.WithAttributes(attr),
+		spanAttrs: trace.WithAttributes(attr),
+		tracer:    c.tracers.Tracer(scope),
+	}
+
+	var err error
+	s.additions, err = meter.Int64Counter("pmc.additions",
+		metric.WithDescription("Number of additions accounted in the sketch."),
+		metric.WithUnit("{addition}"))
+	if err != nil {
+		return nil, err
+	}
+	s.latency, err = meter.Float64Histogram("pmc.estimate.duration",
+		metric.WithDescription("Latency of the estimates of the sketch."),
+		metric.WithUnit("s"))
+	if err != nil {
+		return nil, err
+	}
+	fill, err := meter.Float64ObservableGauge("pmc.fill_ratio",
+		metric.WithDescription("Fraction of the sketch bits that are set."),
+		metric.WithUnit("1"))
+	if err != nil {
+		return nil, err
+	}
+	s.fill, err = meter.RegisterCallback(func(_ context.Context, o metric.Observer) error {
+		o.ObserveFloat64(fill, sketch.GetFillRate()/100, s.attrs)
+		return nil
+	}, fill)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+/*
+Close stops reporting the fill ratio of the sketch.
+*/
+func (s *Sketch) Close() error {
+	return s.fill.Unregister()
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (s *Sketch) Increment(flow []byte) {
+	s.Sketch.Increment(flow)
+	s.additions.Add(context.Background(), 1, s.attrs)
+}
+
+/*
+Add increments the count of the flow by weight, see pmc.Sketch.Add.
+*/
+func (s *Sketch) Add(flow []byte, weight uint64) {
+	s.Sketch.Add(flow, weight)
+	s.additions.Add(context.Background(), int64(weight), s.attrs)
+}
+
+/*
+IncrementBatch increments the count of each of the flows by 1 in a span
+child of ctx.
+*/
+func (s *Sketch) IncrementBatch(ctx context.Context, flows [][]byte) {
+	ctx, span := s.tracer.Start(ctx, "pmc.IncrementBatch", s.spanAttrs,
+		trace.WithAttributes(attribute.Int("pmc.batch_size", len(flows))))
+	defer span.End()
+	s.Sketch.IncrementBatch(flows)
+	s.additions.Add(ctx, int64(len(flows)), s.attrs)
+}
+
+/*
+MergeAll merges others into the sketch in a span child of ctx, see
+pmc.Sketch.MergeAll.
+*/
+func (s *Sketch) MergeAll(ctx context.Context, others ...*pmc.Sketch) error {
+	_, span := s.tracer.Start(ctx, "pmc.MergeAll", s.spanAttrs,
+		trace.WithAttributes(attribute.Int("pmc.sketches", len(others))))
+	defer span.End()
+	if err := s.Sketch.MergeAll(others...); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return err
+	}
+	return nil
+}
+
+/*
+GetEstimate returns the estimated count of a given flow, recording the
+latency of the estimate.
+*/
+func (s *Sketch) GetEstimate(flow []byte) float64 {
+	start := time.Now()
+	e := s.Sketch.GetEstimate(flow)
+	s.latency.Record(context.Background(), time.Since(start).Seconds(), s.attrs)
+	return e
+}
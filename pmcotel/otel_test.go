@@ -0,0 +1,72 @@
+package pmcotel
+
+import (
+	"context"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestWrap(t *testing.T) {
+	reader := sdkmetric.NewManualReader()
+	spans := tracetest.NewSpanRecorder()
+	sketch, _ := pmc.New(1<<16, 64, 32)
+	s, err := Wrap(sketch, "flows",
+		WithMeterProvider(sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))),
+		WithTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Increment([]byte("a"))
+	s.Add([]byte("a"), 10)
+	s.IncrementBatch(context.Background(), [][]byte{[]byte("b"), []byte("c")})
+	s.GetEstimate([]byte("a"))
+	other, _ := pmc.New(1<<15, 64, 32)
+	if err := s.MergeAll(context.Background(), other); err == nil {
+		t.Error("Expected error merging a different sketch, got nil")
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatal(err)
+	}
+	found := map[string]bool{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			found[m.Name] = true
+			switch data := m.Data.(type) {
+			case metricdata.Sum[int64]:
+				if v := data.DataPoints[0].Value; v != 13 {
+					t.Errorf("Expected 13 additions, got %d", v)
+				}
+			case metricdata.Gauge[float64]:
+				if v := data.DataPoints[0].Value; v != sketch.GetFillRate()/100 {
+					t.Errorf("Expected a fill ratio of %f, got %f", sketch.GetFillRate()/100, v)
+				}
+			case metricdata.Histogram[float64]:
+				if n := data.DataPoints[0].Count; n != 1 {
+					t.Errorf("Expected 1 estimate, got %d", n)
+				}
+			}
+		}
+	}
+	for _, name := range []string{"pmc.additions", "pmc.estimate.duration", "pmc.fill_ratio"} {
+		if !found[name] {
+			t.Error("Expected metric", name)
+		}
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 2 || ended[0].Name() != "pmc.IncrementBatch" || ended[1].Name() != "pmc.MergeAll" {
+		t.Fatal("Expected spans for the batch and the merge, got", len(ended))
+	}
+	if len(ended[1].Events()) != 1 {
+		t.Error("Expected the merge error to be recorded")
+	}
+}
@@ -0,0 +1,35 @@
+package pmcpcap
+
+import (
+	"context"
+
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Live feeds the packets captured on the Ethernet interface ifname into the
+sketch until ctx is done, see Feed. Capturing requires CAP_NET_RAW.
+*/
+func Live(ctx context.Context, sketch *pmc.Sketch, ifname string, key KeyFunc) (uint64, error) {
+	h, err := pcapgo.NewEthernetHandle(ifname)
+	if err != nil {
+		return 0, err
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			h.Close()
+		case <-done:
+		}
+	}()
+	n, err := Feed(sketch, h, layers.LayerTypeEthernet, key)
+	if ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+	h.Close()
+	return n, err
+}
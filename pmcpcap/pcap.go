@@ -0,0 +1,143 @@
+/*
+Package pmcpcap feeds packets read from pcap files or live interfaces into a
+PMC sketch, keyed by a configurable flow key.
+*/
+package pmcpcap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"os"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+KeyFunc extracts the flow key of a packet, or returns nil for packets not to
+be accounted.
+*/
+type KeyFunc func(packet gopacket.Packet) []byte
+
+/*
+FiveTuple keys packets by protocol, source and destination addresses and
+ports. Packets without a transport layer are keyed with zero ports.
+*/
+func FiveTuple(packet gopacket.Packet) []byte {
+	net := packet.NetworkLayer()
+	if net == nil {
+		return nil
+	}
+	src, dst := net.NetworkFlow().Endpoints()
+	key := make([]byte, 0, 1+2*16+2*2)
+	var proto byte
+	var sport, dport []byte
+	switch t := packet.TransportLayer().(type) {
+	case *layers.TCP:
+		proto, sport, dport = byte(layers.IPProtocolTCP), t.TransportFlow().Src().Raw(), t.TransportFlow().Dst().Raw()
+	case *layers.UDP:
+		proto, sport, dport = byte(layers.IPProtocolUDP), t.TransportFlow().Src().Raw(), t.TransportFlow().Dst().Raw()
+	default:
+		sport, dport = []byte{0, 0}, []byte{0, 0}
+	}
+	key = append(key, proto)
+	key = append(key, src.Raw()...)
+	key = append(key, dst.Raw()...)
+	key = append(key, sport...)
+	return append(key, dport...)
+}
+
+/*
+SrcIP keys packets by source address.
+*/
+func SrcIP(packet gopacket.Packet) []byte {
+	if net := packet.NetworkLayer(); net != nil {
+		return net.NetworkFlow().Src().Raw()
+	}
+	return nil
+}
+
+/*
+DstIP keys packets by destination address.
+*/
+func DstIP(packet gopacket.Packet) []byte {
+	if net := packet.NetworkLayer(); net != nil {
+		return net.NetworkFlow().Dst().Raw()
+	}
+	return nil
+}
+
+/*
+DstPort keys TCP and UDP packets by destination port.
+*/
+func DstPort(packet gopacket.Packet) []byte {
+	if t := packet.TransportLayer(); t != nil {
+		return t.TransportFlow().Dst().Raw()
+	}
+	return nil
+}
+
+/*
+Feed increments the sketch with the key of every packet read from src until
+io.EOF, and returns the number of packets accounted.
+*/
+func Feed(sketch *pmc.Sketch, src gopacket.PacketDataSource, decoder gopacket.Decoder, key KeyFunc) (uint64, error) {
+	ps := gopacket.NewPacketSource(src, decoder)
+	ps.DecodeOptions = gopacket.DecodeOptions{Lazy: true, NoCopy: true}
+	var n uint64
+	for {
+		packet, err := ps.NextPacket()
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		if k := key(packet); k != nil {
+			sketch.Increment(k)
+			n++
+		}
+	}
+}
+
+/*
+ReadFile feeds the packets of a pcap or pcapng file into the sketch, see
+Feed.
+*/
+func ReadFile(sketch *pmc.Sketch, path string, key KeyFunc) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	return Read(sketch, f, key)
+}
+
+// pcapngMagic is the block type of the section header starting pcapng files.
+const pcapngMagic = 0x0a0d0d0a
+
+/*
+Read feeds the packets of a pcap or pcapng stream into the sketch, see Feed.
+*/
+func Read(sketch *pmc.Sketch, r io.Reader, key KeyFunc) (uint64, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil {
+		return 0, err
+	}
+	if binary.LittleEndian.Uint32(magic) == pcapngMagic {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.DefaultNgReaderOptions)
+		if err != nil {
+			return 0, err
+		}
+		return Feed(sketch, ng, ng.LinkType(), key)
+	}
+	pr, err := pcapgo.NewReader(br)
+	if err != nil {
+		return 0, err
+	}
+	return Feed(sketch, pr, pr.LinkType(), key)
+}
@@ -0,0 +1,70 @@
+package pmcpcap
+
+import (
+	"bytes"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+	"github.com/seiflotfy/pmc"
+)
+
+func packet(t *testing.T, src string, dport layers.UDPPort) []byte {
+	eth := &layers.Ethernet{SrcMAC: net.HardwareAddr{0, 1, 2, 3, 4, 5},
+		DstMAC: net.HardwareAddr{0, 1, 2, 3, 4, 6}, EthernetType: layers.EthernetTypeIPv4}
+	ip := &layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP,
+		SrcIP: net.ParseIP(src), DstIP: net.ParseIP("10.0.0.1")}
+	udp := &layers.UDP{SrcPort: 1234, DstPort: dport}
+	udp.SetNetworkLayerForChecksum(ip)
+	buf := gopacket.NewSerializeBuffer()
+	opts := gopacket.SerializeOptions{FixLengths: true, ComputeChecksums: true}
+	if err := gopacket.SerializeLayers(buf, opts, eth, ip, udp, gopacket.Payload("x")); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestRead(t *testing.T) {
+	var file bytes.Buffer
+	w := pcapgo.NewWriter(&file)
+	w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+	write := func(data []byte, count int) {
+		for i := 0; i < count; i++ {
+			ci := gopacket.CaptureInfo{Timestamp: time.Unix(0, 0), CaptureLength: len(data), Length: len(data)}
+			if err := w.WritePacket(ci, data); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	write(packet(t, "192.168.0.1", 53), 3000)
+	write(packet(t, "192.168.0.2", 53), 10)
+	write(packet(t, "192.168.0.2", 80), 10)
+
+	sketch, _ := pmc.New(1<<20, 256, 32)
+	n, err := Read(sketch, bytes.NewReader(file.Bytes()), SrcIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3020 {
+		t.Error("Expected 3020 packets, got", n)
+	}
+	est := sketch.GetEstimate(net.ParseIP("192.168.0.1").To4())
+	if est < 2500 || est > 3500 {
+		t.Error("Expected estimate close to 3000 for 192.168.0.1, got", est)
+	}
+
+	ports, _ := pmc.New(1<<20, 256, 32)
+	Read(ports, bytes.NewReader(file.Bytes()), DstPort)
+	if est := ports.GetEstimate([]byte{0, 80}); est < 5 || est > 20 {
+		t.Error("Expected estimate close to 10 for port 80, got", est)
+	}
+
+	a := FiveTuple(gopacket.NewPacket(packet(t, "192.168.0.1", 53), layers.LayerTypeEthernet, gopacket.Default))
+	b := FiveTuple(gopacket.NewPacket(packet(t, "192.168.0.1", 80), layers.LayerTypeEthernet, gopacket.Default))
+	if len(a) != 13 || bytes.Equal(a, b) {
+		t.Errorf("Expected distinct 13 byte 5-tuples, got %x and %x", a, b)
+	}
+}
@@ -0,0 +1,94 @@
+/*
+Package pmcprom exposes the state of a PMC sketch as Prometheus metrics.
+*/
+package pmcprom
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Collector is a prometheus.Collector exposing the fill rate, the total
+increments and the memory footprint of a sketch, along with the estimated
+counts of registered flows. Metrics are read on every scrape, from the
+goroutine serving it, so the sketch should be created WithThreadSafety when
+it is incremented concurrently.
+*/
+type Collector struct {
+	sketch *pmc.Sketch
+
+	fillRate   *prometheus.Desc
+	increments *prometheus.Desc
+	memory     *prometheus.Desc
+	estimate   *prometheus.Desc
+
+	mu    sync.Mutex
+	flows map[string]struct{}
+}
+
+/*
+NewCollector returns a Collector for sketch, whose metric names are prefixed
+with namespace. Const labels distinguish several sketches.
+*/
+func NewCollector(sketch *pmc.Sketch, namespace string, labels prometheus.Labels) *Collector {
+	name := func(n string) string { return prometheus.BuildFQName(namespace, "pmc", n) }
+	return &Collector{
+		sketch: sketch,
+		fillRate: prometheus.NewDesc(name("fill_ratio"),
+			"Fraction of the sketch bits that are set.", nil, labels),
+		increments: prometheus.NewDesc(name("increments_total"),
+			"Number of additions accounted in the sketch.", nil, labels),
+		memory: prometheus.NewDesc(name("memory_bytes"),
+			"Memory held by the sketch in bytes, see pmc.Sketch.MemoryUsage.", nil, labels),
+		estimate: prometheus.NewDesc(name("flow_estimate"),
+			"Estimated count of a registered flow.", []string{"flow"}, labels),
+		flows: make(map[string]struct{}),
+	}
+}
+
+/*
+Track registers a flow whose estimate is exported with the flow label.
+*/
+func (c *Collector) Track(flow string) {
+	c.mu.Lock()
+	c.flows[flow] = struct{}{}
+	c.mu.Unlock()
+}
+
+/*
+Untrack stops exporting the estimate of the flow.
+*/
+func (c *Collector) Untrack(flow string) {
+	c.mu.Lock()
+	delete(c.flows, flow)
+	c.mu.Unlock()
+}
+
+/*
+Describe implements prometheus.Collector.
+*/
+func (c *Collector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.fillRate
+	ch <- c.increments
+	ch <- c.memory
+	ch <- c.estimate
+}
+
+/*
+Collect implements prometheus.Collector.
+*/
+func (c *Collector) Collect(ch chan<- prometheus.Metric) {
+	ch <- prometheus.MustNewConstMetric(c.fillRate, prometheus.GaugeValue, c.sketch.GetFillRate()/100)
+	ch <- prometheus.MustNewConstMetric(c.increments, prometheus.CounterValue, float64(c.sketch.N()))
+	ch <- prometheus.MustNewConstMetric(c.memory, prometheus.GaugeValue, float64(c.sketch.MemoryUsage()))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for flow := range c.flows {
+		est := c.sketch.GetEstimate([]byte(flow))
+		ch <- prometheus.MustNewConstMetric(c.estimate, prometheus.GaugeValue, est, flow)
+	}
+}
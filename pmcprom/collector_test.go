@@ -0,0 +1,45 @@
+package pmcprom
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/seiflotfy/pmc"
+)
+
+func TestCollector(t *testing.T) {
+	sketch, _ := pmc.New(1024, 8, 8, pmc.WithThreadSafety())
+	c := NewCollector(sketch, "test", prometheus.Labels{"sketch": "ingress"})
+	c.Track("flow")
+	for i := 0; i < 10; i++ {
+		sketch.Increment([]byte("flow"))
+	}
+
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(c)
+	if n := testutil.CollectAndCount(c); n != 4 {
+		t.Error("Expected 4 metrics, got", n)
+	}
+
+	expected := fmt.Sprintf(`
+# HELP test_pmc_increments_total Number of additions accounted in the sketch.
+# TYPE test_pmc_increments_total counter
+test_pmc_increments_total{sketch="ingress"} 10
+# HELP test_pmc_memory_bytes Memory held by the sketch in bytes, see pmc.Sketch.MemoryUsage.
+# TYPE test_pmc_memory_bytes gauge
+test_pmc_memory_bytes{sketch="ingress"} %d
+`, sketch.MemoryUsage())
+	err := testutil.GatherAndCompare(reg, strings.NewReader(expected),
+		"test_pmc_increments_total", "test_pmc_memory_bytes")
+	if err != nil {
+		t.Error(err)
+	}
+
+	c.Untrack("flow")
+	if n := testutil.CollectAndCount(c, "test_pmc_flow_estimate"); n != 0 {
+		t.Error("Expected no flow estimate after Untrack, got", n)
+	}
+}
@@ -0,0 +1,243 @@
+/*
+Package pmcredis provides a pmc.SharedBackend keeping the bits of a PMC
+sketch in a Redis string, so that several stateless ingest processes can
+feed one logical sketch:
+
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379"})
+	backend := pmcredis.New(client, "flows")
+	sketch, err := pmc.New(1<<23, 256, 32,
+		pmc.WithBitmapBackend(backend), pmc.WithHashSeed(seed), pmc.WithSeed(random))
+
+All processes must use the same l, m, w and hash seed, and different seeds.
+Bits set and additions are buffered and sent in pipelines of BatchSize
+commands, and are flushed before any read, so a process always sees its own
+writes. Reads issue one command per bit, so estimates are best computed on a
+Snapshot of the sketch, which fetches the bitmap once.
+*/
+package pmcredis
+
+import (
+	"context"
+	"math/bits"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/seiflotfy/pmc"
+)
+
+// DefaultBatchSize is the default number of commands pipelined at once.
+const DefaultBatchSize = 512
+
+/*
+Backend is a pmc.SharedBackend storing the bitmap of a sketch in the Redis
+string at Key, and its number of additions at Key + ":n". Redis errors are
+kept until returned by Flush or Err.
+*/
+type Backend struct {
+	Client    redis.UniversalClient
+	Key       string
+	BatchSize int
+
+	pending []uint
+	n       uint64
+	err     error
+}
+
+/*
+New returns a Backend storing the sketch at key.
+*/
+func New(client redis.UniversalClient, key string) *Backend {
+	return &Backend{Client: client, Key: key, BatchSize: DefaultBatchSize}
+}
+
+func (b *Backend) nKey() string {
+	return b.Key + ":n"
+}
+
+func (b *Backend) fail(err error) {
+	if err != nil && err != redis.Nil && b.err == nil {
+		b.err = err
+	}
+}
+
+/*
+Err returns the first Redis error met, if any.
+*/
+func (b *Backend) Err() error {
+	return b.err
+}
+
+/*
+Flush sends the buffered bits and additions, and returns the first Redis error
+met, if any.
+*/
+func (b *Backend) Flush() error {
+	if len(b.pending) == 0 && b.n == 0 {
+		return b.err
+	}
+	ctx := context.Background()
+	_, err := b.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, i := range b.pending {
+			pipe.SetBit(ctx, b.Key, int64(i), 1)
+		}
+		if b.n != 0 {
+			pipe.IncrBy(ctx, b.nKey(), int64(b.n))
+		}
+		return nil
+	})
+	b.fail(err)
+	b.pending, b.n = b.pending[:0], 0
+	return b.err
+}
+
+/*
+Test implements pmc.BitmapBackend.
+*/
+func (b *Backend) Test(i uint) bool {
+	b.Flush()
+	v, err := b.Client.GetBit(context.Background(), b.Key, int64(i)).Result()
+	b.fail(err)
+	return v == 1
+}
+
+/*
+Set implements pmc.BitmapBackend. The bit is buffered, and Set always
+returns true.
+*/
+func (b *Backend) Set(i uint) bool {
+	b.pending = append(b.pending, i)
+	if len(b.pending) >= b.BatchSize {
+		b.Flush()
+	}
+	return true
+}
+
+/*
+Clear implements pmc.BitmapBackend.
+*/
+func (b *Backend) Clear(i uint) bool {
+	b.Flush()
+	v, err := b.Client.SetBit(context.Background(), b.Key, int64(i), 0).Result()
+	b.fail(err)
+	return v == 1
+}
+
+/*
+Count implements pmc.BitmapBackend.
+*/
+func (b *Backend) Count() uint {
+	b.Flush()
+	v, err := b.Client.BitCount(context.Background(), b.Key, nil).Result()
+	b.fail(err)
+	return uint(v)
+}
+
+/*
+AddN implements pmc.SharedBackend.
+*/
+func (b *Backend) AddN(delta uint64) {
+	b.n += delta
+	if b.n >= uint64(b.BatchSize) {
+		b.Flush()
+	}
+}
+
+/*
+N implements pmc.SharedBackend.
+*/
+func (b *Backend) N() uint64 {
+	b.Flush()
+	v, err := b.Client.Get(context.Background(), b.nKey()).Uint64()
+	b.fail(err)
+	return v
+}
+
+// bytes returns the bitmap as stored by Redis, the most significant bit of
+// each byte coming first.
+func (b *Backend) bytes() []byte {
+	b.Flush()
+	v, err := b.Client.Get(context.Background(), b.Key).Bytes()
+	b.fail(err)
+	return v
+}
+
+/*
+ForEach implements pmc.BitmapBackend. The whole bitmap is fetched at once.
+*/
+func (b *Backend) ForEach(fn func(i uint) bool) {
+	forEach(b.bytes(), fn)
+}
+
+func forEach(data []byte, fn func(i uint) bool) {
+	for k, c := range data {
+		for rest := c; rest != 0; {
+			b := uint(bits.LeadingZeros8(rest))
+			if !fn(uint(k)*8 + b) {
+				return
+			}
+			rest &^= 0x80 >> b
+		}
+	}
+}
+
+/*
+Clone implements pmc.BitmapBackend. The bitmap is fetched into a local copy,
+which isn't shared anymore.
+*/
+func (b *Backend) Clone() pmc.BitmapBackend {
+	return &local{data: b.bytes()}
+}
+
+/*
+Reset implements pmc.BitmapBackend. It deletes the sketch from Redis, for
+all processes sharing it.
+*/
+func (b *Backend) Reset() {
+	b.pending, b.n = b.pending[:0], 0
+	b.fail(b.Client.Del(context.Background(), b.Key, b.nKey()).Err())
+}
+
+// local is a copy of a bitmap fetched from Redis, in the same layout.
+type local struct {
+	data []byte
+}
+
+func (lb *local) Test(i uint) bool {
+	return i/8 < uint(len(lb.data)) && lb.data[i/8]&(0x80>>(i%8)) != 0
+}
+
+func (lb *local) Set(i uint) bool {
+	for i/8 >= uint(len(lb.data)) {
+		lb.data = append(lb.data, 0)
+	}
+	was := lb.Test(i)
+	lb.data[i/8] |= 0x80 >> (i % 8)
+	return !was
+}
+
+func (lb *local) Clear(i uint) bool {
+	was := lb.Test(i)
+	if was {
+		lb.data[i/8] &^= 0x80 >> (i % 8)
+	}
+	return was
+}
+
+func (lb *local) Count() uint {
+	count := 0
+	for _, c := range lb.data {
+		count += bits.OnesCount8(c)
+	}
+	return uint(count)
+}
+
+func (lb *local) ForEach(fn func(i uint) bool) {
+	forEach(lb.data, fn)
+}
+
+func (lb *local) Clone() pmc.BitmapBackend {
+	return &local{data: append([]byte(nil), lb.data...)}
+}
+
+func (lb *local) Reset() {
+	lb.data = lb.data[:0]
+}
@@ -0,0 +1,78 @@
+package pmcredis
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/seiflotfy/pmc"
+)
+
+func TestSharedSketch(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	var sketches []*pmc.Sketch
+	var backends []*Backend
+	for seed := uint64(1); seed <= 2; seed++ {
+		b := New(client, "flows")
+		s, err := pmc.New(1<<20, 256, 32, pmc.WithBitmapBackend(b),
+			pmc.WithHashSeed(42), pmc.WithSeed(seed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sketches, backends = append(sketches, s), append(backends, b)
+	}
+	for i := 0; i < 5000; i++ {
+		sketches[0].Increment([]byte("flow"))
+		sketches[1].Increment([]byte("flow"))
+	}
+	for _, b := range backends {
+		if err := b.Flush(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := sketches[0].N(); n != 10000 {
+		t.Errorf("Expected n = 10000 across processes, got %d", n)
+	}
+	est := sketches[1].GetEstimate([]byte("flow"))
+	if math.Abs(est-10000)/10000 > 0.15 {
+		t.Errorf("Expected an estimate close to 10000, got %f", est)
+	}
+	if snap := sketches[0].Snapshot().GetEstimate([]byte("flow")); snap != est {
+		t.Errorf("Expected snapshot estimate %f, got %f", est, snap)
+	}
+
+	sketches[0].Reset()
+	if n := sketches[1].N(); n != 0 {
+		t.Errorf("Expected reset to clear the shared sketch, got n = %d", n)
+	}
+}
+
+func TestBitOrder(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	b := New(client, "bits")
+	for _, i := range []uint{0, 9, 14, 1000} {
+		b.Set(i)
+	}
+	if b.Count() != 4 || !b.Test(9) || b.Test(8) {
+		t.Fatal("Expected bits 0, 9, 14 and 1000 to be set")
+	}
+	var got []uint
+	b.Clone().ForEach(func(i uint) bool {
+		got = append(got, i)
+		return true
+	})
+	if len(got) != 4 || got[0] != 0 || got[1] != 9 || got[2] != 14 || got[3] != 1000 {
+		t.Errorf("Expected [0 9 14 1000], got %v", got)
+	}
+	if !b.Clear(9) || b.Clear(9) {
+		t.Error("Expected Clear to report the previous value")
+	}
+}
@@ -0,0 +1,317 @@
+/*
+Package pmcresp serves PMC sketches over the Redis protocol (RESP), so that
+redis-cli and Redis client libraries can talk to a pmc daemon. Sketches are
+named by a key, like Redis values, and created on first use:
+
+	PMC.INCR key flow [n]         account n additions, 1 by default, to the flow
+	PMC.ESTIMATE key flow         estimated count of the flow
+	PMC.MERGE destkey key [key …] merge the sketches of the keys into destkey
+
+PING and QUIT are supported as well.
+*/
+package pmcresp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Server is a RESP server holding named sketches.
+*/
+type Server struct {
+	newSketch func() (*pmc.Sketch, error)
+
+	mu       sync.Mutex
+	sketches map[string]*pmc.Sketch
+	lis      map[net.Listener]struct{}
+	conns    map[net.Conn]struct{}
+	closed   bool
+}
+
+/*
+NewServer returns a Server creating its sketches with newSketch. Sketches
+can only be merged if they share their parameters and hash seed, so
+newSketch should pass WithHashSeed to New.
+*/
+func NewServer(newSketch func() (*pmc.Sketch, error)) *Server {
+	return &Server{newSketch: newSketch, sketches: make(map[string]*pmc.Sketch),
+		lis: make(map[net.Listener]struct{}), conns: make(map[net.Conn]struct{})}
+}
+
+/*
+ListenAndServe listens on the TCP address addr and calls Serve.
+*/
+func (srv *Server) ListenAndServe(addr string) error {
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return srv.Serve(l)
+}
+
+/*
+ErrServerClosed is returned by Serve after Close.
+*/
+var ErrServerClosed = errors.New("Server closed")
+
+/*
+Serve accepts connections on l, serving each on its own goroutine, until
+Close is called.
+*/
+func (srv *Server) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	if srv.closed {
+		srv.mu.Unlock()
+		l.Close()
+		return ErrServerClosed
+	}
+	srv.lis[l] = struct{}{}
+	srv.mu.Unlock()
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			defer srv.mu.Unlock()
+			delete(srv.lis, l)
+			if srv.closed {
+				return ErrServerClosed
+			}
+			return err
+		}
+		srv.mu.Lock()
+		srv.conns[conn] = struct{}{}
+		srv.mu.Unlock()
+		go srv.serveConn(conn)
+	}
+}
+
+/*
+Close stops the listeners and closes the open connections.
+*/
+func (srv *Server) Close() error {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	srv.closed = true
+	for l := range srv.lis {
+		l.Close()
+	}
+	for c := range srv.conns {
+		c.Close()
+	}
+	return nil
+}
+
+func (srv *Server) serveConn(conn net.Conn) {
+	defer func() {
+		srv.mu.Lock()
+		delete(srv.conns, conn)
+		srv.mu.Unlock()
+		conn.Close()
+	}()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(conn)
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			if err != io.EOF {
+				writeError(w, err.Error())
+				w.Flush()
+			}
+			return
+		}
+		if len(args) == 0 {
+			continue
+		}
+		quit := strings.EqualFold(args[0], "QUIT")
+		if quit {
+			w.WriteString("+OK\r\n")
+		} else {
+			srv.exec(w, args)
+		}
+		// Pipelined commands are answered together.
+		if r.Buffered() == 0 || quit {
+			if err := w.Flush(); err != nil || quit {
+				return
+			}
+		}
+	}
+}
+
+// maxArgs and maxBulk bound the number of arguments of a command and the size
+// of each, and maxInline the size of inline commands and of the length
+// lines of the others, as in Redis.
+const (
+	maxArgs   = 1 << 20
+	maxBulk   = 512 << 20
+	maxInline = 64 << 10
+)
+
+// readCommand reads a command sent either as a RESP array of bulk strings
+// or inline, as typed in a telnet session.
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := readLine(r)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasPrefix(line, "*") {
+		return strings.Fields(line), nil
+	}
+	n, err := strconv.Atoi(line[1:])
+	if err != nil || n < 0 || n > maxArgs {
+		return nil, fmt.Errorf("Protocol error, invalid array length %q", line[1:])
+	}
+	// The arguments and their bytes grow with the input received rather than
+	// with the lengths announced.
+	args := make([]string, 0, min(n, 16))
+	for len(args) < n {
+		line, err := readLine(r)
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("Protocol error, expected '$', got %q", line)
+		}
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 || size > maxBulk {
+			return nil, fmt.Errorf("Protocol error, invalid bulk length %q", line[1:])
+		}
+		var buf strings.Builder
+		if _, err := io.CopyN(&buf, r, int64(size)+2); err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		args = append(args, buf.String()[:size])
+	}
+	return args, nil
+}
+
+// readLine reads a line of at most maxInline bytes, so that a client never
+// sending a newline can't make it buffer without bound.
+func readLine(r *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		chunk, err := r.ReadSlice('\n')
+		if len(line)+len(chunk) > maxInline {
+			return "", errors.New("Protocol error, too big inline request")
+		}
+		line = append(line, chunk...)
+		switch err {
+		case nil:
+			return strings.TrimRight(string(line), "\r\n"), nil
+		case bufio.ErrBufferFull:
+		default:
+			return "", err
+		}
+	}
+}
+
+func writeError(w *bufio.Writer, msg string) {
+	w.WriteString("-ERR " + msg + "\r\n")
+}
+
+func writeBulk(w *bufio.Writer, s string) {
+	fmt.Fprintf(w, "$%d\r\n%s\r\n", len(s), s)
+}
+
+// sketch returns the sketch of the key, creating it if needed.
+func (srv *Server) sketch(key string) (*pmc.Sketch, error) {
+	if s, ok := srv.sketches[key]; ok {
+		return s, nil
+	}
+	s, err := srv.newSketch()
+	if err != nil {
+		return nil, err
+	}
+	srv.sketches[key] = s
+	return s, nil
+}
+
+func (srv *Server) exec(w *bufio.Writer, args []string) {
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+
+	cmd := strings.ToUpper(args[0])
+	arity := func(min, max int) bool {
+		if len(args) < min || (max > 0 && len(args) > max) {
+			writeError(w, fmt.Sprintf("wrong number of arguments for '%s' command", strings.ToLower(cmd)))
+			return false
+		}
+		return true
+	}
+	switch cmd {
+	case "PING":
+		if !arity(1, 2) {
+			return
+		}
+		if len(args) == 2 {
+			writeBulk(w, args[1])
+			return
+		}
+		w.WriteString("+PONG\r\n")
+	case "COMMAND":
+		// redis-cli asks for the command docs on startup.
+		w.WriteString("*0\r\n")
+	case "PMC.INCR":
+		if !arity(3, 4) {
+			return
+		}
+		n := uint64(1)
+		if len(args) == 4 {
+			var err error
+			if n, err = strconv.ParseUint(args[3], 10, 64); err != nil || n == 0 {
+				writeError(w, "value is not a positive integer")
+				return
+			}
+		}
+		s, err := srv.sketch(args[1])
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		s.Add([]byte(args[2]), n)
+		w.WriteString("+OK\r\n")
+	case "PMC.ESTIMATE":
+		if !arity(3, 3) {
+			return
+		}
+		s, ok := srv.sketches[args[1]]
+		est := 0.0
+		if ok {
+			est = s.GetEstimate([]byte(args[2]))
+		}
+		writeBulk(w, strconv.FormatFloat(est, 'f', -1, 64))
+	case "PMC.MERGE":
+		if !arity(3, 0) {
+			return
+		}
+		others := make([]*pmc.Sketch, 0, len(args)-2)
+		for _, key := range args[2:] {
+			if s, ok := srv.sketches[key]; ok {
+				others = append(others, s)
+			}
+		}
+		dest, err := srv.sketch(args[1])
+		if err == nil {
+			err = dest.MergeAll(others...)
+		}
+		if err != nil {
+			writeError(w, err.Error())
+			return
+		}
+		w.WriteString("+OK\r\n")
+	default:
+		writeError(w, fmt.Sprintf("unknown command '%s'", args[0]))
+	}
+}
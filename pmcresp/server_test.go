@@ -0,0 +1,128 @@
+package pmcresp
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+type client struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+func (c *client) do(t *testing.T, args ...string) string {
+	fmt.Fprintf(c.conn, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(c.conn, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	line = strings.TrimRight(line, "\r\n")
+	if strings.HasPrefix(line, "$") {
+		line, _ = c.r.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+	}
+	return line
+}
+
+func TestServer(t *testing.T) {
+	seed := uint64(1)
+	srv := NewServer(func() (*pmc.Sketch, error) {
+		seed++
+		return pmc.New(1<<20, 256, 32, pmc.WithSeed(seed), pmc.WithHashSeed(7))
+	})
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error)
+	go func() { done <- srv.Serve(l) }()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := &client{conn: conn, r: bufio.NewReader(conn)}
+
+	if reply := c.do(t, "PING"); reply != "+PONG" {
+		t.Error("Expected +PONG, got", reply)
+	}
+	if reply := c.do(t, "PMC.INCR", "a", "flow", "5000"); reply != "+OK" {
+		t.Error("Expected +OK, got", reply)
+	}
+	c.do(t, "PMC.INCR", "b", "flow", "5000")
+	if reply := c.do(t, "PMC.INCR", "a", "flow", "x"); !strings.HasPrefix(reply, "-ERR") {
+		t.Error("Expected an error for an invalid count, got", reply)
+	}
+	if reply := c.do(t, "PMC.ESTIMATE", "a"); !strings.HasPrefix(reply, "-ERR wrong number") {
+		t.Error("Expected an arity error, got", reply)
+	}
+	if reply := c.do(t, "PMC.MERGE", "ab", "a", "b"); reply != "+OK" {
+		t.Error("Expected +OK, got", reply)
+	}
+	if reply := c.do(t, "PMC.MERGE", "ab", "ab"); !strings.HasPrefix(reply, "-ERR") {
+		t.Error("Expected an error merging a key into itself, got", reply)
+	}
+	est, _ := strconv.ParseFloat(c.do(t, "PMC.ESTIMATE", "ab", "flow"), 64)
+	if fErr := math.Abs(100 * (1 - est/10000)); fErr > 15 {
+		t.Errorf("Expected merged estimate within 15%% of 10000, got %f", est)
+	}
+	if reply := c.do(t, "PMC.ESTIMATE", "missing", "flow"); reply != "0" {
+		t.Error("Expected estimate 0 for a missing key, got", reply)
+	}
+	if reply := c.do(t, "FOO"); !strings.HasPrefix(reply, "-ERR unknown command") {
+		t.Error("Expected an unknown command error, got", reply)
+	}
+
+	// Inline commands, as typed in a telnet session.
+	fmt.Fprintf(conn, "PING hello\r\n")
+	c.r.ReadString('\n')
+	if reply, _ := c.r.ReadString('\n'); reply != "hello\r\n" {
+		t.Errorf("Expected hello, got %q", reply)
+	}
+	if reply := c.do(t, "QUIT"); reply != "+OK" {
+		t.Error("Expected +OK, got", reply)
+	}
+
+	srv.Close()
+	if err := <-done; err != ErrServerClosed {
+		t.Error("Expected ErrServerClosed, got", err)
+	}
+}
+
+func TestReadCommandLimits(t *testing.T) {
+	for _, input := range []string{
+		"*1\r\n$9223372036854775807\r\n",
+		"*1\r\n$536870913\r\n",
+		"*9223372036854775807\r\n",
+		"*1048577\r\n",
+		strings.Repeat("a", maxInline+1),
+		"*1\r\n$" + strings.Repeat("1", maxInline),
+	} {
+		if _, err := readCommand(bufio.NewReader(strings.NewReader(input))); err == nil ||
+			!strings.HasPrefix(err.Error(), "Protocol error") {
+			t.Errorf("Expected a protocol error for %q, got %v", input, err)
+		}
+	}
+	// Announced lengths are only allocated as the bytes arrive.
+	if _, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$536870912\r\nshort"))); err == nil {
+		t.Error("Expected an error for a truncated bulk string, got nil")
+	}
+	long := strings.Repeat("a ", maxInline/2-2) + "\r\n"
+	if args, err := readCommand(bufio.NewReader(strings.NewReader(long))); err != nil || len(args) != maxInline/2-2 {
+		t.Errorf("Expected an inline command of %d arguments, got %d, %v", maxInline/2-2, len(args), err)
+	}
+	args, err := readCommand(bufio.NewReader(strings.NewReader("*2\r\n$4\r\nPING\r\n$0\r\n\r\n")))
+	if err != nil || len(args) != 2 || args[0] != "PING" || args[1] != "" {
+		t.Error("Expected PING and an empty argument, got", args, err)
+	}
+}
@@ -0,0 +1,88 @@
+/*
+Package pmcroaring provides a compressed bitmap backend for PMC sketches,
+based on roaring bitmaps. It suits very large and sparse sketches, with l in
+the billions, where a dense bitmap of l bits would waste memory:
+
+	sketch, err := pmc.New(1<<34, 256, 32, pmc.WithBitmapBackend(pmcroaring.New()))
+*/
+package pmcroaring
+
+import (
+	"github.com/RoaringBitmap/roaring/v2/roaring64"
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Backend is a pmc.BitmapBackend storing the set bits in a roaring bitmap.
+*/
+type Backend struct {
+	rb *roaring64.Bitmap
+}
+
+/*
+New returns an empty Backend.
+*/
+func New() *Backend {
+	return &Backend{rb: roaring64.New()}
+}
+
+/*
+Test implements pmc.BitmapBackend.
+*/
+func (b *Backend) Test(i uint) bool {
+	return b.rb.Contains(uint64(i))
+}
+
+/*
+Set implements pmc.BitmapBackend.
+*/
+func (b *Backend) Set(i uint) bool {
+	return b.rb.CheckedAdd(uint64(i))
+}
+
+/*
+Clear implements pmc.BitmapBackend.
+*/
+func (b *Backend) Clear(i uint) bool {
+	return b.rb.CheckedRemove(uint64(i))
+}
+
+/*
+Count implements pmc.BitmapBackend.
+*/
+func (b *Backend) Count() uint {
+	return uint(b.rb.GetCardinality())
+}
+
+/*
+ForEach implements pmc.BitmapBackend.
+*/
+func (b *Backend) ForEach(fn func(i uint) bool) {
+	it := b.rb.Iterator()
+	for it.HasNext() {
+		if !fn(uint(it.Next())) {
+			return
+		}
+	}
+}
+
+/*
+Clone implements pmc.BitmapBackend.
+*/
+func (b *Backend) Clone() pmc.BitmapBackend {
+	return &Backend{rb: b.rb.Clone()}
+}
+
+/*
+Reset implements pmc.BitmapBackend.
+*/
+func (b *Backend) Reset() {
+	b.rb.Clear()
+}
+
+/*
+SizeInBytes returns the memory used by the bitmap.
+*/
+func (b *Backend) SizeInBytes() uint64 {
+	return b.rb.GetSizeInBytes()
+}
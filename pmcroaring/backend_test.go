@@ -0,0 +1,64 @@
+package pmcroaring
+
+import (
+	"math"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestBackend(t *testing.T) {
+	b := New()
+	sketch, err := pmc.New(1<<34, 256, 32, pmc.WithBitmapBackend(b), pmc.WithSeed(pmc.DefaultSeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sketch.Add([]byte("flow"), 20000)
+	for i := 0; i < 1000; i++ {
+		sketch.Increment([]byte{byte(i), byte(i >> 8)})
+	}
+	est := sketch.GetEstimate([]byte("flow"))
+	if fErr := math.Abs(100 * (1 - est/20000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 20000, got %f", est)
+	}
+	// A dense bitmap of 2^34 bits takes 2GiB.
+	if size := b.SizeInBytes(); size > 1<<20 {
+		t.Error("Expected a sparse bitmap of less than 1MiB, got", size)
+	}
+
+	c := sketch.Clone()
+	sketch.Reset()
+	if b.Count() != 0 || sketch.GetEstimate([]byte("flow")) != 0 {
+		t.Error("Expected an empty backend after Reset")
+	}
+	if c.GetEstimate([]byte("flow")) != est {
+		t.Error("Expected the clone to keep its own bits")
+	}
+}
+
+func TestBackendEncoding(t *testing.T) {
+	dense, _ := pmc.New(1<<20, 256, 32, pmc.WithSeed(pmc.DefaultSeed))
+	sparse, _ := pmc.New(1<<20, 256, 32, pmc.WithSeed(pmc.DefaultSeed), pmc.WithBitmapBackend(New()))
+	for _, s := range []*pmc.Sketch{dense, sparse} {
+		s.Add([]byte("flow"), 5000)
+	}
+	a, _ := dense.MarshalBinary()
+	b, _ := sparse.MarshalBinary()
+	if string(a) != string(b) {
+		t.Fatal("Expected the same encoding whatever the backend")
+	}
+
+	restored, _ := pmc.New(1<<20, 256, 32, pmc.WithBitmapBackend(New()))
+	if err := restored.UnmarshalBinary(a); err != nil {
+		t.Fatal(err)
+	}
+	if restored.GetEstimate([]byte("flow")) != dense.GetEstimate([]byte("flow")) {
+		t.Error("Expected the decoded sketch to estimate like the original")
+	}
+	if err := dense.Merge(restored); err != nil {
+		t.Fatal(err)
+	}
+	if dense.GetFillRate() != restored.GetFillRate() {
+		t.Error("Expected merging the same bits to leave the fill rate unchanged")
+	}
+}
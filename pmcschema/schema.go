@@ -0,0 +1,117 @@
+/*
+Package pmcschema encodes PMC sketches in schema'd formats, for data
+platforms which only accept those: protocol buffers, with the Sketch message
+of sketch.proto, and CBOR, with a map of the same fields.
+
+	data, err := pmcschema.MarshalProto(sketch)
+	sketch, err := pmcschema.UnmarshalProto(data)
+
+	data, err := pmcschema.MarshalCBOR(sketch)
+	sketch, err := pmcschema.UnmarshalCBOR(data)
+
+The fields are those of the binary encoding described in FORMAT.md, those
+of pmc.State.
+*/
+package pmcschema
+
+import (
+	"encoding/binary"
+	"fmt"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/seiflotfy/pmc"
+	"google.golang.org/protobuf/proto"
+)
+
+/*
+ToProto returns the Sketch message of sketch.
+*/
+func ToProto(sketch *pmc.Sketch) (*Sketch, error) {
+	st := sketch.State()
+	return &Sketch{L: st.L, M: st.M, W: st.W, N: st.N, HashSeed: st.HashSeed, Words: st.Words}, nil
+}
+
+/*
+FromProto returns the sketch of a Sketch message, failing if its parameters
+are invalid or its words don't match l, as pmc.FromState does.
+*/
+func FromProto(msg *Sketch) (*pmc.Sketch, error) {
+	return pmc.FromState(pmc.State{L: msg.L, M: msg.M, W: msg.W, N: msg.N,
+		HashSeed: msg.HashSeed, Words: msg.Words})
+}
+
+/*
+MarshalProto returns the protocol buffers encoding of the Sketch message of
+sketch.
+*/
+func MarshalProto(sketch *pmc.Sketch) ([]byte, error) {
+	msg, err := ToProto(sketch)
+	if err != nil {
+		return nil, err
+	}
+	return proto.Marshal(msg)
+}
+
+/*
+UnmarshalProto returns the sketch of a Sketch message encoded by
+MarshalProto.
+*/
+func UnmarshalProto(data []byte) (*pmc.Sketch, error) {
+	var msg Sketch
+	if err := proto.Unmarshal(data, &msg); err != nil {
+		return nil, err
+	}
+	return FromProto(&msg)
+}
+
+// cborSketch is the CBOR representation of a sketch, a map keyed by the
+// names of the fields of the Sketch message. The words are a byte string of
+// their little-endian encoding.
+type cborSketch struct {
+	L        uint64 `cbor:"l"`
+	M        uint64 `cbor:"m"`
+	W        uint64 `cbor:"w"`
+	N        uint64 `cbor:"n"`
+	HashSeed uint64 `cbor:"hash_seed"`
+	Words    []byte `cbor:"words"`
+}
+
+// cborEncoding sorts map keys as required by deterministic CBOR, so that a
+// sketch always has the same encoding.
+var cborEncoding, _ = cbor.CoreDetEncOptions().EncMode()
+
+/*
+MarshalCBOR returns the CBOR encoding of sketch, a map of the fields of the
+Sketch message, its words being a byte string.
+*/
+func MarshalCBOR(sketch *pmc.Sketch) ([]byte, error) {
+	msg, err := ToProto(sketch)
+	if err != nil {
+		return nil, err
+	}
+	cs := cborSketch{L: msg.L, M: msg.M, W: msg.W, N: msg.N, HashSeed: msg.HashSeed,
+		Words: make([]byte, 0, 8*len(msg.Words))}
+	for _, word := range msg.Words {
+		cs.Words = binary.LittleEndian.AppendUint64(cs.Words, word)
+	}
+	return cborEncoding.Marshal(cs)
+}
+
+/*
+UnmarshalCBOR returns the sketch encoded by MarshalCBOR.
+*/
+func UnmarshalCBOR(data []byte) (*pmc.Sketch, error) {
+	var cs cborSketch
+	if err := cbor.Unmarshal(data, &cs); err != nil {
+		return nil, err
+	}
+	if len(cs.Words)%8 != 0 {
+		return nil, fmt.Errorf("Expected words of 8 bytes, got %d bytes", len(cs.Words))
+	}
+	msg := &Sketch{L: cs.L, M: cs.M, W: cs.W, N: cs.N, HashSeed: cs.HashSeed,
+		Words: make([]uint64, len(cs.Words)/8)}
+	for i := range msg.Words {
+		msg.Words[i] = binary.LittleEndian.Uint64(cs.Words[8*i:])
+	}
+	return FromProto(msg)
+}
@@ -0,0 +1,83 @@
+package pmcschema
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func newTestSketch(t *testing.T) *pmc.Sketch {
+	sketch, err := pmc.New(1000, 16, 16, pmc.WithDeterministic(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k := 0; k < 500; k++ {
+		sketch.Increment([]byte(fmt.Sprintf("flow-%d", k%5)))
+	}
+	return sketch
+}
+
+func TestRoundTrip(t *testing.T) {
+	sketch := newTestSketch(t)
+	for _, codec := range []struct {
+		name      string
+		marshal   func(*pmc.Sketch) ([]byte, error)
+		unmarshal func([]byte) (*pmc.Sketch, error)
+	}{{"proto", MarshalProto, UnmarshalProto}, {"cbor", MarshalCBOR, UnmarshalCBOR}} {
+		data, err := codec.marshal(sketch)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := codec.unmarshal(data)
+		if err != nil {
+			t.Fatalf("%s: %v", codec.name, err)
+		}
+		if got.Params() != sketch.Params() || got.N() != sketch.N() {
+			t.Errorf("%s: expected %+v and N = %d, got %+v and %d",
+				codec.name, sketch.Params(), sketch.N(), got.Params(), got.N())
+		}
+		for k := 0; k < 5; k++ {
+			flow := []byte(fmt.Sprintf("flow-%d", k))
+			if e, g := sketch.GetEstimate(flow), got.GetEstimate(flow); e != g {
+				t.Errorf("%s: expected estimate %v of %s, got %v", codec.name, e, flow, g)
+			}
+		}
+		again, _ := codec.marshal(got)
+		if string(again) != string(data) {
+			t.Errorf("%s: expected the same encoding after a round trip", codec.name)
+		}
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	msg, err := ToProto(newTestSketch(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msg.Words) != 16 {
+		t.Errorf("Expected 16 words for l = 1000, got %d", len(msg.Words))
+	}
+	msg.Words = msg.Words[1:]
+	if _, err := FromProto(msg); err == nil {
+		t.Error("Expected an error for missing words")
+	}
+	if _, err := FromProto(&Sketch{L: 64, M: 2, W: 1, Words: make([]uint64, 1)}); err == nil {
+		t.Error("Expected an error for w = 1")
+	}
+	if _, err := FromProto(&Sketch{L: 1 << 62, M: 2, W: 32}); err == nil {
+		t.Error("Expected an error for l = 2^62")
+	}
+	if _, err := FromProto(&Sketch{L: 1<<40 + 1, M: 2, W: 32, Words: make([]uint64, 1)}); err == nil {
+		t.Error("Expected an error for l beyond pmc.MaxL")
+	}
+	if _, err := FromProto(&Sketch{L: 60, M: 2, W: 32, Words: []uint64{1 << 60}}); err == nil {
+		t.Error("Expected an error for bits beyond l")
+	}
+	if _, err := UnmarshalCBOR([]byte{0xa1, 0x61, 'l'}); err == nil {
+		t.Error("Expected an error for truncated CBOR")
+	}
+	if _, err := UnmarshalProto([]byte{0xff}); err == nil {
+		t.Error("Expected an error for invalid protocol buffers")
+	}
+}
@@ -0,0 +1,175 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.12
+// 	protoc        (unknown)
+// source: sketch.proto
+
+package pmcschema
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Sketch is the state of a PMC sketch, with the fields of the binary
+// encoding described in FORMAT.md.
+type Sketch struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	// l is the number of bits of the bitmap.
+	L uint64 `protobuf:"varint,1,opt,name=l,proto3" json:"l,omitempty"`
+	// m is the number of rows of a flow.
+	M uint64 `protobuf:"varint,2,opt,name=m,proto3" json:"m,omitempty"`
+	// w is the number of columns of a flow.
+	W uint64 `protobuf:"varint,3,opt,name=w,proto3" json:"w,omitempty"`
+	// n is the number of additions.
+	N        uint64 `protobuf:"varint,4,opt,name=n,proto3" json:"n,omitempty"`
+	HashSeed uint64 `protobuf:"fixed64,5,opt,name=hash_seed,json=hashSeed,proto3" json:"hash_seed,omitempty"`
+	// words are the ceil(l/64) words of the bitmap, bit p being bit p mod 64
+	// of word p/64.
+	Words         []uint64 `protobuf:"fixed64,6,rep,packed,name=words,proto3" json:"words,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Sketch) Reset() {
+	*x = Sketch{}
+	mi := &file_sketch_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Sketch) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Sketch) ProtoMessage() {}
+
+func (x *Sketch) ProtoReflect() protoreflect.Message {
+	mi := &file_sketch_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Sketch.ProtoReflect.Descriptor instead.
+func (*Sketch) Descriptor() ([]byte, []int) {
+	return file_sketch_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Sketch) GetL() uint64 {
+	if x != nil {
+		return x.L
+	}
+	return 0
+}
+
+func (x *Sketch) GetM() uint64 {
+	if x != nil {
+		return x.M
+	}
+	return 0
+}
+
+func (x *Sketch) GetW() uint64 {
+	if x != nil {
+		return x.W
+	}
+	return 0
+}
+
+func (x *Sketch) GetN() uint64 {
+	if x != nil {
+		return x.N
+	}
+	return 0
+}
+
+func (x *Sketch) GetHashSeed() uint64 {
+	if x != nil {
+		return x.HashSeed
+	}
+	return 0
+}
+
+func (x *Sketch) GetWords() []uint64 {
+	if x != nil {
+		return x.Words
+	}
+	return nil
+}
+
+var File_sketch_proto protoreflect.FileDescriptor
+
+const file_sketch_proto_rawDesc = "" +
+	"\n" +
+	"\fsketch.proto\x12\rpmc.schema.v1\"s\n" +
+	"\x06Sketch\x12\f\n" +
+	"\x01l\x18\x01 \x01(\x04R\x01l\x12\f\n" +
+	"\x01m\x18\x02 \x01(\x04R\x01m\x12\f\n" +
+	"\x01w\x18\x03 \x01(\x04R\x01w\x12\f\n" +
+	"\x01n\x18\x04 \x01(\x04R\x01n\x12\x1b\n" +
+	"\thash_seed\x18\x05 \x01(\x06R\bhashSeed\x12\x14\n" +
+	"\x05words\x18\x06 \x03(\x06R\x05wordsB$Z\"github.com/seiflotfy/pmc/pmcschemab\x06proto3"
+
+var (
+	file_sketch_proto_rawDescOnce sync.Once
+	file_sketch_proto_rawDescData []byte
+)
+
+func file_sketch_proto_rawDescGZIP() []byte {
+	file_sketch_proto_rawDescOnce.Do(func() {
+		file_sketch_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sketch_proto_rawDesc), len(file_sketch_proto_rawDesc)))
+	})
+	return file_sketch_proto_rawDescData
+}
+
+var file_sketch_proto_msgTypes = make([]protoimpl.MessageInfo, 1)
+var file_sketch_proto_goTypes = []any{
+	(*Sketch)(nil), // 0: pmc.schema.v1.Sketch
+}
+var file_sketch_proto_depIdxs = []int32{
+	0, // [0:0] is the sub-list for method output_type
+	0, // [0:0] is the sub-list for method input_type
+	0, // [0:0] is the sub-list for extension type_name
+	0, // [0:0] is the sub-list for extension extendee
+	0, // [0:0] is the sub-list for field type_name
+}
+
+func init() { file_sketch_proto_init() }
+func file_sketch_proto_init() {
+	if File_sketch_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sketch_proto_rawDesc), len(file_sketch_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   1,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_sketch_proto_goTypes,
+		DependencyIndexes: file_sketch_proto_depIdxs,
+		MessageInfos:      file_sketch_proto_msgTypes,
+	}.Build()
+	File_sketch_proto = out.File
+	file_sketch_proto_goTypes = nil
+	file_sketch_proto_depIdxs = nil
+}
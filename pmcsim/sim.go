@@ -0,0 +1,180 @@
+/*
+Package pmcsim calibrates PMC sketches on synthetic traffic, to choose l, m
+and w empirically before deploying them:
+
+	report, err := pmcsim.Run(pmcsim.Params{L: 1 << 20, M: 256, W: 32},
+		pmcsim.Traffic{Flows: 10000, Packets: 1000000, Zipf: 1.1})
+	fmt.Print(report)
+
+The traffic is fed to a sketch with the candidate parameters, and the
+estimates of all flows are compared with their exact counts at several
+points of the ingestion, giving the observed relative error by flow size as
+the fill rate grows.
+*/
+package pmcsim
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sort"
+	"strings"
+
+	"github.com/seiflotfy/pmc"
+)
+
+/*
+Params are the candidate parameters of the sketch.
+*/
+type Params struct {
+	L, M, W uint
+}
+
+/*
+Traffic describes the synthetic traffic: Packets additions spread over Flows
+flows, flow i receiving a share proportional to 1/(i+1)^Zipf, so that 0
+gives uniform traffic. Steps is the number of evenly spaced points of the
+ingestion at which errors are measured, 1 by default, and Seed seeds both
+the traffic and the sketch.
+*/
+type Traffic struct {
+	Flows   int
+	Packets uint64
+	Zipf    float64
+	Steps   int
+	Seed    uint64
+}
+
+/*
+Bucket is the observed error of the flows of a size range, in [Min, Max).
+*/
+type Bucket struct {
+	Min, Max uint64
+	Flows    int
+	// The errors are relative to the exact counts of the flows.
+	MeanError, MedianError, MaxError float64
+}
+
+/*
+Step is the state of the simulation at one point of the ingestion.
+*/
+type Step struct {
+	N        uint64
+	FillRate float64
+	Buckets  []Bucket
+}
+
+/*
+Report is the outcome of a simulation.
+*/
+type Report struct {
+	Params  Params
+	Traffic Traffic
+	Steps   []Step
+}
+
+/*
+Run simulates traffic t on a sketch with parameters p.
+*/
+func Run(p Params, t Traffic) (*Report, error) {
+	if t.Flows <= 0 || t.Packets == 0 {
+		return nil, errors.New("Expected flows and packets > 0")
+	}
+	if t.Zipf < 0 {
+		return nil, fmt.Errorf("Expected zipf >= 0, got %v", t.Zipf)
+	}
+	if t.Steps <= 0 {
+		t.Steps = 1
+	}
+	if t.Seed == 0 {
+		t.Seed = pmc.DefaultSeed
+	}
+	sketch, err := pmc.New(p.L, p.M, p.W, pmc.WithDeterministic(t.Seed))
+	if err != nil {
+		return nil, err
+	}
+
+	// cdf is the cumulative distribution of the flows, sampled per packet.
+	cdf := make([]float64, t.Flows)
+	total := 0.0
+	for i := range cdf {
+		total += math.Pow(float64(i+1), -t.Zipf)
+		cdf[i] = total
+	}
+	rnd := rand.New(rand.NewPCG(t.Seed, t.Seed>>1|1))
+	keys := make([][]byte, t.Flows)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("flow-%d", i))
+	}
+
+	report := &Report{Params: p, Traffic: t}
+	counts := make([]uint64, t.Flows)
+	for k := uint64(1); k <= t.Packets; k++ {
+		f := sort.SearchFloat64s(cdf, rnd.Float64()*total)
+		if f == t.Flows {
+			f--
+		}
+		counts[f]++
+		sketch.Increment(keys[f])
+		if k*uint64(t.Steps)/t.Packets != (k-1)*uint64(t.Steps)/t.Packets {
+			report.Steps = append(report.Steps, measure(sketch, keys, counts))
+		}
+	}
+	return report, nil
+}
+
+// measure returns the errors of the flows seen so far, by decade of size.
+func measure(sketch *pmc.Sketch, keys [][]byte, counts []uint64) Step {
+	errs := map[int][]float64{}
+	for i, count := range counts {
+		if count == 0 {
+			continue
+		}
+		decade := int(math.Log10(float64(count)))
+		est := sketch.GetEstimate(keys[i])
+		errs[decade] = append(errs[decade], math.Abs(est-float64(count))/float64(count))
+	}
+
+	step := Step{N: sketch.N(), FillRate: sketch.GetFillRate()}
+	for decade := 0; len(errs) > 0; decade++ {
+		e, ok := errs[decade]
+		if !ok {
+			continue
+		}
+		delete(errs, decade)
+		sort.Float64s(e)
+		sum := 0.0
+		for _, v := range e {
+			sum += v
+		}
+		step.Buckets = append(step.Buckets, Bucket{
+			Min:         uint64(math.Pow10(decade)),
+			Max:         uint64(math.Pow10(decade + 1)),
+			Flows:       len(e),
+			MeanError:   sum / float64(len(e)),
+			MedianError: e[len(e)/2],
+			MaxError:    e[len(e)-1],
+		})
+	}
+	return step
+}
+
+/*
+String returns the report as a table of errors by fill rate and flow size.
+*/
+func (r *Report) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "l=%d m=%d w=%d flows=%d packets=%d zipf=%v\n",
+		r.Params.L, r.Params.M, r.Params.W, r.Traffic.Flows, r.Traffic.Packets, r.Traffic.Zipf)
+	fmt.Fprintf(&b, "%10s %8s %16s %8s %8s %8s %8s\n",
+		"n", "fill", "size", "flows", "mean", "median", "max")
+	for _, s := range r.Steps {
+		for _, bk := range s.Buckets {
+			fmt.Fprintf(&b, "%10d %7.2f%% %16s %8d %7.2f%% %7.2f%% %7.2f%%\n",
+				s.N, s.FillRate, fmt.Sprintf("[%d, %d)", bk.Min, bk.Max), bk.Flows,
+				100*bk.MeanError, 100*bk.MedianError, 100*bk.MaxError)
+		}
+	}
+	return b.String()
+}
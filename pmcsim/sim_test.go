@@ -0,0 +1,39 @@
+package pmcsim
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	report, err := Run(Params{L: 1 << 20, M: 256, W: 32},
+		Traffic{Flows: 1000, Packets: 200000, Zipf: 1.1, Steps: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Steps) != 4 || report.Steps[3].N != 200000 {
+		t.Fatal("Expected 4 steps ending at 200000 additions, got", len(report.Steps))
+	}
+	if report.Steps[0].FillRate >= report.Steps[3].FillRate {
+		t.Error("Expected the fill rate to grow")
+	}
+	last := report.Steps[3].Buckets
+	big := last[len(last)-1]
+	if big.Min < 1000 || big.MeanError > 0.2 {
+		t.Errorf("Expected heavy flows to be estimated within 20%%, got %+v", big)
+	}
+	flows := 0
+	for _, b := range last {
+		flows += b.Flows
+	}
+	if flows > 1000 {
+		t.Error("Expected at most 1000 flows, got", flows)
+	}
+	if s := report.String(); !strings.Contains(s, "l=1048576") || strings.Count(s, "\n") < 6 {
+		t.Error("Expected a table of errors, got", s)
+	}
+
+	if _, err := Run(Params{L: 1 << 20, M: 256, W: 32}, Traffic{}); err == nil {
+		t.Error("Expected error without traffic, got nil")
+	}
+}
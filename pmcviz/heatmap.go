@@ -0,0 +1,119 @@
+/*
+Package pmcviz renders PMC sketches as heatmaps, to diagnose hash skew and
+saturation in production dumps at a glance:
+
+	img := pmcviz.Occupancy(sketch, 256, 256)
+	png.Encode(w, img)
+
+Occupancy shows the fill rate of the bitmap region by region, which is even
+when flows hash well, and Matrix the virtual matrix of a single flow. Both
+images can be encoded to PNG with image/png, or to SVG with WriteSVG.
+*/
+package pmcviz
+
+import (
+	"bufio"
+	"fmt"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+
+	"github.com/seiflotfy/pmc"
+)
+
+// Heat maps a fill rate in [0, 1] to a color going from black through red
+// and yellow to white.
+func Heat(fill float64) color.RGBA {
+	v := fill * 3
+	switch {
+	case v <= 0:
+		return color.RGBA{A: 255}
+	case v < 1:
+		return color.RGBA{R: uint8(255 * v), A: 255}
+	case v < 2:
+		return color.RGBA{R: 255, G: uint8(255 * (v - 1)), A: 255}
+	case v < 3:
+		return color.RGBA{R: 255, G: 255, B: uint8(255 * (v - 2)), A: 255}
+	}
+	return color.RGBA{R: 255, G: 255, B: 255, A: 255}
+}
+
+/*
+Occupancy returns a width by height heatmap of the bitmap of sketch, split
+into width*height consecutive regions, row by row, each colored by its fill
+rate with Heat.
+*/
+func Occupancy(sketch *pmc.Sketch, width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	words := sketch.Bits()
+	l, cells := uint64(len(words))*64, uint64(width*height)
+	if cells == 0 {
+		return img
+	}
+	for c := uint64(0); c < cells; c++ {
+		// Regions are ranges of whole words when l allows it.
+		from, to := c*l/cells, (c+1)*l/cells
+		ones := 0
+		for i := from; i < to; {
+			if i%64 == 0 && to-i >= 64 {
+				ones += bits.OnesCount64(words[i/64])
+				i += 64
+				continue
+			}
+			ones += int(words[i/64] >> (i % 64) & 1)
+			i++
+		}
+		fill := 0.0
+		if to > from {
+			fill = float64(ones) / float64(to-from)
+		}
+		img.SetRGBA(int(c)%width, int(c)/width, Heat(fill))
+	}
+	return img
+}
+
+/*
+Matrix returns an image of the virtual matrix of flow in sketch, with one
+column per column of the matrix and one line per row, set bits in white and
+clear bits in black.
+*/
+func Matrix(sketch *pmc.Sketch, flow []byte) *image.RGBA {
+	matrix := sketch.VirtualMatrix(flow)
+	img := image.NewRGBA(image.Rect(0, 0, len(matrix[0]), len(matrix)))
+	for y, row := range matrix {
+		for x, set := range row {
+			if set {
+				img.SetRGBA(x, y, Heat(1))
+			} else {
+				img.SetRGBA(x, y, Heat(0))
+			}
+		}
+	}
+	return img
+}
+
+/*
+WriteSVG writes img to w as an SVG image, each pixel scaled to a square of
+scale units. Runs of pixels of the same color are merged into one rectangle.
+*/
+func WriteSVG(w io.Writer, img image.Image, scale int) error {
+	bw := bufio.NewWriter(w)
+	b := img.Bounds()
+	fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" shape-rendering="crispEdges">`+"\n",
+		b.Dx()*scale, b.Dy()*scale)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			run := 1
+			for x+run < b.Max.X && color.RGBAModel.Convert(img.At(x+run, y)) == c {
+				run++
+			}
+			fmt.Fprintf(bw, `<rect x="%d" y="%d" width="%d" height="%d" fill="#%02x%02x%02x"/>`+"\n",
+				(x-b.Min.X)*scale, (y-b.Min.Y)*scale, run*scale, scale, c.R, c.G, c.B)
+			x += run
+		}
+	}
+	fmt.Fprintln(bw, "</svg>")
+	return bw.Flush()
+}
@@ -0,0 +1,61 @@
+package pmcviz
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/seiflotfy/pmc"
+)
+
+func TestOccupancy(t *testing.T) {
+	sketch, _ := pmc.New(1<<16, 64, 32)
+	for i := 0; i < 20000; i++ {
+		sketch.Increment([]byte(fmt.Sprint(i)))
+	}
+	img := Occupancy(sketch, 16, 8)
+	if img.Bounds().Dx() != 16 || img.Bounds().Dy() != 8 {
+		t.Fatal("Expected a 16x8 image, got", img.Bounds())
+	}
+	want := Heat(sketch.GetFillRate() / 100)
+	if got := img.RGBAAt(3, 5); got.G != want.G || got.R < want.R-40 || got.R > want.R+40 {
+		t.Errorf("Expected regions colored like the overall fill rate %v, got %v", want, got)
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+
+	empty, _ := pmc.New(1<<16, 64, 32)
+	if got := Occupancy(empty, 4, 4).RGBAAt(0, 0); got != Heat(0) {
+		t.Error("Expected an empty sketch to be black, got", got)
+	}
+}
+
+func TestMatrix(t *testing.T) {
+	sketch, _ := pmc.New(1<<16, 8, 4)
+	sketch.Add([]byte("flow"), 100)
+	img := Matrix(sketch, []byte("flow"))
+	if img.Bounds().Dx() != 4 || img.Bounds().Dy() != 8 {
+		t.Fatal("Expected a 4x8 image, got", img.Bounds())
+	}
+	matrix := sketch.VirtualMatrix([]byte("flow"))
+	for y, row := range matrix {
+		for x, set := range row {
+			if (img.RGBAAt(x, y) == Heat(1)) != set {
+				t.Fatalf("Expected pixel %d, %d to show bit %v", x, y, set)
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := WriteSVG(&buf, img, 10); err != nil {
+		t.Fatal(err)
+	}
+	svg := buf.String()
+	if !strings.HasPrefix(svg, "<svg") || !strings.Contains(svg, `width="40" height="80"`) {
+		t.Errorf("Expected a 40x80 SVG image, got %q", svg)
+	}
+}
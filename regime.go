@@ -0,0 +1,96 @@
+package pmc
+
+import "fmt"
+
+/*
+DefaultSmallThreshold is the default threshold of WithSmallThreshold.
+*/
+const DefaultSmallThreshold = 0.3
+
+/*
+Regime is the estimator an estimate was obtained with.
+*/
+type Regime int
+
+const (
+	// RegimeNone is the regime of flows none of whose rows has been hit, and
+	// of sketches without additions, estimated as 0.
+	RegimeNone Regime = iota
+	// RegimeSmall is the regime of small multiplicities, estimated from the
+	// empty rows of the flow like linear counting does.
+	RegimeSmall
+	// RegimeZSum is the regime of larger multiplicities, estimated from the
+	// leading runs of set bits of the rows of the flow.
+	RegimeZSum
+	// RegimeMLE is the regime of sketches created WithMLE.
+	RegimeMLE
+)
+
+func (r Regime) String() string {
+	switch r {
+	case RegimeNone:
+		return "none"
+	case RegimeSmall:
+		return "small"
+	case RegimeZSum:
+		return "zsum"
+	case RegimeMLE:
+		return "mle"
+	}
+	return fmt.Sprintf("Regime(%d)", int(r))
+}
+
+/*
+WithSmallThreshold sets the switchover between the two estimators of the
+PMC paper: a flow is estimated from its empty rows when they make up more
+than threshold of its m rows, once corrected for the fill rate, and from
+the leading runs of its rows otherwise. The paper picks 0.3, which suits
+its parameters; the empty rows estimator degrades as their fraction drops,
+so sketches with few rows may want a higher threshold. threshold must be in
+(0, 1), DefaultSmallThreshold by default.
+*/
+func WithSmallThreshold(threshold float64) Option {
+	return func(sketch *Sketch) error {
+		if !(threshold > 0 && threshold < 1) {
+			return fmt.Errorf("Expected 0 < threshold < 1, got %v", threshold)
+		}
+		sketch.small = threshold
+		return nil
+	}
+}
+
+/*
+EstimateDetail is an estimate along with how it was obtained.
+*/
+type EstimateDetail struct {
+	Estimate float64
+	Regime   Regime
+	// EmptyRows is the number of rows of the flow whose first column is
+	// unset, compared against the threshold of WithSmallThreshold.
+	EmptyRows uint
+	// FillRate is the fraction of the bits set, in [0, 1], that the
+	// estimate is corrected for.
+	FillRate float64
+}
+
+/*
+GetEstimateDetailed returns the estimated count of a given flow as
+GetEstimate does, along with the regime it was obtained in.
+*/
+func (sketch *Sketch) GetEstimateDetailed(flow []byte) EstimateDetail {
+	sketch.mu.Lock()
+	n, p := float64(sketch.N()), sketch.getP()
+	key, buf := sketch.key(flow)
+	getPos := sketch.flowPositions(key)
+	e, regime := sketch.estimate(getPos, p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	d := EstimateDetail{Estimate: e, Regime: regime,
+		EmptyRows: uint(sketch.getEmptyRows(getPos)), FillRate: p}
+	releaseKey(buf)
+	sketch.mu.Unlock()
+	if sketch.hooks != nil && sketch.hooks.OnEstimate != nil {
+		sketch.hooks.OnEstimate(flow, e)
+	}
+	return d
+}
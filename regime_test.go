@@ -0,0 +1,47 @@
+package pmc
+
+import "testing"
+
+func TestGetEstimateDetailed(t *testing.T) {
+	s, _ := New(1<<20, 256, 32, WithDeterministic(7))
+	if d := s.GetEstimateDetailed([]byte("flow")); d.Regime != RegimeNone || d.Estimate != 0 {
+		t.Error("Expected no estimate on an empty sketch, got", d)
+	}
+	s.Add([]byte("small"), 50)
+	s.Add([]byte("large"), 50000)
+	small := s.GetEstimateDetailed([]byte("small"))
+	if small.Regime != RegimeSmall || small.EmptyRows < 200 {
+		t.Error("Expected a small multiplicity, got", small)
+	}
+	large := s.GetEstimateDetailed([]byte("large"))
+	if large.Regime != RegimeZSum || large.EmptyRows != 0 {
+		t.Error("Expected the z-sum estimator, got", large)
+	}
+	if e := s.GetEstimate([]byte("large")); e != large.Estimate {
+		t.Errorf("Expected estimate %f, got %f", e, large.Estimate)
+	}
+	if large.Regime.String() != "zsum" {
+		t.Error("Expected zsum, got", large.Regime)
+	}
+}
+
+func TestWithSmallThreshold(t *testing.T) {
+	a, _ := New(1<<20, 256, 32, WithDeterministic(7))
+	b, _ := New(1<<20, 256, 32, WithDeterministic(7), WithSmallThreshold(0.9))
+	a.Add([]byte("flow"), 100)
+	b.Add([]byte("flow"), 100)
+	if d := a.GetEstimateDetailed([]byte("flow")); d.Regime != RegimeSmall {
+		t.Error("Expected a small multiplicity at 0.3, got", d.Regime)
+	}
+	if d := b.GetEstimateDetailed([]byte("flow")); d.Regime != RegimeZSum {
+		t.Error("Expected the z-sum estimator at 0.9, got", d.Regime)
+	}
+	if c := b.Clone(); c.GetEstimateDetailed([]byte("flow")).Regime != RegimeZSum {
+		t.Error("Expected clones to keep the threshold")
+	}
+	for _, threshold := range []float64{0, 1, -0.5} {
+		if _, err := New(1<<10, 16, 32, WithSmallThreshold(threshold)); err == nil {
+			t.Errorf("Expected error for threshold %v, got nil", threshold)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package pmc
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+/*
+Reset clears the sketch, so that it can be reused without reallocating its
+bitmap.
+*/
+func (sketch *Sketch) Reset() {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if sketch.backend != nil {
+		sketch.backend.Reset()
+	}
+	sketch.own()
+	for i := range sketch.bitmap {
+		sketch.bitmap[i] = 0
+	}
+	atomic.StoreUint64(&sketch.n, 0)
+	sketch.ones = 0
+	if sketch.hll != nil {
+		sketch.hll.reset()
+	}
+}
+
+/*
+Clone returns a deep copy of the sketch, e.g. to take a consistent snapshot
+for estimation while additions continue on the original. The copy uses the
+same Hasher, hash seed and hooks, and is thread-safe if the original is. It
+draws from a generator of the same kind as that of the original, seeded from
+it, so that copies don't sample the same cells as each other.
+*/
+func (sketch *Sketch) Clone() *Sketch {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	return sketch.clone()
+}
+
+/*
+NewLike returns an empty sketch with the parameters and options of the
+sketch, on the default bitmap, e.g. to decode into the sketches of peers
+created with the same options, which ReadFrom rejects on a zero Sketch
+unless they use the default Hasher, GeoSampler and direction.
+*/
+func (sketch *Sketch) NewLike() *Sketch {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	c := sketch.like()
+	c.bitmap = newBitArray(c.l)
+	if sketch.hll != nil {
+		c.hll = sketch.hll.clone()
+		c.hll.reset()
+	}
+	c.setDefaults()
+	return c
+}
+
+// like returns a sketch with the parameters and options of the sketch, but
+// without bitmap nor additions.
+func (sketch *Sketch) like() *Sketch {
+	c := &Sketch{l: sketch.l, m: sketch.m, w: sketch.w,
+		hasher: sketch.hasher, sampler: sketch.sampler,
+		hashSeed: sketch.hashSeed, noDrop: sketch.noDrop, mle: sketch.mle,
+		small: sketch.small, reverse: sketch.reverse, hooks: sketch.hooks,
+		lockFree: sketch.lockFree, rnd: sketch.deriveRand()}
+	if _, ok := sketch.mu.(*sync.Mutex); ok {
+		c.mu = &sync.Mutex{}
+	}
+	return c
+}
+
+func (sketch *Sketch) clone() *Sketch {
+	c := sketch.like()
+	c.n = sketch.N()
+	switch {
+	case sketch.backend != nil:
+		c.backend = sketch.backend.Clone()
+		c.ones = sketch.ones
+		if sketch.remote != nil {
+			c.ones = uint64(c.backend.Count())
+		}
+	case sketch.lockFree:
+		// Bits set while copying are counted by the ones of the copy.
+		c.bitmap = sketch.bitmap.cloneAtomic()
+		c.ones = uint64(c.bitmap.count())
+	default:
+		c.bitmap = sketch.bitmap.clone()
+		c.ones = sketch.ones
+	}
+	if sketch.hll != nil {
+		c.hll = sketch.hll.clone()
+	}
+	c.setDefaults()
+	return c
+}
@@ -0,0 +1,67 @@
+package pmc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestReset(t *testing.T) {
+	s, _ := New(1024, 8, 8)
+	s.IncrementN([]byte("flow"), 1000)
+	s.Reset()
+
+	if s.n != 0 || s.bitmap.any() {
+		t.Error("Expected reset sketch to be empty")
+	}
+	if len(s.bitmap) != 16 {
+		t.Error("Expected reset sketch to keep its bitmap, got", len(s.bitmap))
+	}
+}
+
+func TestClone(t *testing.T) {
+	s, _ := New(1024, 8, 8, WithThreadSafety())
+	s.IncrementN([]byte("flow"), 1000)
+
+	c := s.Clone()
+	if c.n != s.n || !c.bitmap.equal(s.bitmap) {
+		t.Error("Expected clone to equal original")
+	}
+	if c.mu == s.mu {
+		t.Error("Expected clone to have its own mutex")
+	}
+
+	s.IncrementN([]byte("other"), 1000)
+	if c.n != 1000 {
+		t.Error("Expected clone to be unaffected by the original, got n ==", c.n)
+	}
+}
+
+func TestCloneRand(t *testing.T) {
+	for name, opt := range map[string]Option{
+		"xorshift": WithSeed(7),
+		"chacha8":  WithCryptoRand(),
+		"lockfree": WithLockFree(),
+	} {
+		s, _ := New(1024, 8, 8, opt)
+		a, b := s.Clone(), s.Clone()
+		if a.next() == b.next() {
+			t.Errorf("%s: expected clones to draw different values", name)
+		}
+		if fmt.Sprintf("%T", a.rnd) != fmt.Sprintf("%T", s.rnd) {
+			t.Errorf("%s: expected a clone generator %T, got %T", name, s.rnd, a.rnd)
+		}
+	}
+	s, _ := New(1024, 8, 8, WithCryptoRand())
+	if !s.Clone().rnd.(*chachaRand).random {
+		t.Error("Expected the clone of a random generator to be random")
+	}
+}
+
+func TestCloneHooks(t *testing.T) {
+	var estimates int
+	s, _ := New(1024, 8, 8, WithHooks(Hooks{OnEstimate: func([]byte, float64) { estimates++ }}))
+	s.Clone().GetEstimate([]byte("flow"))
+	if estimates != 1 {
+		t.Error("Expected the hooks to observe the clone, got", estimates, "estimates")
+	}
+}
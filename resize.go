@@ -0,0 +1,89 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+)
+
+/*
+KeyIterator iterates over flows, e.g. the flows tracked next to a sketch or
+read back from a log. Next returns false once there are no more flows.
+*/
+type KeyIterator interface {
+	Next() (flow []byte, ok bool)
+}
+
+type sliceKeys [][]byte
+
+func (keys *sliceKeys) Next() ([]byte, bool) {
+	if len(*keys) == 0 {
+		return nil, false
+	}
+	flow := (*keys)[0]
+	*keys = (*keys)[1:]
+	return flow, true
+}
+
+/*
+SliceKeys returns a KeyIterator over flows.
+*/
+func SliceKeys(flows [][]byte) KeyIterator {
+	keys := sliceKeys(flows)
+	return &keys
+}
+
+/*
+Rebuild returns a sketch with the new parameters l, m and w, to which the
+estimate in the sketch of every flow from keys, as returned by
+GetEstimateUint64, is added. Only the
+traffic of these flows carries over.
+*/
+func (sketch *Sketch) Rebuild(l, m, w uint, keys KeyIterator, opts ...Option) (*Sketch, error) {
+	rebuilt, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	for flow, ok := keys.Next(); ok; flow, ok = keys.Next() {
+		if est := sketch.GetEstimateUint64(flow, RoundNearest); est > 0 {
+			rebuilt.Add(flow, est)
+		}
+	}
+	return rebuilt, nil
+}
+
+/*
+Fold halves l by ORing the upper half of the bitmap onto its lower half,
+which keeps every flow on the same bits modulo the new l, and so its
+estimate, at the cost of a higher fill rate. It resizes an over-provisioned
+sketch without losing its history; l must be even, and a power of two to be
+folded repeatedly. Fold must not run concurrently with writes to lock-free
+sketches, and isn't supported by memory-mapped sketches and sketches with a
+SharedBackend.
+*/
+func (sketch *Sketch) Fold() error {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if sketch.l%2 != 0 {
+		return fmt.Errorf("Expected an even l to fold, got %d", sketch.l)
+	}
+	if sketch.mapping != nil || sketch.remote != nil {
+		return errors.New("Memory-mapped and shared sketches can't be folded")
+	}
+
+	half := sketch.l / 2
+	folded := newBitArray(half)
+	if sketch.backend == nil && half%64 == 0 {
+		words := half / 64
+		for i := range folded {
+			folded[i] = sketch.bitmap[i] | sketch.bitmap[uint(i)+words]
+		}
+	} else {
+		sketch.forEachSet(func(pos uint) bool {
+			folded.set(pos % half)
+			return true
+		})
+	}
+	sketch.l = half
+	sketch.setBitmap(folded)
+	return nil
+}
@@ -0,0 +1,73 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestRebuild(t *testing.T) {
+	s, _ := New(1<<20, 256, 32, WithHashSeed(1))
+	var flows [][]byte
+	for i := 0; i < 20; i++ {
+		flow := []byte(fmt.Sprint("flow", i))
+		s.Add(flow, uint64(1000*(i+1)))
+		flows = append(flows, flow)
+	}
+
+	rebuilt, err := s.Rebuild(1<<22, 128, 32, SliceKeys(flows), WithHashSeed(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rebuilt.l != 1<<22 || rebuilt.m != 128 {
+		t.Error("Expected the new parameters")
+	}
+	want := s.GetEstimate(flows[19])
+	if est := rebuilt.GetEstimate(flows[19]); math.Abs(est-want)/want > 0.2 {
+		t.Errorf("Expected an estimate close to %f, got %f", want, est)
+	}
+	if _, err := s.Rebuild(0, 128, 32, SliceKeys(flows)); err == nil {
+		t.Error("Expected an error for l == 0")
+	}
+}
+
+func TestFold(t *testing.T) {
+	for _, backend := range []bool{false, true} {
+		var opts []Option
+		if backend {
+			opts = append(opts, WithBitmapBackend(mapBackend{}))
+		}
+		s, _ := New(1<<22, 256, 32, opts...)
+		for i := 0; i < 1000; i++ {
+			s.Increment([]byte(fmt.Sprint(i)))
+		}
+		s.Add([]byte("heavy"), 20000)
+		before := s.Bits()
+
+		if err := s.Fold(); err != nil {
+			t.Fatal(err)
+		}
+		if s.l != 1<<21 {
+			t.Fatal("Expected l to be halved, got", s.l)
+		}
+		after := s.Bits()
+		half := len(before) / 2
+		for i := range after {
+			if after[i] != before[i]|before[i+half] {
+				t.Fatal("Expected the halves of the bitmap to be ORed")
+			}
+		}
+		if ones := uint64(bitArray(after).count()); s.ones != ones {
+			t.Errorf("Expected %d set bits, got %d", ones, s.ones)
+		}
+		est := s.GetEstimate([]byte("heavy"))
+		if math.Abs(est-20000)/20000 > 0.2 {
+			t.Errorf("Expected an estimate close to 20000 after folding, got %f", est)
+		}
+	}
+
+	odd, _ := New(1001, 8, 8)
+	if err := odd.Fold(); err == nil {
+		t.Error("Expected an error for an odd l")
+	}
+}
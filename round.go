@@ -0,0 +1,58 @@
+package pmc
+
+import "math"
+
+/*
+Rounding is the rounding of GetEstimateUint64.
+*/
+type Rounding int
+
+const (
+	// RoundNearest rounds estimates half away from zero.
+	RoundNearest Rounding = iota
+	// RoundDown truncates estimates.
+	RoundDown
+	// RoundUp rounds estimates up.
+	RoundUp
+)
+
+/*
+GetEstimateUint64 returns the estimated count of a given flow as an integer,
+rounded with rounding. Unlike GetEstimate, which returns the absolute value
+of negative estimates, a flow whose estimate comes out negative after the
+noise of the other flows is accounted for gets 0, as do flows none of whose
+rows has been hit; any other flow has been seen and gets at least 1.
+Estimates beyond the range of a uint64 are clamped to math.MaxUint64.
+*/
+func (sketch *Sketch) GetEstimateUint64(flow []byte, rounding Rounding) uint64 {
+	sketch.mu.Lock()
+	n, p := float64(sketch.N()), sketch.getP()
+	key, buf := sketch.key(flow)
+	e, regime := sketch.estimateSigned(sketch.flowPositions(key), p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	releaseKey(buf)
+	sketch.mu.Unlock()
+	if sketch.hooks != nil && sketch.hooks.OnEstimate != nil {
+		sketch.hooks.OnEstimate(flow, math.Abs(e))
+	}
+	if regime == RegimeNone || !(e > 0) {
+		return 0
+	}
+
+	switch rounding {
+	case RoundDown:
+		e = math.Floor(e)
+	case RoundUp:
+		e = math.Ceil(e)
+	default:
+		e = math.Round(e)
+	}
+	switch {
+	case e < 1:
+		return 1
+	case e >= math.MaxUint64:
+		return math.MaxUint64
+	}
+	return uint64(e)
+}
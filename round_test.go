@@ -0,0 +1,39 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetEstimateUint64(t *testing.T) {
+	s, _ := New(1<<16, 64, 32, WithDeterministic(7))
+	if e := s.GetEstimateUint64([]byte("flow"), RoundNearest); e != 0 {
+		t.Error("Expected 0 on an empty sketch, got", e)
+	}
+	s.Add([]byte("flow"), 1000)
+	e := s.GetEstimate([]byte("flow"))
+	for rounding, want := range map[Rounding]float64{
+		RoundNearest: math.Round(e), RoundDown: math.Floor(e), RoundUp: math.Ceil(e),
+	} {
+		if got := s.GetEstimateUint64([]byte("flow"), rounding); float64(got) != want {
+			t.Errorf("Expected %v with rounding %d, got %d", want, rounding, got)
+		}
+	}
+
+	// Absent flows of a half full sketch have about as many empty rows as the
+	// noise predicts, and so negative estimates half of the time.
+	for i := 0; s.GetFillRate() < 50; i++ {
+		s.Add([]byte(fmt.Sprint("noise-", i)), 500)
+	}
+	negative := 0
+	for i := 0; i < 100; i++ {
+		flow := []byte(fmt.Sprint("absent-", i))
+		if got := s.GetEstimateUint64(flow, RoundNearest); got == 0 && s.GetEstimate(flow) > 1 {
+			negative++
+		}
+	}
+	if negative == 0 {
+		t.Error("Expected negative estimates to be clamped to 0")
+	}
+}
@@ -0,0 +1,232 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/dgryski/go-farm"
+)
+
+// vnode is a point of the ring of a Router, owned by the shard name.
+type vnode struct {
+	hash uint64
+	name string
+}
+
+/*
+Router spreads flows across named sketches by consistent hashing, e.g. to
+parallelize ingestion or to assign flows to the nodes of a cluster: each
+shard owns replicas points of a ring of 64-bit hashes, and a flow belongs to
+the shard owning the first point at or after its hash. The ring only depends
+on the names of the shards, so that all nodes agree on the owner of a flow.
+
+When shards are added or removed, only the flows of the ranges of the ring
+that change hands move, and the history of these flows moves along by
+merging the previous owner of each range into the new one. Merged sketches
+carry the bits of all flows of the previous owners, not only of those that
+moved, so rebalancing raises the fill rate of the new owners. All shards
+must therefore be compatible, see Compatible.
+
+A Router is safe for concurrent use, its sketches should be created
+WithThreadSafety if it is used concurrently.
+*/
+type Router struct {
+	mu       sync.RWMutex
+	replicas int
+	ring     []vnode
+	shards   map[string]*Sketch
+}
+
+/*
+NewRouter returns an empty Router placing replicas points per shard on the
+ring. More points spread flows more evenly: the share of a shard deviates
+from its fair share by about 1/sqrt(replicas).
+*/
+func NewRouter(replicas int) (*Router, error) {
+	if replicas <= 0 {
+		return nil, fmt.Errorf("Expected replicas > 0, got %d", replicas)
+	}
+	return &Router{replicas: replicas, shards: make(map[string]*Sketch)}, nil
+}
+
+// points returns the hashes of the points of the shard name.
+func (r *Router) points(name string) []uint64 {
+	points := make([]uint64, r.replicas)
+	for i := range points {
+		points[i] = farm.Hash64([]byte(name + "#" + strconv.Itoa(i)))
+	}
+	return points
+}
+
+// owner returns the shard owning hash h, skipping the points of skip.
+func (r *Router) owner(h uint64, skip string) string {
+	i := sort.Search(len(r.ring), func(i int) bool { return r.ring[i].hash >= h })
+	for k := 0; k < len(r.ring); k++ {
+		v := r.ring[(i+k)%len(r.ring)]
+		if v.name != skip {
+			return v.name
+		}
+	}
+	return ""
+}
+
+/*
+AddShard adds sketch as the shard name, into which the shards it takes
+ranges of the ring from are merged. It fails with ErrIncompatibleParams if
+sketch isn't compatible with the other shards, and if name is already used.
+*/
+func (r *Router) AddShard(name string, sketch *Sketch) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.shards[name]; ok {
+		return fmt.Errorf("Expected a new shard name, got %q", name)
+	}
+	for _, other := range r.shards {
+		if err := sketch.checkCompatible(other); err != nil {
+			return err
+		}
+	}
+	donors := map[string]bool{}
+	for _, h := range r.points(name) {
+		if donor := r.owner(h, name); donor != "" {
+			donors[donor] = true
+		}
+	}
+	var others []*Sketch
+	for donor := range donors {
+		others = append(others, r.shards[donor])
+	}
+	if err := sketch.MergeAll(others...); err != nil {
+		return err
+	}
+
+	r.shards[name] = sketch
+	for _, h := range r.points(name) {
+		r.ring = append(r.ring, vnode{hash: h, name: name})
+	}
+	sort.Slice(r.ring, func(i, j int) bool {
+		if r.ring[i].hash != r.ring[j].hash {
+			return r.ring[i].hash < r.ring[j].hash
+		}
+		return r.ring[i].name < r.ring[j].name
+	})
+	return nil
+}
+
+/*
+RemoveShard removes the shard name, merging it into the shards taking over
+its ranges of the ring, and returns its sketch.
+*/
+func (r *Router) RemoveShard(name string) (*Sketch, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	sketch, ok := r.shards[name]
+	if !ok {
+		return nil, fmt.Errorf("Expected an existing shard, got %q", name)
+	}
+	heirs := map[string]bool{}
+	for _, h := range r.points(name) {
+		if heir := r.owner(h, name); heir != "" {
+			heirs[heir] = true
+		}
+	}
+	for heir := range heirs {
+		if err := r.shards[heir].Merge(sketch); err != nil {
+			return nil, err
+		}
+	}
+
+	delete(r.shards, name)
+	ring := r.ring[:0]
+	for _, v := range r.ring {
+		if v.name != name {
+			ring = append(ring, v)
+		}
+	}
+	r.ring = ring
+	return sketch, nil
+}
+
+/*
+ErrNoShards is returned by Router.Shard when the Router has no shards.
+*/
+var ErrNoShards = errors.New("Router has no shards")
+
+/*
+Owner returns the name of the shard flow belongs to, or "" if there are no
+shards.
+*/
+func (r *Router) Owner(flow []byte) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.owner(farm.Hash64(flow), "")
+}
+
+/*
+Shard returns the sketch of the shard flow belongs to.
+*/
+func (r *Router) Shard(flow []byte) (*Sketch, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.shard(flow)
+}
+
+func (r *Router) shard(flow []byte) (*Sketch, error) {
+	if len(r.ring) == 0 {
+		return nil, ErrNoShards
+	}
+	return r.shards[r.owner(farm.Hash64(flow), "")], nil
+}
+
+/*
+Increment the count of the flow by 1 in its shard
+*/
+func (r *Router) Increment(flow []byte) error {
+	return r.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow in its shard, see Sketch.Add.
+*/
+func (r *Router) Add(flow []byte, weight uint64) error {
+	// Shards aren't merged away during the addition.
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sketch, err := r.shard(flow)
+	if err != nil {
+		return err
+	}
+	sketch.Add(flow, weight)
+	return nil
+}
+
+/*
+GetEstimate returns the estimated count of the flow in its shard, or 0 if
+there are no shards.
+*/
+func (r *Router) GetEstimate(flow []byte) float64 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	sketch, err := r.shard(flow)
+	if err != nil {
+		return 0
+	}
+	return sketch.GetEstimate(flow)
+}
+
+/*
+Shards returns the names of the shards, in lexical order.
+*/
+func (r *Router) Shards() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.shards))
+	for name := range r.shards {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
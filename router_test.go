@@ -0,0 +1,101 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+)
+
+func newRouterShard(seed uint64) *Sketch {
+	s, _ := New(1<<20, 128, 32, WithSeed(seed), WithHashSeed(1))
+	return s
+}
+
+func TestRouter(t *testing.T) {
+	r, err := NewRouter(100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Increment([]byte("flow")); err != ErrNoShards {
+		t.Error("Expected ErrNoShards, got", err)
+	}
+	for i, name := range []string{"a", "b", "c"} {
+		if err := r.AddShard(name, newRouterShard(uint64(i+1))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := r.Shards(); !reflect.DeepEqual(names, []string{"a", "b", "c"}) {
+		t.Error("Expected 3 shards, got", names)
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 3000; i++ {
+		flow := []byte(fmt.Sprint("flow-", i))
+		r.Add(flow, 100)
+		counts[r.Owner(flow)]++
+	}
+	for name, c := range counts {
+		if c < 700 || c > 1300 {
+			t.Errorf("Expected about 1000 flows in shard %s, got %d", name, c)
+		}
+	}
+	if e := r.GetEstimate([]byte("flow-7")); math.Abs(e-100) > 30 {
+		t.Error("Expected estimate within 30% of 100, got", e)
+	}
+
+	if err := r.AddShard("a", newRouterShard(9)); err == nil {
+		t.Error("Expected error for a duplicate shard, got nil")
+	}
+	other, _ := New(1<<20, 128, 32, WithHashSeed(2))
+	if err := r.AddShard("d", other); !errors.Is(err, ErrIncompatibleParams) {
+		t.Error("Expected ErrIncompatibleParams, got", err)
+	}
+}
+
+func TestRouterRebalance(t *testing.T) {
+	r, _ := NewRouter(100)
+	r.AddShard("a", newRouterShard(1))
+	r.AddShard("b", newRouterShard(2))
+	flows := make([][]byte, 200)
+	for i := range flows {
+		flows[i] = []byte(fmt.Sprint("flow-", i))
+		r.Add(flows[i], 1000)
+	}
+
+	check := func(step string) {
+		for _, flow := range flows {
+			if e := r.GetEstimate(flow); math.Abs(e-1000) > 250 {
+				t.Errorf("Expected estimate within 25%% of 1000 after %s, got %f", step, e)
+				return
+			}
+		}
+	}
+	before := map[string]string{}
+	for _, flow := range flows {
+		before[string(flow)] = r.Owner(flow)
+	}
+	r.AddShard("c", newRouterShard(3))
+	moved := 0
+	for _, flow := range flows {
+		if owner := r.Owner(flow); owner != before[string(flow)] {
+			if owner != "c" {
+				t.Errorf("Expected flows to only move to the new shard, got %s", owner)
+			}
+			moved++
+		}
+	}
+	if moved == 0 || moved > 120 {
+		t.Error("Expected about a third of the flows to move, got", moved)
+	}
+	check("adding a shard")
+
+	if _, err := r.RemoveShard("a"); err != nil {
+		t.Fatal(err)
+	}
+	check("removing a shard")
+	if _, err := r.RemoveShard("a"); err == nil {
+		t.Error("Expected error removing a missing shard, got nil")
+	}
+}
@@ -0,0 +1,96 @@
+package pmc
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+RowFillRates returns the fraction of the w bits set of each of the m rows of
+the virtual matrix of flow. The rows of a flow are hit evenly, so rates
+varying widely between rows point at a key or a hash concentrating the
+flow on few rows.
+*/
+func (sketch *Sketch) RowFillRates(flow []byte) []float64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	rates := make([]float64, sketch.m)
+	key, buf := sketch.key(flow)
+	sketch.rowFill(sketch.flowPositions(key), rates)
+	releaseKey(buf)
+	for i := range rates {
+		rates[i] /= float64(sketch.w)
+	}
+	return rates
+}
+
+// rowFill adds the number of set bits of each row of the flow at getPos to
+// counts.
+func (sketch *Sketch) rowFill(getPos positions, counts []float64) {
+	for i := uint(0); i < sketch.m; i++ {
+		for j := uint(0); j < sketch.w; j++ {
+			if sketch.test(getPos(i, j)) {
+				counts[i]++
+			}
+		}
+	}
+}
+
+/*
+GlobalRowFillRates returns RowFillRates averaged over probes flows never
+added, for which each rate is expected to be the fill rate of the sketch:
+a row whose rate stands out is one whose positions the hash doesn't spread
+over the whole bitmap.
+*/
+func (sketch *Sketch) GlobalRowFillRates(probes int) ([]float64, error) {
+	if probes <= 0 {
+		return nil, fmt.Errorf("Expected probes > 0, got %d", probes)
+	}
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	rates := make([]float64, sketch.m)
+	probe := make([]byte, 0, 32)
+	for k := 0; k < probes; k++ {
+		probe = strconv.AppendInt(append(probe[:0], "\x00pmc-probe-"...), int64(k), 10)
+		key, buf := sketch.key(probe)
+		sketch.rowFill(sketch.flowPositions(key), rates)
+		releaseKey(buf)
+	}
+	for i := range rates {
+		rates[i] /= float64(probes) * float64(sketch.w)
+	}
+	return rates, nil
+}
+
+/*
+RegionFillRates returns the fraction of the bits set of each of regions
+equal slices of the bitmap, the last one taking the remainder. All regions
+are expected to be filled alike, uneven rates pointing at a hash not
+spreading the flows over the whole bitmap.
+*/
+func (sketch *Sketch) RegionFillRates(regions int) ([]float64, error) {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if regions <= 0 || uint(regions) > sketch.l {
+		return nil, fmt.Errorf("Expected regions in [1, %d], got %d", sketch.l, regions)
+	}
+	size := sketch.l / uint(regions)
+	rates := make([]float64, regions)
+	if sketch.backend != nil {
+		sketch.forEachSet(func(pos uint) bool {
+			rates[min(pos/size, uint(regions-1))]++
+			return true
+		})
+	}
+	for r := range rates {
+		from, to := uint(r)*size, uint(r+1)*size
+		if r == regions-1 {
+			to = sketch.l
+		}
+		if sketch.backend == nil {
+			rates[r] = float64(sketch.bitmap.countRange(from, to))
+		}
+		rates[r] /= float64(to - from)
+	}
+	return rates, nil
+}
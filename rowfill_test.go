@@ -0,0 +1,98 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+// badHasher ignores the row seed, so all the rows of a flow share their
+// positions.
+type badHasher struct{}
+
+func (badHasher) Hash(flow []byte, i, j uint64) uint64 {
+	return FarmHasher{}.Hash(flow, 0, j)
+}
+
+func TestRowFillRates(t *testing.T) {
+	s, _ := New(1<<16, 32, 32, WithHashSeed(5))
+	for k := 0; k < 20000; k++ {
+		s.Increment([]byte(fmt.Sprintf("flow-%d", k%2000)))
+	}
+	s.Add([]byte("heavy"), 1<<20)
+	rates := s.RowFillRates([]byte("heavy"))
+	if len(rates) != 32 {
+		t.Fatalf("Expected 32 rows, got %d", len(rates))
+	}
+	for i, r := range rates {
+		if r < 0.5 {
+			t.Errorf("Expected row %d of a heavy flow to be mostly set, got %v", i, r)
+		}
+	}
+
+	p := s.getP()
+	global, err := s.GlobalRowFillRates(200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, r := range global {
+		if math.Abs(r-p) > 0.05 {
+			t.Errorf("Expected row %d to be filled about %v, got %v", i, p, r)
+		}
+	}
+	regions, err := s.RegionFillRates(10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for r, rate := range regions {
+		if math.Abs(rate-p) > 0.05 {
+			t.Errorf("Expected region %d to be filled about %v, got %v", r, p, rate)
+		}
+	}
+	if _, err := s.RegionFillRates(0); err == nil {
+		t.Error("Expected an error for 0 regions")
+	}
+	if _, err := s.GlobalRowFillRates(0); err == nil {
+		t.Error("Expected an error for 0 probes")
+	}
+}
+
+func TestRowFillRatesBadHash(t *testing.T) {
+	s, _ := New(1<<16, 32, 32, WithHash(badHasher{}), WithHashSeed(5))
+	s.Add([]byte("flow"), 1000)
+	// Every row of the flow is the same row.
+	rates := s.RowFillRates([]byte("flow"))
+	for i, r := range rates {
+		if r != rates[0] {
+			t.Errorf("Expected row %d to be filled like row 0 with a bad hash, got %v and %v", i, r, rates[0])
+		}
+	}
+}
+
+func TestRegionFillRatesBackend(t *testing.T) {
+	s, _ := New(1000, 8, 8, WithHashSeed(5))
+	b, _ := New(1000, 8, 8, WithHashSeed(5), WithBitmapBackend(mapBackend{}))
+	for k := 0; k < 300; k++ {
+		pos := s.getPos([]byte(fmt.Sprintf("flow-%d", k)), uint(k%8), 0)
+		s.setBit(pos)
+		b.setBit(pos)
+	}
+	for _, regions := range []int{1, 3, 7, 64, 1000} {
+		dense, _ := s.RegionFillRates(regions)
+		sparse, _ := b.RegionFillRates(regions)
+		for r := range dense {
+			if dense[r] != sparse[r] {
+				t.Fatalf("Expected region %d of %d to be filled like the backend, got %v and %v",
+					r, regions, dense[r], sparse[r])
+			}
+		}
+	}
+}
+
+func BenchmarkRegionFillRates(b *testing.B) {
+	s, _ := New(1<<26, 64, 32)
+	s.IncrementN([]byte("flow"), 1000000)
+	for i := 0; i < b.N; i++ {
+		s.RegionFillRates(64)
+	}
+}
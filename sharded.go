@@ -0,0 +1,170 @@
+package pmc
+
+import (
+	"errors"
+	"math/rand/v2"
+	"runtime"
+	"sync"
+)
+
+// shard is a sub-sketch of a ShardedSketch, padded so that the locks of
+// neighbouring shards don't share a cache line.
+type shard struct {
+	mu     sync.Mutex
+	sketch *Sketch
+	dirty  bool
+	_      [64]byte
+}
+
+/*
+ShardedSketch spreads additions over several sub-sketches to avoid the
+contention of concurrent writers on a single one. Each addition goes to
+whichever shard is free, and estimates are computed on the union of the
+shards, which is updated lazily with the shards written since the previous
+estimate. A ShardedSketch is safe for concurrent use.
+*/
+type ShardedSketch struct {
+	shards []shard
+
+	mu     sync.Mutex
+	merged *Sketch
+}
+
+/*
+NewSharded returns a ShardedSketch of the given number of shards, each a
+sketch created by New with l, m, w and opts; 0 shards picks one per
+GOMAXPROCS.
+*/
+func NewSharded(l, m, w uint, shards int, opts ...Option) (*ShardedSketch, error) {
+	if shards < 0 {
+		return nil, errors.New("Expected shards >= 0")
+	}
+	if shards == 0 {
+		shards = runtime.GOMAXPROCS(0)
+	}
+	first, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	ss := &ShardedSketch{shards: make([]shard, shards)}
+	ss.shards[0].sketch = first
+	for i := 1; i < shards; i++ {
+		if ss.shards[i].sketch, err = newSibling(first, l, opts); err != nil {
+			return nil, err
+		}
+	}
+	ss.merged = first.clone()
+	return ss, nil
+}
+
+// lock returns a locked shard, preferring one that isn't in use. Shards are
+// tried in turn from a random one, drawn from the per-thread generator of the
+// runtime, so that writers share no state but the shards themselves.
+func (ss *ShardedSketch) lock() *shard {
+	start := rand.IntN(len(ss.shards))
+	for i := range ss.shards {
+		s := &ss.shards[(start+i)%len(ss.shards)]
+		if s.mu.TryLock() {
+			return s
+		}
+	}
+	s := &ss.shards[start]
+	s.mu.Lock()
+	return s
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (ss *ShardedSketch) Increment(flow []byte) {
+	s := ss.lock()
+	s.sketch.increment(flow)
+	s.dirty = true
+	s.mu.Unlock()
+	if s.sketch.hooks != nil {
+		s.sketch.incremented(flow, 1)
+	}
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (ss *ShardedSketch) Add(flow []byte, weight uint64) {
+	s := ss.lock()
+	s.sketch.incrementN(flow, weight)
+	s.dirty = true
+	s.mu.Unlock()
+	if s.sketch.hooks != nil {
+		s.sketch.incremented(flow, weight)
+	}
+}
+
+// union merges the shards written since the last call into merged. Bits are
+// never cleared but by Reset, so merged stays a superset of the shards.
+func (ss *ShardedSketch) union() *Sketch {
+	var n uint64
+	changed := false
+	for i := range ss.shards {
+		s := &ss.shards[i]
+		s.mu.Lock()
+		if s.dirty {
+			ss.merged.union(s.sketch)
+			s.dirty = false
+			changed = true
+		}
+		n += s.sketch.N()
+		s.mu.Unlock()
+	}
+	if changed {
+		ss.merged.recount()
+		ss.merged.n = n
+	}
+	return ss.merged
+}
+
+/*
+GetEstimate returns the estimated count of a given flow
+*/
+func (ss *ShardedSketch) GetEstimate(flow []byte) float64 {
+	ss.mu.Lock()
+	e := ss.union().getEstimate(flow)
+	ss.mu.Unlock()
+	ss.shards[0].sketch.estimated(flow, e)
+	return e
+}
+
+/*
+N returns the number of additions of all shards.
+*/
+func (ss *ShardedSketch) N() uint64 {
+	var n uint64
+	for i := range ss.shards {
+		n += ss.shards[i].sketch.N()
+	}
+	return n
+}
+
+/*
+Merged returns a new sketch holding the union of the shards.
+*/
+func (ss *ShardedSketch) Merged() *Sketch {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	return ss.union().Clone()
+}
+
+/*
+Reset clears all shards.
+*/
+func (ss *ShardedSketch) Reset() {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+	for i := range ss.shards {
+		s := &ss.shards[i]
+		s.mu.Lock()
+		s.sketch.Reset()
+		s.dirty = false
+		s.mu.Unlock()
+	}
+	ss.merged.Reset()
+}
@@ -0,0 +1,64 @@
+package pmc
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+func TestShardedSketch(t *testing.T) {
+	ss, err := NewSharded(1<<22, 256, 32, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewSharded(1<<22, 256, 32, -1); err == nil {
+		t.Error("Expected error for negative shards, got nil")
+	}
+
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 2500; i++ {
+				ss.Increment([]byte("flow"))
+			}
+		}()
+	}
+	// Estimates are computed concurrently with the additions.
+	ss.GetEstimate([]byte("flow"))
+	wg.Wait()
+
+	if ss.N() != 20000 {
+		t.Error("Expected N() == 20000, got", ss.N())
+	}
+	est := ss.GetEstimate([]byte("flow"))
+	if fErr := math.Abs(100 * (1 - est/20000)); fErr > 15 {
+		t.Errorf("Expected estimate within 15%% of 20000, got %f", est)
+	}
+	if merged := ss.Merged(); merged.GetEstimate([]byte("flow")) != est {
+		t.Error("Expected the merged sketch to estimate like the sharded one")
+	}
+
+	ss.Add([]byte("flow"), 10000)
+	if est := ss.GetEstimate([]byte("flow")); math.Abs(100*(1-est/30000)) > 15 {
+		t.Errorf("Expected estimate within 15%% of 30000, got %f", est)
+	}
+
+	ss.Reset()
+	if est := ss.GetEstimate([]byte("flow")); est != 0 || ss.N() != 0 {
+		t.Error("Expected an empty sketch after Reset, got", est)
+	}
+}
+
+// BenchmarkShardedIncrement measures concurrent writers, run it with e.g.
+// -cpu 1,4,16 to see how the shards scale.
+func BenchmarkShardedIncrement(b *testing.B) {
+	ss, _ := NewSharded(1<<22, 256, 32, 0)
+	flow := []byte("flow")
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			ss.Increment(flow)
+		}
+	})
+}
@@ -0,0 +1,28 @@
+package pmc
+
+/*
+TotalEstimate returns the total count of all flows, which is the number of
+additions, as the denominator of GetShare.
+*/
+func (sketch *Sketch) TotalEstimate() float64 {
+	return float64(sketch.N())
+}
+
+/*
+GetShare returns the estimated fraction of all additions that went to flow,
+in [0, 1]. The estimate of a flow can overshoot the total count on small
+sketches, so it is capped at 1.
+*/
+func (sketch *Sketch) GetShare(flow []byte) float64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	n := sketch.TotalEstimate()
+	if n == 0 {
+		return 0
+	}
+	share := sketch.getEstimate(flow) / n
+	if share > 1 {
+		return 1
+	}
+	return share
+}
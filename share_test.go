@@ -0,0 +1,30 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestGetShare(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	if s.GetShare([]byte("heavy")) != 0 {
+		t.Error("Expected a share of 0 in an empty sketch")
+	}
+	for i := 0; i < 30000; i++ {
+		s.Increment([]byte(fmt.Sprint(i)))
+	}
+	s.Add([]byte("heavy"), 10000)
+	if s.TotalEstimate() != 40000 {
+		t.Error("Expected a total of 40000, got", s.TotalEstimate())
+	}
+	if share := s.GetShare([]byte("heavy")); math.Abs(share-0.25) > 0.05 {
+		t.Errorf("Expected a share close to 0.25, got %f", share)
+	}
+
+	tiny, _ := New(64, 8, 8)
+	tiny.Add([]byte("flow"), 1000)
+	if share := tiny.GetShare([]byte("flow")); share > 1 {
+		t.Errorf("Expected a share of at most 1, got %f", share)
+	}
+}
@@ -0,0 +1,72 @@
+package pmc
+
+import (
+	"math"
+	"math/bits"
+)
+
+// words returns the bitmap words, expanded from the backend if any.
+func (sketch *Sketch) words() []uint64 {
+	if sketch.backend != nil {
+		return sketch.Bits()
+	}
+	return sketch.bitmap
+}
+
+// overlap returns the number of bits set in both a and b, in a, and in b.
+func overlap(a, b *Sketch) (inter, onesA, onesB float64, err error) {
+	if err := a.checkCompatible(b); err != nil {
+		return 0, 0, 0, err
+	}
+	wa, wb := a.words(), b.words()
+	var i, na, nb int
+	for k := range wa {
+		i += bits.OnesCount64(wa[k] & wb[k])
+		na += bits.OnesCount64(wa[k])
+		nb += bits.OnesCount64(wb[k])
+	}
+	if float64(na) > saturationFill*float64(a.l) || float64(nb) > saturationFill*float64(b.l) {
+		return 0, 0, 0, ErrSaturated
+	}
+	return float64(i), float64(na), float64(nb), nil
+}
+
+// similarity returns shared/total, discounting from both the bits expected
+// to be set in both sketches by chance if their traffic were unrelated.
+func similarity(shared, total, chance float64) float64 {
+	if total <= chance {
+		return 0
+	}
+	return math.Max(0, math.Min(1, (shared-chance)/(total-chance)))
+}
+
+/*
+Jaccard estimates how similar the traffic mixes counted by two sketches are,
+from 0 for unrelated traffic to 1 for the same traffic, e.g. to detect that
+two links carry the same attack. It is the Jaccard index of the bitmaps,
+corrected for the bits both would share by chance. Both sketches must have
+the same l, m, w and hash seed, and must not be modified meanwhile; it fails
+with ErrIncompatibleParams otherwise, and with ErrSaturated if either is
+saturated, when the correction for chance is dominated by noise.
+*/
+func Jaccard(a, b *Sketch) (float64, error) {
+	inter, onesA, onesB, err := overlap(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return similarity(inter, onesA+onesB-inter, onesA*onesB/float64(a.l)), nil
+}
+
+/*
+Overlap estimates which part of the traffic of the sketch with the fewest
+set bits is also seen by the other, from 0 to 1, e.g. when one link carries
+a subset of the traffic of another. It is the overlap coefficient of the
+bitmaps, corrected for chance like Jaccard.
+*/
+func Overlap(a, b *Sketch) (float64, error) {
+	inter, onesA, onesB, err := overlap(a, b)
+	if err != nil {
+		return 0, err
+	}
+	return similarity(inter, math.Min(onesA, onesB), onesA*onesB/float64(a.l)), nil
+}
@@ -0,0 +1,47 @@
+package pmc
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestJaccard(t *testing.T) {
+	newSketch := func(seed uint64) *Sketch {
+		s, _ := New(1<<20, 256, 32, WithSeed(seed), WithHashSeed(42))
+		return s
+	}
+	feed := func(s *Sketch, prefix string, flows int) {
+		for i := 0; i < flows; i++ {
+			s.Add([]byte(fmt.Sprint(prefix, i)), 5000)
+		}
+	}
+
+	a, b, c := newSketch(1), newSketch(2), newSketch(3)
+	feed(a, "attack", 100)
+	feed(b, "attack", 100)
+	feed(c, "other", 100)
+	same, err := Jaccard(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	unrelated, _ := Jaccard(a, c)
+	if same < 0.5 || unrelated > 0.05 {
+		t.Errorf("Expected a high similarity for the same traffic and a low one otherwise, got %f and %f", same, unrelated)
+	}
+	if self, _ := Jaccard(a, a); self != 1 {
+		t.Error("Expected a sketch to be identical to itself, got", self)
+	}
+
+	subset := newSketch(4)
+	feed(subset, "attack", 20)
+	partial, _ := Jaccard(a, subset)
+	contained, _ := Overlap(a, subset)
+	if contained < 0.6 || contained <= partial {
+		t.Errorf("Expected a subset to overlap more than it's similar, got %f and %f", contained, partial)
+	}
+
+	d, _ := New(1<<19, 256, 32, WithHashSeed(42))
+	if _, err := Jaccard(a, d); err == nil {
+		t.Error("Expected error for different parameters, got nil")
+	}
+}
@@ -0,0 +1,93 @@
+package pmc
+
+import "sync"
+
+/*
+Snapshot is an immutable view of a sketch at the time Sketch.Snapshot was
+called. It is safe for concurrent use.
+*/
+type Snapshot struct {
+	sketch *Sketch
+}
+
+/*
+Snapshot returns an immutable view of the sketch, so that estimates can be
+computed on a stable bitmap while additions continue, without the fill rate,
+empty rows and leading runs of a flow being read from different states.
+Taking a snapshot doesn't copy the bitmap: the snapshot shares it with the
+sketch until the sketch is next written to, which copies it first. Lock-free
+sketches, whose writers can't be made to copy, and sketches with a
+BitmapBackend or a memory-mapped bitmap are copied right away.
+*/
+func (sketch *Sketch) Snapshot() *Snapshot {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	if sketch.lockFree || sketch.backend != nil || sketch.mapping != nil {
+		c := sketch.clone()
+		c.lockFree, c.mu = false, &sync.Mutex{}
+		return &Snapshot{sketch: c}
+	}
+	sketch.shared = true
+	return &Snapshot{sketch: &Sketch{l: sketch.l, m: sketch.m, w: sketch.w,
+		bitmap: sketch.bitmap, n: sketch.N(), ones: sketch.ones,
+		hasher: sketch.hasher, sampler: sketch.sampler, hashSeed: sketch.hashSeed,
+		noDrop: sketch.noDrop, mle: sketch.mle, small: sketch.small, reverse: sketch.reverse,
+		mu: &sync.Mutex{}, shared: true}}
+}
+
+// own copies the bitmap before it's written to if it's shared with a
+// snapshot.
+func (sketch *Sketch) own() {
+	if sketch.shared {
+		sketch.bitmap = sketch.bitmap.clone()
+		sketch.shared = false
+	}
+}
+
+/*
+GetEstimate returns the estimated count of a given flow, see
+Sketch.GetEstimate.
+*/
+func (s *Snapshot) GetEstimate(flow []byte) float64 {
+	return s.sketch.GetEstimate(flow)
+}
+
+/*
+GetEstimateWithError returns the estimated count of a given flow and its
+standard error, see Sketch.GetEstimateWithError.
+*/
+func (s *Snapshot) GetEstimateWithError(flow []byte) (est, stderr float64) {
+	return s.sketch.GetEstimateWithError(flow)
+}
+
+/*
+GetEstimates returns the estimated counts of the given flows, see
+Sketch.GetEstimates.
+*/
+func (s *Snapshot) GetEstimates(flows [][]byte) []float64 {
+	return s.sketch.GetEstimates(flows)
+}
+
+/*
+GetFillRate returns the percentage of set bits of the snapshot.
+*/
+func (s *Snapshot) GetFillRate() float64 {
+	return s.sketch.GetFillRate()
+}
+
+/*
+N returns the number of additions of the snapshot.
+*/
+func (s *Snapshot) N() uint64 {
+	return s.sketch.N()
+}
+
+/*
+Sketch returns a new sketch in the state of the snapshot, e.g. to persist
+it.
+*/
+func (s *Snapshot) Sketch() *Sketch {
+	c := s.sketch.Clone()
+	c.mu = nopLocker{}
+	return c
+}
@@ -0,0 +1,50 @@
+package pmc
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestSnapshot(t *testing.T) {
+	s, _ := New(1<<20, 256, 32)
+	s.Add([]byte("flow"), 5000)
+	est := s.GetEstimate([]byte("flow"))
+
+	snap := s.Snapshot()
+	if &snap.sketch.bitmap[0] != &s.bitmap[0] {
+		t.Error("Expected the snapshot to share the bitmap until the next write")
+	}
+	s.Add([]byte("flow"), 5000)
+	if &snap.sketch.bitmap[0] == &s.bitmap[0] {
+		t.Error("Expected the sketch to copy its bitmap on write")
+	}
+	if snap.GetEstimate([]byte("flow")) != est || snap.N() != 5000 {
+		t.Error("Expected the snapshot to be unchanged by later additions")
+	}
+	if s.GetEstimate([]byte("flow")) <= est {
+		t.Error("Expected the sketch to keep counting")
+	}
+
+	c := snap.Sketch()
+	c.Increment([]byte("flow"))
+	if snap.N() != 5000 {
+		t.Error("Expected the sketch of a snapshot to be independent of it")
+	}
+
+	// Snapshots of lock-free sketches can be read while writers continue.
+	lf, _ := New(1<<20, 256, 32, WithLockFree())
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 1000; i++ {
+			lf.Increment([]byte("flow"))
+		}
+	}()
+	lsnap := lf.Snapshot()
+	lsnap.GetEstimate([]byte("flow"))
+	wg.Wait()
+	if lsnap.N() > 1000 {
+		t.Error("Expected at most 1000 additions in the snapshot, got", lsnap.N())
+	}
+}
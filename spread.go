@@ -0,0 +1,129 @@
+package pmc
+
+import (
+	"errors"
+	"math"
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// spreadPrecision is the precision of the HyperLogLog counting the distinct
+// pairs added to a SpreadSketch.
+const spreadPrecision = 14
+
+/*
+SpreadSketch counts the distinct destinations of each source rather than its
+additions, to detect scanners and super-spreaders: the sources contacting
+many distinct hosts or ports. Adding a pair sets a bit of the virtual matrix
+of the source picked by hashing the destination instead of at random, so
+that a pair added again sets the same bit, and the PMC estimate of a source
+is its number of distinct destinations. The number of additions of the
+sketch is that of distinct pairs, estimated with a HyperLogLog. A
+SpreadSketch is safe for concurrent use.
+*/
+type SpreadSketch struct {
+	mu     sync.Mutex
+	sketch *Sketch
+	pairs  *hll
+	// changed is whether the pairs changed since N was last refreshed.
+	changed bool
+	k       int
+	heap    hitterHeap
+}
+
+/*
+NewSpread returns a SpreadSketch with a sketch created by New with l, m, w
+and opts, tracking the k sources with the most distinct destinations, or
+none if k is 0.
+*/
+func NewSpread(l, m, w uint, k int, opts ...Option) (*SpreadSketch, error) {
+	if k < 0 {
+		return nil, errors.New("Expected k >= 0")
+	}
+	sketch, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return &SpreadSketch{sketch: sketch, pairs: newHLL(spreadPrecision), k: k,
+		heap: hitterHeap{index: make(map[string]int)}}, nil
+}
+
+/*
+Add accounts dst among the destinations of src.
+*/
+func (s *SpreadSketch) Add(src, dst []byte) {
+	sketch := s.sketch
+	src, buf := sketch.key(src)
+	defer releaseKey(buf)
+	h := sketch.hasher.Hash(dst, sketch.hashSeed, ^sketch.hashSeed)
+	// The row, column and dropping of the pair are drawn from the hash of
+	// the destination.
+	i, _ := bits.Mul64(mix64(h), uint64(sketch.m))
+	j := sketch.sampler.Sample(mix64(h + 1))
+	if j >= sketch.w {
+		j = sketch.w - 1
+	}
+	keep := float64(mix64(h+2)>>11)/(1<<53) < sketch.keepProb(j)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.pairs.add(sketch.hasher.Hash(src, h, ^h)) {
+		s.changed = true
+	}
+	if !keep {
+		return
+	}
+	sketch.mu.Lock()
+	ones := sketch.ones
+	sketch.setBit(sketch.getPos(src, uint(i), j))
+	set := sketch.ones != ones
+	sketch.mu.Unlock()
+	// Only a new bit changes the estimate of the source.
+	if set && s.k > 0 {
+		s.heap.offer(src, s.getEstimate(src), s.k)
+	}
+}
+
+// refresh sets the number of additions of the sketch to the estimated
+// number of distinct pairs.
+func (s *SpreadSketch) refresh() {
+	if s.changed {
+		atomic.StoreUint64(&s.sketch.n, uint64(math.Round(s.pairs.estimate())))
+		s.changed = false
+	}
+}
+
+func (s *SpreadSketch) getEstimate(src []byte) float64 {
+	s.refresh()
+	return s.sketch.GetEstimate(src)
+}
+
+/*
+GetEstimate returns the estimated number of distinct destinations of src.
+*/
+func (s *SpreadSketch) GetEstimate(src []byte) float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.getEstimate(src)
+}
+
+/*
+Top returns the tracked sources ordered by decreasing number of distinct
+destinations, as estimated when their last destination was added.
+*/
+func (s *SpreadSketch) Top() []HeavyHitter {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.heap.top()
+}
+
+/*
+N returns the estimated number of distinct pairs added.
+*/
+func (s *SpreadSketch) N() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.refresh()
+	return s.sketch.N()
+}
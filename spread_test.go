@@ -0,0 +1,43 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+)
+
+func TestSpreadSketch(t *testing.T) {
+	s, err := NewSpread(1<<18, 128, 32, 3, WithHashSeed(7))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rep := 0; rep < 3; rep++ {
+		for d := 0; d < 2000; d++ {
+			s.Add([]byte("scanner"), []byte(fmt.Sprintf("host-%d", d)))
+		}
+		for src := 0; src < 100; src++ {
+			for d := 0; d < 5; d++ {
+				for k := 0; k < 30; k++ {
+					s.Add([]byte(fmt.Sprintf("client-%d", src)), []byte(fmt.Sprintf("server-%d", d)))
+				}
+			}
+		}
+	}
+
+	if n := float64(s.N()); math.Abs(n-2500)/2500 > 0.05 {
+		t.Errorf("Expected about 2500 distinct pairs, got %v", n)
+	}
+	if est := s.GetEstimate([]byte("scanner")); math.Abs(est-2000)/2000 > 0.2 {
+		t.Errorf("Expected about 2000 destinations for the scanner, got %v", est)
+	}
+	if est := s.GetEstimate([]byte("client-0")); est > 30 {
+		t.Errorf("Expected about 5 destinations for a client despite its additions, got %v", est)
+	}
+	top := s.Top()
+	if len(top) != 3 || top[0].Flow != "scanner" {
+		t.Errorf("Expected the scanner to top 3 sources, got %v", top)
+	}
+	if _, err := NewSpread(1<<10, 8, 8, -1); err == nil {
+		t.Error("Expected an error for k < 0")
+	}
+}
@@ -0,0 +1,97 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unsafe"
+)
+
+// saturationFill is the fill rate beyond which a sketch is reported as
+// saturated: the noise of the other flows then dominates the estimates.
+const saturationFill = 0.5
+
+/*
+Stats describes the state of a sketch, see Sketch.Stats.
+*/
+type Stats struct {
+	L, M, W uint
+	// N is the number of additions.
+	N uint64
+	// Ones is the number of set bits, and FillRate their percentage of L.
+	Ones     uint64
+	FillRate float64
+	// MemoryBytes is the memory held by the sketch, see MemoryUsage.
+	MemoryBytes uint64
+	// DistinctFlows is the estimated number of distinct flows, or NaN
+	// without WithDistinctFlows.
+	DistinctFlows float64
+	// Saturated reports whether more than half the bits are set, past which
+	// estimates lose their accuracy and the sketch should be larger.
+	Saturated bool
+}
+
+/*
+String returns a one-line summary of the stats.
+*/
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "l=%d m=%d w=%d n=%d ones=%d fill_rate=%.2f%% memory=%dB",
+		s.L, s.M, s.W, s.N, s.Ones, s.FillRate, s.MemoryBytes)
+	if !math.IsNaN(s.DistinctFlows) {
+		fmt.Fprintf(&b, " distinct_flows=%.0f", s.DistinctFlows)
+	}
+	if s.Saturated {
+		b.WriteString(" saturated")
+	}
+	return b.String()
+}
+
+/*
+MemoryUsage returns the number of bytes held by the sketch, its bitmap and
+its HyperLogLog if any. A BitmapBackend is only accounted for if it has a
+SizeInBytes() uint64 method, as the pmcroaring backend does.
+*/
+func (sketch *Sketch) MemoryUsage() uint64 {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	return sketch.memoryUsage()
+}
+
+func (sketch *Sketch) memoryUsage() uint64 {
+	size := uint64(unsafe.Sizeof(*sketch))
+	if sized, ok := sketch.backend.(interface{ SizeInBytes() uint64 }); ok {
+		size += sized.SizeInBytes()
+	} else {
+		size += 8 * uint64(len(sketch.bitmap))
+	}
+	if sketch.hll != nil {
+		size += uint64(len(sketch.hll.registers))
+	}
+	return size
+}
+
+/*
+Stats returns the parameters and the state of the sketch.
+*/
+func (sketch *Sketch) Stats() Stats {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	ones := sketch.getOnes()
+	p := float64(ones) / float64(sketch.l)
+	s := Stats{
+		L:             sketch.l,
+		M:             sketch.m,
+		W:             sketch.w,
+		N:             sketch.N(),
+		Ones:          ones,
+		FillRate:      p * 100,
+		MemoryBytes:   sketch.memoryUsage(),
+		DistinctFlows: math.NaN(),
+		Saturated:     sketch.isSaturated(),
+	}
+	if sketch.hll != nil {
+		s.DistinctFlows = sketch.hll.estimate()
+	}
+	return s
+}
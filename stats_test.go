@@ -0,0 +1,41 @@
+package pmc
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestStats(t *testing.T) {
+	s, _ := New(1<<16, 64, 32, WithDistinctFlows(10))
+	s.Add([]byte("flow"), 1000)
+	stats := s.Stats()
+	if stats.L != 1<<16 || stats.M != 64 || stats.W != 32 || stats.N != 1000 {
+		t.Errorf("Expected the parameters and n of the sketch, got %+v", stats)
+	}
+	if stats.Ones != uint64(s.bitmap.count()) || stats.FillRate != s.GetFillRate() {
+		t.Errorf("Expected %d set bits, got %d", s.bitmap.count(), stats.Ones)
+	}
+	if stats.MemoryBytes != s.MemoryUsage() || stats.MemoryBytes < 8*1024+1024 {
+		t.Error("Expected the bitmap and the HyperLogLog to be accounted for, got", stats.MemoryBytes)
+	}
+	if math.Round(stats.DistinctFlows) != 1 || stats.Saturated {
+		t.Errorf("Expected 1 distinct flow and no saturation, got %+v", stats)
+	}
+	if str := stats.String(); !strings.Contains(str, "n=1000") || !strings.Contains(str, "distinct_flows=1") {
+		t.Error("Expected a summary of the stats, got", str)
+	}
+
+	full, _ := New(1024, 64, 32)
+	full.Add([]byte("flow"), 100000)
+	for i := 0; i < 5000; i++ {
+		full.Increment([]byte{byte(i), byte(i >> 8)})
+	}
+	stats = full.Stats()
+	if !stats.Saturated || !math.IsNaN(stats.DistinctFlows) {
+		t.Errorf("Expected a saturated sketch without distinct flows, got %+v", stats)
+	}
+	if str := stats.String(); !strings.HasSuffix(str, " saturated") || strings.Contains(str, "distinct") {
+		t.Error("Expected the summary to report saturation, got", str)
+	}
+}
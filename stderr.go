@@ -0,0 +1,68 @@
+package pmc
+
+import "math"
+
+/*
+GetEstimateWithError returns the estimated count of a given flow along with
+its standard error, which accounts for the bits set by other flows at the
+current fill rate p.
+
+For small multiplicities the estimate counts the empty first-column bits of
+the flow's rows, which receive half of the additions, the same way linear
+counting does. A row stays empty with probability (1-p)e^-t, t = est/2m, so
+the variance is 4m(e^t/(1-p) - t - 1), that of linear counting when p = 0.
+
+Larger multiplicities are estimated from the lengths of the leading runs of
+set bits of the rows. The mean and the variance of a run follow from the
+probability 1 - (1-p)(1-prob(k))^(est/m) of each column k to be set, and the
+standard error is that of the average run over the m rows, divided by the
+growth of the mean run with the estimate. It is 0.78/sqrt(m) of the
+estimate when p = 0, as for probabilistic counting, and grows without bound
+as the runs saturate, in which case it is +Inf.
+*/
+func (sketch *Sketch) GetEstimateWithError(flow []byte) (est, stderr float64) {
+	sketch.mu.Lock()
+	defer sketch.mu.Unlock()
+	n, p := float64(sketch.N()), sketch.getP()
+	key, buf := sketch.key(flow)
+	est, regime := sketch.estimate(sketch.flowPositions(key), p, func() float64 {
+		return sketch.phi(n, p)
+	})
+	releaseKey(buf)
+
+	m := float64(sketch.m)
+	if regime == RegimeSmall || regime == RegimeNone {
+		t := est / (2 * m)
+		return est, 2 * math.Sqrt(m*(math.Exp(t)/(1-p)-t-1))
+	}
+	// The growth of the mean run is measured over ±1% of the estimate.
+	const h = 0.01
+	nu := est / m
+	mean, variance := sketch.runMoments(nu, p)
+	lo, _ := sketch.runMoments(nu*(1-h), p)
+	hi, _ := sketch.runMoments(nu*(1+h), p)
+	slope := (hi - lo) / (2 * h * nu)
+	if slope <= 0 || math.IsNaN(mean) {
+		return est, math.Inf(1)
+	}
+	return est, m * math.Sqrt(variance/m) / slope
+}
+
+// runMoments returns the mean and the variance of the column of the first
+// clear bit of a row receiving n additions of a flow at fill rate p, in the
+// model of getE.
+func (sketch *Sketch) runMoments(n, p float64) (mean, variance float64) {
+	tail := sketch.sampler.Tail
+	prob := func(k uint) float64 {
+		return (tail(k) - tail(k+1)) * sketch.keepProb(k)
+	}
+	sq := 0.0
+	q := 1 - math.Exp(n*math.Log1p(-prob(0)))*(1-p)
+	for k := uint(1); k <= sketch.w; k++ {
+		next := q * (1 - math.Exp(n*math.Log1p(-prob(k)))*(1-p))
+		mean += float64(k) * (q - next)
+		sq += float64(k*k) * (q - next)
+		q = next
+	}
+	return mean, sq - mean*mean
+}
@@ -0,0 +1,66 @@
+package pmc
+
+import (
+	"math"
+	"strconv"
+	"testing"
+)
+
+func TestGetEstimateWithError(t *testing.T) {
+	s, _ := New(8000000, 256, 64)
+	for _, n := range []uint64{100, 100000} {
+		flow := []byte("flow-" + strconv.FormatUint(n, 10))
+		s.IncrementN(flow, n)
+
+		est, stderr := s.GetEstimateWithError(flow)
+		if est != s.GetEstimate(flow) {
+			t.Errorf("Expected estimate %f, got %f", s.GetEstimate(flow), est)
+		}
+		if stderr <= 0 || stderr > est/2 {
+			t.Errorf("Expected 0 < stderr <= est/2 for n=%d, got %f (est %f)", n, stderr, est)
+		}
+		if math.Abs(est-float64(n)) > 4*stderr {
+			t.Errorf("Expected %d within 4 stderr of %f, got stderr %f", n, est, stderr)
+		}
+	}
+}
+
+func TestGetEstimateWithErrorHighFill(t *testing.T) {
+	s, _ := New(1<<14, 64, 32)
+	for i := 0; i < 200000; i++ {
+		s.Increment([]byte(strconv.Itoa(i)))
+	}
+	if p := s.getP(); p < 0.99 {
+		t.Fatal("Expected a fill rate above 99%, got", p)
+	}
+	s.IncrementN([]byte("flow"), 10000)
+	est, stderr := s.GetEstimateWithError([]byte("flow"))
+	if math.Abs(est-10000) > 4*stderr {
+		t.Errorf("Expected 10000 within 4 stderr of %f at high fill, got stderr %f", est, stderr)
+	}
+
+	// The noise of other flows widens the error of the same estimate.
+	empty, _ := New(1<<20, 64, 32)
+	empty.IncrementN([]byte("flow"), 10000)
+	half, _ := New(1<<16, 64, 32)
+	for i := 0; i < 40000; i++ {
+		half.Increment([]byte(strconv.Itoa(i)))
+	}
+	half.IncrementN([]byte("flow"), 10000)
+	e0, s0 := empty.GetEstimateWithError([]byte("flow"))
+	e1, s1 := half.GetEstimateWithError([]byte("flow"))
+	if s1/e1 <= 1.2*s0/e0 {
+		t.Errorf("Expected a larger relative error at fill %f, got %f and %f", half.getP(), s1/e1, s0/e0)
+	}
+}
+
+func TestRunMoments(t *testing.T) {
+	s, _ := New(1<<16, 64, 32)
+	for _, n := range []float64{1, 100, 1e6} {
+		for _, p := range []float64{0, 0.3, 0.9} {
+			if mean, variance := s.runMoments(n, p); math.Abs(mean-s.getE(n, p)) > 1e-9 || variance < 0 {
+				t.Errorf("Expected the mean run %v of getE and a variance >= 0, got %v, %v", s.getE(n, p), mean, variance)
+			}
+		}
+	}
+}
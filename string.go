@@ -0,0 +1,25 @@
+package pmc
+
+import "unsafe"
+
+// bytesOf returns the bytes of s without copying them. The Hasher must not
+// modify or retain them, which none of the Hashers of this package do.
+func bytesOf(s string) []byte {
+	return unsafe.Slice(unsafe.StringData(s), len(s))
+}
+
+/*
+IncrementString increments the count of the flow s by 1 like
+Increment([]byte(s)), without converting s to a byte slice.
+*/
+func (sketch *Sketch) IncrementString(s string) {
+	sketch.Increment(bytesOf(s))
+}
+
+/*
+GetEstimateString returns the estimated count of the flow s like
+GetEstimate([]byte(s)), without converting s to a byte slice.
+*/
+func (sketch *Sketch) GetEstimateString(s string) float64 {
+	return sketch.GetEstimate(bytesOf(s))
+}
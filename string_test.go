@@ -0,0 +1,30 @@
+package pmc
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestIncrementString(t *testing.T) {
+	a, _ := New(1<<20, 256, 32, WithSeed(DefaultSeed))
+	b, _ := New(1<<20, 256, 32, WithSeed(DefaultSeed))
+	for i := 0; i < 5000; i++ {
+		flow := strconv.Itoa(i % 50)
+		a.IncrementString(flow)
+		b.Increment([]byte(flow))
+	}
+	if !a.bitmap.equal(b.bitmap) {
+		t.Error("Expected IncrementString to set the same bits as Increment")
+	}
+	if a.GetEstimateString("7") != b.GetEstimate([]byte("7")) {
+		t.Error("Expected GetEstimateString to estimate like GetEstimate")
+	}
+	if a.GetEstimateString("") != 0 {
+		t.Error("Expected estimate 0 for the empty flow")
+	}
+
+	flow := "https://example.com/"
+	if allocs := testing.AllocsPerRun(100, func() { a.IncrementString(flow) }); allocs != 0 {
+		t.Error("Expected IncrementString not to allocate, got", allocs)
+	}
+}
@@ -0,0 +1,143 @@
+package pmc
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+Sample is the estimate of a tracked flow at a point in time.
+*/
+type Sample struct {
+	Time     time.Time
+	Estimate float64
+}
+
+// history is a fixed-size ring of samples.
+type history struct {
+	samples []Sample
+	next    int
+	full    bool
+}
+
+func (h *history) add(s Sample) {
+	h.samples[h.next] = s
+	h.next = (h.next + 1) % len(h.samples)
+	if h.next == 0 {
+		h.full = true
+	}
+}
+
+func (h *history) ordered() []Sample {
+	if !h.full {
+		return append([]Sample(nil), h.samples[:h.next]...)
+	}
+	return append(append([]Sample(nil), h.samples[h.next:]...), h.samples[:h.next]...)
+}
+
+/*
+Tracker records the estimates of a watchlist of flows over time, keeping the
+last size samples of each flow. A Tracker is safe for concurrent use; if
+interval is positive the sketch is queried from another goroutine, so it
+should be created WithThreadSafety when it is incremented concurrently.
+*/
+type Tracker struct {
+	mu     sync.Mutex
+	sketch *Sketch
+	size   int
+	flows  map[string]*history
+	stop   chan struct{}
+}
+
+/*
+NewTracker returns a Tracker keeping size samples per flow of the estimates
+of sketch. If interval is positive a sample is recorded every interval until
+Close is called, otherwise Record has to be called by the user.
+*/
+func NewTracker(sketch *Sketch, size int, interval time.Duration) (*Tracker, error) {
+	if size <= 0 {
+		return nil, errors.New("Expected size > 0, got 0")
+	}
+	t := &Tracker{sketch: sketch, size: size, flows: make(map[string]*history)}
+	if interval > 0 {
+		t.stop = make(chan struct{})
+		go t.recordEvery(interval, t.stop)
+	}
+	return t, nil
+}
+
+func (t *Tracker) recordEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			t.record(now)
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Track adds the flow to the watchlist. Tracking a flow twice keeps its
+history.
+*/
+func (t *Tracker) Track(flow []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if _, ok := t.flows[string(flow)]; !ok {
+		t.flows[string(flow)] = &history{samples: make([]Sample, t.size)}
+	}
+}
+
+/*
+Untrack removes the flow and its history from the watchlist.
+*/
+func (t *Tracker) Untrack(flow []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.flows, string(flow))
+}
+
+/*
+Record samples the estimates of all tracked flows now.
+*/
+func (t *Tracker) Record() {
+	t.record(time.Now())
+}
+
+func (t *Tracker) record(now time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for flow, h := range t.flows {
+		h.add(Sample{Time: now, Estimate: t.sketch.GetEstimate([]byte(flow))})
+	}
+}
+
+/*
+History returns the recorded samples of the flow from oldest to newest, or
+nil if the flow is not tracked.
+*/
+func (t *Tracker) History(flow []byte) []Sample {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	h, ok := t.flows[string(flow)]
+	if !ok {
+		return nil
+	}
+	return h.ordered()
+}
+
+/*
+Close stops the automatic recording of samples.
+*/
+func (t *Tracker) Close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
@@ -0,0 +1,56 @@
+package pmc
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTracker(t *testing.T) {
+	s, _ := New(1<<22, 256, 32)
+	tr, err := NewTracker(s, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewTracker(s, 0, 0); err == nil {
+		t.Error("Expected error for size == 0, got nil")
+	}
+	flow := []byte("suspect")
+	tr.Track(flow)
+	if h := tr.History(flow); len(h) != 0 {
+		t.Error("Expected empty history, got", h)
+	}
+
+	for i := 0; i < 5; i++ {
+		s.Add(flow, 10000)
+		tr.Record()
+	}
+	h := tr.History(flow)
+	if len(h) != 3 {
+		t.Fatal("Expected 3 samples, got", len(h))
+	}
+	for i := 1; i < len(h); i++ {
+		if h[i].Estimate <= h[i-1].Estimate || h[i].Time.Before(h[i-1].Time) {
+			t.Error("Expected samples from oldest to newest, got", h)
+		}
+	}
+	if h[0].Estimate < 20000 {
+		t.Error("Expected the oldest samples to be dropped, got", h[0].Estimate)
+	}
+
+	tr.Untrack(flow)
+	if h := tr.History(flow); h != nil {
+		t.Error("Expected nil history for an untracked flow, got", h)
+	}
+}
+
+func TestTrackerTicker(t *testing.T) {
+	s, _ := New(1024, 8, 8, WithThreadSafety())
+	tr, _ := NewTracker(s, 4, time.Millisecond)
+	defer tr.Close()
+	tr.Track([]byte("flow"))
+	s.Increment([]byte("flow"))
+	time.Sleep(20 * time.Millisecond)
+	if h := tr.History([]byte("flow")); len(h) == 0 {
+		t.Error("Expected samples to be recorded automatically")
+	}
+}
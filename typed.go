@@ -0,0 +1,55 @@
+package pmc
+
+/*
+TypedSketch counts flows of type K, keyed by a function of the application's
+choice, so that struct keys need not be serialized at every call site.
+*/
+type TypedSketch[K any] struct {
+	sketch *Sketch
+	key    func(K) []byte
+	hash   func(K) uint64
+}
+
+/*
+NewTyped returns a TypedSketch counting the flows of sketch under key(k).
+*/
+func NewTyped[K any](sketch *Sketch, key func(K) []byte) *TypedSketch[K] {
+	return &TypedSketch[K]{sketch: sketch, key: key}
+}
+
+/*
+NewTypedHash returns a TypedSketch counting the flows of sketch under the
+64-bit hash(k), see Increment64. This avoids building a key for types that
+hash cheaply, like fixed-size structs.
+*/
+func NewTypedHash[K any](sketch *Sketch, hash func(K) uint64) *TypedSketch[K] {
+	return &TypedSketch[K]{sketch: sketch, hash: hash}
+}
+
+/*
+Increment the count of the flow k by 1
+*/
+func (ts *TypedSketch[K]) Increment(k K) {
+	if ts.hash != nil {
+		ts.sketch.Increment64(ts.hash(k))
+		return
+	}
+	ts.sketch.Increment(ts.key(k))
+}
+
+/*
+GetEstimate returns the estimated count of the flow k
+*/
+func (ts *TypedSketch[K]) GetEstimate(k K) float64 {
+	if ts.hash != nil {
+		return ts.sketch.Estimate64(ts.hash(k))
+	}
+	return ts.sketch.GetEstimate(ts.key(k))
+}
+
+/*
+Sketch returns the underlying sketch.
+*/
+func (ts *TypedSketch[K]) Sketch() *Sketch {
+	return ts.sketch
+}
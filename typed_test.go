@@ -0,0 +1,40 @@
+package pmc
+
+import (
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+type flowKey struct {
+	SrcIP   [4]byte
+	DstPort uint16
+}
+
+func TestTypedSketch(t *testing.T) {
+	key := func(k flowKey) []byte {
+		return binary.BigEndian.AppendUint16(k.SrcIP[:], k.DstPort)
+	}
+	hash := func(k flowKey) uint64 {
+		return uint64(binary.BigEndian.Uint32(k.SrcIP[:]))<<16 | uint64(k.DstPort)
+	}
+	s, _ := New(1<<22, 256, 32)
+	for _, ts := range []*TypedSketch[flowKey]{NewTyped(s, key), NewTypedHash(s, hash)} {
+		s.Reset()
+		elephant := flowKey{[4]byte{10, 0, 0, 1}, 443}
+		for i := 0; i < 10000; i++ {
+			ts.Increment(elephant)
+		}
+		ts.Increment(flowKey{[4]byte{10, 0, 0, 2}, 443})
+		est := ts.GetEstimate(elephant)
+		if fErr := math.Abs(100 * (1 - est/10000)); fErr > 15 {
+			t.Errorf("Expected estimate within 15%% of 10000, got %f", est)
+		}
+		if est := ts.GetEstimate(flowKey{[4]byte{10, 0, 0, 1}, 80}); est > 100 {
+			t.Error("Expected a small estimate for another port, got", est)
+		}
+		if ts.Sketch() != s {
+			t.Error("Expected the underlying sketch")
+		}
+	}
+}
@@ -0,0 +1,186 @@
+package pmc
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+ValidationReport is the observed relative error of the estimates of the
+sampled flows of a ValidationHarness, against their exact counts. Expected is
+the relative standard error 0.78/sqrt(m) the sketch is configured for, which
+about 68% of the errors should stay below. Flows sampled with a count of 0,
+having only been added weights of 0, have no relative error and are left
+out.
+*/
+type ValidationReport struct {
+	Time     time.Time
+	Keys     int
+	Mean     float64
+	P50      float64
+	P90      float64
+	P99      float64
+	Max      float64
+	Expected float64
+}
+
+/*
+String returns the report as a single line.
+*/
+func (r ValidationReport) String() string {
+	return fmt.Sprintf("keys=%d mean=%.2f%% p50=%.2f%% p90=%.2f%% p99=%.2f%% max=%.2f%% expected=%.2f%%",
+		r.Keys, 100*r.Mean, 100*r.P50, 100*r.P90, 100*r.P99, 100*r.Max, 100*r.Expected)
+}
+
+/*
+ValidationHarness keeps exact counts for a random sample of the flows added
+to a sketch, so that the accuracy observed on the actual traffic can be
+checked against the configured one. Flows are sampled by a hash salted with
+the hash seed of the sketch, so a sampled flow is counted from its first
+addition and an adversary can't tell which flows are sampled; at most
+maxKeys flows are sampled. Additions made to the sketch directly would be
+missing from the exact counts and show up as errors, so all of them must go
+through the harness. A ValidationHarness is safe for concurrent use; if
+interval is positive the sketch is queried from another goroutine, so it
+should be created WithThreadSafety.
+*/
+type ValidationHarness struct {
+	mu        sync.Mutex
+	sketch    *Sketch
+	salt      uint64
+	threshold uint64
+	maxKeys   int
+	counts    map[string]uint64
+	fn        func(ValidationReport)
+	stop      chan struct{}
+}
+
+/*
+NewValidationHarness returns a ValidationHarness sampling a fraction rate in
+(0, 1] of the flows of sketch, up to maxKeys. If interval is positive, fn is
+called with a Report every interval until Close is called.
+*/
+func NewValidationHarness(sketch *Sketch, rate float64, maxKeys int, interval time.Duration, fn func(ValidationReport)) (*ValidationHarness, error) {
+	if rate <= 0 || rate > 1 {
+		return nil, fmt.Errorf("Expected 0 < rate <= 1, got %v", rate)
+	}
+	if maxKeys <= 0 {
+		return nil, fmt.Errorf("Expected maxKeys > 0, got %d", maxKeys)
+	}
+	if interval > 0 && fn == nil {
+		return nil, errors.New("Expected a non nil function")
+	}
+	v := &ValidationHarness{
+		sketch:    sketch,
+		salt:      mix64(sketch.hashSeed + 1),
+		threshold: math.MaxUint64,
+		maxKeys:   maxKeys,
+		counts:    make(map[string]uint64),
+		fn:        fn,
+	}
+	if rate < 1 {
+		v.threshold = uint64(math.Ldexp(rate, 64))
+	}
+	if interval > 0 {
+		v.stop = make(chan struct{})
+		go v.reportEvery(interval, v.stop)
+	}
+	return v, nil
+}
+
+func (v *ValidationHarness) reportEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			r := v.Report()
+			r.Time = now
+			v.fn(r)
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Increment the count of the flow by 1
+*/
+func (v *ValidationHarness) Increment(flow []byte) {
+	v.Add(flow, 1)
+}
+
+/*
+Add accounts weight units to the flow, see Sketch.Add.
+*/
+func (v *ValidationHarness) Add(flow []byte, weight uint64) {
+	v.sketch.Add(flow, weight)
+	// Both directions of a conversation are counted together.
+	flow, buf := v.sketch.key(flow)
+	defer releaseKey(buf)
+	if v.sketch.hasher.Hash(flow, v.salt, ^v.salt) > v.threshold {
+		return
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if c, ok := v.counts[string(flow)]; ok {
+		v.counts[string(flow)] = c + weight
+	} else if len(v.counts) < v.maxKeys {
+		v.counts[string(flow)] = weight
+	}
+}
+
+/*
+Report returns the observed errors of the sampled flows now.
+*/
+func (v *ValidationHarness) Report() ValidationReport {
+	v.mu.Lock()
+	errs := make([]float64, 0, len(v.counts))
+	for flow, count := range v.counts {
+		if count == 0 {
+			continue
+		}
+		est := v.sketch.GetEstimate([]byte(flow))
+		errs = append(errs, math.Abs(est-float64(count))/float64(count))
+	}
+	v.mu.Unlock()
+
+	r := ValidationReport{Time: time.Now(), Keys: len(errs), Expected: 0.78 / math.Sqrt(float64(v.sketch.m))}
+	if len(errs) == 0 {
+		return r
+	}
+	sort.Float64s(errs)
+	sum := 0.0
+	for _, e := range errs {
+		sum += e
+	}
+	quantile := func(q float64) float64 {
+		return errs[int(q*float64(len(errs)-1))]
+	}
+	r.Mean = sum / float64(len(errs))
+	r.P50, r.P90, r.P99, r.Max = quantile(0.5), quantile(0.9), quantile(0.99), errs[len(errs)-1]
+	return r
+}
+
+/*
+Sketch returns the underlying sketch.
+*/
+func (v *ValidationHarness) Sketch() *Sketch {
+	return v.sketch
+}
+
+/*
+Close stops the periodic reports.
+*/
+func (v *ValidationHarness) Close() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.stop != nil {
+		close(v.stop)
+		v.stop = nil
+	}
+}
@@ -0,0 +1,74 @@
+package pmc
+
+import (
+	"fmt"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestValidationHarness(t *testing.T) {
+	sketch, err := New(1<<20, 256, 32, WithDeterministic(DefaultSeed))
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := NewValidationHarness(sketch, 0.1, 50, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 1000; i++ {
+		v.Add([]byte(fmt.Sprint(i)), uint64(1000+i))
+	}
+	r := v.Report()
+	if r.Keys == 0 || r.Keys > 50 {
+		t.Error("Expected between 1 and 50 sampled keys, got", r.Keys)
+	}
+	if r.P50 > 2*r.Expected || r.Max < r.P99 || r.P99 < r.P50 {
+		t.Error("Expected consistent error quantiles, got", r)
+	}
+	if sketch.N() != 1000*1000+999*1000/2 {
+		t.Error("Expected all additions in the sketch, got", sketch.N())
+	}
+}
+
+func TestValidationHarnessZero(t *testing.T) {
+	sketch, _ := New(1<<16, 64, 32)
+	v, _ := NewValidationHarness(sketch, 1, 10, 0, nil)
+	v.Add([]byte("zero"), 0)
+	v.Add([]byte("flow"), 100)
+	if r := v.Report(); r.Keys != 1 || math.IsNaN(r.Mean) || math.IsInf(r.Max, 0) {
+		t.Error("Expected 1 sampled key with finite errors, got", r)
+	}
+}
+
+func TestValidationHarnessInterval(t *testing.T) {
+	sketch, _ := New(1<<16, 64, 32, WithThreadSafety())
+	reports := make(chan ValidationReport, 1)
+	v, err := NewValidationHarness(sketch, 1, 10, time.Millisecond, func(r ValidationReport) {
+		select {
+		case reports <- r:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer v.Close()
+	v.Add([]byte("flow"), 100)
+	if r := <-reports; r.Keys != 1 {
+		t.Error("Expected 1 sampled key, got", r.Keys)
+	}
+}
+
+func TestValidationHarnessErrors(t *testing.T) {
+	sketch, _ := New(1<<16, 64, 32)
+	if _, err := NewValidationHarness(sketch, 0, 10, 0, nil); err == nil {
+		t.Error("Expected an error for a rate of 0")
+	}
+	if _, err := NewValidationHarness(sketch, 0.5, 0, 0, nil); err == nil {
+		t.Error("Expected an error for maxKeys of 0")
+	}
+	if _, err := NewValidationHarness(sketch, 0.5, 10, time.Second, nil); err == nil {
+		t.Error("Expected an error for a nil function")
+	}
+}
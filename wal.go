@@ -0,0 +1,117 @@
+package pmc
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"io"
+	"sync"
+)
+
+// maxWALFlow bounds the length of a logged flow, so that a corrupted log
+// can't make Replay allocate without limit.
+const maxWALFlow = 1 << 20
+
+/*
+WAL is a write-ahead log of the additions to a sketch: every addition is
+appended to the log as its flow and weight before being applied. Replaying
+the log with Replay into an empty sketch created with the same parameters,
+seeds and Hasher rebuilds the sketch exactly, and replaying it into a sketch
+with other parameters re-counts the same traffic. Lock-free sketches draw
+from a shared random number generator and can't be rebuilt exactly. A WAL is
+safe for concurrent use.
+*/
+type WAL struct {
+	mu     sync.Mutex
+	sketch *Sketch
+	w      *bufio.Writer
+	buf    []byte
+}
+
+/*
+NewWAL returns a WAL adding to sketch and appending to w. The log is
+buffered, see Flush.
+*/
+func NewWAL(sketch *Sketch, w io.Writer) *WAL {
+	return &WAL{sketch: sketch, w: bufio.NewWriter(w)}
+}
+
+/*
+Increment logs and adds a single occurrence of flow.
+*/
+func (wal *WAL) Increment(flow []byte) error {
+	return wal.Add(flow, 1)
+}
+
+/*
+Add logs and adds weight occurrences of flow, see Sketch.Add. The addition
+isn't applied if it can't be logged.
+*/
+func (wal *WAL) Add(flow []byte, weight uint64) error {
+	if len(flow) > maxWALFlow {
+		return errors.New("Flow too long for the write-ahead log")
+	}
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	wal.buf = binary.AppendUvarint(wal.buf[:0], uint64(len(flow)))
+	wal.buf = append(wal.buf, flow...)
+	wal.buf = binary.AppendUvarint(wal.buf, weight)
+	if _, err := wal.w.Write(wal.buf); err != nil {
+		return err
+	}
+	wal.sketch.Add(flow, weight)
+	return nil
+}
+
+/*
+Flush writes the buffered records to the underlying writer. Additions since
+the last Flush are lost if the process crashes.
+*/
+func (wal *WAL) Flush() error {
+	wal.mu.Lock()
+	defer wal.mu.Unlock()
+	return wal.w.Flush()
+}
+
+/*
+Sketch returns the sketch the WAL adds to.
+*/
+func (wal *WAL) Sketch() *Sketch {
+	return wal.sketch
+}
+
+/*
+Replay adds the records of a log written by a WAL to the sketch, and returns
+the number of records replayed. A record cut short by a crash ends the replay
+with io.ErrUnexpectedEOF, after all complete records were added.
+*/
+func (sketch *Sketch) Replay(r io.Reader) (uint64, error) {
+	br := bufio.NewReader(r)
+	var flow []byte
+	var count uint64
+	for {
+		size, err := binary.ReadUvarint(br)
+		if err == io.EOF {
+			return count, nil
+		}
+		if err != nil {
+			return count, err
+		}
+		if size > maxWALFlow {
+			return count, errors.New("Invalid flow length in write-ahead log")
+		}
+		if uint64(cap(flow)) < size {
+			flow = make([]byte, size)
+		}
+		flow = flow[:size]
+		if _, err := io.ReadFull(br, flow); err != nil {
+			return count, io.ErrUnexpectedEOF
+		}
+		weight, err := binary.ReadUvarint(br)
+		if err != nil {
+			return count, io.ErrUnexpectedEOF
+		}
+		sketch.Add(flow, weight)
+		count++
+	}
+}
@@ -0,0 +1,48 @@
+package pmc
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"testing"
+)
+
+func TestWALReplay(t *testing.T) {
+	s, _ := New(1<<20, 256, 32, WithHashSeed(7))
+	var log bytes.Buffer
+	wal := NewWAL(s, &log)
+	for i := 0; i < 2000; i++ {
+		if err := wal.Increment([]byte(fmt.Sprint(i % 50))); err != nil {
+			t.Fatal(err)
+		}
+	}
+	wal.Add([]byte("heavy"), 50000)
+	if err := wal.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	rebuilt, _ := New(1<<20, 256, 32, WithHashSeed(7))
+	count, err := rebuilt.Replay(bytes.NewReader(log.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 2001 {
+		t.Errorf("Expected 2001 records, got %d", count)
+	}
+	if rebuilt.N() != s.N() || !rebuilt.bitmap.equal(s.bitmap) {
+		t.Error("Expected the replayed sketch to be identical")
+	}
+
+	resized, _ := New(1<<22, 128, 32)
+	resized.Replay(bytes.NewReader(log.Bytes()))
+	if est := resized.GetEstimate([]byte("heavy")); math.Abs(est-50000)/50000 > 0.2 {
+		t.Errorf("Expected an estimate close to 50000 in the resized sketch, got %f", est)
+	}
+
+	cut, _ := New(1<<20, 256, 32, WithHashSeed(7))
+	count, err = cut.Replay(bytes.NewReader(log.Bytes()[:log.Len()-2]))
+	if err != io.ErrUnexpectedEOF || count != 2000 {
+		t.Errorf("Expected 2000 records and io.ErrUnexpectedEOF, got %d and %v", count, err)
+	}
+}
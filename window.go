@@ -0,0 +1,150 @@
+package pmc
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+/*
+WindowedSketch counts flows over a sliding time window. It keeps a ring of
+sub-sketches, each covering one interval; additions go to the current one,
+which is replaced by the oldest, cleared, sub-sketch on every rotation.
+Estimates merge all sub-sketches and so cover the trailing
+len(buckets)*interval. Forgetting whole intervals keeps the bitmaps valid
+for the estimator, which thinning the bits of a single sketch doesn't, so
+this is how long-running sketches favor recent traffic without saturating;
+GetDecayedEstimate weights the buckets by their age.
+A WindowedSketch is safe for concurrent use.
+*/
+type WindowedSketch struct {
+	mu      sync.Mutex
+	buckets []*Sketch
+	// merged is the merge of the buckets, unless stale is set by an addition
+	// or a rotation since it was last merged.
+	merged *Sketch
+	stale  bool
+	cur    int
+	stop   chan struct{}
+}
+
+/*
+NewWindowed returns a WindowedSketch of the given number of buckets, each a
+sketch created by New with l, m, w and opts. If interval is positive the
+buckets are rotated automatically every interval until Close is called,
+otherwise Rotate has to be called by the user. opts are applied to every
+bucket, so they must not include a BitmapBackend, which is rejected.
+*/
+func NewWindowed(l, m, w uint, buckets int, interval time.Duration, opts ...Option) (*WindowedSketch, error) {
+	if buckets <= 0 {
+		return nil, errors.New("Expected buckets > 0, got 0")
+	}
+	first, err := New(l, m, w, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if first.backend != nil {
+		return nil, errors.New("Windowed sketches only support the default bitmap")
+	}
+	ws := &WindowedSketch{buckets: make([]*Sketch, buckets)}
+	ws.buckets[0] = first
+	for i := 1; i < buckets; i++ {
+		if ws.buckets[i], err = newSibling(first, l, opts); err != nil {
+			return nil, err
+		}
+	}
+	ws.merged = ws.buckets[0].Clone()
+
+	if interval > 0 {
+		ws.stop = make(chan struct{})
+		go ws.rotateEvery(interval, ws.stop)
+	}
+	return ws, nil
+}
+
+func (ws *WindowedSketch) rotateEvery(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			ws.Rotate()
+		case <-stop:
+			return
+		}
+	}
+}
+
+/*
+Rotate drops the oldest bucket of the window and starts a new one.
+*/
+func (ws *WindowedSketch) Rotate() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.cur = (ws.cur + 1) % len(ws.buckets)
+	ws.buckets[ws.cur].Reset()
+	ws.stale = true
+}
+
+/*
+Close stops the automatic rotation of the buckets.
+*/
+func (ws *WindowedSketch) Close() {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	if ws.stop != nil {
+		close(ws.stop)
+		ws.stop = nil
+	}
+}
+
+/*
+Increment the count of the flow by 1 in the current bucket
+*/
+func (ws *WindowedSketch) Increment(flow []byte) {
+	ws.mu.Lock()
+	ws.buckets[ws.cur].Increment(flow)
+	ws.stale = true
+	ws.mu.Unlock()
+}
+
+/*
+Add accounts weight units to the flow in the current bucket, see Sketch.Add.
+*/
+func (ws *WindowedSketch) Add(flow []byte, weight uint64) {
+	ws.mu.Lock()
+	ws.buckets[ws.cur].Add(flow, weight)
+	ws.stale = true
+	ws.mu.Unlock()
+}
+
+/*
+GetEstimate returns the estimated count of a given flow over the window. The
+merge of the buckets is kept until the next addition or rotation, so only
+the first estimate after one costs a merge.
+*/
+func (ws *WindowedSketch) GetEstimate(flow []byte) float64 {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.window().GetEstimate(flow)
+}
+
+/*
+Window returns a new sketch holding the merge of all buckets of the window.
+*/
+func (ws *WindowedSketch) Window() *Sketch {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	return ws.window().Clone()
+}
+
+// window returns the merge of the buckets, merging them again into the
+// reused merged sketch if stale.
+func (ws *WindowedSketch) window() *Sketch {
+	if ws.stale {
+		ws.merged.Reset()
+		ws.merged.MergeAll(ws.buckets...)
+		ws.stale = false
+	}
+	return ws.merged
+}
@@ -0,0 +1,102 @@
+package pmc
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestWindowedSketch(t *testing.T) {
+	ws, err := NewWindowed(1<<22, 256, 32, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	flow := []byte("flow")
+
+	for b := 0; b < 3; b++ {
+		ws.Add(flow, 10000)
+		ws.Rotate()
+	}
+	// The oldest bucket has been dropped by the last rotation.
+	est := ws.GetEstimate(flow)
+	if fErr := math.Abs(100 * (1 - est/20000)); fErr > 15 {
+		t.Errorf("Expected window estimate within 15%% of 20000, got %f", est)
+	}
+	if n := ws.Window().n; n != 20000 {
+		t.Error("Expected window n == 20000, got", n)
+	}
+
+	ws.Rotate()
+	ws.Rotate()
+	if est := ws.GetEstimate(flow); est != 0 {
+		t.Error("Expected empty window estimate 0, got", est)
+	}
+}
+
+func TestWindowedSketchTicker(t *testing.T) {
+	ws, _ := NewWindowed(1024, 8, 8, 2, time.Millisecond)
+	defer ws.Close()
+	ws.Increment([]byte("flow"))
+
+	deadline := time.Now().Add(time.Second)
+	for ws.Window().n != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected buckets to be rotated automatically")
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
+
+func TestWindowedSketchCache(t *testing.T) {
+	ws, _ := NewWindowed(1<<16, 64, 32, 2, 0)
+	flow := []byte("flow")
+	ws.Add(flow, 1000)
+	est := ws.GetEstimate(flow)
+	if ws.stale {
+		t.Fatal("Expected the merge of the buckets to be kept")
+	}
+	if e := ws.GetEstimate(flow); e != est {
+		t.Errorf("Expected the same estimate %f, got %f", est, e)
+	}
+	ws.Add(flow, 1000)
+	if e := ws.GetEstimate(flow); e <= est {
+		t.Errorf("Expected an addition to be estimated, got %f after %f", e, est)
+	}
+	ws.Rotate()
+	ws.Rotate()
+	if e := ws.GetEstimate(flow); e != 0 {
+		t.Error("Expected rotations to be estimated, got", e)
+	}
+}
+
+func TestNewWindowedInvalid(t *testing.T) {
+	if _, err := NewWindowed(1024, 8, 8, 0, 0); err == nil {
+		t.Error("Expected error for 0 buckets, got nil")
+	}
+	if _, err := NewWindowed(1024, 8, 8, 2, 0, WithBitmapBackend(mapBackend{})); err == nil {
+		t.Error("Expected error for a BitmapBackend, got nil")
+	}
+}
+
+func TestNewWindowedOptions(t *testing.T) {
+	opts := make([]Option, 1, 4)
+	opts[0] = WithCryptoRand()
+	ws, err := NewWindowed(1024, 8, 8, 3, 0, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if spare := opts[:2]; spare[1] != nil {
+		t.Error("Expected the options of the caller to be left untouched")
+	}
+	for i, b := range ws.buckets {
+		if b.hashSeed != ws.buckets[0].hashSeed {
+			t.Errorf("Expected bucket %d to share the hash seed", i)
+		}
+		if _, ok := b.rnd.(*chachaRand); !ok {
+			t.Errorf("Expected bucket %d to keep the generator of the options, got %T", i, b.rnd)
+		}
+	}
+	if ws.buckets[1].next() == ws.buckets[2].next() {
+		t.Error("Expected buckets to draw different values")
+	}
+}